@@ -35,4 +35,7 @@ func defaultConfig() {
 	viper.SetDefault("global.web_rate_limit", 100)
 	viper.SetDefault("connect_timeout", 5)
 	viper.SetDefault("auto_price_updates", true)
+	viper.SetDefault("storage.local.path", "./files")
+	viper.SetDefault("storage.file_drive", "local")
+	viper.SetDefault("batch.concurrency", 5)
 }
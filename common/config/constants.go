@@ -46,6 +46,21 @@ var OIDCAuthEnabled = false
 // chat cache
 var ChatCacheEnabled = false
 var ChatCacheExpireMinute = 5 // 5 Minute
+// 语义缓存：使用指定渠道生成最后一条用户消息的向量，相似度达到阈值即命中
+var ChatCacheSemanticEnabled = false
+var ChatCacheSemanticChannelId = 0
+var ChatCacheSemanticModel = "text-embedding-3-small"
+var ChatCacheSemanticThreshold = 0.95
+
+// responses api
+var ResponsesExpireDays = 30 // 30 Day
+
+// assistants api，不指定模型的请求（创建线程、列出助手等）用于选择渠道的模型
+var AssistantsDefaultModel = ""
+
+// batch api
+var BatchEnabled = false
+var BatchDiscount = 0.5
 
 // mj
 var MjNotifyEnabled = false
@@ -108,6 +123,33 @@ var RetryTimes = 0
 var DefaultChannelWeight = uint(1)
 var RetryCooldownSeconds = 5
 
+// 熔断器：窗口内请求数达到 CircuitBreakerMinRequests 且错误率超过阈值时熔断，
+// 熔断时间从 CircuitBreakerOpenSeconds 开始按次数翻倍，最长 CircuitBreakerMaxOpenSeconds
+var CircuitBreakerEnabled = false
+var CircuitBreakerWindowSeconds = 60
+var CircuitBreakerMinRequests = 10
+var CircuitBreakerErrorRate = 0.5
+var CircuitBreakerOpenSeconds = 30
+var CircuitBreakerMaxOpenSeconds = 600
+var CircuitBreakerProbeRequests = 3
+
+// 同一优先级内的负载均衡策略，分组可以单独设置
+var BalanceStrategy = "weight"
+
+// 所有渠道都达到并发或速率限制时，请求最多排队的数量和等待的秒数，为 0 时直接返回错误
+var ChannelQueueSize = 100
+var ChannelQueueTimeout = 10
+
+// 对冲请求：第一个渠道超过 HedgeDelay 毫秒还没有返回首字时，同时请求第二个渠道，先返回的生效
+var HedgeEnabled = false
+var HedgeDelay = 2000
+
+// 流式响应中途中断时，带上已输出的内容换一个渠道续写，最多续写 StreamFailoverTimes 次
+// StreamFailoverPrompt 为空时只把已输出的内容作为 assistant 前缀，否则再追加一条 user 消息要求继续
+var StreamFailoverEnabled = false
+var StreamFailoverTimes = 1
+var StreamFailoverPrompt = ""
+
 var CFWorkerImageUrl = ""
 var CFWorkerImageKey = ""
 
@@ -276,6 +318,8 @@ const (
 	RelayModeSuno
 	RelayModeRerank
 	RelayModeChatRealtime
+	RelayModeResponses
+	RelayModeBatch
 )
 
 type ContextKey string
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common/logger"
+	"strings"
+	"sync"
+)
+
+// 模型的降级链，模型的所有渠道都失败或不可用时，按顺序改用后面的模型，例如 {"gpt-4o": ["claude-3-5-sonnet", "deepseek-chat"]}
+var modelFallbacks = map[string][]string{}
+var modelFallbacksLock sync.RWMutex
+
+func ParseModelFallbacks(jsonStr string) (map[string][]string, error) {
+	fallbacks := map[string][]string{}
+	if strings.TrimSpace(jsonStr) == "" {
+		return fallbacks, nil
+	}
+
+	if err := json.Unmarshal([]byte(jsonStr), &fallbacks); err != nil {
+		return nil, err
+	}
+
+	for modelName, chain := range fallbacks {
+		seen := map[string]bool{modelName: true}
+		for _, fallback := range chain {
+			if fallback == "" {
+				return nil, fmt.Errorf("%s: fallback model is empty", modelName)
+			}
+			if seen[fallback] {
+				return nil, fmt.Errorf("%s: duplicate fallback model %s", modelName, fallback)
+			}
+			seen[fallback] = true
+		}
+	}
+
+	return fallbacks, nil
+}
+
+func UpdateModelFallbacksByJSONString(jsonStr string) error {
+	fallbacks, err := ParseModelFallbacks(jsonStr)
+	if err != nil {
+		return err
+	}
+
+	modelFallbacksLock.Lock()
+	modelFallbacks = fallbacks
+	modelFallbacksLock.Unlock()
+
+	return nil
+}
+
+func ModelFallbacks2JSONString() string {
+	modelFallbacksLock.RLock()
+	defer modelFallbacksLock.RUnlock()
+
+	jsonBytes, err := json.Marshal(modelFallbacks)
+	if err != nil {
+		logger.SysError("error marshalling model fallbacks: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// GetModelFallbacks 令牌设置了该模型的降级链时优先使用令牌的设置
+func GetModelFallbacks(modelName string, tokenFallbacks map[string][]string) []string {
+	if chain, ok := tokenFallbacks[modelName]; ok {
+		return chain
+	}
+
+	modelFallbacksLock.RLock()
+	defer modelFallbacksLock.RUnlock()
+	return modelFallbacks[modelName]
+}
@@ -0,0 +1,281 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	ParamOverrideSet     = "set"     // 设置字段，已存在时覆盖
+	ParamOverrideDefault = "default" // 字段不存在时设置
+	ParamOverrideRemove  = "remove"  // 删除字段
+	ParamOverrideClamp   = "clamp"   // 把数值限制在 min 和 max 之间
+	ParamOverrideRename  = "rename"  // 把字段移动到 to
+)
+
+// ParamOverrideRule 渠道请求参数的改写规则，按顺序执行所有匹配模型的规则
+type ParamOverrideRule struct {
+	Models []string `json:"models,omitempty"` // 为空表示所有模型，支持 * 结尾的前缀匹配
+	Op     string   `json:"op"`
+	// 字段路径，例如 max_tokens、$.stream_options.include_usage、messages[0].content
+	Path  string   `json:"path"`
+	Value any      `json:"value,omitempty"` // 字符串中的 {user_id}、{token_id} 等变量会被替换
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+	To    string   `json:"to,omitempty"`
+
+	path []string
+	to   []string
+}
+
+func ParseParamOverrideRules(jsonStr string) ([]*ParamOverrideRule, error) {
+	rules := []*ParamOverrideRule{}
+	if strings.TrimSpace(jsonStr) == "" {
+		return rules, nil
+	}
+
+	if err := json.Unmarshal([]byte(jsonStr), &rules); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("rule %d: %s", i+1, err.Error())
+		}
+	}
+
+	return rules, nil
+}
+
+func (rule *ParamOverrideRule) compile() (err error) {
+	if rule.path, err = parseParamPath(rule.Path); err != nil {
+		return err
+	}
+
+	switch rule.Op {
+	case ParamOverrideSet, ParamOverrideDefault:
+		if rule.Value == nil {
+			return fmt.Errorf("value is required")
+		}
+	case ParamOverrideRemove:
+	case ParamOverrideClamp:
+		if rule.Min == nil && rule.Max == nil {
+			return fmt.Errorf("min or max is required")
+		}
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			return fmt.Errorf("min is greater than max")
+		}
+	case ParamOverrideRename:
+		if rule.to, err = parseParamPath(rule.To); err != nil {
+			return fmt.Errorf("to: %s", err.Error())
+		}
+	default:
+		return fmt.Errorf("invalid op %s", rule.Op)
+	}
+
+	return nil
+}
+
+// parseParamPath 把 $.a.b[0].c 解析为 [a b 0 c]
+func parseParamPath(path string) ([]string, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(path), "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+
+	keys := strings.Split(path, ".")
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("invalid path %s", path)
+		}
+	}
+	return keys, nil
+}
+
+func (rule *ParamOverrideRule) matchModel(modelName string) bool {
+	if len(rule.Models) == 0 {
+		return true
+	}
+	for _, pattern := range rule.Models {
+		if pattern == modelName || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(modelName, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply 执行规则，返回是否修改了请求
+func (rule *ParamOverrideRule) apply(body map[string]any, vars map[string]string) bool {
+	switch rule.Op {
+	case ParamOverrideSet:
+		return setParam(body, rule.path, renderParamValue(rule.Value, vars))
+	case ParamOverrideDefault:
+		if _, ok := getParam(body, rule.path); ok {
+			return false
+		}
+		return setParam(body, rule.path, renderParamValue(rule.Value, vars))
+	case ParamOverrideRemove:
+		return removeParam(body, rule.path)
+	case ParamOverrideClamp:
+		value, ok := getParam(body, rule.path)
+		if !ok {
+			return false
+		}
+		number, ok := paramNumber(value)
+		if !ok {
+			return false
+		}
+		clamped := number
+		if rule.Min != nil && clamped < *rule.Min {
+			clamped = *rule.Min
+		}
+		if rule.Max != nil && clamped > *rule.Max {
+			clamped = *rule.Max
+		}
+		if clamped == number {
+			return false
+		}
+		return setParam(body, rule.path, json.Number(strconv.FormatFloat(clamped, 'f', -1, 64)))
+	case ParamOverrideRename:
+		value, ok := getParam(body, rule.path)
+		if !ok {
+			return false
+		}
+		removeParam(body, rule.path)
+		return setParam(body, rule.to, value)
+	}
+	return false
+}
+
+// ApplyParamOverrideRules 按顺序执行匹配模型的规则，返回修改后的请求体和生效的规则序号（从 1 开始）
+// 请求体不是 JSON 对象时原样返回
+func ApplyParamOverrideRules(rules []*ParamOverrideRule, modelName string, data []byte, vars map[string]string) ([]byte, []int, error) {
+	if len(rules) == 0 {
+		return data, nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var body map[string]any
+	if err := decoder.Decode(&body); err != nil || body == nil {
+		return data, nil, nil
+	}
+
+	applied := make([]int, 0)
+	for i, rule := range rules {
+		if rule.matchModel(modelName) && rule.apply(body, vars) {
+			applied = append(applied, i+1)
+		}
+	}
+	if len(applied) == 0 {
+		return data, applied, nil
+	}
+
+	newData, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newData, applied, nil
+}
+
+func renderParamValue(value any, vars map[string]string) any {
+	text, ok := value.(string)
+	if !ok || len(vars) == 0 {
+		return value
+	}
+	for key, val := range vars {
+		text = strings.ReplaceAll(text, "{"+key+"}", val)
+	}
+	return text
+}
+
+func paramNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// getParam 读取路径上的值，数字表示数组下标
+func getParam(body map[string]any, path []string) (any, bool) {
+	var current any = body
+	for _, key := range path {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setParam 设置路径上的值，中间不存在的对象会被创建，数组下标越界时不修改
+func setParam(body map[string]any, path []string, value any) bool {
+	var current any = body
+	for i, key := range path {
+		last := i == len(path)-1
+		switch node := current.(type) {
+		case map[string]any:
+			if last {
+				node[key] = value
+				return true
+			}
+			next, ok := node[key]
+			if !ok || next == nil {
+				next = map[string]any{}
+				node[key] = next
+			}
+			current = next
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return false
+			}
+			if last {
+				node[index] = value
+				return true
+			}
+			current = node[index]
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// removeParam 删除对象中的字段，不支持删除数组元素
+func removeParam(body map[string]any, path []string) bool {
+	parent, ok := getParam(body, path[:len(path)-1])
+	if !ok {
+		return false
+	}
+	node, ok := parent.(map[string]any)
+	if !ok {
+		return false
+	}
+	key := path[len(path)-1]
+	if _, ok := node[key]; !ok {
+		return false
+	}
+	delete(node, key)
+	return true
+}
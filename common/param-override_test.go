@@ -0,0 +1,128 @@
+package common_test
+
+import (
+	"one-api/common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func applyParamOverride(t *testing.T, rules, model, body string) (string, []int) {
+	parsed, err := common.ParseParamOverrideRules(rules)
+	assert.Nil(t, err)
+
+	vars := map[string]string{"user_id": "7", "token_id": "9"}
+	data, applied, err := common.ApplyParamOverrideRules(parsed, model, []byte(body), vars)
+	assert.Nil(t, err)
+
+	return string(data), applied
+}
+
+func TestParamOverrideSet(t *testing.T) {
+	data, applied := applyParamOverride(t, `[{"op":"set","path":"max_tokens","value":100}]`, "gpt-4o", `{"model":"gpt-4o","max_tokens":10}`)
+	assert.JSONEq(t, `{"model":"gpt-4o","max_tokens":100}`, data)
+	assert.Equal(t, []int{1}, applied)
+
+	// 不存在的中间对象会自动创建
+	data, _ = applyParamOverride(t, `[{"op":"set","path":"$.stream_options.include_usage","value":true}]`, "gpt-4o", `{}`)
+	assert.JSONEq(t, `{"stream_options":{"include_usage":true}}`, data)
+
+	data, _ = applyParamOverride(t, `[{"op":"set","path":"messages[0].content","value":"hi"}]`, "gpt-4o", `{"messages":[{"role":"user","content":"hello"}]}`)
+	assert.JSONEq(t, `{"messages":[{"role":"user","content":"hi"}]}`, data)
+
+	// 数组越界时不修改
+	data, applied = applyParamOverride(t, `[{"op":"set","path":"messages[3].content","value":"hi"}]`, "gpt-4o", `{"messages":[]}`)
+	assert.JSONEq(t, `{"messages":[]}`, data)
+	assert.Empty(t, applied)
+
+	data, _ = applyParamOverride(t, `[{"op":"set","path":"user","value":"user-{user_id}-{token_id}"}]`, "gpt-4o", `{}`)
+	assert.JSONEq(t, `{"user":"user-7-9"}`, data)
+}
+
+func TestParamOverrideDefault(t *testing.T) {
+	rules := `[{"op":"default","path":"temperature","value":0.5}]`
+
+	data, applied := applyParamOverride(t, rules, "gpt-4o", `{"temperature":1}`)
+	assert.JSONEq(t, `{"temperature":1}`, data)
+	assert.Empty(t, applied)
+
+	data, applied = applyParamOverride(t, rules, "gpt-4o", `{}`)
+	assert.JSONEq(t, `{"temperature":0.5}`, data)
+	assert.Equal(t, []int{1}, applied)
+}
+
+func TestParamOverrideClamp(t *testing.T) {
+	rules := `[{"op":"clamp","path":"max_tokens","min":1,"max":4096}]`
+
+	data, _ := applyParamOverride(t, rules, "gpt-4o", `{"max_tokens":100000}`)
+	assert.JSONEq(t, `{"max_tokens":4096}`, data)
+
+	data, _ = applyParamOverride(t, rules, "gpt-4o", `{"max_tokens":0}`)
+	assert.JSONEq(t, `{"max_tokens":1}`, data)
+
+	data, applied := applyParamOverride(t, rules, "gpt-4o", `{"max_tokens":10}`)
+	assert.JSONEq(t, `{"max_tokens":10}`, data)
+	assert.Empty(t, applied)
+
+	// 非数字不处理
+	data, applied = applyParamOverride(t, rules, "gpt-4o", `{"max_tokens":"100000"}`)
+	assert.JSONEq(t, `{"max_tokens":"100000"}`, data)
+	assert.Empty(t, applied)
+}
+
+func TestParamOverrideRemoveAndRename(t *testing.T) {
+	data, applied := applyParamOverride(t, `[{"op":"remove","path":"logit_bias"},{"op":"remove","path":"missing"}]`, "gpt-4o", `{"model":"gpt-4o","logit_bias":{"1":1}}`)
+	assert.JSONEq(t, `{"model":"gpt-4o"}`, data)
+	assert.Equal(t, []int{1}, applied)
+
+	// 规则按顺序执行，后面的规则作用于前面的结果
+	data, applied = applyParamOverride(t, `[{"op":"rename","path":"max_tokens","to":"max_completion_tokens"},{"op":"clamp","path":"max_completion_tokens","max":5}]`, "gpt-4o", `{"max_tokens":10}`)
+	assert.JSONEq(t, `{"max_completion_tokens":5}`, data)
+	assert.Equal(t, []int{1, 2}, applied)
+}
+
+func TestParamOverrideModels(t *testing.T) {
+	rules := `[{"models":["o1*"],"op":"remove","path":"temperature"},{"models":["gpt-4o"],"op":"remove","path":"top_p"}]`
+
+	data, applied := applyParamOverride(t, rules, "o1-mini", `{"temperature":1,"top_p":1}`)
+	assert.JSONEq(t, `{"top_p":1}`, data)
+	assert.Equal(t, []int{1}, applied)
+
+	data, applied = applyParamOverride(t, rules, "gpt-4o", `{"temperature":1,"top_p":1}`)
+	assert.JSONEq(t, `{"temperature":1}`, data)
+	assert.Equal(t, []int{2}, applied)
+}
+
+func TestParamOverrideKeepsBody(t *testing.T) {
+	// 大整数保持原样
+	data, _ := applyParamOverride(t, `[{"op":"set","path":"user","value":"x"}]`, "gpt-4o", `{"seed":12345678901234567890}`)
+	assert.JSONEq(t, `{"seed":12345678901234567890,"user":"x"}`, data)
+
+	// 不是 JSON 对象的请求体原样返回
+	for _, body := range []string{`[1,2]`, `not json`, `null`} {
+		data, applied := applyParamOverride(t, `[{"op":"set","path":"a","value":1}]`, "gpt-4o", body)
+		assert.Equal(t, body, data)
+		assert.Empty(t, applied)
+	}
+}
+
+func TestParseParamOverrideRulesError(t *testing.T) {
+	invalid := []string{
+		`[{`,
+		`[{"op":"append","path":"a"}]`,
+		`[{"op":"remove"}]`,
+		`[{"op":"remove","path":"a..b"}]`,
+		`[{"op":"set","path":"a"}]`,
+		`[{"op":"clamp","path":"a"}]`,
+		`[{"op":"clamp","path":"a","min":2,"max":1}]`,
+		`[{"op":"rename","path":"a"}]`,
+	}
+	for _, rules := range invalid {
+		_, err := common.ParseParamOverrideRules(rules)
+		assert.NotNil(t, err, rules)
+	}
+
+	rules, err := common.ParseParamOverrideRules("  ")
+	assert.Nil(t, err)
+	assert.Empty(t, rules)
+}
@@ -75,6 +75,35 @@ func RedisDecrease(key string, value int64) error {
 	return RDB.DecrBy(ctx, key, value).Err()
 }
 
+func RedisListPush(key string, value string, expiration time.Duration) error {
+	ctx := context.Background()
+	pipe := RDB.TxPipeline()
+	pipe.RPush(ctx, key, value)
+	pipe.Expire(ctx, key, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func RedisListRange(key string) ([]string, error) {
+	ctx := context.Background()
+	return RDB.LRange(ctx, key, 0, -1).Result()
+}
+
+func RedisHashSet(key, field, value string) error {
+	ctx := context.Background()
+	return RDB.HSet(ctx, key, field, value).Err()
+}
+
+func RedisHashDel(key string, fields ...string) error {
+	ctx := context.Background()
+	return RDB.HDel(ctx, key, fields...).Err()
+}
+
+func RedisHashGetAll(key string) (map[string]string, error) {
+	ctx := context.Background()
+	return RDB.HGetAll(ctx, key).Result()
+}
+
 func NewScript(script string) *redis.Script {
 	return redis.NewScript(script)
 }
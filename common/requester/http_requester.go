@@ -26,6 +26,8 @@ type HTTPRequester struct {
 	proxyAddr         string
 	Context           context.Context
 	IsOpenAI          bool
+	// 发送前改写 JSON 请求体，例如渠道的参数改写规则
+	BodyHandler func(body []byte) ([]byte, error)
 }
 
 // NewHTTPRequester 创建一个新的 HTTPRequester 实例。
@@ -64,6 +66,9 @@ func (r *HTTPRequester) NewRequest(method, url string, setters ...requestOption)
 	for _, setter := range setters {
 		setter(args)
 	}
+	if err := r.handleBody(args); err != nil {
+		return nil, err
+	}
 	req, err := utils.RequestBuilder(r.setProxy(), method, url, args.body, args.header)
 	if err != nil {
 		return nil, err
@@ -72,6 +77,25 @@ func (r *HTTPRequester) NewRequest(method, url string, setters ...requestOption)
 	return req, nil
 }
 
+func (r *HTTPRequester) handleBody(args *requestOptions) error {
+	if r.BodyHandler == nil || args.body == nil {
+		return nil
+	}
+	if _, ok := args.body.(io.Reader); ok {
+		return nil
+	}
+
+	body, err := json.Marshal(args.body)
+	if err != nil {
+		return err
+	}
+	if body, err = r.BodyHandler(body); err != nil {
+		return err
+	}
+	args.body = bytes.NewReader(body)
+	return nil
+}
+
 // 发送请求
 func (r *HTTPRequester) SendRequest(req *http.Request, response any, outputResp bool) (*http.Response, *types.OpenAIErrorWithStatusCode) {
 	resp, err := HTTPClient.Do(req)
@@ -0,0 +1,207 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/types"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	RetryActionRetry    = "retry"    // 换一个渠道重试
+	RetryActionCooldown = "cooldown" // 冷却当前渠道后重试
+	RetryActionDisable  = "disable"  // 自动禁用当前渠道，是否重试由其他规则决定
+	RetryActionFail     = "fail"     // 不重试，直接返回错误
+)
+
+// RetryRule 渠道请求出错时的处理规则，条件为空表示不限制
+// 是否重试和是否禁用分开判断：disable 规则只决定是否禁用，其他规则按顺序使用第一条满足所有条件的规则决定是否重试
+type RetryRule struct {
+	Name         string   `json:"name,omitempty"`
+	ChannelTypes []int    `json:"channel_types,omitempty"`
+	StatusCodes  []string `json:"status_codes,omitempty"` // 支持 429、5xx 两种写法
+	ErrorCodes   []string `json:"error_codes,omitempty"`  // 匹配错误的 code 或 type
+	Message      string   `json:"message,omitempty"`      // 匹配错误信息的正则
+	Action       string   `json:"action"`
+	// 冷却时间，为 0 时使用 RetryCooldownSeconds
+	CooldownSeconds int `json:"cooldown_seconds,omitempty"`
+
+	messageRegex *regexp.Regexp
+}
+
+// 默认规则与之前写死的重试和自动禁用逻辑一致
+var defaultRetryRules = []*RetryRule{
+	{Name: "unauthorized", StatusCodes: []string{"401"}, Action: RetryActionDisable},
+	{Name: "gemini forbidden", ChannelTypes: []int{config.ChannelTypeGemini}, StatusCodes: []string{"403"}, Action: RetryActionDisable},
+	{Name: "account unavailable", ErrorCodes: []string{"invalid_api_key", "account_deactivated", "billing_not_active", "insufficient_quota", "authentication_error", "permission_error", "forbidden"}, Action: RetryActionDisable},
+	{Name: "quota exhausted", Message: "Your credit balance is too low|This organization has been disabled|You exceeded your current quota|Permission denied|Access denied|credit|balance", Action: RetryActionDisable},
+	{Name: "rate limit", StatusCodes: []string{"429"}, Action: RetryActionCooldown},
+	{Name: "timeout", StatusCodes: []string{"504", "524", "408"}, Action: RetryActionFail},
+	{Name: "anthropic organization disabled", ChannelTypes: []int{config.ChannelTypeAnthropic}, StatusCodes: []string{"400"}, Message: "This organization has been disabled", Action: RetryActionRetry},
+	{Name: "bad request", StatusCodes: []string{"400", "2xx"}, Action: RetryActionFail},
+	{Name: "others", Action: RetryActionRetry},
+}
+
+var retryRules = defaultRetryRules
+var retryRulesLock sync.RWMutex
+
+var statusCodePattern = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
+func init() {
+	for _, rule := range defaultRetryRules {
+		if err := rule.compile(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (rule *RetryRule) compile() error {
+	switch rule.Action {
+	case RetryActionRetry, RetryActionCooldown, RetryActionDisable, RetryActionFail:
+	default:
+		return fmt.Errorf("invalid action: %s", rule.Action)
+	}
+
+	for _, code := range rule.StatusCodes {
+		if !statusCodePattern.MatchString(code) {
+			return fmt.Errorf("invalid status code: %s", code)
+		}
+	}
+
+	if rule.Message != "" {
+		regex, err := regexp.Compile(rule.Message)
+		if err != nil {
+			return fmt.Errorf("invalid message regex: %s", err.Error())
+		}
+		rule.messageRegex = regex
+	}
+
+	return nil
+}
+
+func (rule *RetryRule) match(channelType int, err *types.OpenAIErrorWithStatusCode) bool {
+	if len(rule.ChannelTypes) > 0 && !utils.Contains(channelType, rule.ChannelTypes) {
+		return false
+	}
+
+	if len(rule.StatusCodes) > 0 {
+		statusCode := strconv.Itoa(err.StatusCode)
+		matched := false
+		for _, code := range rule.StatusCodes {
+			if code == statusCode || (strings.HasSuffix(code, "xx") && code[0] == statusCode[0]) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(rule.ErrorCodes) > 0 {
+		errorCode := ""
+		if err.Code != nil {
+			errorCode = fmt.Sprintf("%v", err.Code)
+		}
+		if !utils.Contains(errorCode, rule.ErrorCodes) && !utils.Contains(err.Type, rule.ErrorCodes) {
+			return false
+		}
+	}
+
+	if rule.messageRegex != nil && !rule.messageRegex.MatchString(err.Message) {
+		return false
+	}
+
+	return true
+}
+
+func ParseRetryRules(jsonStr string) ([]*RetryRule, error) {
+	if strings.TrimSpace(jsonStr) == "" {
+		return defaultRetryRules, nil
+	}
+
+	var rules []*RetryRule
+	if err := json.Unmarshal([]byte(jsonStr), &rules); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("rule #%d: %s", i+1, err.Error())
+		}
+	}
+
+	return rules, nil
+}
+
+// UpdateRetryRulesByJSONString 更新重试规则，为空时恢复默认规则
+func UpdateRetryRulesByJSONString(jsonStr string) error {
+	rules, err := ParseRetryRules(jsonStr)
+	if err != nil {
+		return err
+	}
+
+	retryRulesLock.Lock()
+	retryRules = rules
+	retryRulesLock.Unlock()
+
+	return nil
+}
+
+func RetryRules2JSONString() string {
+	retryRulesLock.RLock()
+	defer retryRulesLock.RUnlock()
+
+	jsonBytes, err := json.Marshal(retryRules)
+	if err != nil {
+		logger.SysError("error marshalling retry rules: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func getRetryRules() []*RetryRule {
+	retryRulesLock.RLock()
+	defer retryRulesLock.RUnlock()
+	return retryRules
+}
+
+// MatchRetryRule 返回决定是否重试的规则及其下标，跳过 disable 规则，没有匹配时返回 -1，调用方按重试处理
+func MatchRetryRule(channelType int, err *types.OpenAIErrorWithStatusCode) (int, *RetryRule) {
+	return MatchRetryRuleIn(getRetryRules(), channelType, err)
+}
+
+func MatchRetryRuleIn(rules []*RetryRule, channelType int, err *types.OpenAIErrorWithStatusCode) (int, *RetryRule) {
+	return matchRule(rules, channelType, err, false)
+}
+
+// MatchDisableRule 返回错误匹配的第一条 disable 规则及其下标，没有匹配时返回 -1
+func MatchDisableRule(channelType int, err *types.OpenAIErrorWithStatusCode) (int, *RetryRule) {
+	return MatchDisableRuleIn(getRetryRules(), channelType, err)
+}
+
+func MatchDisableRuleIn(rules []*RetryRule, channelType int, err *types.OpenAIErrorWithStatusCode) (int, *RetryRule) {
+	return matchRule(rules, channelType, err, true)
+}
+
+func matchRule(rules []*RetryRule, channelType int, err *types.OpenAIErrorWithStatusCode, disable bool) (int, *RetryRule) {
+	if err == nil {
+		return -1, nil
+	}
+
+	for i, rule := range rules {
+		if (rule.Action == RetryActionDisable) != disable {
+			continue
+		}
+		if rule.match(channelType, err) {
+			return i, rule
+		}
+	}
+
+	return -1, nil
+}
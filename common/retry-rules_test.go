@@ -0,0 +1,110 @@
+package common_test
+
+import (
+	"testing"
+
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newAPIError(statusCode int, code any, errType, message string) *types.OpenAIErrorWithStatusCode {
+	return &types.OpenAIErrorWithStatusCode{
+		OpenAIError: types.OpenAIError{
+			Code:    code,
+			Type:    errType,
+			Message: message,
+		},
+		StatusCode: statusCode,
+	}
+}
+
+func TestDefaultRetryRules(t *testing.T) {
+	cases := []struct {
+		name        string
+		channelType int
+		err         *types.OpenAIErrorWithStatusCode
+		retry       bool
+		action      string
+		disable     bool
+	}{
+		{"unauthorized", config.ChannelTypeOpenAI, newAPIError(401, "invalid_api_key", "", "Incorrect API key"), true, common.RetryActionRetry, true},
+		{"gemini forbidden", config.ChannelTypeGemini, newAPIError(403, nil, "", "forbidden"), true, common.RetryActionRetry, true},
+		{"openai forbidden", config.ChannelTypeOpenAI, newAPIError(403, nil, "", "forbidden"), true, common.RetryActionRetry, false},
+		{"rate limit", config.ChannelTypeOpenAI, newAPIError(429, nil, "", "Rate limit reached"), true, common.RetryActionCooldown, false},
+		{"server error", config.ChannelTypeOpenAI, newAPIError(500, nil, "", "internal error"), true, common.RetryActionRetry, false},
+		{"timeout", config.ChannelTypeOpenAI, newAPIError(504, nil, "", "gateway timeout"), false, common.RetryActionFail, false},
+		{"bad request", config.ChannelTypeOpenAI, newAPIError(400, nil, "", "invalid messages"), false, common.RetryActionFail, false},
+		// 错误信息中的 credit、balance 只决定禁用，不影响状态码决定的重试
+		{"bad request with balance", config.ChannelTypeOpenAI, newAPIError(400, nil, "", "field balance is invalid"), false, common.RetryActionFail, true},
+		{"timeout with credit", config.ChannelTypeOpenAI, newAPIError(408, nil, "", "credit check timeout"), false, common.RetryActionFail, true},
+		{"gateway timeout with balance", config.ChannelTypeOpenAI, newAPIError(504, nil, "", "balance service timeout"), false, common.RetryActionFail, true},
+		{"insufficient quota", config.ChannelTypeOpenAI, newAPIError(429, nil, "insufficient_quota", "You exceeded your current quota"), true, common.RetryActionCooldown, true},
+		{"anthropic organization disabled", config.ChannelTypeAnthropic, newAPIError(400, nil, "", "This organization has been disabled."), true, common.RetryActionRetry, true},
+		{"success status", config.ChannelTypeOpenAI, newAPIError(200, nil, "", "empty response"), false, common.RetryActionFail, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, rule := common.MatchRetryRule(c.channelType, c.err)
+			assert.NotNil(t, rule)
+			assert.Equal(t, c.action, rule.Action)
+			assert.Equal(t, c.retry, rule.Action != common.RetryActionFail)
+
+			_, disableRule := common.MatchDisableRule(c.channelType, c.err)
+			assert.Equal(t, c.disable, disableRule != nil)
+		})
+	}
+}
+
+func TestCustomRetryRules(t *testing.T) {
+	rules, err := common.ParseRetryRules(`[
+		{"name": "overloaded", "status_codes": ["5xx"], "message": "overloaded", "action": "cooldown", "cooldown_seconds": 30},
+		{"name": "model not found", "error_codes": ["model_not_found"], "action": "fail"},
+		{"name": "disable forbidden", "status_codes": ["403"], "action": "disable"}
+	]`)
+	assert.Nil(t, err)
+
+	cases := []struct {
+		name         string
+		err          *types.OpenAIErrorWithStatusCode
+		index        int
+		disableIndex int
+	}{
+		{"overloaded", newAPIError(529, nil, "", "Overloaded: server is overloaded"), 0, -1},
+		{"model not found", newAPIError(404, "model_not_found", "", "model not found"), 1, -1},
+		{"forbidden", newAPIError(403, nil, "", "forbidden"), -1, 2},
+		{"no rule", newAPIError(502, nil, "", "bad gateway"), -1, -1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			index, _ := common.MatchRetryRuleIn(rules, config.ChannelTypeOpenAI, c.err)
+			assert.Equal(t, c.index, index)
+
+			disableIndex, _ := common.MatchDisableRuleIn(rules, config.ChannelTypeOpenAI, c.err)
+			assert.Equal(t, c.disableIndex, disableIndex)
+		})
+	}
+}
+
+func TestParseRetryRulesInvalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules string
+	}{
+		{"invalid json", `[{`},
+		{"invalid action", `[{"action": "ignore"}]`},
+		{"invalid status code", `[{"status_codes": ["6xx"], "action": "retry"}]`},
+		{"invalid regex", `[{"message": "(", "action": "retry"}]`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := common.ParseRetryRules(c.rules)
+			assert.NotNil(t, err)
+		})
+	}
+}
@@ -177,6 +177,39 @@ func SendQuotaWarningCodeEmail(userName, email string, quota int, noMoreQuota bo
 	return stmp.Render(email, subject, content)
 }
 
+func SendSubscriptionEmail(userName, email, planName, expiredTime string, expired bool) error {
+	stmp, err := GetSystemStmp()
+
+	if err != nil {
+		return err
+	}
+
+	contentTemp := `<p style="font-size: 30px">Hi <strong>%s,</strong></p>
+		<p>
+			%s。
+		</p>
+		
+		<p style="text-align: center; font-size: 13px;">
+			<a target="__blank" href="%s" class="button" style="color: #ffffff;">立即续费</a>
+		</p>
+		
+		<p style="color: #858585; padding-top: 15px;">
+			如果链接无法点击，请尝试点击下面的链接或将其复制到浏览器中打开<br> %s
+		</p>`
+
+	subject := "您的订阅即将到期"
+	message := fmt.Sprintf("您订阅的套餐 %s 将于 %s 到期，到期后未使用的订阅额度将被回收，为了不影响您的使用，请及时续费", planName, expiredTime)
+	if expired {
+		subject = "您的订阅已到期"
+		message = fmt.Sprintf("您订阅的套餐 %s 已于 %s 到期，未使用的订阅额度已被回收", planName, expiredTime)
+	}
+	renewLink := fmt.Sprintf("%s/topup", config.ServerAddress)
+
+	content := fmt.Sprintf(contentTemp, userName, message, renewLink, renewLink)
+
+	return stmp.Render(email, subject, content)
+}
+
 func DialAndSend(c *mail.Client, messages ...*mail.Msg) error {
 	ctx := context.Background()
 	if err := c.DialWithContext(ctx); err != nil {
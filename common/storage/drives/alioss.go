@@ -3,6 +3,8 @@ package drives
 import (
 	"bytes"
 	"fmt"
+	"io"
+
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 )
 
@@ -26,17 +28,23 @@ func (a *AliOSSUpload) Name() string {
 	return "AliOSS"
 }
 
-func (a *AliOSSUpload) Upload(data []byte, fileName string) (string, error) {
-	// Create OSS Client
+func (a *AliOSSUpload) getBucket() (*oss.Bucket, error) {
 	client, err := oss.New(a.Endpoint, a.AccessKeyId, a.AccessKeySecret)
 	if err != nil {
-		return "", fmt.Errorf("creating OSS client: %w", err)
+		return nil, fmt.Errorf("creating OSS client: %w", err)
 	}
 
-	// Create Bucket
 	bucket, err := client.Bucket(a.BucketName)
 	if err != nil {
-		return "", fmt.Errorf("getting bucket: %w", err)
+		return nil, fmt.Errorf("getting bucket: %w", err)
+	}
+	return bucket, nil
+}
+
+func (a *AliOSSUpload) Upload(data []byte, fileName string) (string, error) {
+	bucket, err := a.getBucket()
+	if err != nil {
+		return "", err
 	}
 
 	// Upload File
@@ -54,3 +62,42 @@ func (a *AliOSSUpload) Upload(data []byte, fileName string) (string, error) {
 
 	return objectURL, nil
 }
+
+// Put 保存文件，不返回访问地址
+func (a *AliOSSUpload) Put(key string, reader io.Reader) (int64, error) {
+	bucket, err := a.getBucket()
+	if err != nil {
+		return 0, err
+	}
+
+	counter := &countReader{reader: reader}
+	if err := bucket.PutObject(key, counter); err != nil {
+		return 0, fmt.Errorf("uploading file: %w", err)
+	}
+	return counter.size, nil
+}
+
+func (a *AliOSSUpload) Get(key string) (io.ReadCloser, error) {
+	bucket, err := a.getBucket()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := bucket.GetObject(key)
+	if err != nil {
+		return nil, fmt.Errorf("getting file: %w", err)
+	}
+	return reader, nil
+}
+
+func (a *AliOSSUpload) Delete(key string) error {
+	bucket, err := a.getBucket()
+	if err != nil {
+		return err
+	}
+
+	if err := bucket.DeleteObject(key); err != nil {
+		return fmt.Errorf("deleting file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package drives
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type LocalDrive struct {
+	Path string
+}
+
+func NewLocalDrive(path string) *LocalDrive {
+	return &LocalDrive{
+		Path: path,
+	}
+}
+
+func (l *LocalDrive) Name() string {
+	return "Local"
+}
+
+func (l *LocalDrive) getPath(key string) string {
+	return filepath.Join(l.Path, filepath.FromSlash(key))
+}
+
+func (l *LocalDrive) Put(key string, reader io.Reader) (int64, error) {
+	path := l.getPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	size, err := io.Copy(file, reader)
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+
+	return size, nil
+}
+
+func (l *LocalDrive) Get(key string) (io.ReadCloser, error) {
+	return os.Open(l.getPath(key))
+}
+
+func (l *LocalDrive) Delete(key string) error {
+	err := os.Remove(l.getPath(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// countReader 统计读取的字节数，用于不返回文件大小的存储
+type countReader struct {
+	reader io.Reader
+	size   int64
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.size += int64(n)
+	return n, err
+}
@@ -3,11 +3,13 @@ package drives
 import (
 	"bytes"
 	"fmt"
+	"io"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 type S3Upload struct {
@@ -36,9 +38,7 @@ func (a *S3Upload) Name() string {
 	return "S3"
 }
 
-func (a *S3Upload) Upload(data []byte, s3Key string) (string, error) {
-
-	// 创建 S3 会话
+func (a *S3Upload) newSession() (*session.Session, error) {
 	sess, err := session.NewSession(&aws.Config{
 		Credentials: credentials.NewStaticCredentials(
 			a.AccessKeyId,
@@ -50,7 +50,17 @@ func (a *S3Upload) Upload(data []byte, s3Key string) (string, error) {
 		S3ForcePathStyle: aws.Bool(true),
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create session: %v", err)
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+	return sess, nil
+}
+
+func (a *S3Upload) Upload(data []byte, s3Key string) (string, error) {
+
+	// 创建 S3 会话
+	sess, err := a.newSession()
+	if err != nil {
+		return "", err
 	}
 
 	svc := s3.New(sess)
@@ -80,3 +90,56 @@ func (a *S3Upload) Upload(data []byte, s3Key string) (string, error) {
 
 	return fmt.Sprintf("%s/%s", a.CustomDomain, s3Key), nil
 }
+
+// Put 保存文件，不返回公共访问地址
+func (a *S3Upload) Put(key string, reader io.Reader) (int64, error) {
+	sess, err := a.newSession()
+	if err != nil {
+		return 0, err
+	}
+
+	counter := &countReader{reader: reader}
+	_, err = s3manager.NewUploader(sess).Upload(&s3manager.UploadInput{
+		Bucket: aws.String(a.BucketName),
+		Key:    aws.String(key),
+		Body:   counter,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload file to S3: %v", err)
+	}
+
+	return counter.size, nil
+}
+
+func (a *S3Upload) Get(key string) (io.ReadCloser, error) {
+	sess, err := a.newSession()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := s3.New(sess).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(a.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file from S3: %v", err)
+	}
+
+	return output.Body, nil
+}
+
+func (a *S3Upload) Delete(key string) error {
+	sess, err := a.newSession()
+	if err != nil {
+		return err
+	}
+
+	_, err = s3.New(sess).DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(a.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file from S3: %v", err)
+	}
+	return nil
+}
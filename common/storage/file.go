@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"one-api/common/storage/drives"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// FileDrive 可以读回和删除文件的存储，用于保存用户通过 Files API 上传的文件
+type FileDrive interface {
+	Name() string
+	Put(key string, reader io.Reader) (int64, error)
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// 新上传的文件使用的存储，已保存的文件使用保存时记录的存储
+var fileDriveName string
+
+func InitFileStorage() {
+	AddFileDrive(drives.NewLocalDrive(viper.GetString("storage.local.path")))
+
+	fileDriveName = viper.GetString("storage.file_drive")
+	if _, err := getFileDrive(fileDriveName); err != nil {
+		fileDriveName = "Local"
+	}
+}
+
+func getFileDrive(name string) (FileDrive, error) {
+	for driveName, drive := range storageDrives.fileDrives {
+		if strings.EqualFold(driveName, name) {
+			return drive, nil
+		}
+	}
+	return nil, fmt.Errorf("file storage %s is not configured", name)
+}
+
+func FileDriveName() string {
+	drive, err := getFileDrive(fileDriveName)
+	if err != nil {
+		return ""
+	}
+	return drive.Name()
+}
+
+func PutFile(key string, reader io.Reader) (int64, error) {
+	drive, err := getFileDrive(fileDriveName)
+	if err != nil {
+		return 0, err
+	}
+	return drive.Put(key, reader)
+}
+
+func GetFile(driveName, key string) (io.ReadCloser, error) {
+	drive, err := getFileDrive(driveName)
+	if err != nil {
+		return nil, err
+	}
+	return drive.Get(key)
+}
+
+func DeleteFile(driveName, key string) error {
+	drive, err := getFileDrive(driveName)
+	if err != nil {
+		return err
+	}
+	return drive.Delete(key)
+}
@@ -8,6 +8,8 @@ import (
 
 type Storage struct {
 	drives map[string]StorageDrive
+	// 可以读回和删除文件的存储，用于 Files API
+	fileDrives map[string]FileDrive
 }
 
 func InitStorage() {
@@ -15,6 +17,7 @@ func InitStorage() {
 	InitSMStorage()
 	InitALIOSSStorage()
 	InitS3Storage()
+	InitFileStorage()
 }
 
 func InitALIOSSStorage() {
@@ -9,7 +9,8 @@ type StorageDrive interface {
 
 func New() *Storage {
 	storageDrive := &Storage{
-		drives: make(map[string]StorageDrive, 0),
+		drives:     make(map[string]StorageDrive, 0),
+		fileDrives: make(map[string]FileDrive, 0),
 	}
 
 	return storageDrive
@@ -19,6 +20,13 @@ func AddStorageDrive(drives ...StorageDrive) {
 	storageDrives.addDrives(drives...)
 }
 
+// AddFileDrive 添加只用于保存文件的存储，不参与图片上传
+func AddFileDrive(drives ...FileDrive) {
+	for _, d := range drives {
+		storageDrives.addFileDrive(d)
+	}
+}
+
 func (s *Storage) addDrives(drives ...StorageDrive) {
 	for _, d := range drives {
 		s.addDrive(d)
@@ -32,5 +40,19 @@ func (s *Storage) addDrive(drive StorageDrive) {
 			return
 		}
 		s.drives[driveName] = drive
+
+		if fileDrive, ok := drive.(FileDrive); ok {
+			s.addFileDrive(fileDrive)
+		}
+	}
+}
+
+func (s *Storage) addFileDrive(drive FileDrive) {
+	if drive != nil {
+		driveName := drive.Name()
+		if _, ok := s.fileDrives[driveName]; ok {
+			return
+		}
+		s.fileDrives[driveName] = drive
 	}
 }
@@ -1,7 +1,9 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
+	"one-api/common"
 	"one-api/model"
 	"strconv"
 	"time"
@@ -89,3 +91,35 @@ func GetStatisticsDetail(c *gin.Context) {
 		"data":    statisticsDetail,
 	})
 }
+
+const maxProfitStatisticsSeconds = 366 * 24 * 60 * 60
+
+// GetProfitStatistics 按天、渠道和模型统计计费额度与上游成本的差额
+func GetProfitStatistics(c *gin.Context) {
+	startTimestamp, startErr := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, endErr := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if startErr != nil || endErr != nil || startTimestamp <= 0 || endTimestamp < startTimestamp {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+	// 统计表按天聚合，限制查询范围避免扫描过多数据
+	if endTimestamp-startTimestamp > maxProfitStatisticsSeconds {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("查询范围不能超过一年"))
+		return
+	}
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+
+	startDate := time.Unix(startTimestamp, 0).Format("2006-01-02")
+	endDate := time.Unix(endTimestamp, 0).Format("2006-01-02")
+	profitStatistics, err := model.GetProfitStatisticsByPeriod(startDate, endDate, channelId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    profitStatistics,
+	})
+}
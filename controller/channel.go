@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"one-api/common"
@@ -31,6 +32,21 @@ func GetChannelsList(c *gin.Context) {
 	})
 }
 
+// GetChannelBalance 查看分组下模型的负载均衡策略和渠道有效权重
+func GetChannelBalance(c *gin.Context) {
+	balance, err := model.ChannelGroup.GetBalance(c.Query("group"), c.Query("model"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    balance,
+	})
+}
+
 func GetChannel(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -65,6 +81,27 @@ func AddChannel(c *gin.Context) {
 		})
 		return
 	}
+	if _, err := model.ParseUpstreamCost(channel.GetUpstreamCost()); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "上游成本格式错误：" + err.Error(),
+		})
+		return
+	}
+	if _, err := common.ParseParamOverrideRules(channel.GetParamOverride()); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "参数改写规则格式错误：" + err.Error(),
+		})
+		return
+	}
+	if err := model.ValidateChannelKeyStrategy(channel.KeyStrategy); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	channel.CreatedTime = utils.GetTimestamp()
 	keys := strings.Split(channel.Key, "\n")
 
@@ -164,6 +201,27 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	if _, err := model.ParseUpstreamCost(channel.GetUpstreamCost()); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "上游成本格式错误：" + err.Error(),
+		})
+		return
+	}
+	if _, err := common.ParseParamOverrideRules(channel.GetParamOverride()); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "参数改写规则格式错误：" + err.Error(),
+		})
+		return
+	}
+	if err := model.ValidateChannelKeyStrategy(channel.KeyStrategy); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	if channel.Models == "" {
 		err = channel.Update(false)
 	} else {
@@ -233,3 +291,63 @@ func BatchDelModelChannels(c *gin.Context) {
 		"message": "更新成功",
 	})
 }
+
+type paramOverrideTestRequest struct {
+	// 为空时使用渠道当前的规则，用于保存前测试
+	Rules     string          `json:"rules"`
+	ChannelId int             `json:"channel_id"`
+	Model     string          `json:"model"` // 为空时使用请求体中的 model
+	Body      json.RawMessage `json:"body" binding:"required"`
+	// 规则中 {user_id}、{token_id}、{group} 等变量的值
+	Vars map[string]string `json:"vars"`
+}
+
+// TestParamOverride 查看请求体经过参数改写规则后的结果，不会发送请求
+func TestParamOverride(c *gin.Context) {
+	var request paramOverrideTestRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	rulesStr := request.Rules
+	if rulesStr == "" && request.ChannelId > 0 {
+		channel, err := model.GetChannelById(request.ChannelId)
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+		rulesStr = channel.GetParamOverride()
+	}
+
+	rules, err := common.ParseParamOverrideRules(rulesStr)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(request.Body, &body); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("请求体必须是 JSON 对象"))
+		return
+	}
+	modelName := request.Model
+	if modelName == "" {
+		modelName, _ = body["model"].(string)
+	}
+
+	result, applied, err := common.ApplyParamOverrideRules(rules, modelName, request.Body, request.Vars)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"body":    json.RawMessage(result),
+			"applied": applied,
+		},
+	})
+}
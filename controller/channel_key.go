@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getChannelKeyParams(c *gin.Context) (*model.Channel, int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, 0, err
+	}
+	channel, err := model.GetChannelById(id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	keyId := 0
+	if c.Param("key_id") != "" {
+		keyId, err = strconv.Atoi(c.Param("key_id"))
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return channel, keyId, nil
+}
+
+func GetChannelKeys(c *gin.Context) {
+	channel, _, err := getChannelKeyParams(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	keys, err := model.GetChannelKeys(channel.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    keys,
+	})
+}
+
+type AddChannelKeysRequest struct {
+	Keys string `json:"keys" binding:"required"` // 每行一个密钥
+}
+
+func AddChannelKeys(c *gin.Context) {
+	channel, _, err := getChannelKeyParams(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var request AddChannelKeysRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	count, err := model.AddChannelKeys(channel, strings.Split(request.Keys, "\n"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+}
+
+func DeleteChannelKey(c *gin.Context) {
+	channel, keyId, err := getChannelKeyParams(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.DeleteChannelKey(channel.Id, keyId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func EnableChannelKey(c *gin.Context) {
+	channel, keyId, err := getChannelKeyParams(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if _, err := model.GetChannelKeyById(channel.Id, keyId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.UpdateChannelKeyStatus(keyId, config.ChannelStatusEnabled, ""); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type ChannelKeyTestResult struct {
+	Id      int     `json:"id"`
+	Success bool    `json:"success"`
+	Message string  `json:"message"`
+	Time    float64 `json:"time"`
+	Status  int     `json:"status"`
+}
+
+// TestChannelKeys 逐个测试渠道密钥池中的密钥，失败的密钥按自动禁用规则禁用，自动禁用的密钥测试通过后恢复
+func TestChannelKeys(c *gin.Context) {
+	channel, _, err := getChannelKeyParams(c)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	keys, err := model.GetChannelKeys(channel.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if len(keys) == 0 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("渠道没有密钥池"))
+		return
+	}
+
+	testModel := c.Query("model")
+	results := make([]*ChannelKeyTestResult, 0, len(keys))
+	for i, key := range keys {
+		if i > 0 {
+			time.Sleep(config.RequestInterval)
+		}
+
+		keyChannel := *channel
+		keyChannel.Key = key.Key
+		keyChannel.KeyId = key.Id
+
+		tik := time.Now()
+		err, openaiErr := testChannel(&keyChannel, testModel)
+		result := &ChannelKeyTestResult{
+			Id:      key.Id,
+			Success: err == nil,
+			Message: "测试成功",
+			Time:    float64(time.Since(tik).Milliseconds()) / 1000.0,
+			Status:  key.Status,
+		}
+
+		if err != nil {
+			result.Message = err.Error()
+			if openaiErr != nil {
+				model.RecordChannelKeyError(key.Id, err.Error())
+			}
+			if key.Status == config.ChannelStatusEnabled && ShouldDisableChannel(channel.Type, openaiErr) {
+				DisableChannelKey(channel.Id, key.Id, err.Error())
+				result.Status = config.ChannelStatusAutoDisabled
+			}
+		} else if key.Status == config.ChannelStatusAutoDisabled && shouldEnableChannel(err, openaiErr) {
+			if err := model.UpdateChannelKeyStatus(key.Id, config.ChannelStatusEnabled, ""); err == nil {
+				result.Status = config.ChannelStatusEnabled
+			}
+		}
+		results = append(results, result)
+	}
+
+	// 密钥都被禁用时禁用渠道，有密钥恢复时启用自动禁用的渠道
+	if !model.ChannelKeys.HasEnabled(channel.Id) {
+		if channel.Status == config.ChannelStatusEnabled {
+			DisableChannel(channel.Id, channel.Name, "所有密钥均已被禁用", false)
+		}
+	} else if channel.Status == config.ChannelStatusAutoDisabled && config.AutomaticEnableChannelEnabled {
+		EnableChannel(channel.Id, channel.Name, false)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("已测试 %d 个密钥", len(results)),
+		"data":    results,
+	})
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"one-api/common"
 	"one-api/model"
@@ -71,6 +72,21 @@ func UpdateChannelsTag(c *gin.Context) {
 		return
 	}
 
+	if _, err := model.ParseUpstreamCost(channel.GetUpstreamCost()); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("上游成本格式错误："+err.Error()))
+		return
+	}
+
+	if _, err := common.ParseParamOverrideRules(channel.GetParamOverride()); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("参数改写规则格式错误："+err.Error()))
+		return
+	}
+
+	if err := model.ValidateChannelKeyStrategy(channel.KeyStrategy); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
 	err = model.UpdateChannelsTag(tag, &channel)
 	if err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
@@ -3,11 +3,12 @@ package controller
 import (
 	"fmt"
 	"net/http"
+	"one-api/common"
 	"one-api/common/config"
+	"one-api/common/logger"
 	"one-api/common/notify"
 	"one-api/model"
 	"one-api/types"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,60 +39,8 @@ func ShouldDisableChannel(channelType int, err *types.OpenAIErrorWithStatusCode)
 		return false
 	}
 
-	if err.StatusCode == http.StatusUnauthorized {
-		return true
-	}
-
-	if err.StatusCode == http.StatusForbidden {
-		switch channelType {
-		case config.ChannelTypeGemini:
-			return true
-		}
-	}
-
-	switch err.OpenAIError.Code {
-	case "invalid_api_key":
-		return true
-	case "account_deactivated":
-		return true
-	case "billing_not_active":
-		return true
-	}
-
-	switch err.Type {
-	case "insufficient_quota":
-		return true
-	// https://docs.anthropic.com/claude/reference/errors
-	case "authentication_error":
-		return true
-	case "permission_error":
-		return true
-	case "forbidden":
-		return true
-	}
-
-	if strings.Contains(err.OpenAIError.Message, "Your credit balance is too low") { // anthropic
-		return true
-	} else if strings.Contains(err.OpenAIError.Message, "This organization has been disabled.") {
-		return true
-	} else if strings.Contains(err.OpenAIError.Message, "You exceeded your current quota") {
-		return true
-	} else if strings.Contains(err.OpenAIError.Message, "Permission denied") {
-		return true
-	}
-
-	if strings.Contains(err.OpenAIError.Message, "credit") {
-		return true
-	}
-	if strings.Contains(err.OpenAIError.Message, "balance") {
-		return true
-	}
-
-	if strings.Contains(err.OpenAIError.Message, "Access denied") {
-		return true
-	}
-	return false
-
+	_, rule := common.MatchDisableRule(channelType, err)
+	return rule != nil
 }
 
 // disable & notify
@@ -106,6 +55,18 @@ func DisableChannel(channelId int, channelName string, reason string, sendNotify
 	notify.Send(subject, content)
 }
 
+// DisableChannelKey 禁用渠道密钥池中的密钥
+func DisableChannelKey(channelId, keyId int, reason string) {
+	if err := model.UpdateChannelKeyStatus(keyId, config.ChannelStatusAutoDisabled, reason); err != nil {
+		logger.SysError(fmt.Sprintf("failed to disable channel #%d key #%d: %s", channelId, keyId, err.Error()))
+		return
+	}
+
+	subject := fmt.Sprintf("通道 #%d 的密钥 #%d 已被禁用", channelId, keyId)
+	content := fmt.Sprintf("通道 #%d 的密钥 #%d 已被禁用，原因：%s", channelId, keyId, reason)
+	notify.Send(subject, content)
+}
+
 // enable & notify
 func EnableChannel(channelId int, channelName string, sendNotify bool) {
 	model.UpdateChannelStatusById(channelId, config.ChannelStatusEnabled)
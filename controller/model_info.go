@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/relay/relay_util"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetModelInfosList(c *gin.Context) {
+	infos := relay_util.GetModelInfosList(c.DefaultQuery("type", "db"))
+	if infos == nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("model info data not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    infos,
+	})
+}
+
+func AddModelInfo(c *gin.Context) {
+	var info model.ModelInfo
+	if err := c.ShouldBindJSON(&info); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := relay_util.ModelInfoInstance.AddModelInfo(&info); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func UpdateModelInfo(c *gin.Context) {
+	modelName := c.Param("model")
+	if modelName == "" || len(modelName) < 2 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("model name is required"))
+		return
+	}
+	modelName = modelName[1:]
+	modelName, _ = url.PathUnescape(modelName)
+
+	var info model.ModelInfo
+	if err := c.ShouldBindJSON(&info); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := relay_util.ModelInfoInstance.UpdateModelInfo(modelName, &info); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeleteModelInfo(c *gin.Context) {
+	modelName := c.Param("model")
+	if modelName == "" || len(modelName) < 2 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("model name is required"))
+		return
+	}
+	modelName = modelName[1:]
+	modelName, _ = url.PathUnescape(modelName)
+
+	if err := relay_util.ModelInfoInstance.DeleteModelInfo(modelName); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func SyncModelInfos(c *gin.Context) {
+	overwrite := c.DefaultQuery("overwrite", "false")
+
+	infos := make([]*model.ModelInfo, 0)
+	if err := c.ShouldBindJSON(&infos); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if len(infos) == 0 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("model infos is required"))
+		return
+	}
+
+	if err := relay_util.ModelInfoInstance.SyncModelInfos(infos, overwrite == "true"); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
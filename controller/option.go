@@ -3,9 +3,12 @@ package controller
 import (
 	"encoding/json"
 	"net/http"
+	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/utils"
 	"one-api/model"
+	"one-api/types"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -75,6 +78,39 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "BalanceStrategy":
+		if option.Value == "" || !model.IsBalanceStrategy(option.Value) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无效的负载均衡策略",
+			})
+			return
+		}
+	case "CircuitBreakerErrorRate":
+		rate, err := strconv.ParseFloat(option.Value, 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "熔断错误率必须在 0 到 1 之间",
+			})
+			return
+		}
+	case "RetryRules":
+		if _, err := common.ParseRetryRules(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "重试规则格式错误：" + err.Error(),
+			})
+			return
+		}
+	case "ModelFallbacks":
+		if _, err := common.ParseModelFallbacks(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "模型降级链格式错误：" + err.Error(),
+			})
+			return
+		}
 	case "TurnstileCheckEnabled":
 		if option.Value == "true" && config.TurnstileSiteKey == "" {
 			c.JSON(http.StatusOK, gin.H{
@@ -98,3 +134,57 @@ func UpdateOption(c *gin.Context) {
 	})
 	return
 }
+
+type retryRulesTestRequest struct {
+	// 为空时使用当前生效的规则，用于保存前测试
+	Rules       string `json:"rules"`
+	ChannelType int    `json:"channel_type"`
+	StatusCode  int    `json:"status_code" binding:"required"`
+	Code        string `json:"code"`
+	Type        string `json:"type"`
+	Message     string `json:"message"`
+}
+
+// TestRetryRules 查看一个错误会匹配哪条重试规则和禁用规则
+func TestRetryRules(c *gin.Context) {
+	var request retryRulesTestRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	apiErr := &types.OpenAIErrorWithStatusCode{
+		OpenAIError: types.OpenAIError{
+			Message: request.Message,
+			Type:    request.Type,
+			Code:    request.Code,
+		},
+		StatusCode: request.StatusCode,
+	}
+
+	var index, disableIndex int
+	var rule, disableRule *common.RetryRule
+	if request.Rules != "" {
+		rules, err := common.ParseRetryRules(request.Rules)
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+		index, rule = common.MatchRetryRuleIn(rules, request.ChannelType, apiErr)
+		disableIndex, disableRule = common.MatchDisableRuleIn(rules, request.ChannelType, apiErr)
+	} else {
+		index, rule = common.MatchRetryRule(request.ChannelType, apiErr)
+		disableIndex, disableRule = common.MatchDisableRule(request.ChannelType, apiErr)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"index":         index,
+			"rule":          rule,
+			"disable_index": disableIndex,
+			"disable_rule":  disableRule,
+		},
+	})
+}
@@ -156,12 +156,24 @@ func PaymentCallback(c *gin.Context) {
 
 	order.GatewayNo = payNotify.GatewayNo
 	order.Status = model.OrderStatusSuccess
+	if order.PlanId > 0 {
+		// 订阅订单先标记为已支付，开通失败时由定时任务重试
+		order.Status = model.OrderStatusPaid
+		order.RecurringToken = payNotify.RecurringToken
+	}
 	err = order.Update()
 	if err != nil {
 		logger.SysError(fmt.Sprintf("gateway callback failed to update order, trade_no: %s,", payNotify.TradeNo))
 		return
 	}
 
+	if order.PlanId > 0 {
+		if err := model.FulfillSubscriptionOrder(order); err != nil {
+			logger.SysError(fmt.Sprintf("gateway callback failed to fulfill subscription order, trade_no: %s, error: %s", payNotify.TradeNo, err.Error()))
+		}
+		return
+	}
+
 	err = model.IncreaseUserQuota(order.UserId, order.Quota)
 	if err != nil {
 		logger.SysError(fmt.Sprintf("gateway callback failed to increase user quota, trade_no: %s,", payNotify.TradeNo))
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/relay/relay_util"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetPriceContracts(c *gin.Context) {
+	var params model.SearchPriceContractParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	contracts, err := model.GetPriceContractsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    contracts,
+	})
+}
+
+func GetPriceContractById(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	contract, err := model.GetPriceContractById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    contract,
+	})
+}
+
+func AddPriceContract(c *gin.Context) {
+	contract := model.PriceContract{}
+	if err := c.ShouldBindJSON(&contract); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := contract.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	contract.CreatedAt = utils.GetTimestamp()
+	if err := contract.Create(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func UpdatePriceContract(c *gin.Context) {
+	contract := model.PriceContract{}
+	if err := c.ShouldBindJSON(&contract); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := contract.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := contract.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeletePriceContract(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	contract, err := model.GetPriceContractById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := contract.Delete(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func ChangePriceContractEnable(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	contract, err := model.GetPriceContractById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.ChangePriceContractEnable(id, !*contract.Enable); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type UserPriceContract struct {
+	Id      int     `json:"id"`
+	TokenId int     `json:"token_id"`
+	Model   string  `json:"model"`
+	Type    string  `json:"type"`
+	Input   float64 `json:"input"`
+	Output  float64 `json:"output"`
+	Ratio   float64 `json:"ratio"`
+	// 约定后的实际价格，模糊匹配的约定没有
+	Price *model.Price `json:"price,omitempty"`
+}
+
+// GetUserPriceContracts 用户查看自己的约定价格
+func GetUserPriceContracts(c *gin.Context) {
+	contracts := model.GlobalPriceContracts.GetUserContracts(c.GetInt("id"))
+
+	data := make([]*UserPriceContract, 0, len(contracts))
+	for _, contract := range contracts {
+		item := &UserPriceContract{
+			Id:      contract.Id,
+			TokenId: contract.TokenId,
+			Model:   contract.Model,
+			Type:    contract.Type,
+			Input:   contract.Input,
+			Output:  contract.Output,
+			Ratio:   contract.Ratio,
+		}
+		if !strings.HasSuffix(contract.Model, "*") {
+			item.Price = contract.Apply(relay_util.PricingInstance.GetRawPrice(contract.Model))
+		}
+		data = append(data, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/utils"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetPriceModifiers(c *gin.Context) {
+	var params model.SearchPriceModifierParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	modifiers, err := model.GetPriceModifiersList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    modifiers,
+	})
+}
+
+func GetPriceModifierById(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	modifier, err := model.GetPriceModifierById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    modifier,
+	})
+}
+
+func AddPriceModifier(c *gin.Context) {
+	modifier := model.PriceModifier{}
+	if err := c.ShouldBindJSON(&modifier); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := modifier.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	modifier.CreatedAt = utils.GetTimestamp()
+	if err := modifier.Create(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func UpdatePriceModifier(c *gin.Context) {
+	modifier := model.PriceModifier{}
+	if err := c.ShouldBindJSON(&modifier); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := modifier.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := modifier.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeletePriceModifier(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	modifier, err := model.GetPriceModifierById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := modifier.Delete(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func ChangePriceModifierEnable(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	modifier, err := model.GetPriceModifierById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.ChangePriceModifierEnable(id, !*modifier.Enable); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
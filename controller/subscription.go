@@ -0,0 +1,326 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"one-api/common"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/payment"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetSubscriptionPlans(c *gin.Context) {
+	var params model.SearchSubscriptionPlanParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	plans, err := model.GetSubscriptionPlansList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+func GetSubscriptionPlanById(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	plan, err := model.GetSubscriptionPlanById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func AddSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := plan.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	plan.CreatedAt = utils.GetTimestamp()
+	if err := plan.Create(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func UpdateSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := plan.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := plan.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeleteSubscriptionPlan(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	plan, err := model.GetSubscriptionPlanById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := plan.Delete(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func ChangeSubscriptionPlanEnable(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	plan, err := model.GetSubscriptionPlanById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.ChangeSubscriptionPlanEnable(id, !*plan.Enable); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetUserSubscriptions(c *gin.Context) {
+	var params model.SearchUserSubscriptionParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	subscriptions, err := model.GetUserSubscriptionsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    subscriptions,
+	})
+}
+
+// ExpireUserSubscription 管理员立即结束用户的订阅
+func ExpireUserSubscription(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	subscription, err := model.GetUserSubscriptionById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if subscription.Status != model.SubscriptionStatusActive {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("订阅已到期"))
+		return
+	}
+
+	plan, err := model.GetSubscriptionPlanById(subscription.PlanId)
+	if err != nil {
+		plan = &model.SubscriptionPlan{Id: subscription.PlanId}
+	}
+
+	if err := subscription.ExpireEarly(plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetAvailableSubscriptionPlans 用户可以订阅的套餐
+func GetAvailableSubscriptionPlans(c *gin.Context) {
+	plans, err := model.GetEnabledSubscriptionPlans()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+// GetSelfSubscription 用户当前生效中的订阅，没有订阅时 data 为空
+func GetSelfSubscription(c *gin.Context) {
+	subscription, err := model.GetUserActiveSubscription(c.GetInt("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"message": "",
+				"data":    nil,
+			})
+			return
+		}
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if plan, err := model.GetSubscriptionPlanById(subscription.PlanId); err == nil {
+		subscription.Plan = plan
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    subscription,
+	})
+}
+
+// CancelSubscriptionAutoRenew 用户关闭自动续费，订阅在到期时间后结束
+func CancelSubscriptionAutoRenew(c *gin.Context) {
+	subscription, err := model.GetUserActiveSubscription(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("没有生效中的订阅"))
+		return
+	}
+
+	if err := model.DisableSubscriptionAutoRenew(subscription.Id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type SubscriptionOrderRequest struct {
+	UUID      string `json:"uuid" binding:"required"`
+	PlanId    int    `json:"plan_id" binding:"required"`
+	AutoRenew bool   `json:"auto_renew"`
+}
+
+// CreateSubscriptionOrder 订阅或续费套餐，已订阅同一个套餐时支付成功后延长一个周期
+func CreateSubscriptionOrder(c *gin.Context) {
+	var orderReq SubscriptionOrderRequest
+	if err := c.ShouldBindJSON(&orderReq); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	plan, err := model.GetSubscriptionPlanById(orderReq.PlanId)
+	if err != nil || plan.Enable == nil || !*plan.Enable {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("套餐不存在"))
+		return
+	}
+
+	userId := c.GetInt("id")
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("用户不存在"))
+		return
+	}
+
+	if subscription, err := model.GetUserActiveSubscription(userId); err == nil && subscription.PlanId != plan.Id {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("已有其他套餐的订阅，请到期后再订阅"))
+		return
+	}
+
+	// 关闭用户未完成的订单
+	go model.CloseUnfinishedOrder()
+
+	paymentService, err := payment.NewPaymentService(orderReq.UUID)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	fee, payMoney := payment.CalculatePlanAmount(paymentService.Payment, plan.Price)
+	tradeNo := utils.GenerateTradeNo()
+	payRequest, err := paymentService.PaySubscription(tradeNo, payMoney, user, orderReq.AutoRenew)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("创建支付失败，请稍后再试"))
+		return
+	}
+
+	order := &model.Order{
+		UserId:        userId,
+		GatewayId:     paymentService.Payment.ID,
+		TradeNo:       tradeNo,
+		OrderAmount:   payMoney,
+		OrderCurrency: paymentService.Payment.Currency,
+		Fee:           fee,
+		Status:        model.OrderStatusPending,
+		Quota:         plan.Quota,
+		PlanId:        plan.Id,
+		AutoRenew:     orderReq.AutoRenew && paymentService.SupportRecurring(),
+	}
+
+	if err := order.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("创建订单失败，请稍后再试"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": &OrderResponse{
+			TradeNo:    tradeNo,
+			PayRequest: payRequest,
+		},
+	})
+}
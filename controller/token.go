@@ -107,6 +107,13 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if _, err := common.ParseModelFallbacks(token.ModelFallbacks); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "模型降级链格式错误：" + err.Error(),
+		})
+		return
+	}
 
 	if token.Group != "" && model.GlobalUserGroupRatio.GetBySymbol(token.Group) == nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -117,16 +124,21 @@ func AddToken(c *gin.Context) {
 	}
 
 	cleanToken := model.Token{
-		UserId:         c.GetInt("id"),
-		Name:           token.Name,
-		Key:            utils.GenerateKey(),
-		CreatedTime:    utils.GetTimestamp(),
-		AccessedTime:   utils.GetTimestamp(),
-		ExpiredTime:    token.ExpiredTime,
-		RemainQuota:    token.RemainQuota,
-		UnlimitedQuota: token.UnlimitedQuota,
-		ChatCache:      token.ChatCache,
-		Group:          token.Group,
+		UserId:             c.GetInt("id"),
+		Name:               token.Name,
+		Key:                utils.GenerateKey(),
+		CreatedTime:        utils.GetTimestamp(),
+		AccessedTime:       utils.GetTimestamp(),
+		ExpiredTime:        token.ExpiredTime,
+		RemainQuota:        token.RemainQuota,
+		UnlimitedQuota:     token.UnlimitedQuota,
+		ChatCache:          token.ChatCache,
+		ChatCacheThreshold: token.ChatCacheThreshold,
+		Group:              token.Group,
+		RPM:                token.RPM,
+		TPM:                token.TPM,
+		MaxConcurrency:     token.MaxConcurrency,
+		ModelFallbacks:     token.ModelFallbacks,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -178,6 +190,13 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if _, err := common.ParseModelFallbacks(token.ModelFallbacks); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "模型降级链格式错误：" + err.Error(),
+		})
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -220,7 +239,12 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.RemainQuota = token.RemainQuota
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.ChatCache = token.ChatCache
+		cleanToken.ChatCacheThreshold = token.ChatCacheThreshold
 		cleanToken.Group = token.Group
+		cleanToken.RPM = token.RPM
+		cleanToken.TPM = token.TPM
+		cleanToken.MaxConcurrency = token.MaxConcurrency
+		cleanToken.ModelFallbacks = token.ModelFallbacks
 	}
 	err = cleanToken.Update()
 	if err != nil {
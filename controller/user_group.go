@@ -53,6 +53,11 @@ func AddUserGroup(c *gin.Context) {
 		return
 	}
 
+	if userGroup.BalanceStrategy != "" && !model.IsBalanceStrategy(userGroup.BalanceStrategy) {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的负载均衡策略"))
+		return
+	}
+
 	if err := userGroup.Create(); err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
@@ -72,6 +77,11 @@ func UpdateUserGroup(c *gin.Context) {
 		return
 	}
 
+	if userGroup.BalanceStrategy != "" && !model.IsBalanceStrategy(userGroup.BalanceStrategy) {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的负载均衡策略"))
+		return
+	}
+
 	if err := userGroup.Update(); err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
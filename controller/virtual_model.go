@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/utils"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetVirtualModels(c *gin.Context) {
+	var params model.SearchVirtualModelParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	virtualModels, err := model.GetVirtualModelsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    virtualModels,
+	})
+}
+
+func GetVirtualModelById(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	virtualModel, err := model.GetVirtualModelById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    virtualModel,
+	})
+}
+
+func AddVirtualModel(c *gin.Context) {
+	virtualModel := model.VirtualModel{}
+	if err := c.ShouldBindJSON(&virtualModel); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := virtualModel.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	virtualModel.CreatedAt = utils.GetTimestamp()
+	if err := virtualModel.Create(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func UpdateVirtualModel(c *gin.Context) {
+	virtualModel := model.VirtualModel{}
+	if err := c.ShouldBindJSON(&virtualModel); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := virtualModel.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := virtualModel.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeleteVirtualModel(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	virtualModel, err := model.GetVirtualModelById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := virtualModel.Delete(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func ChangeVirtualModelEnable(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	virtualModel, err := model.GetVirtualModelById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.ChangeVirtualModelEnable(id, !*virtualModel.Enable); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/model"
+	"one-api/payment"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
@@ -30,6 +31,7 @@ func InitCron() {
 			)),
 		gocron.NewTask(func() {
 			model.RemoveChatCache()
+			model.RemoveResponseState()
 			logger.SysLog("删除过期缓存数据")
 		}),
 	)
@@ -70,5 +72,38 @@ func InitCron() {
 		return
 	}
 
+	// 每小时处理订阅续费和到期，先续费再处理周期，避免已续费的订阅被结束
+	_, err = scheduler.NewJob(
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(func() {
+			payment.RenewSubscriptions()
+			model.ProcessSubscriptionPeriods()
+			logger.SysLog("处理订阅续费和到期")
+		}),
+	)
+
+	if err != nil {
+		logger.SysError("Cron job error: " + err.Error())
+		return
+	}
+
+	// 每天提醒即将到期且不能自动续费的订阅
+	_, err = scheduler.NewJob(
+		gocron.DailyJob(
+			1,
+			gocron.NewAtTimes(
+				gocron.NewAtTime(10, 0, 0),
+			)),
+		gocron.NewTask(func() {
+			model.RemindExpiringSubscriptions()
+			logger.SysLog("发送订阅到期提醒")
+		}),
+	)
+
+	if err != nil {
+		logger.SysError("Cron job error: " + err.Error())
+		return
+	}
+
 	scheduler.Start()
 }
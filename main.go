@@ -55,6 +55,7 @@ func main() {
 	// Initialize oidc
 	oidc.InitOIDCConfig()
 	relay_util.NewPricing()
+	relay_util.NewModelInfos()
 	initMemoryCache()
 	initSync()
 
@@ -63,11 +64,11 @@ func main() {
 	// Initialize Telegram bot
 	telegram.InitTelegramBot()
 
+	storage.InitStorage()
 	controller.InitMidjourneyTask()
 	task.InitTask()
 	notify.InitNotifier()
 	cron.InitCron()
-	storage.InitStorage()
 
 	initHttpServer()
 }
@@ -93,6 +94,7 @@ func initMemoryCache() {
 func initSync() {
 	// go controller.AutomaticallyUpdateChannels(viper.GetInt("channel.update_frequency"))
 	go controller.AutomaticallyTestChannels(viper.GetInt("channel.test_frequency"))
+	go model.SyncCircuitBreakers(5)
 }
 
 func initHttpServer() {
@@ -132,6 +134,11 @@ func SyncChannelCache(frequency int) {
 		time.Sleep(time.Duration(frequency) * time.Second)
 		logger.SysLog("syncing channels from database")
 		model.ChannelGroup.Load()
+		model.ChannelKeys.Load()
+		model.GlobalVirtualModels.Load()
+		model.GlobalPriceModifiers.Load()
+		model.GlobalPriceContracts.Load()
 		relay_util.PricingInstance.Init()
+		relay_util.ModelInfoInstance.Init()
 	}
 }
@@ -14,6 +14,10 @@ var (
 	httpRequestDuration *prometheus.HistogramVec
 	providerCounter     *prometheus.CounterVec
 	panicCounter        *prometheus.CounterVec
+	breakerState        *prometheus.GaugeVec
+	breakerTransitions  *prometheus.CounterVec
+	queueDepth          prometheus.Gauge
+	queueWaitDuration   *prometheus.HistogramVec
 )
 
 func init() {
@@ -52,6 +56,38 @@ func init() {
 		[]string{"type"},
 	)
 
+	// 4. 监控熔断器，状态 0 关闭 1 半开 2 打开，model 为空表示整个渠道
+	breakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "channel_circuit_breaker_state",
+			Help: "Current circuit breaker state of channels.",
+		},
+		[]string{"channel_id", "model"},
+	)
+	breakerTransitions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "channel_circuit_breaker_transitions_total",
+			Help: "Total number of circuit breaker state transitions.",
+		},
+		[]string{"channel_id", "model", "state"},
+	)
+
+	// 5. 监控等待渠道名额的队列
+	queueDepth = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "channel_queue_depth",
+			Help: "Number of requests waiting for a channel slot.",
+		},
+	)
+	queueWaitDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "channel_queue_wait_seconds",
+			Help:    "Time requests spent waiting for a channel slot.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"result"},
+	)
+
 }
 
 // 记录 HTTP 请求
@@ -94,6 +130,29 @@ func RecordProvider(c *gin.Context, statusCode int) {
 	})
 }
 
+// 记录熔断器状态变化
+func RecordBreakerState(channelId int, model string, state string, value int) {
+	go SafelyRecordMetric(func() {
+		id := strconv.Itoa(channelId)
+		breakerState.WithLabelValues(id, model).Set(float64(value))
+		breakerTransitions.WithLabelValues(id, model, state).Inc()
+	})
+}
+
+// 记录排队的请求数，在队列的锁内同步调用，保证顺序
+func RecordChannelQueueDepth(depth int) {
+	SafelyRecordMetric(func() {
+		queueDepth.Set(float64(depth))
+	})
+}
+
+// 记录排队时间，result 为 acquired、timeout、canceled 或 full
+func RecordChannelQueueWait(duration time.Duration, result string) {
+	go SafelyRecordMetric(func() {
+		queueWaitDuration.WithLabelValues(result).Observe(duration.Seconds())
+	})
+}
+
 // 记录 panic
 func RecordPanic(panicType string) {
 	panicCounter.WithLabelValues(panicType).Inc()
@@ -114,6 +114,9 @@ func tokenAuth(c *gin.Context, key string) {
 	c.Set("token_name", token.Name)
 	c.Set("token_group", token.Group)
 	c.Set("chat_cache", token.ChatCache)
+	c.Set("chat_cache_threshold", token.ChatCacheThreshold)
+	c.Set("token_rate_limit", model.TokenRateLimitScope(token))
+	c.Set("token_model_fallbacks", token.ModelFallbacks)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			if strings.HasPrefix(parts[1], "!") {
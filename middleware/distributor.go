@@ -3,6 +3,7 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"one-api/common/utils"
 	"one-api/model"
 
 	"github.com/gin-gonic/gin"
@@ -28,5 +29,10 @@ func Distribute() func(c *gin.Context) {
 
 		c.Set("group_ratio", groupRatio.Ratio)
 		c.Next()
+
+		// 请求结束后兜底归还渠道的并发名额
+		if permit, ok := utils.GetGinValue[*model.ChannelPermit](c, "channel_permit"); ok {
+			permit.Release()
+		}
 	}
 }
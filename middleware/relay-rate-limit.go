@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+	"one-api/common/utils"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RelayRateLimit 只用于调用模型的接口，需要在 Distribute 之后使用
+func RelayRateLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// 分组已经在 Distribute 中检查过
+		userGroup := model.GlobalUserGroupRatio.GetBySymbol(c.GetString("token_group"))
+		if userGroup != nil && !relayRateLimit(c, userGroup) {
+			return
+		}
+		c.Next()
+
+		// 请求结束后兜底归还令牌和分组的并发名额
+		if result, ok := utils.GetGinValue[*model.RateLimitResult](c, "rate_limit_result"); ok {
+			result.Release()
+		}
+	}
+}
+
+// relayRateLimit 检查令牌和分组的 RPM、TPM 和并发数限制，并返回 OpenAI 格式的 x-ratelimit-* 响应头
+func relayRateLimit(c *gin.Context, userGroup *model.UserGroup) bool {
+	scopes := make([]*model.RateLimitScope, 0, 2)
+	if scope, ok := utils.GetGinValue[*model.RateLimitScope](c, "token_rate_limit"); ok && scope != nil {
+		scopes = append(scopes, scope)
+	}
+	if scope := model.GroupRateLimitScope(userGroup, c.GetInt("id")); scope != nil {
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return true
+	}
+
+	result := model.RateLimiter.Acquire(scopes)
+	setRateLimitHeaders(c, result)
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.Itoa(int(result.ResetAfter.Seconds())))
+		abortWithMessage(c, http.StatusTooManyRequests, "请求过于频繁，请稍后再试")
+		return false
+	}
+
+	c.Set("rate_limit_scopes", scopes)
+	c.Set("rate_limit_result", result)
+	return true
+}
+
+// setRateLimitHeaders 多个限制同时生效时，返回剩余量最少的一个
+func setRateLimitHeaders(c *gin.Context, result *model.RateLimitResult) {
+	requestLimit, requestRemaining := -1, 0
+	tokenLimit, tokenRemaining := -1, 0
+	for _, usage := range result.Usages {
+		if usage.Scope.RPM > 0 {
+			remaining := usage.Scope.RPM - usage.Requests
+			if result.Allowed {
+				remaining--
+			}
+			if requestLimit < 0 || remaining < requestRemaining {
+				requestLimit, requestRemaining = usage.Scope.RPM, max(remaining, 0)
+			}
+		}
+		if usage.Scope.TPM > 0 {
+			remaining := usage.Scope.TPM - usage.Tokens
+			if tokenLimit < 0 || remaining < tokenRemaining {
+				tokenLimit, tokenRemaining = usage.Scope.TPM, max(remaining, 0)
+			}
+		}
+	}
+
+	reset := result.ResetAfter.Round(time.Second).String()
+	if requestLimit >= 0 {
+		c.Header("x-ratelimit-limit-requests", strconv.Itoa(requestLimit))
+		c.Header("x-ratelimit-remaining-requests", strconv.Itoa(requestRemaining))
+		c.Header("x-ratelimit-reset-requests", reset)
+	}
+	if tokenLimit >= 0 {
+		c.Header("x-ratelimit-limit-tokens", strconv.Itoa(tokenLimit))
+		c.Header("x-ratelimit-remaining-tokens", strconv.Itoa(tokenRemaining))
+		c.Header("x-ratelimit-reset-tokens", reset)
+	}
+}
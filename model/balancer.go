@@ -2,10 +2,11 @@ package model
 
 import (
 	"errors"
-	"math/rand"
+	"fmt"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/utils"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +16,7 @@ type ChannelChoice struct {
 	Channel       *Channel
 	CooldownsTime int64
 	Disable       bool
+	CostRatios    map[string]float64
 }
 
 type ChannelsChooser struct {
@@ -38,8 +40,19 @@ func FilterOnlyChat() ChannelsFilterFunc {
 	}
 }
 
-func (cc *ChannelsChooser) Cooldowns(channelId int) bool {
-	if config.RetryCooldownSeconds == 0 {
+// FilterChannelTypes 跳过不属于指定类型的渠道
+func FilterChannelTypes(channelTypes []int) ChannelsFilterFunc {
+	return func(_ int, choice *ChannelChoice) bool {
+		return !utils.Contains(choice.Channel.Type, channelTypes)
+	}
+}
+
+// Cooldowns 冷却渠道，seconds 为 0 时使用 RetryCooldownSeconds
+func (cc *ChannelsChooser) Cooldowns(channelId int, seconds int) bool {
+	if seconds <= 0 {
+		seconds = config.RetryCooldownSeconds
+	}
+	if seconds <= 0 {
 		return false
 	}
 	cc.Lock()
@@ -48,7 +61,7 @@ func (cc *ChannelsChooser) Cooldowns(channelId int) bool {
 		return false
 	}
 
-	cc.Channels[channelId].CooldownsTime = time.Now().Unix() + int64(config.RetryCooldownSeconds)
+	cc.Channels[channelId].CooldownsTime = time.Now().Unix() + int64(seconds)
 	return true
 }
 
@@ -70,6 +83,7 @@ func (cc *ChannelsChooser) Enable(channelId int) {
 	}
 
 	cc.Channels[channelId].Disable = false
+	channelBreakers.Reset(channelId)
 }
 
 func (cc *ChannelsChooser) ChangeStatus(channelId int, status bool) {
@@ -80,14 +94,47 @@ func (cc *ChannelsChooser) ChangeStatus(channelId int, status bool) {
 	}
 }
 
-func (cc *ChannelsChooser) balancer(channelIds []int, filters []ChannelsFilterFunc) *Channel {
+// RecordResult 将请求结果计入渠道及渠道下模型的熔断器和负载均衡统计
+func (cc *ChannelsChooser) RecordResult(channelId int, modelName string, success bool) {
+	channelBreakers.Record(channelId, modelName, success)
+	channelBalanceStats.RecordResult(channelId, modelName, success)
+}
+
+// StartRequest 和 FinishRequest 记录渠道进行中的请求数
+func (cc *ChannelsChooser) StartRequest(channelId int) {
+	channelBalanceStats.Start(channelId)
+}
+
+func (cc *ChannelsChooser) FinishRequest(channelId int) {
+	channelBalanceStats.Finish(channelId)
+}
+
+// RecordLatency 记录渠道在模型上的首字延迟
+func (cc *ChannelsChooser) RecordLatency(channelId int, modelName string, latency time.Duration) {
+	channelBalanceStats.RecordLatency(channelId, modelName, latency)
+}
+
+func (cc *ChannelsChooser) GetBalanceStats(channelId int) []*BalanceStatus {
+	return channelBalanceStats.Status(channelId)
+}
+
+func (cc *ChannelsChooser) GetBreakers(channelId int) []*BreakerStatus {
+	return channelBreakers.Status(channelId)
+}
+
+// channelAvailable 渠道没有被禁用、冷却或熔断，并且还有可用的 key
+func channelAvailable(choice *ChannelChoice, modelName string, nowTime int64) bool {
+	channelId := choice.Channel.Id
+	return !choice.Disable && choice.CooldownsTime < nowTime && channelBreakers.Allow(channelId, modelName) && ChannelKeys.Available(channelId)
+}
+
+func (cc *ChannelsChooser) balancer(channelIds []int, modelName, strategy string, filters []ChannelsFilterFunc) *Channel {
 	nowTime := time.Now().Unix()
-	totalWeight := 0
 
 	validChannels := make([]*ChannelChoice, 0, len(channelIds))
 	for _, channelId := range channelIds {
 		choice, ok := cc.Channels[channelId]
-		if !ok || choice.Disable || choice.CooldownsTime >= nowTime {
+		if !ok || !channelAvailable(choice, modelName, nowTime) {
 			continue
 		}
 
@@ -102,8 +149,6 @@ func (cc *ChannelsChooser) balancer(channelIds []int, filters []ChannelsFilterFu
 			continue
 		}
 
-		weight := int(*choice.Channel.Weight)
-		totalWeight += weight
 		validChannels = append(validChannels, choice)
 	}
 
@@ -111,25 +156,15 @@ func (cc *ChannelsChooser) balancer(channelIds []int, filters []ChannelsFilterFu
 		return nil
 	}
 
-	if len(validChannels) == 1 {
-		return validChannels[0].Channel
+	channel := validChannels[0].Channel
+	if len(validChannels) > 1 {
+		channel = channelBalanceStats.choose(strategy, validChannels, modelName).Channel
 	}
 
-	choiceWeight := rand.Intn(totalWeight)
-	for _, choice := range validChannels {
-		weight := int(*choice.Channel.Weight)
-		choiceWeight -= weight
-		if choiceWeight < 0 {
-			return choice.Channel
-		}
-	}
-
-	return nil
+	return channel
 }
 
-func (cc *ChannelsChooser) Next(group, modelName string, filters ...ChannelsFilterFunc) (*Channel, error) {
-	cc.RLock()
-	defer cc.RUnlock()
+func (cc *ChannelsChooser) getChannelsPriority(group, modelName string) ([][]int, error) {
 	if _, ok := cc.Rule[group]; !ok {
 		return nil, errors.New("group not found")
 	}
@@ -143,12 +178,24 @@ func (cc *ChannelsChooser) Next(group, modelName string, filters ...ChannelsFilt
 		}
 	}
 
+	return channelsPriority, nil
+}
+
+func (cc *ChannelsChooser) Next(group, modelName string, filters ...ChannelsFilterFunc) (*Channel, error) {
+	cc.RLock()
+	defer cc.RUnlock()
+	channelsPriority, err := cc.getChannelsPriority(group, modelName)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(channelsPriority) == 0 {
 		return nil, errors.New("channel not found")
 	}
 
+	strategy := groupBalanceStrategy(group)
 	for _, priority := range channelsPriority {
-		channel := cc.balancer(priority, filters)
+		channel := cc.balancer(priority, modelName, strategy, filters)
 		if channel != nil {
 			return channel, nil
 		}
@@ -157,6 +204,73 @@ func (cc *ChannelsChooser) Next(group, modelName string, filters ...ChannelsFilt
 	return nil, errors.New("channel not found")
 }
 
+// BalanceChannel 渠道在负载均衡中的当前状态，不可用的渠道有效权重为 0
+type BalanceChannel struct {
+	Id              int     `json:"id"`
+	Name            string  `json:"name"`
+	Weight          uint    `json:"weight"`
+	EffectiveWeight float64 `json:"effective_weight"`
+	CostRatio       float64 `json:"cost_ratio"`
+	InFlight        int     `json:"in_flight"`
+	Available       bool    `json:"available"`
+}
+
+type BalanceInfo struct {
+	Group      string              `json:"group"`
+	Model      string              `json:"model"`
+	Strategy   string              `json:"strategy"`
+	Priorities [][]*BalanceChannel `json:"priorities"`
+}
+
+// GetBalance 查看分组下模型每个优先级中渠道的有效权重
+func (cc *ChannelsChooser) GetBalance(group, modelName string) (*BalanceInfo, error) {
+	cc.RLock()
+	defer cc.RUnlock()
+	channelsPriority, err := cc.getChannelsPriority(group, modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	nowTime := time.Now().Unix()
+	info := &BalanceInfo{
+		Group:      group,
+		Model:      modelName,
+		Strategy:   groupBalanceStrategy(group),
+		Priorities: make([][]*BalanceChannel, 0, len(channelsPriority)),
+	}
+	for _, priority := range channelsPriority {
+		channels := make([]*BalanceChannel, 0, len(priority))
+		validChannels := make([]*ChannelChoice, 0, len(priority))
+		validIndexes := make([]int, 0, len(priority))
+		for _, channelId := range priority {
+			choice, ok := cc.Channels[channelId]
+			if !ok {
+				continue
+			}
+			available := channelAvailable(choice, modelName, nowTime)
+			if available {
+				validChannels = append(validChannels, choice)
+				validIndexes = append(validIndexes, len(channels))
+			}
+			channels = append(channels, &BalanceChannel{
+				Id:        channelId,
+				Name:      choice.Channel.Name,
+				Weight:    *choice.Channel.Weight,
+				CostRatio: getUpstreamCostRatio(choice.CostRatios, modelName),
+				InFlight:  channelBalanceStats.getInFlight(channelId),
+				Available: available,
+			})
+		}
+
+		for i, weight := range channelBalanceStats.strategyWeights(info.Strategy, validChannels, modelName) {
+			channels[validIndexes[i]].EffectiveWeight = weight
+		}
+		info.Priorities = append(info.Priorities, channels)
+	}
+
+	return info, nil
+}
+
 func (cc *ChannelsChooser) GetGroupModels(group string) ([]string, error) {
 	cc.RLock()
 	defer cc.RUnlock()
@@ -173,6 +287,81 @@ func (cc *ChannelsChooser) GetGroupModels(group string) ([]string, error) {
 	return models, nil
 }
 
+// GetGroupModelByChannelTypes 返回分组下任一由指定类型渠道提供的模型，没有时返回空字符串
+func (cc *ChannelsChooser) GetGroupModelByChannelTypes(group string, channelTypes []int) string {
+	cc.RLock()
+	defer cc.RUnlock()
+
+	models := make([]string, 0, len(cc.Rule[group]))
+	for modelName := range cc.Rule[group] {
+		// 通配的模型不能直接用于选择渠道
+		if !strings.HasSuffix(modelName, "*") {
+			models = append(models, modelName)
+		}
+	}
+	sort.Strings(models)
+
+	for _, modelName := range models {
+		for _, priority := range cc.Rule[group][modelName] {
+			for _, channelId := range priority {
+				if choice, ok := cc.Channels[channelId]; ok && utils.Contains(choice.Channel.Type, channelTypes) {
+					return modelName
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// Acquire 选择渠道并占用并发名额，达到限制的渠道会被跳过，所有可用渠道都达到限制时返回 ErrChannelsSaturated
+// 占用并发名额后再占用熔断的探测名额，探测名额已被占用时归还并发名额并跳过该渠道
+func (cc *ChannelsChooser) Acquire(group, modelName string, filters ...ChannelsFilterFunc) (*Channel, *ChannelPermit, error) {
+	saturatedIds := make([]int, 0)
+	skipIds := make([]int, 0)
+	for {
+		channel, err := cc.Next(group, modelName, append(filters, FilterChannelId(skipIds))...)
+		if err != nil {
+			if len(saturatedIds) > 0 {
+				return nil, nil, ErrChannelsSaturated
+			}
+			return channel, nil, err
+		}
+
+		permit, ok := channelLimiters.TryAcquire(channel)
+		if !ok {
+			saturatedIds = append(saturatedIds, channel.Id)
+			skipIds = append(skipIds, channel.Id)
+			continue
+		}
+		if !channelBreakers.Acquire(channel.Id, modelName) {
+			permit.Release()
+			skipIds = append(skipIds, channel.Id)
+			continue
+		}
+		return channel, permit, nil
+	}
+}
+
+// RecordTokens 记录渠道消耗的 token 数，用于 TPM 限制
+func (cc *ChannelsChooser) RecordTokens(channelId int, tokens int) {
+	if channel := cc.GetChannel(channelId); channel != nil {
+		channelLimiters.RecordTokens(channel, tokens)
+	}
+}
+
+// GetCostRatio 渠道在模型上的上游成本比例，渠道不存在时按售价计算
+func (cc *ChannelsChooser) GetCostRatio(channelId int, modelName string) float64 {
+	cc.RLock()
+	defer cc.RUnlock()
+
+	if choice, ok := cc.Channels[channelId]; ok {
+		return getUpstreamCostRatio(choice.CostRatios, modelName)
+	}
+
+	return 1
+}
+
 func (cc *ChannelsChooser) GetChannel(channelId int) *Channel {
 	cc.RLock()
 	defer cc.RUnlock()
@@ -204,10 +393,15 @@ func (cc *ChannelsChooser) Load() {
 		if *channel.Weight == 0 {
 			channel.Weight = &config.DefaultChannelWeight
 		}
+		costRatios, err := ParseUpstreamCost(channel.GetUpstreamCost())
+		if err != nil {
+			logger.SysError(fmt.Sprintf("channel #%d upstream cost is invalid: %s", channel.Id, err.Error()))
+		}
 		newChannels[channel.Id] = &ChannelChoice{
 			Channel:       channel,
 			CooldownsTime: 0,
 			Disable:       false,
+			CostRatios:    costRatios,
 		}
 	}
 
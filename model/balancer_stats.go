@@ -0,0 +1,274 @@
+package model
+
+import (
+	"math/rand"
+	"one-api/common/config"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BalanceStrategyWeight        = "weight"         // 按静态权重随机
+	BalanceStrategyLatency       = "latency"        // 按延迟和成功率调整后的有效权重随机
+	BalanceStrategyP2C           = "p2c"            // 按有效权重随机选两个，取负载较低的一个
+	BalanceStrategyLeastInFlight = "least_inflight" // 选择进行中请求数与权重之比最小的渠道
+	BalanceStrategyCost          = "cost"           // 选择上游成本最低的渠道，成本相同时按权重随机
+)
+
+var balanceStrategies = []string{
+	BalanceStrategyWeight,
+	BalanceStrategyLatency,
+	BalanceStrategyP2C,
+	BalanceStrategyLeastInFlight,
+	BalanceStrategyCost,
+}
+
+// EWMA 的平滑系数，越大越偏向最近的请求
+const balanceEWMAAlpha = 0.2
+
+// 有效权重的下限，保证表现较差的渠道仍有少量流量来更新统计
+const minEffectiveWeightRatio = 0.05
+
+func IsBalanceStrategy(strategy string) bool {
+	for _, s := range balanceStrategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+type channelModelStats struct {
+	latency float64 // 首字延迟的 EWMA，单位毫秒
+	success float64 // 成功率的 EWMA
+	samples int
+}
+
+// BalanceStatus 渠道在某个模型上的实时统计
+type BalanceStatus struct {
+	Model       string  `json:"model"`
+	Latency     float64 `json:"latency"`
+	SuccessRate float64 `json:"success_rate"`
+	Samples     int     `json:"samples"`
+}
+
+type balanceStats struct {
+	sync.RWMutex
+	models   map[string]*channelModelStats // channelId:model -> stats
+	inFlight map[int]int
+}
+
+var channelBalanceStats = &balanceStats{
+	models:   make(map[string]*channelModelStats),
+	inFlight: make(map[int]int),
+}
+
+func ewma(old, value float64) float64 {
+	return old + balanceEWMAAlpha*(value-old)
+}
+
+func (bs *balanceStats) get(channelId int, modelName string) *channelModelStats {
+	key := breakerKey(channelId, modelName)
+	stats, ok := bs.models[key]
+	if !ok {
+		stats = &channelModelStats{success: 1}
+		bs.models[key] = stats
+	}
+	return stats
+}
+
+func (bs *balanceStats) Start(channelId int) {
+	bs.Lock()
+	defer bs.Unlock()
+	bs.inFlight[channelId]++
+}
+
+func (bs *balanceStats) Finish(channelId int) {
+	bs.Lock()
+	defer bs.Unlock()
+	if bs.inFlight[channelId] > 0 {
+		bs.inFlight[channelId]--
+	}
+}
+
+func (bs *balanceStats) RecordLatency(channelId int, modelName string, latency time.Duration) {
+	bs.Lock()
+	defer bs.Unlock()
+	stats := bs.get(channelId, modelName)
+	value := float64(latency.Milliseconds())
+	if stats.latency == 0 {
+		stats.latency = value
+	} else {
+		stats.latency = ewma(stats.latency, value)
+	}
+}
+
+func (bs *balanceStats) RecordResult(channelId int, modelName string, success bool) {
+	bs.Lock()
+	defer bs.Unlock()
+	stats := bs.get(channelId, modelName)
+	value := 0.0
+	if success {
+		value = 1
+	}
+	stats.success = ewma(stats.success, value)
+	stats.samples++
+}
+
+func (bs *balanceStats) Status(channelId int) []*BalanceStatus {
+	bs.RLock()
+	defer bs.RUnlock()
+	prefix := strconv.Itoa(channelId) + ":"
+	statuses := make([]*BalanceStatus, 0)
+	for key, stats := range bs.models {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		statuses = append(statuses, &BalanceStatus{
+			Model:       strings.TrimPrefix(key, prefix),
+			Latency:     stats.latency,
+			SuccessRate: stats.success,
+			Samples:     stats.samples,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Model < statuses[j].Model
+	})
+	return statuses
+}
+
+// effectiveWeights 按成功率和相对于同级最快渠道的延迟调整静态权重，没有统计数据的渠道保持原权重
+func (bs *balanceStats) effectiveWeights(choices []*ChannelChoice, modelName string) []float64 {
+	bs.RLock()
+	defer bs.RUnlock()
+
+	bestLatency := 0.0
+	for _, choice := range choices {
+		if stats, ok := bs.models[breakerKey(choice.Channel.Id, modelName)]; ok && stats.latency > 0 {
+			if bestLatency == 0 || stats.latency < bestLatency {
+				bestLatency = stats.latency
+			}
+		}
+	}
+
+	weights := make([]float64, len(choices))
+	for i, choice := range choices {
+		weight := float64(*choice.Channel.Weight)
+		effective := weight
+		if stats, ok := bs.models[breakerKey(choice.Channel.Id, modelName)]; ok {
+			effective *= stats.success
+			if stats.latency > 0 && bestLatency > 0 {
+				effective *= bestLatency / stats.latency
+			}
+		}
+		weights[i] = max(effective, weight*minEffectiveWeightRatio)
+	}
+	return weights
+}
+
+func (bs *balanceStats) getInFlight(channelId int) int {
+	bs.RLock()
+	defer bs.RUnlock()
+	return bs.inFlight[channelId]
+}
+
+func weightedRandom(weights []float64) int {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+
+	choiceWeight := rand.Float64() * total
+	for i, weight := range weights {
+		choiceWeight -= weight
+		if choiceWeight < 0 {
+			return i
+		}
+	}
+
+	// 浮点误差导致没有选中时，取最后一个权重不为 0 的
+	for i := len(weights) - 1; i > 0; i-- {
+		if weights[i] > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// strategyWeights 策略实际使用的权重，latency 和 p2c 使用动态调整后的有效权重
+func (bs *balanceStats) strategyWeights(strategy string, choices []*ChannelChoice, modelName string) []float64 {
+	if strategy == BalanceStrategyLatency || strategy == BalanceStrategyP2C {
+		return bs.effectiveWeights(choices, modelName)
+	}
+
+	weights := make([]float64, len(choices))
+	for i, choice := range choices {
+		weights[i] = float64(*choice.Channel.Weight)
+	}
+
+	// 只保留成本最低的渠道，其他渠道在报错、冷却或熔断后才会被选中
+	if strategy == BalanceStrategyCost {
+		costs := make([]float64, len(choices))
+		minCost := -1.0
+		for i, choice := range choices {
+			costs[i] = getUpstreamCostRatio(choice.CostRatios, modelName)
+			if minCost < 0 || costs[i] < minCost {
+				minCost = costs[i]
+			}
+		}
+		for i := range weights {
+			if costs[i]-minCost > 1e-9 {
+				weights[i] = 0
+			}
+		}
+	}
+
+	return weights
+}
+
+// choose 按策略从同一优先级的可用渠道中选择一个
+func (bs *balanceStats) choose(strategy string, choices []*ChannelChoice, modelName string) *ChannelChoice {
+	weights := bs.strategyWeights(strategy, choices, modelName)
+	switch strategy {
+	case BalanceStrategyP2C:
+		first := weightedRandom(weights)
+		rest := make([]float64, len(weights))
+		copy(rest, weights)
+		rest[first] = 0
+		second := weightedRandom(rest)
+		// 进行中的请求越多、有效权重越低，负载越高
+		firstLoad := float64(bs.getInFlight(choices[first].Channel.Id)+1) / weights[first]
+		secondLoad := float64(bs.getInFlight(choices[second].Channel.Id)+1) / weights[second]
+		if secondLoad < firstLoad {
+			return choices[second]
+		}
+		return choices[first]
+	case BalanceStrategyLeastInFlight:
+		var best *ChannelChoice
+		bestLoad := 0.0
+		// 从随机位置开始遍历，负载相同时不会总是选中同一个渠道
+		offset := rand.Intn(len(choices))
+		for i := range choices {
+			choice := choices[(i+offset)%len(choices)]
+			load := float64(bs.getInFlight(choice.Channel.Id)) / weights[(i+offset)%len(choices)]
+			if best == nil || load < bestLoad {
+				best = choice
+				bestLoad = load
+			}
+		}
+		return best
+	}
+
+	return choices[weightedRandom(weights)]
+}
+
+// groupBalanceStrategy 分组设置的策略优先，否则使用全局设置
+func groupBalanceStrategy(group string) string {
+	if userGroup := GlobalUserGroupRatio.GetBySymbol(group); userGroup != nil && userGroup.BalanceStrategy != "" {
+		return userGroup.BalanceStrategy
+	}
+	return config.BalanceStrategy
+}
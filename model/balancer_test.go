@@ -0,0 +1,87 @@
+package model_test
+
+import (
+	"one-api/common/config"
+	"one-api/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCostChooser(channels ...*model.ChannelChoice) *model.ChannelsChooser {
+	chooser := &model.ChannelsChooser{
+		Channels: make(map[int]*model.ChannelChoice),
+		Rule:     map[string]map[string][][]int{"default": {"gpt-4o": {{}}}},
+	}
+	for _, choice := range channels {
+		chooser.Channels[choice.Channel.Id] = choice
+		chooser.Rule["default"]["gpt-4o"][0] = append(chooser.Rule["default"]["gpt-4o"][0], choice.Channel.Id)
+	}
+	return chooser
+}
+
+func newCostChoice(id int, cost float64, maxConcurrency int) *model.ChannelChoice {
+	weight := uint(1)
+	return &model.ChannelChoice{
+		Channel: &model.Channel{
+			Id:             id,
+			Weight:         &weight,
+			MaxConcurrency: maxConcurrency,
+		},
+		CostRatios: map[string]float64{"*": cost},
+	}
+}
+
+func TestCostStrategySpillOver(t *testing.T) {
+	strategy := config.BalanceStrategy
+	config.BalanceStrategy = model.BalanceStrategyCost
+	defer func() { config.BalanceStrategy = strategy }()
+
+	chooser := newCostChooser(
+		newCostChoice(9101, 0.5, 1),
+		newCostChoice(9102, 1, 0),
+		newCostChoice(9103, 2, 0),
+	)
+
+	// 最便宜的渠道有名额时总是选中
+	cheapest, permit, err := chooser.Acquire("default", "gpt-4o")
+	assert.Nil(t, err)
+	assert.Equal(t, 9101, cheapest.Id)
+
+	// 名额用完后溢出到剩余渠道中最便宜的一个，而不是随机选择
+	for i := 0; i < 10; i++ {
+		channel, spilled, err := chooser.Acquire("default", "gpt-4o")
+		assert.Nil(t, err)
+		assert.Equal(t, 9102, channel.Id)
+		spilled.Release()
+	}
+
+	// 名额归还后回到最便宜的渠道
+	permit.Release()
+	channel, permit, err := chooser.Acquire("default", "gpt-4o")
+	assert.Nil(t, err)
+	assert.Equal(t, 9101, channel.Id)
+	permit.Release()
+}
+
+func TestCostStrategySaturated(t *testing.T) {
+	strategy := config.BalanceStrategy
+	config.BalanceStrategy = model.BalanceStrategyCost
+	defer func() { config.BalanceStrategy = strategy }()
+
+	chooser := newCostChooser(
+		newCostChoice(9201, 0.5, 1),
+		newCostChoice(9202, 1, 1),
+	)
+
+	_, first, err := chooser.Acquire("default", "gpt-4o")
+	assert.Nil(t, err)
+	_, second, err := chooser.Acquire("default", "gpt-4o")
+	assert.Nil(t, err)
+
+	_, _, err = chooser.Acquire("default", "gpt-4o")
+	assert.ErrorIs(t, err, model.ErrChannelsSaturated)
+
+	first.Release()
+	second.Release()
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BatchResult 批处理每一行请求的执行结果，任务结束后合并为输出文件
+// 保存在数据库中，任务中断后由其他节点接管时可以跳过已经执行过的请求
+type BatchResult struct {
+	Id       int64          `json:"id"`
+	TaskId   int64          `json:"task_id" gorm:"uniqueIndex:idx_batch_result_custom_id"`
+	CustomId string         `json:"custom_id" gorm:"type:varchar(255);uniqueIndex:idx_batch_result_custom_id"`
+	IsError  bool           `json:"is_error" gorm:"default:false"`
+	Data     datatypes.JSON `json:"data"`
+}
+
+// InsertBatchResult 保存一行请求的结果，同一个请求已有结果时忽略
+func InsertBatchResult(result *BatchResult) error {
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(result).Error
+}
+
+// GetBatchFinished 返回已经执行过的 custom_id 及是否失败
+func GetBatchFinished(taskId int64) (map[string]bool, error) {
+	var results []*BatchResult
+	err := DB.Select("custom_id", "is_error").Where("task_id = ?", taskId).Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	finished := make(map[string]bool, len(results))
+	for _, result := range results {
+		finished[result.CustomId] = result.IsError
+	}
+	return finished, nil
+}
+
+// EachBatchResult 按执行顺序分批读取结果
+func EachBatchResult(taskId int64, isError bool, fn func(results []*BatchResult) error) error {
+	var results []*BatchResult
+	return DB.Where("task_id = ? AND is_error = ?", taskId, isError).Order("id").FindInBatches(&results, 500, func(tx *gorm.DB, batch int) error {
+		return fn(results)
+	}).Error
+}
+
+func DeleteBatchResults(taskId int64) error {
+	return DB.Where("task_id = ?", taskId).Delete(&BatchResult{}).Error
+}
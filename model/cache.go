@@ -17,6 +17,7 @@ var (
 	UserTokensKey               = "token:%s"
 	UsernameCacheKey            = "user_name:%d"
 	UserQuotaCacheKey           = "user_quota:%d"
+	UserPlanQuotaCacheKey       = "user_plan_quota:%d"
 	UserEnabledCacheKey         = "user_enabled:%d"
 	UserRealtimeQuotaKey        = "user_realtime_quota:%d"
 	UserRealtimeQuotaExpiration = 24 * time.Hour
@@ -98,6 +99,33 @@ func CacheDecreaseUserQuota(id int, quota int) error {
 	return err
 }
 
+// CacheGetUserPlanQuota 用户生效中的订阅剩余的额度，订阅额度变化时清除缓存
+func CacheGetUserPlanQuota(id int) (quota int, err error) {
+	if !config.RedisEnabled {
+		return GetUserPlanQuota(id)
+	}
+	quotaString, err := redis.RedisGet(fmt.Sprintf(UserPlanQuotaCacheKey, id))
+	if err != nil {
+		quota, err = GetUserPlanQuota(id)
+		if err != nil {
+			return 0, err
+		}
+		err = redis.RedisSet(fmt.Sprintf(UserPlanQuotaCacheKey, id), fmt.Sprintf("%d", quota), time.Duration(TokenCacheSeconds)*time.Second)
+		if err != nil {
+			logger.SysError("Redis set user plan quota error: " + err.Error())
+		}
+		return quota, err
+	}
+	quota, err = strconv.Atoi(quotaString)
+	return quota, err
+}
+
+func clearUserPlanQuotaCache(userId int) {
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserPlanQuotaCacheKey, userId))
+	}
+}
+
 func CacheIsUserEnabled(userId int) (bool, error) {
 	if !config.RedisEnabled {
 		return IsUserEnabled(userId)
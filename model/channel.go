@@ -1,6 +1,8 @@
 package model
 
 import (
+	"encoding/json"
+	"fmt"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/utils"
@@ -30,14 +32,28 @@ type Channel struct {
 	UsedQuota          int64   `json:"used_quota" gorm:"bigint;default:0"`
 	ModelMapping       *string `json:"model_mapping" gorm:"type:varchar(1024);default:''"`
 	ModelHeaders       *string `json:"model_headers" gorm:"type:varchar(1024);default:''"`
+	UpstreamCost       *string `json:"upstream_cost" gorm:"type:varchar(1024);default:''"` // 上游成本占模型售价的比例，JSON 格式 {"*": 0.3, "gpt-4o": 0.5}
 	Priority           *int64  `json:"priority" gorm:"bigint;default:0"`
 	Proxy              *string `json:"proxy" gorm:"type:varchar(255);default:''"`
 	TestModel          string  `json:"test_model" form:"test_model" gorm:"type:varchar(50);default:''"`
 	OnlyChat           bool    `json:"only_chat" form:"only_chat" gorm:"default:false"`
 	PreCost            int     `json:"pre_cost" form:"pre_cost" gorm:"default:1"`
+	// 并发数和每分钟的请求数、token 数限制，为 0 时不限制
+	MaxConcurrency int `json:"max_concurrency" form:"max_concurrency" gorm:"default:0"`
+	RPM            int `json:"rpm" form:"rpm" gorm:"column:rpm;default:0"`
+	TPM            int `json:"tpm" form:"tpm" gorm:"column:tpm;default:0"`
+	// 请求参数改写规则，JSON 数组，见 common.ParamOverrideRule
+	ParamOverride *string `json:"param_override" gorm:"type:text"`
+	// 密钥池的轮换策略，见 ChannelKeyStrategy*，为空时轮询
+	KeyStrategy string `json:"key_strategy" form:"key_strategy" gorm:"type:varchar(32);default:''"`
+	// 本次请求使用的密钥池中的密钥，为 0 表示使用渠道的 key
+	KeyId int `json:"-" form:"-" gorm:"-"`
 
 	Plugin    *datatypes.JSONType[PluginType] `json:"plugin" form:"plugin" gorm:"type:json"`
 	DeletedAt gorm.DeletedAt                  `json:"-" gorm:"index"`
+
+	Breakers     []*BreakerStatus `json:"breakers,omitempty" form:"-" gorm:"-"`
+	BalanceStats []*BalanceStatus `json:"balance_stats,omitempty" form:"-" gorm:"-"`
 }
 
 type PluginType map[string]map[string]interface{}
@@ -104,7 +120,18 @@ func GetChannelsList(params *SearchChannelsParams) (*DataResult[Channel], error)
 		db = db.Where("tag = ''")
 	}
 
-	return PaginateAndOrder(db, &params.PaginationParams, &channels, allowedChannelOrderFields)
+	result, err := PaginateAndOrder(db, &params.PaginationParams, &channels, allowedChannelOrderFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// 附带熔断器状态和负载均衡统计
+	for _, channel := range channels {
+		channel.Breakers = ChannelGroup.GetBreakers(channel.Id)
+		channel.BalanceStats = ChannelGroup.GetBalanceStats(channel.Id)
+	}
+
+	return result, nil
 }
 
 func GetAllChannels() ([]*Channel, error) {
@@ -217,6 +244,65 @@ func (channel *Channel) GetModelMapping() string {
 	return *channel.ModelMapping
 }
 
+func (channel *Channel) GetParamOverride() string {
+	if channel.ParamOverride == nil {
+		return ""
+	}
+	return *channel.ParamOverride
+}
+
+func (channel *Channel) GetUpstreamCost() string {
+	if channel.UpstreamCost == nil {
+		return ""
+	}
+	return *channel.UpstreamCost
+}
+
+// ParseUpstreamCost 解析渠道的上游成本比例，key 为模型名称，支持 * 结尾的前缀匹配，单独的 * 表示所有模型
+func ParseUpstreamCost(upstreamCost string) (map[string]float64, error) {
+	if strings.TrimSpace(upstreamCost) == "" {
+		return nil, nil
+	}
+
+	costs := make(map[string]float64)
+	if err := json.Unmarshal([]byte(upstreamCost), &costs); err != nil {
+		return nil, err
+	}
+
+	for modelName, ratio := range costs {
+		if ratio < 0 {
+			return nil, fmt.Errorf("模型 %s 的上游成本不能小于 0", modelName)
+		}
+	}
+
+	return costs, nil
+}
+
+// getUpstreamCostRatio 依次匹配模型全名、最长的前缀和 *，都没有时按售价计算
+func getUpstreamCostRatio(costs map[string]float64, modelName string) float64 {
+	if ratio, ok := costs[modelName]; ok {
+		return ratio
+	}
+
+	matchLength := 0
+	ratio, ok := costs["*"]
+	if !ok {
+		ratio = 1
+	}
+	for key, value := range costs {
+		prefix := strings.TrimSuffix(key, "*")
+		if key == "*" || prefix == key || len(prefix) <= matchLength {
+			continue
+		}
+		if strings.HasPrefix(modelName, prefix) {
+			matchLength = len(prefix)
+			ratio = value
+		}
+	}
+
+	return ratio
+}
+
 func (channel *Channel) Insert() error {
 	var err error
 	err = DB.Omit("UsedQuota").Create(channel).Error
@@ -286,8 +372,13 @@ func (channel *Channel) Delete() error {
 		return err
 	}
 	err = channel.DeleteAbilities()
+	if err != nil {
+		return err
+	}
+	err = deleteChannelKeys(channel.Id)
 	if err == nil {
 		go ChannelGroup.Load()
+		go ChannelKeys.Load()
 	}
 	return err
 }
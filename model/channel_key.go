@@ -0,0 +1,364 @@
+package model
+
+import (
+	"errors"
+	"math/rand"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ChannelKeyStrategyRoundRobin = "round_robin"
+	ChannelKeyStrategyRandom     = "random"
+	ChannelKeyStrategyLeastUsed  = "least_used"
+)
+
+// ChannelKey 渠道密钥池中的一个密钥，渠道有密钥池时使用池中的密钥代替渠道的 key
+type ChannelKey struct {
+	Id           int    `json:"id"`
+	ChannelId    int    `json:"channel_id" gorm:"index"`
+	Key          string `json:"key" gorm:"type:text"`
+	Status       int    `json:"status" gorm:"default:1"`
+	UsedCount    int64  `json:"used_count" gorm:"bigint;default:0"`
+	UsedQuota    int64  `json:"used_quota" gorm:"bigint;default:0"`
+	FailCount    int64  `json:"fail_count" gorm:"bigint;default:0"`
+	LastError    string `json:"last_error" gorm:"type:varchar(255);default:''"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint;default:0"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+
+	CooldownsTime int64 `json:"cooldowns_time" gorm:"-"`
+}
+
+func ValidateChannelKeyStrategy(strategy string) error {
+	switch strategy {
+	case "", ChannelKeyStrategyRoundRobin, ChannelKeyStrategyRandom, ChannelKeyStrategyLeastUsed:
+		return nil
+	}
+	return errors.New("invalid key strategy: " + strategy)
+}
+
+func GetChannelKeys(channelId int) ([]*ChannelKey, error) {
+	var keys []*ChannelKey
+	err := DB.Where("channel_id = ?", channelId).Order("id asc").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		key.CooldownsTime = ChannelKeys.GetCooldownsTime(key.Id)
+	}
+	return keys, nil
+}
+
+func GetChannelKeyById(channelId, id int) (*ChannelKey, error) {
+	var key ChannelKey
+	err := DB.Where("channel_id = ? and id = ?", channelId, id).First(&key).Error
+	return &key, err
+}
+
+// AddChannelKeys 添加密钥，密钥池为空时会先把渠道原有的 key 加入池中，已存在的密钥会被忽略
+func AddChannelKeys(channel *Channel, keys []string) (int, error) {
+	existing, err := GetChannelKeys(channel.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	exists := make(map[string]bool, len(existing))
+	for _, key := range existing {
+		exists[key.Key] = true
+	}
+	if len(existing) == 0 && channel.Key != "" {
+		keys = append([]string{channel.Key}, keys...)
+	}
+
+	now := utils.GetTimestamp()
+	newKeys := make([]*ChannelKey, 0, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" || exists[key] {
+			continue
+		}
+		exists[key] = true
+		newKeys = append(newKeys, &ChannelKey{
+			ChannelId:   channel.Id,
+			Key:         key,
+			Status:      config.ChannelStatusEnabled,
+			CreatedTime: now,
+		})
+	}
+
+	if len(newKeys) == 0 {
+		return 0, nil
+	}
+	if err := DB.CreateInBatches(newKeys, 100).Error; err != nil {
+		return 0, err
+	}
+
+	ChannelKeys.Load()
+	return len(newKeys), nil
+}
+
+func DeleteChannelKey(channelId, id int) error {
+	result := DB.Where("channel_id = ? and id = ?", channelId, id).Delete(&ChannelKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	ChannelKeys.Load()
+	return nil
+}
+
+func deleteChannelKeys(channelId int) error {
+	return DB.Where("channel_id = ?", channelId).Delete(&ChannelKey{}).Error
+}
+
+func UpdateChannelKeyStatus(id int, status int, reason string) error {
+	updates := map[string]any{"status": status}
+	if status == config.ChannelStatusEnabled {
+		updates["fail_count"] = 0
+		updates["last_error"] = ""
+	} else if reason != "" {
+		updates["last_error"] = truncateKeyError(reason)
+	}
+
+	err := DB.Model(&ChannelKey{}).Where("id = ?", id).Updates(updates).Error
+	if err != nil {
+		return err
+	}
+
+	ChannelKeys.ChangeStatus(id, status)
+	return nil
+}
+
+func truncateKeyError(message string) string {
+	if runes := []rune(message); len(runes) > 255 {
+		return string(runes[:255])
+	}
+	return message
+}
+
+// RecordChannelKeyError 记录密钥的失败次数和最后一次错误
+func RecordChannelKeyError(id int, message string) {
+	err := DB.Model(&ChannelKey{}).Where("id = ?", id).Updates(map[string]any{
+		"fail_count": gorm.Expr("fail_count + ?", 1),
+		"last_error": truncateKeyError(message),
+	}).Error
+	if err != nil {
+		logger.SysError("failed to record channel key error: " + err.Error())
+	}
+}
+
+func UpdateChannelKeyUsage(id int, quota int) {
+	if config.BatchUpdateEnabled {
+		addNewRecord(BatchUpdateTypeChannelKeyUsedQuota, id, quota)
+		addNewRecord(BatchUpdateTypeChannelKeyUsedCount, id, 1)
+		return
+	}
+	updateChannelKeyUsage(id, quota, 1)
+}
+
+func updateChannelKeyUsage(id int, quota int, count int) {
+	err := DB.Model(&ChannelKey{}).Where("id = ?", id).Updates(map[string]any{
+		"used_quota":     gorm.Expr("used_quota + ?", quota),
+		"used_count":     gorm.Expr("used_count + ?", count),
+		"last_used_time": utils.GetTimestamp(),
+	}).Error
+	if err != nil {
+		logger.SysError("failed to update channel key usage: " + err.Error())
+	}
+}
+
+var ChannelKeys = &ChannelKeyPools{
+	pools:     make(map[int]*channelKeyPool),
+	keys:      make(map[int]*ChannelKey),
+	cooldowns: make(map[int]int64),
+	used:      make(map[int]int64),
+}
+
+type channelKeyPool struct {
+	keys   []*ChannelKey
+	cursor int
+}
+
+// ChannelKeyPools 渠道密钥池的缓存，冷却时间和使用次数只保存在内存中
+type ChannelKeyPools struct {
+	sync.RWMutex
+	pools     map[int]*channelKeyPool
+	keys      map[int]*ChannelKey
+	cooldowns map[int]int64
+	used      map[int]int64
+}
+
+func (p *ChannelKeyPools) Load() {
+	var keys []*ChannelKey
+	if err := DB.Order("id asc").Find(&keys).Error; err != nil {
+		logger.SysError("failed to load channel keys: " + err.Error())
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	newPools := make(map[int]*channelKeyPool)
+	newKeys := make(map[int]*ChannelKey, len(keys))
+	newUsed := make(map[int]int64, len(keys))
+	for _, key := range keys {
+		pool, ok := newPools[key.ChannelId]
+		if !ok {
+			pool = &channelKeyPool{}
+			if oldPool, ok := p.pools[key.ChannelId]; ok {
+				pool.cursor = oldPool.cursor
+			}
+			newPools[key.ChannelId] = pool
+		}
+		pool.keys = append(pool.keys, key)
+		newKeys[key.Id] = key
+		newUsed[key.Id] = max(key.UsedCount, p.used[key.Id])
+	}
+
+	p.pools = newPools
+	p.keys = newKeys
+	p.used = newUsed
+}
+
+// Pick 按渠道的轮换策略选择密钥，返回使用该密钥的渠道副本，没有密钥池时返回原渠道
+func (p *ChannelKeyPools) Pick(channel *Channel) *Channel {
+	p.Lock()
+	defer p.Unlock()
+
+	pool, ok := p.pools[channel.Id]
+	if !ok {
+		return channel
+	}
+
+	nowTime := time.Now().Unix()
+	available := make([]*ChannelKey, 0, len(pool.keys))
+	var cooling *ChannelKey
+	for _, key := range pool.keys {
+		if key.Status != config.ChannelStatusEnabled {
+			continue
+		}
+		if p.cooldowns[key.Id] >= nowTime {
+			// 所有密钥都在冷却时使用最早结束冷却的密钥
+			if cooling == nil || p.cooldowns[key.Id] < p.cooldowns[cooling.Id] {
+				cooling = key
+			}
+			continue
+		}
+		available = append(available, key)
+	}
+
+	var key *ChannelKey
+	switch {
+	case len(available) == 0:
+		key = cooling
+	case channel.KeyStrategy == ChannelKeyStrategyRandom:
+		key = available[rand.Intn(len(available))]
+	case channel.KeyStrategy == ChannelKeyStrategyLeastUsed:
+		key = available[0]
+		for _, k := range available[1:] {
+			if p.used[k.Id] < p.used[key.Id] {
+				key = k
+			}
+		}
+	default:
+		pool.cursor = (pool.cursor + 1) % len(available)
+		key = available[pool.cursor]
+	}
+
+	if key == nil {
+		return channel
+	}
+	p.used[key.Id]++
+
+	newChannel := *channel
+	newChannel.Key = key.Key
+	newChannel.KeyId = key.Id
+	return &newChannel
+}
+
+// Available 渠道没有密钥池或者有可用的密钥
+func (p *ChannelKeyPools) Available(channelId int) bool {
+	p.RLock()
+	defer p.RUnlock()
+
+	pool, ok := p.pools[channelId]
+	if !ok {
+		return true
+	}
+
+	nowTime := time.Now().Unix()
+	for _, key := range pool.keys {
+		if key.Status == config.ChannelStatusEnabled && p.cooldowns[key.Id] < nowTime {
+			return true
+		}
+	}
+	return false
+}
+
+// HasEnabled 渠道的密钥池中是否还有启用的密钥
+func (p *ChannelKeyPools) HasEnabled(channelId int) bool {
+	p.RLock()
+	defer p.RUnlock()
+
+	pool, ok := p.pools[channelId]
+	if !ok {
+		return true
+	}
+	for _, key := range pool.keys {
+		if key.Status == config.ChannelStatusEnabled {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ChannelKeyPools) Cooldowns(keyId int, seconds int) bool {
+	if seconds <= 0 {
+		seconds = config.RetryCooldownSeconds
+	}
+	if seconds <= 0 {
+		return false
+	}
+	p.Lock()
+	defer p.Unlock()
+	if _, ok := p.keys[keyId]; !ok {
+		return false
+	}
+
+	p.cooldowns[keyId] = time.Now().Unix() + int64(seconds)
+	return true
+}
+
+func (p *ChannelKeyPools) GetCooldownsTime(keyId int) int64 {
+	p.RLock()
+	defer p.RUnlock()
+
+	if cooldownsTime := p.cooldowns[keyId]; cooldownsTime >= time.Now().Unix() {
+		return cooldownsTime
+	}
+	return 0
+}
+
+func (p *ChannelKeyPools) ChangeStatus(keyId int, status int) {
+	p.Lock()
+	defer p.Unlock()
+
+	key, ok := p.keys[keyId]
+	if !ok {
+		return
+	}
+	key.Status = status
+	if status == config.ChannelStatusEnabled {
+		delete(p.cooldowns, keyId)
+	}
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/common/utils"
+	"sync"
+	"time"
+)
+
+// 并发名额的租期，节点异常退出或者没有归还的名额到期后自动失效
+const channelPermitLeaseSeconds = 600
+
+var acquireChannelPermitScript = redis.NewScript(`
+	local now = tonumber(ARGV[1])
+	local lease = tonumber(ARGV[2])
+	local maxConcurrency = tonumber(ARGV[3])
+	local rpm = tonumber(ARGV[4])
+	local tpm = tonumber(ARGV[5])
+
+	if maxConcurrency > 0 then
+		redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - lease)
+		if redis.call("ZCARD", KEYS[1]) >= maxConcurrency then
+			return 0
+		end
+	end
+	if rpm > 0 and tonumber(redis.call("GET", KEYS[2]) or "0") >= rpm then
+		return 0
+	end
+	if tpm > 0 and tonumber(redis.call("GET", KEYS[3]) or "0") >= tpm then
+		return 0
+	end
+
+	if maxConcurrency > 0 then
+		redis.call("ZADD", KEYS[1], now, ARGV[6])
+		redis.call("EXPIRE", KEYS[1], lease)
+	end
+	if rpm > 0 then
+		redis.call("INCR", KEYS[2])
+		redis.call("EXPIRE", KEYS[2], 120)
+	end
+	return 1
+`)
+
+// ChannelPermit 渠道的并发名额，请求结束后需要归还
+type ChannelPermit struct {
+	ChannelId int
+	id        string
+	once      sync.Once
+}
+
+type localChannelLimit struct {
+	permits map[string]int64 // permitId -> 获取时间
+	minute  int64
+	rpm     int
+	tpm     int
+}
+
+type channelLimiter struct {
+	sync.Mutex
+	limits map[int]*localChannelLimit
+}
+
+var channelLimiters = &channelLimiter{
+	limits: make(map[int]*localChannelLimit),
+}
+
+func channelLimited(channel *Channel) bool {
+	return channel.MaxConcurrency > 0 || channel.RPM > 0 || channel.TPM > 0
+}
+
+func channelLimitKeys(channelId int, minute int64) []string {
+	return []string{
+		fmt.Sprintf("channel_limit:concurrency:%d", channelId),
+		fmt.Sprintf("channel_limit:rpm:%d:%d", channelId, minute),
+		fmt.Sprintf("channel_limit:tpm:%d:%d", channelId, minute),
+	}
+}
+
+func (cl *channelLimiter) getLocal(channelId int, minute int64) *localChannelLimit {
+	limit, ok := cl.limits[channelId]
+	if !ok {
+		limit = &localChannelLimit{permits: make(map[string]int64)}
+		cl.limits[channelId] = limit
+	}
+	if limit.minute != minute {
+		limit.minute = minute
+		limit.rpm = 0
+		limit.tpm = 0
+	}
+	return limit
+}
+
+// TryAcquire 渠道没有设置限制时直接通过，否则在并发数、RPM 和 TPM 都没有超出时占用一个名额
+func (cl *channelLimiter) TryAcquire(channel *Channel) (*ChannelPermit, bool) {
+	if !channelLimited(channel) {
+		return nil, true
+	}
+
+	now := time.Now().Unix()
+	minute := now / 60
+	permit := &ChannelPermit{ChannelId: channel.Id, id: utils.GetUUID()}
+
+	if config.RedisEnabled {
+		acquired, err := acquireChannelPermitScript.Run(
+			context.Background(),
+			redis.GetRedisClient(),
+			channelLimitKeys(channel.Id, minute),
+			now, channelPermitLeaseSeconds, channel.MaxConcurrency, channel.RPM, channel.TPM, permit.id,
+		).Int()
+		if err != nil {
+			// redis 出错时不限制，避免所有请求失败
+			logger.SysError("acquire channel permit failed: " + err.Error())
+			return nil, true
+		}
+		return permit, acquired == 1
+	}
+
+	cl.Lock()
+	defer cl.Unlock()
+	limit := cl.getLocal(channel.Id, minute)
+	if channel.MaxConcurrency > 0 {
+		for id, acquiredAt := range limit.permits {
+			if acquiredAt < now-channelPermitLeaseSeconds {
+				delete(limit.permits, id)
+			}
+		}
+		if len(limit.permits) >= channel.MaxConcurrency {
+			return nil, false
+		}
+	}
+	if (channel.RPM > 0 && limit.rpm >= channel.RPM) || (channel.TPM > 0 && limit.tpm >= channel.TPM) {
+		return nil, false
+	}
+
+	if channel.MaxConcurrency > 0 {
+		limit.permits[permit.id] = now
+	}
+	limit.rpm++
+	return permit, true
+}
+
+func (cl *channelLimiter) release(permit *ChannelPermit) {
+	if config.RedisEnabled {
+		key := channelLimitKeys(permit.ChannelId, 0)[0]
+		if err := redis.GetRedisClient().ZRem(context.Background(), key, permit.id).Err(); err != nil {
+			logger.SysError("release channel permit failed: " + err.Error())
+		}
+	} else {
+		cl.Lock()
+		if limit, ok := cl.limits[permit.ChannelId]; ok {
+			delete(limit.permits, permit.id)
+		}
+		cl.Unlock()
+	}
+
+	channelQueue.publishRelease(permit.ChannelId)
+}
+
+// RecordTokens 记录渠道本分钟消耗的 token，用于 TPM 限制
+func (cl *channelLimiter) RecordTokens(channel *Channel, tokens int) {
+	if channel.TPM <= 0 || tokens <= 0 {
+		return
+	}
+
+	minute := time.Now().Unix() / 60
+	if config.RedisEnabled {
+		key := channelLimitKeys(channel.Id, minute)[2]
+		pipe := redis.GetRedisClient().TxPipeline()
+		pipe.IncrBy(context.Background(), key, int64(tokens))
+		pipe.Expire(context.Background(), key, 120*time.Second)
+		if _, err := pipe.Exec(context.Background()); err != nil {
+			logger.SysError("record channel tokens failed: " + err.Error())
+		}
+		return
+	}
+
+	cl.Lock()
+	defer cl.Unlock()
+	cl.getLocal(channel.Id, minute).tpm += tokens
+}
+
+// Release 归还并发名额，可以重复调用
+func (permit *ChannelPermit) Release() {
+	if permit == nil {
+		return
+	}
+
+	permit.once.Do(func() {
+		channelLimiters.release(permit)
+	})
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"gorm.io/gorm/clause"
+)
+
+// ChannelObject 记录通过网关在上游创建的有状态对象（助手、线程、向量库等）所属的渠道和用户
+type ChannelObject struct {
+	ObjectId  string `json:"object_id" gorm:"type:varchar(128);primaryKey"`
+	UserId    int    `json:"user_id" gorm:"type:int;not null;index"`
+	ChannelId int    `json:"channel_id" gorm:"type:int;index"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+func (object *ChannelObject) Insert() error {
+	return DB.Clauses(clause.OnConflict{
+		DoNothing: true,
+	}).Create(object).Error
+}
+
+// InsertIfNotExists 记录已存在时返回 false，用于只处理一次的对象（例如已计费的运行）
+func (object *ChannelObject) InsertIfNotExists() (bool, error) {
+	result := DB.Clauses(clause.OnConflict{
+		DoNothing: true,
+	}).Create(object)
+	return result.RowsAffected > 0, result.Error
+}
+
+func GetChannelObjects(objectIds []string) ([]*ChannelObject, error) {
+	var objects []*ChannelObject
+	if len(objectIds) == 0 {
+		return objects, nil
+	}
+
+	err := DB.Where("object_id IN ?", objectIds).Find(&objects).Error
+	return objects, err
+}
+
+func DeleteChannelObject(objectId string, userId int) error {
+	return DB.Where("object_id = ? and user_id = ?", objectId, userId).Delete(&ChannelObject{}).Error
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/metrics"
+	"sync"
+	"time"
+)
+
+// 多节点部署时通过 redis 广播名额归还，所有节点都会唤醒各自的队首
+const channelPermitReleasedChannel = "channel_limit:released"
+
+var (
+	ErrChannelsSaturated = errors.New("channels saturated")
+	ErrQueueFull         = errors.New("queue is full")
+	ErrQueueTimeout      = errors.New("queue timeout")
+)
+
+// QueueWaiter 等待渠道名额的请求，按优先级从高到低、同优先级按进入顺序排列
+type QueueWaiter struct {
+	priority int
+	seq      uint64
+	joinedAt time.Time
+	ready    chan struct{}
+	woken    bool
+	queued   bool
+}
+
+type requestQueue struct {
+	sync.Mutex
+	waiters []*QueueWaiter
+	seq     uint64
+	watcher sync.Once
+}
+
+var channelQueue = &requestQueue{}
+
+func NewQueueWaiter(priority int) *QueueWaiter {
+	return &QueueWaiter{priority: priority}
+}
+
+func (w *QueueWaiter) before(other *QueueWaiter) bool {
+	if w.priority != other.priority {
+		return w.priority > other.priority
+	}
+	return w.seq < other.seq
+}
+
+func (q *requestQueue) join(w *QueueWaiter) error {
+	q.Lock()
+	defer q.Unlock()
+
+	if w.queued {
+		w.woken = false
+		w.ready = make(chan struct{})
+		return nil
+	}
+
+	if len(q.waiters) >= config.ChannelQueueSize {
+		return ErrQueueFull
+	}
+
+	q.seq++
+	w.seq = q.seq
+	w.joinedAt = time.Now()
+	w.ready = make(chan struct{})
+	w.queued = true
+
+	index := len(q.waiters)
+	for i, waiter := range q.waiters {
+		if w.before(waiter) {
+			index = i
+			break
+		}
+	}
+	q.waiters = append(q.waiters, nil)
+	copy(q.waiters[index+1:], q.waiters[index:])
+	q.waiters[index] = w
+	metrics.RecordChannelQueueDepth(len(q.waiters))
+
+	q.watcher.Do(q.watch)
+
+	return nil
+}
+
+// watch 监听其他节点归还名额，并在 RPM、TPM 窗口重置时唤醒队首
+func (q *requestQueue) watch() {
+	if config.RedisEnabled {
+		go func() {
+			pubsub := redis.GetRedisClient().Subscribe(context.Background(), channelPermitReleasedChannel)
+			for range pubsub.Channel() {
+				q.notify()
+			}
+		}()
+	}
+
+	go func() {
+		for {
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			q.notify()
+		}
+	}()
+}
+
+// publishRelease 广播名额归还，redis 不可用时只唤醒本节点
+func (q *requestQueue) publishRelease(channelId int) {
+	if config.RedisEnabled {
+		err := redis.GetRedisClient().Publish(context.Background(), channelPermitReleasedChannel, channelId).Err()
+		if err == nil {
+			return
+		}
+		logger.SysError("publish channel permit release failed: " + err.Error())
+	}
+	q.notify()
+}
+
+// wakeAfter 唤醒排在 after 之后第一个还在等待的请求，after 为空时从队首开始
+func (q *requestQueue) wakeAfter(after *QueueWaiter) {
+	q.Lock()
+	defer q.Unlock()
+
+	for _, waiter := range q.waiters {
+		if after != nil && !after.before(waiter) {
+			continue
+		}
+		if !waiter.woken {
+			waiter.woken = true
+			close(waiter.ready)
+			return
+		}
+	}
+}
+
+// notify 有名额释放时唤醒优先级最高的请求
+func (q *requestQueue) notify() {
+	q.wakeAfter(nil)
+}
+
+func (q *requestQueue) leave(w *QueueWaiter, result string) {
+	q.Lock()
+	defer q.Unlock()
+
+	if !w.queued {
+		return
+	}
+	w.queued = false
+	for i, waiter := range q.waiters {
+		if waiter == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			break
+		}
+	}
+	metrics.RecordChannelQueueDepth(len(q.waiters))
+	metrics.RecordChannelQueueWait(time.Since(w.joinedAt), result)
+}
+
+// Wait 进入队列等待被唤醒，之前已经被唤醒过的请求保留原来的位置
+func (w *QueueWaiter) Wait(ctx context.Context, deadline time.Time) error {
+	if err := channelQueue.join(w); err != nil {
+		metrics.RecordChannelQueueWait(0, "full")
+		return err
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-timer.C:
+		channelQueue.leave(w, "timeout")
+		return ErrQueueTimeout
+	case <-ctx.Done():
+		channelQueue.leave(w, "canceled")
+		return ctx.Err()
+	}
+}
+
+// Pass 被唤醒后仍然没有拿到名额，把机会让给后面的请求
+func (w *QueueWaiter) Pass() {
+	channelQueue.wakeAfter(w)
+}
+
+// Leave 拿到名额后离开队列
+func (w *QueueWaiter) Leave() {
+	if w == nil {
+		return
+	}
+	channelQueue.leave(w, "acquired")
+}
@@ -59,16 +59,22 @@ func UpdateChannelsTag(tag string, channel *Channel) error {
 	tx := DB.Begin()
 	err = tx.Model(Channel{}).Where("tag = ?", tag).Updates(
 		Channel{
-			Other:        channel.Other,
-			Models:       channel.Models,
-			Group:        channel.Group,
-			Tag:          channel.Tag,
-			ModelMapping: channel.ModelMapping,
-			Proxy:        channel.Proxy,
-			TestModel:    channel.TestModel,
-			OnlyChat:     channel.OnlyChat,
-			Plugin:       channel.Plugin,
-			PreCost:      channel.PreCost,
+			Other:          channel.Other,
+			Models:         channel.Models,
+			Group:          channel.Group,
+			Tag:            channel.Tag,
+			ModelMapping:   channel.ModelMapping,
+			UpstreamCost:   channel.UpstreamCost,
+			ParamOverride:  channel.ParamOverride,
+			KeyStrategy:    channel.KeyStrategy,
+			Proxy:          channel.Proxy,
+			TestModel:      channel.TestModel,
+			OnlyChat:       channel.OnlyChat,
+			Plugin:         channel.Plugin,
+			PreCost:        channel.PreCost,
+			MaxConcurrency: channel.MaxConcurrency,
+			RPM:            channel.RPM,
+			TPM:            channel.TPM,
 		}).Error
 
 	if err != nil {
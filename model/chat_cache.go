@@ -37,5 +37,29 @@ func GetChatCacheListByUserId(userId int) ([]*ChatCache, error) {
 
 func RemoveChatCache() error {
 	now := time.Now().Unix()
+	if err := DB.Where("expiration < ?", now).Delete(ChatCacheVector{}).Error; err != nil {
+		return err
+	}
 	return DB.Where("expiration < ?", now).Delete(ChatCache{}).Error
 }
+
+// ChatCacheVector 语义缓存的向量，Scope 相同（除最后一条用户消息外请求一致）的缓存之间才比较相似度
+type ChatCacheVector struct {
+	Id         int    `json:"id"`
+	Scope      string `json:"scope" gorm:"type:varchar(32);not null;index"`
+	UserId     int    `json:"user_id" gorm:"type:int;not null;index"`
+	Hash       string `json:"hash" gorm:"type:varchar(32);not null"`
+	Vector     string `json:"vector" gorm:"type:text;not null"`
+	Expiration int64  `json:"expiration" gorm:"type:bigint;not null;index"`
+}
+
+func (vector *ChatCacheVector) Insert() error {
+	return DB.Create(vector).Error
+}
+
+func GetChatCacheVectors(scope string, userId int) ([]*ChatCacheVector, error) {
+	var vectors []*ChatCacheVector
+	now := time.Now().Unix()
+	err := DB.Where("scope = ? and user_id = ? and expiration > ?", scope, userId, now).Find(&vectors).Error
+	return vectors, err
+}
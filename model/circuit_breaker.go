@@ -0,0 +1,412 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/metrics"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	BreakerStateClosed   = "closed"
+	BreakerStateHalfOpen = "half_open"
+	BreakerStateOpen     = "open"
+)
+
+var breakerStateValues = map[string]int{
+	BreakerStateClosed:   0,
+	BreakerStateHalfOpen: 1,
+	BreakerStateOpen:     2,
+}
+
+// 多节点共享的熔断状态，field 为熔断器的 key
+const circuitBreakerRedisKey = "circuit_breakers"
+
+// 滑动窗口分桶数量，每个桶的宽度为 CircuitBreakerWindowSeconds / breakerBucketCount
+const breakerBucketCount = 10
+
+type breakerBucket struct {
+	index    int64
+	total    int
+	failures int
+}
+
+type circuitBreaker struct {
+	channelId int
+	model     string // 为空表示整个渠道
+	state     string
+	buckets   [breakerBucketCount]breakerBucket
+	level     int // 连续熔断的次数，决定下一次熔断的时长
+	openUntil int64
+	probes    int // 半开状态下已放行的探测请求
+	successes int // 半开状态下探测成功的次数
+	probeTime int64
+}
+
+// BreakerStatus 熔断器的状态，用于渠道列表展示和多节点同步
+type BreakerStatus struct {
+	ChannelId int    `json:"channel_id"`
+	Model     string `json:"model,omitempty"`
+	State     string `json:"state"`
+	Total     int    `json:"total"`
+	Failures  int    `json:"failures"`
+	Level     int    `json:"level"`
+	OpenUntil int64  `json:"open_until,omitempty"`
+}
+
+type circuitBreakers struct {
+	sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+var channelBreakers = &circuitBreakers{
+	breakers: make(map[string]*circuitBreaker),
+}
+
+func breakerKey(channelId int, modelName string) string {
+	if modelName == "" {
+		return strconv.Itoa(channelId)
+	}
+	return fmt.Sprintf("%d:%s", channelId, modelName)
+}
+
+func breakerBucketWidth() int64 {
+	width := int64(config.CircuitBreakerWindowSeconds) / breakerBucketCount
+	if width < 1 {
+		width = 1
+	}
+	return width
+}
+
+func breakerProbeLimit() int {
+	if config.CircuitBreakerProbeRequests < 1 {
+		return 1
+	}
+	return config.CircuitBreakerProbeRequests
+}
+
+// 第 level 次熔断的时长，按次数翻倍
+func breakerOpenSeconds(level int) int64 {
+	seconds := int64(config.CircuitBreakerOpenSeconds)
+	if seconds < 1 {
+		seconds = 1
+	}
+	maxSeconds := int64(config.CircuitBreakerMaxOpenSeconds)
+	for i := 0; i < level && (maxSeconds <= 0 || seconds < maxSeconds); i++ {
+		seconds *= 2
+	}
+	if maxSeconds > 0 && seconds > maxSeconds {
+		seconds = maxSeconds
+	}
+	return seconds
+}
+
+func (b *circuitBreaker) counts(now int64) (total, failures int) {
+	current := now / breakerBucketWidth()
+	for _, bucket := range b.buckets {
+		if current-bucket.index < breakerBucketCount {
+			total += bucket.total
+			failures += bucket.failures
+		}
+	}
+	return
+}
+
+func (b *circuitBreaker) setState(state string) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	metrics.RecordBreakerState(b.channelId, b.model, state, breakerStateValues[state])
+	name := fmt.Sprintf("channel #%d", b.channelId)
+	if b.model != "" {
+		name += " model " + b.model
+	}
+	logger.SysLog(fmt.Sprintf("%s circuit breaker %s", name, state))
+}
+
+// refresh 熔断时间结束后进入半开状态，探测请求长时间没有结果时重新放行
+func (b *circuitBreaker) refresh(now int64) {
+	switch b.state {
+	case BreakerStateOpen:
+		if now >= b.openUntil {
+			b.probes = 0
+			b.successes = 0
+			b.setState(BreakerStateHalfOpen)
+		}
+	case BreakerStateHalfOpen:
+		if b.probes >= breakerProbeLimit() && now-b.probeTime > int64(config.CircuitBreakerWindowSeconds) {
+			b.probes = b.successes
+		}
+	}
+}
+
+func (b *circuitBreaker) allow(now int64) bool {
+	b.refresh(now)
+	switch b.state {
+	case BreakerStateOpen:
+		return false
+	case BreakerStateHalfOpen:
+		return b.probes < breakerProbeLimit()
+	}
+	return true
+}
+
+func (b *circuitBreaker) acquire(now int64) {
+	b.refresh(now)
+	if b.state == BreakerStateHalfOpen {
+		b.probes++
+		b.probeTime = now
+	}
+}
+
+func (b *circuitBreaker) record(success bool, now int64) {
+	b.refresh(now)
+
+	switch b.state {
+	case BreakerStateOpen:
+		// 熔断前发出的请求，结果不再统计
+		return
+	case BreakerStateHalfOpen:
+		if !success {
+			b.trip(now)
+			return
+		}
+		b.successes++
+		if b.successes >= breakerProbeLimit() {
+			b.level = 0
+			b.setState(BreakerStateClosed)
+			b.publish()
+		}
+		return
+	}
+
+	index := now / breakerBucketWidth()
+	bucket := &b.buckets[index%breakerBucketCount]
+	if bucket.index != index {
+		*bucket = breakerBucket{index: index}
+	}
+	bucket.total++
+	if success {
+		return
+	}
+	bucket.failures++
+
+	total, failures := b.counts(now)
+	if total >= config.CircuitBreakerMinRequests && float64(failures) >= float64(total)*config.CircuitBreakerErrorRate {
+		b.trip(now)
+	}
+}
+
+func (b *circuitBreaker) trip(now int64) {
+	b.openUntil = now + breakerOpenSeconds(b.level)
+	b.level++
+	b.buckets = [breakerBucketCount]breakerBucket{}
+	b.setState(BreakerStateOpen)
+	b.publish()
+}
+
+func (b *circuitBreaker) status(now int64) *BreakerStatus {
+	b.refresh(now)
+	total, failures := b.counts(now)
+	status := &BreakerStatus{
+		ChannelId: b.channelId,
+		Model:     b.model,
+		State:     b.state,
+		Total:     total,
+		Failures:  failures,
+		Level:     b.level,
+	}
+	if b.state == BreakerStateOpen {
+		status.OpenUntil = b.openUntil
+	}
+	return status
+}
+
+// publish 将熔断同步到 redis，恢复后删除，需要在持有锁时调用
+func (b *circuitBreaker) publish() {
+	if !config.RedisEnabled {
+		return
+	}
+
+	key := breakerKey(b.channelId, b.model)
+	if b.state == BreakerStateClosed {
+		go func() {
+			if err := redis.RedisHashDel(circuitBreakerRedisKey, key); err != nil {
+				logger.SysError("delete circuit breaker failed: " + err.Error())
+			}
+		}()
+		return
+	}
+
+	data, _ := json.Marshal(&BreakerStatus{
+		ChannelId: b.channelId,
+		Model:     b.model,
+		State:     BreakerStateOpen,
+		Level:     b.level,
+		OpenUntil: b.openUntil,
+	})
+	go func() {
+		if err := redis.RedisHashSet(circuitBreakerRedisKey, key, string(data)); err != nil {
+			logger.SysError("publish circuit breaker failed: " + err.Error())
+		}
+	}()
+}
+
+func (cb *circuitBreakers) get(channelId int, modelName string) *circuitBreaker {
+	key := breakerKey(channelId, modelName)
+	breaker, ok := cb.breakers[key]
+	if !ok {
+		breaker = &circuitBreaker{
+			channelId: channelId,
+			model:     modelName,
+			state:     BreakerStateClosed,
+		}
+		cb.breakers[key] = breaker
+	}
+	return breaker
+}
+
+// Allow 渠道和渠道下的模型都没有熔断时才可用
+func (cb *circuitBreakers) Allow(channelId int, modelName string) bool {
+	if !config.CircuitBreakerEnabled {
+		return true
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+	now := time.Now().Unix()
+	if breaker, ok := cb.breakers[breakerKey(channelId, "")]; ok && !breaker.allow(now) {
+		return false
+	}
+	if breaker, ok := cb.breakers[breakerKey(channelId, modelName)]; ok && modelName != "" && !breaker.allow(now) {
+		return false
+	}
+	return true
+}
+
+// Acquire 渠道被选中后调用，半开状态下占用一次探测名额，名额已经被其他请求占用时返回 false
+func (cb *circuitBreakers) Acquire(channelId int, modelName string) bool {
+	if !config.CircuitBreakerEnabled {
+		return true
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+	now := time.Now().Unix()
+	breakers := make([]*circuitBreaker, 0, 2)
+	if breaker, ok := cb.breakers[breakerKey(channelId, "")]; ok {
+		breakers = append(breakers, breaker)
+	}
+	if breaker, ok := cb.breakers[breakerKey(channelId, modelName)]; ok && modelName != "" {
+		breakers = append(breakers, breaker)
+	}
+
+	for _, breaker := range breakers {
+		if !breaker.allow(now) {
+			return false
+		}
+	}
+	for _, breaker := range breakers {
+		breaker.acquire(now)
+	}
+	return true
+}
+
+func (cb *circuitBreakers) Record(channelId int, modelName string, success bool) {
+	if !config.CircuitBreakerEnabled {
+		return
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+	now := time.Now().Unix()
+	cb.get(channelId, "").record(success, now)
+	if modelName != "" {
+		cb.get(channelId, modelName).record(success, now)
+	}
+}
+
+func (cb *circuitBreakers) Reset(channelId int) {
+	cb.Lock()
+	defer cb.Unlock()
+	for key, breaker := range cb.breakers {
+		if breaker.channelId != channelId {
+			continue
+		}
+		if breaker.state != BreakerStateClosed {
+			breaker.setState(BreakerStateClosed)
+			breaker.publish()
+		}
+		delete(cb.breakers, key)
+	}
+}
+
+func (cb *circuitBreakers) Status(channelId int) []*BreakerStatus {
+	cb.Lock()
+	defer cb.Unlock()
+	now := time.Now().Unix()
+	statuses := make([]*BreakerStatus, 0)
+	for _, breaker := range cb.breakers {
+		if breaker.channelId == channelId {
+			statuses = append(statuses, breaker.status(now))
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Model < statuses[j].Model
+	})
+	return statuses
+}
+
+// sync 采用其他节点发布的熔断，并清理已经过期的记录
+func (cb *circuitBreakers) sync() {
+	values, err := redis.RedisHashGetAll(circuitBreakerRedisKey)
+	if err != nil {
+		logger.SysError("sync circuit breakers failed: " + err.Error())
+		return
+	}
+
+	now := time.Now().Unix()
+	cb.Lock()
+	defer cb.Unlock()
+	for key, value := range values {
+		var status BreakerStatus
+		if err := json.Unmarshal([]byte(value), &status); err != nil || status.OpenUntil+int64(config.CircuitBreakerWindowSeconds) < now {
+			redis.RedisHashDel(circuitBreakerRedisKey, key)
+			continue
+		}
+		if status.OpenUntil <= now {
+			continue
+		}
+
+		breaker := cb.get(status.ChannelId, status.Model)
+		if breaker.state == BreakerStateOpen && breaker.openUntil >= status.OpenUntil {
+			continue
+		}
+		breaker.openUntil = status.OpenUntil
+		if status.Level > breaker.level {
+			breaker.level = status.Level
+		}
+		breaker.buckets = [breakerBucketCount]breakerBucket{}
+		breaker.setState(BreakerStateOpen)
+	}
+}
+
+func SyncCircuitBreakers(frequency int) {
+	if !config.RedisEnabled {
+		return
+	}
+
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		if config.CircuitBreakerEnabled {
+			channelBreakers.sync()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"one-api/common/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireKeepsProbeOfSaturatedChannel(t *testing.T) {
+	enabled, probeRequests := config.CircuitBreakerEnabled, config.CircuitBreakerProbeRequests
+	config.CircuitBreakerEnabled, config.CircuitBreakerProbeRequests = true, 1
+	defer func() {
+		config.CircuitBreakerEnabled, config.CircuitBreakerProbeRequests = enabled, probeRequests
+		channelBreakers.Lock()
+		delete(channelBreakers.breakers, breakerKey(9301, ""))
+		delete(channelBreakers.breakers, breakerKey(9302, ""))
+		channelBreakers.Unlock()
+	}()
+
+	weight := uint(1)
+	saturated := &Channel{Id: 9301, Weight: &weight, MaxConcurrency: 1}
+	available := &Channel{Id: 9302, Weight: &weight}
+	chooser := &ChannelsChooser{
+		Channels: map[int]*ChannelChoice{
+			saturated.Id: {Channel: saturated},
+			available.Id: {Channel: available},
+		},
+		Rule: map[string]map[string][][]int{"default": {"gpt-4o": {{saturated.Id}, {available.Id}}}},
+	}
+
+	// 两个渠道都处于半开状态
+	channelBreakers.Lock()
+	for _, channel := range []*Channel{saturated, available} {
+		channelBreakers.breakers[breakerKey(channel.Id, "")] = &circuitBreaker{channelId: channel.Id, state: BreakerStateHalfOpen}
+	}
+	channelBreakers.Unlock()
+
+	held, ok := channelLimiters.TryAcquire(saturated)
+	assert.True(t, ok)
+	defer held.Release()
+
+	// 并发已满的渠道被跳过，不会占用它的探测名额
+	channel, permit, err := chooser.Acquire("default", "gpt-4o")
+	assert.Nil(t, err)
+	assert.Equal(t, available.Id, channel.Id)
+	permit.Release()
+
+	assert.Equal(t, 0, channelBreakers.breakers[breakerKey(saturated.Id, "")].probes)
+	assert.Equal(t, 1, channelBreakers.breakers[breakerKey(available.Id, "")].probes)
+
+	// 探测名额用完后不再放行
+	assert.False(t, channelBreakers.Acquire(available.Id, "gpt-4o"))
+	assert.True(t, channelBreakers.Acquire(saturated.Id, "gpt-4o"))
+}
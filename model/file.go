@@ -0,0 +1,101 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// File 用户通过 Files API 上传的文件，内容保存在存储驱动中
+type File struct {
+	Id         int    `json:"id"`
+	FileId     string `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	Filename   string `json:"filename" gorm:"type:varchar(255)"`
+	Purpose    string `json:"purpose" gorm:"type:varchar(32);index"`
+	Bytes      int64  `json:"bytes" gorm:"bigint"`
+	Drive      string `json:"drive" gorm:"type:varchar(32)"`
+	StorageKey string `json:"storage_key" gorm:"type:varchar(255)"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint"`
+}
+
+// UpstreamFile 文件上传到上游渠道后对应的上游文件 id
+type UpstreamFile struct {
+	Id             int    `json:"id"`
+	FileId         string `json:"file_id" gorm:"type:varchar(64);uniqueIndex:idx_file_channel"`
+	ChannelId      int    `json:"channel_id" gorm:"uniqueIndex:idx_file_channel"`
+	UpstreamFileId string `json:"upstream_file_id" gorm:"type:varchar(255)"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint"`
+}
+
+func GetUserFile(fileId string, userId int) (*File, error) {
+	file := &File{}
+	err := DB.Where("file_id = ? and user_id = ?", fileId, userId).First(file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return file, err
+}
+
+// GetUserFilesList 获取用户的文件列表，after 为上一页最后一个文件的 file_id
+func GetUserFilesList(userId int, purpose string, after string, limit int, desc bool) (files []*File, err error) {
+	tx := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		tx = tx.Where("purpose = ?", purpose)
+	}
+
+	if after != "" {
+		afterFile, err := GetUserFile(after, userId)
+		if err != nil {
+			return nil, err
+		}
+		if afterFile != nil {
+			if desc {
+				tx = tx.Where("id < ?", afterFile.Id)
+			} else {
+				tx = tx.Where("id > ?", afterFile.Id)
+			}
+		}
+	}
+
+	order := "id asc"
+	if desc {
+		order = "id desc"
+	}
+
+	err = tx.Order(order).Limit(limit).Find(&files).Error
+	return
+}
+
+func (file *File) Insert() error {
+	return DB.Create(file).Error
+}
+
+func (file *File) Delete() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", file.FileId).Delete(&UpstreamFile{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(file).Error
+	})
+}
+
+func GetUpstreamFile(fileId string, channelId int) (*UpstreamFile, error) {
+	upstreamFile := &UpstreamFile{}
+	err := DB.Where("file_id = ? and channel_id = ?", fileId, channelId).First(upstreamFile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return upstreamFile, err
+}
+
+func GetUpstreamFiles(fileId string) (upstreamFiles []*UpstreamFile, err error) {
+	err = DB.Where("file_id = ?", fileId).Find(&upstreamFiles).Error
+	return
+}
+
+func (upstreamFile *UpstreamFile) Insert() error {
+	return DB.Create(upstreamFile).Error
+}
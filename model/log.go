@@ -26,6 +26,7 @@ type Log struct {
 	ChannelId        int    `json:"channel_id" gorm:"index"`
 	RequestTime      int    `json:"request_time" gorm:"default:0"`
 	IsStream         bool   `json:"is_stream" gorm:"default:false"`
+	UpstreamCost     int    `json:"upstream_cost" gorm:"default:0"`
 
 	Metadata datatypes.JSONType[map[string]any] `json:"metadata" gorm:"type:json"`
 
@@ -96,6 +97,10 @@ func RecordConsumeLog(
 	}
 
 	if metadata != nil {
+		// 上游成本单独存一列，用于统计利润
+		if upstreamCost, ok := metadata["upstream_cost"].(int); ok {
+			log.UpstreamCost = upstreamCost
+		}
 		log.Metadata = datatypes.NewJSONType(metadata)
 	}
 
@@ -25,7 +25,11 @@ func SetupDB() {
 		logger.FatalLog("failed to initialize database: " + err.Error())
 	}
 	ChannelGroup.Load()
+	ChannelKeys.Load()
 	GlobalUserGroupRatio.Load()
+	GlobalVirtualModels.Load()
+	GlobalPriceModifiers.Load()
+	GlobalPriceContracts.Load()
 	config.RootUserEmail = GetRootUserEmail()
 
 	if viper.GetBool("batch_update_enabled") {
@@ -147,11 +151,15 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
+		err = db.AutoMigrate(&ModelInfo{})
+		if err != nil {
+			return err
+		}
 		err = db.AutoMigrate(&Midjourney{})
 		if err != nil {
 			return err
 		}
-		err = db.AutoMigrate(&ChatCache{})
+		err = db.AutoMigrate(&ChatCache{}, &ChatCacheVector{})
 		if err != nil {
 			return err
 		}
@@ -163,7 +171,7 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
-		err = db.AutoMigrate(&Task{})
+		err = db.AutoMigrate(&Task{}, &BatchResult{})
 		if err != nil {
 			return err
 		}
@@ -177,6 +185,51 @@ func InitDB() (err error) {
 			return err
 		}
 
+		err = db.AutoMigrate(&ResponseState{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&File{}, &UpstreamFile{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&ChannelObject{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&VirtualModel{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&ChannelKey{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&PriceModifier{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&PriceContract{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&SubscriptionPlan{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&UserSubscription{})
+		if err != nil {
+			return err
+		}
+
 		migrationAfter(DB)
 
 		logger.SysLog("database migrated")
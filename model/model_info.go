@@ -0,0 +1,112 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	ModelCapabilityVision   = "vision"
+	ModelCapabilityTools    = "tools"
+	ModelCapabilityAudio    = "audio"
+	ModelCapabilityJSONMode = "json_mode"
+)
+
+// ModelInfo 模型的元数据，用于提前校验请求和筛选渠道，为 0 或 false 表示该项未知或不支持
+type ModelInfo struct {
+	Model           string `json:"model" gorm:"type:varchar(100);primaryKey" binding:"required"`
+	ContextLength   int    `json:"context_length" gorm:"default:0" binding:"gte=0"`
+	MaxOutputTokens int    `json:"max_output_tokens" gorm:"default:0" binding:"gte=0"`
+	Vision          bool   `json:"vision" gorm:"default:false"`
+	Tools           bool   `json:"tools" gorm:"default:false"`
+	Audio           bool   `json:"audio" gorm:"default:false"`
+	JSONMode        bool   `json:"json_mode" gorm:"column:json_mode;default:false"`
+}
+
+func GetAllModelInfos() ([]*ModelInfo, error) {
+	var infos []*ModelInfo
+	err := DB.Find(&infos).Error
+	return infos, err
+}
+
+// Capabilities 返回模型支持的能力
+func (info *ModelInfo) Capabilities() []string {
+	capabilities := make([]string, 0, 4)
+	if info.Vision {
+		capabilities = append(capabilities, ModelCapabilityVision)
+	}
+	if info.Tools {
+		capabilities = append(capabilities, ModelCapabilityTools)
+	}
+	if info.Audio {
+		capabilities = append(capabilities, ModelCapabilityAudio)
+	}
+	if info.JSONMode {
+		capabilities = append(capabilities, ModelCapabilityJSONMode)
+	}
+	return capabilities
+}
+
+// Supports 模型是否支持所有的能力
+func (info *ModelInfo) Supports(capabilities []string) bool {
+	for _, capability := range capabilities {
+		switch capability {
+		case ModelCapabilityVision:
+			if !info.Vision {
+				return false
+			}
+		case ModelCapabilityTools:
+			if !info.Tools {
+				return false
+			}
+		case ModelCapabilityAudio:
+			if !info.Audio {
+				return false
+			}
+		case ModelCapabilityJSONMode:
+			if !info.JSONMode {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (info *ModelInfo) Insert() error {
+	return DB.Create(info).Error
+}
+
+func (info *ModelInfo) Delete() error {
+	return DB.Where("model = ?", info.Model).Delete(&ModelInfo{}).Error
+}
+
+func InsertModelInfos(tx *gorm.DB, infos []*ModelInfo) error {
+	return tx.CreateInBatches(infos, 100).Error
+}
+
+func DeleteAllModelInfos(tx *gorm.DB) error {
+	return tx.Where("1=1").Delete(&ModelInfo{}).Error
+}
+
+func GetDefaultModelInfos() []*ModelInfo {
+	infos := []*ModelInfo{
+		{Model: "gpt-4", ContextLength: 8192, MaxOutputTokens: 8192, Tools: true},
+		{Model: "gpt-4-turbo", ContextLength: 128000, MaxOutputTokens: 4096, Vision: true, Tools: true, JSONMode: true},
+		{Model: "gpt-4o", ContextLength: 128000, MaxOutputTokens: 16384, Vision: true, Tools: true, JSONMode: true},
+		{Model: "gpt-4o-mini", ContextLength: 128000, MaxOutputTokens: 16384, Vision: true, Tools: true, JSONMode: true},
+		{Model: "gpt-4o-audio-preview", ContextLength: 128000, MaxOutputTokens: 16384, Tools: true, Audio: true},
+		{Model: "gpt-3.5-turbo", ContextLength: 16385, MaxOutputTokens: 4096, Tools: true, JSONMode: true},
+		{Model: "o1", ContextLength: 200000, MaxOutputTokens: 100000, Vision: true, Tools: true, JSONMode: true},
+		{Model: "o1-mini", ContextLength: 128000, MaxOutputTokens: 65536},
+		{Model: "o3-mini", ContextLength: 200000, MaxOutputTokens: 100000, Tools: true, JSONMode: true},
+		{Model: "claude-3-5-sonnet-20241022", ContextLength: 200000, MaxOutputTokens: 8192, Vision: true, Tools: true},
+		{Model: "claude-3-5-haiku-20241022", ContextLength: 200000, MaxOutputTokens: 8192, Tools: true},
+		{Model: "claude-3-opus-20240229", ContextLength: 200000, MaxOutputTokens: 4096, Vision: true, Tools: true},
+		{Model: "gemini-1.5-pro", ContextLength: 2097152, MaxOutputTokens: 8192, Vision: true, Tools: true, Audio: true, JSONMode: true},
+		{Model: "gemini-1.5-flash", ContextLength: 1048576, MaxOutputTokens: 8192, Vision: true, Tools: true, Audio: true, JSONMode: true},
+		{Model: "gemini-2.0-flash", ContextLength: 1048576, MaxOutputTokens: 8192, Vision: true, Tools: true, Audio: true, JSONMode: true},
+		{Model: "deepseek-chat", ContextLength: 65536, MaxOutputTokens: 8192, Tools: true, JSONMode: true},
+		{Model: "deepseek-reasoner", ContextLength: 65536, MaxOutputTokens: 8192},
+	}
+
+	return infos
+}
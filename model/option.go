@@ -80,17 +80,45 @@ func InitOptionMap() {
 	config.OptionMap["QuotaPerUnit"] = strconv.FormatFloat(config.QuotaPerUnit, 'f', -1, 64)
 	config.OptionMap["RetryTimes"] = strconv.Itoa(config.RetryTimes)
 	config.OptionMap["RetryCooldownSeconds"] = strconv.Itoa(config.RetryCooldownSeconds)
+	config.OptionMap["BalanceStrategy"] = config.BalanceStrategy
+	config.OptionMap["ChannelQueueSize"] = strconv.Itoa(config.ChannelQueueSize)
+	config.OptionMap["ChannelQueueTimeout"] = strconv.Itoa(config.ChannelQueueTimeout)
+	config.OptionMap["HedgeEnabled"] = strconv.FormatBool(config.HedgeEnabled)
+	config.OptionMap["HedgeDelay"] = strconv.Itoa(config.HedgeDelay)
+	config.OptionMap["StreamFailoverEnabled"] = strconv.FormatBool(config.StreamFailoverEnabled)
+	config.OptionMap["StreamFailoverTimes"] = strconv.Itoa(config.StreamFailoverTimes)
+	config.OptionMap["StreamFailoverPrompt"] = config.StreamFailoverPrompt
+	config.OptionMap["CircuitBreakerEnabled"] = strconv.FormatBool(config.CircuitBreakerEnabled)
+	config.OptionMap["CircuitBreakerWindowSeconds"] = strconv.Itoa(config.CircuitBreakerWindowSeconds)
+	config.OptionMap["CircuitBreakerMinRequests"] = strconv.Itoa(config.CircuitBreakerMinRequests)
+	config.OptionMap["CircuitBreakerErrorRate"] = strconv.FormatFloat(config.CircuitBreakerErrorRate, 'f', -1, 64)
+	config.OptionMap["CircuitBreakerOpenSeconds"] = strconv.Itoa(config.CircuitBreakerOpenSeconds)
+	config.OptionMap["CircuitBreakerMaxOpenSeconds"] = strconv.Itoa(config.CircuitBreakerMaxOpenSeconds)
+	config.OptionMap["CircuitBreakerProbeRequests"] = strconv.Itoa(config.CircuitBreakerProbeRequests)
 
 	config.OptionMap["MjNotifyEnabled"] = strconv.FormatBool(config.MjNotifyEnabled)
 
 	config.OptionMap["ChatCacheEnabled"] = strconv.FormatBool(config.ChatCacheEnabled)
 	config.OptionMap["ChatCacheExpireMinute"] = strconv.Itoa(config.ChatCacheExpireMinute)
+	config.OptionMap["ChatCacheSemanticEnabled"] = strconv.FormatBool(config.ChatCacheSemanticEnabled)
+	config.OptionMap["ChatCacheSemanticChannelId"] = strconv.Itoa(config.ChatCacheSemanticChannelId)
+	config.OptionMap["ChatCacheSemanticModel"] = config.ChatCacheSemanticModel
+	config.OptionMap["ChatCacheSemanticThreshold"] = strconv.FormatFloat(config.ChatCacheSemanticThreshold, 'f', -1, 64)
+
+	config.OptionMap["ResponsesExpireDays"] = strconv.Itoa(config.ResponsesExpireDays)
+
+	config.OptionMap["AssistantsDefaultModel"] = config.AssistantsDefaultModel
+
+	config.OptionMap["BatchEnabled"] = strconv.FormatBool(config.BatchEnabled)
+	config.OptionMap["BatchDiscount"] = strconv.FormatFloat(config.BatchDiscount, 'f', -1, 64)
 
 	config.OptionMap["ChatImageRequestProxy"] = ""
 
 	config.OptionMap["PaymentUSDRate"] = strconv.FormatFloat(config.PaymentUSDRate, 'f', -1, 64)
 	config.OptionMap["PaymentMinAmount"] = strconv.Itoa(config.PaymentMinAmount)
 	config.OptionMap["RechargeDiscount"] = common.RechargeDiscount2JSONString()
+	config.OptionMap["RetryRules"] = common.RetryRules2JSONString()
+	config.OptionMap["ModelFallbacks"] = common.ModelFallbacks2JSONString()
 
 	config.OptionMap["CFWorkerImageUrl"] = config.CFWorkerImageUrl
 	config.OptionMap["CFWorkerImageKey"] = config.CFWorkerImageKey
@@ -134,16 +162,27 @@ func UpdateOption(key string, value string) error {
 }
 
 var optionIntMap = map[string]*int{
-	"SMTPPort":              &config.SMTPPort,
-	"QuotaForNewUser":       &config.QuotaForNewUser,
-	"QuotaForInviter":       &config.QuotaForInviter,
-	"QuotaForInvitee":       &config.QuotaForInvitee,
-	"QuotaRemindThreshold":  &config.QuotaRemindThreshold,
-	"PreConsumedQuota":      &config.PreConsumedQuota,
-	"RetryTimes":            &config.RetryTimes,
-	"RetryCooldownSeconds":  &config.RetryCooldownSeconds,
-	"ChatCacheExpireMinute": &config.ChatCacheExpireMinute,
-	"PaymentMinAmount":      &config.PaymentMinAmount,
+	"SMTPPort":                     &config.SMTPPort,
+	"QuotaForNewUser":              &config.QuotaForNewUser,
+	"QuotaForInviter":              &config.QuotaForInviter,
+	"QuotaForInvitee":              &config.QuotaForInvitee,
+	"QuotaRemindThreshold":         &config.QuotaRemindThreshold,
+	"PreConsumedQuota":             &config.PreConsumedQuota,
+	"RetryTimes":                   &config.RetryTimes,
+	"RetryCooldownSeconds":         &config.RetryCooldownSeconds,
+	"CircuitBreakerWindowSeconds":  &config.CircuitBreakerWindowSeconds,
+	"CircuitBreakerMinRequests":    &config.CircuitBreakerMinRequests,
+	"CircuitBreakerOpenSeconds":    &config.CircuitBreakerOpenSeconds,
+	"CircuitBreakerMaxOpenSeconds": &config.CircuitBreakerMaxOpenSeconds,
+	"CircuitBreakerProbeRequests":  &config.CircuitBreakerProbeRequests,
+	"ChannelQueueSize":             &config.ChannelQueueSize,
+	"ChannelQueueTimeout":          &config.ChannelQueueTimeout,
+	"HedgeDelay":                   &config.HedgeDelay,
+	"StreamFailoverTimes":          &config.StreamFailoverTimes,
+	"ChatCacheExpireMinute":        &config.ChatCacheExpireMinute,
+	"ChatCacheSemanticChannelId":   &config.ChatCacheSemanticChannelId,
+	"ResponsesExpireDays":          &config.ResponsesExpireDays,
+	"PaymentMinAmount":             &config.PaymentMinAmount,
 }
 
 var optionBoolMap = map[string]*bool{
@@ -164,6 +203,11 @@ var optionBoolMap = map[string]*bool{
 	"DisplayInCurrencyEnabled":       &config.DisplayInCurrencyEnabled,
 	"MjNotifyEnabled":                &config.MjNotifyEnabled,
 	"ChatCacheEnabled":               &config.ChatCacheEnabled,
+	"ChatCacheSemanticEnabled":       &config.ChatCacheSemanticEnabled,
+	"CircuitBreakerEnabled":          &config.CircuitBreakerEnabled,
+	"HedgeEnabled":                   &config.HedgeEnabled,
+	"StreamFailoverEnabled":          &config.StreamFailoverEnabled,
+	"BatchEnabled":                   &config.BatchEnabled,
 }
 
 var optionStringMap = map[string]*string{
@@ -195,6 +239,10 @@ var optionStringMap = map[string]*string{
 	"ChatImageRequestProxy":       &config.ChatImageRequestProxy,
 	"CFWorkerImageUrl":            &config.CFWorkerImageUrl,
 	"CFWorkerImageKey":            &config.CFWorkerImageKey,
+	"ChatCacheSemanticModel":      &config.ChatCacheSemanticModel,
+	"AssistantsDefaultModel":      &config.AssistantsDefaultModel,
+	"BalanceStrategy":             &config.BalanceStrategy,
+	"StreamFailoverPrompt":        &config.StreamFailoverPrompt,
 }
 
 func updateOptionMap(key string, value string) (err error) {
@@ -225,6 +273,16 @@ func updateOptionMap(key string, value string) (err error) {
 		config.QuotaPerUnit, _ = strconv.ParseFloat(value, 64)
 	case "PaymentUSDRate":
 		config.PaymentUSDRate, _ = strconv.ParseFloat(value, 64)
+	case "BatchDiscount":
+		config.BatchDiscount, _ = strconv.ParseFloat(value, 64)
+	case "ChatCacheSemanticThreshold":
+		config.ChatCacheSemanticThreshold, _ = strconv.ParseFloat(value, 64)
+	case "CircuitBreakerErrorRate":
+		config.CircuitBreakerErrorRate, _ = strconv.ParseFloat(value, 64)
+	case "RetryRules":
+		err = common.UpdateRetryRulesByJSONString(value)
+	case "ModelFallbacks":
+		err = common.UpdateModelFallbacksByJSONString(value)
 	case "RechargeDiscount":
 		err = common.UpdateRechargeDiscountByJSONString(value)
 		config.RechargeDiscount = common.RechargeDiscount2JSONString()
@@ -10,34 +10,40 @@ type OrderStatus string
 
 const (
 	OrderStatusPending OrderStatus = "pending"
+	OrderStatusPaid    OrderStatus = "paid" // 已支付但订阅还未开通
 	OrderStatusSuccess OrderStatus = "success"
 	OrderStatusFailed  OrderStatus = "failed"
 	OrderStatusClosed  OrderStatus = "closed"
 )
 
 type Order struct {
-	ID            int            `json:"id"`
-	UserId        int            `json:"user_id"`
-	GatewayId     int            `json:"gateway_id"`
-	TradeNo       string         `json:"trade_no" gorm:"type:varchar(50);uniqueIndex"`
-	GatewayNo     string         `json:"gateway_no" gorm:"type:varchar(100)"`
-	Amount        int            `json:"amount" gorm:"default:0"`
-	OrderAmount   float64        `json:"order_amount" gorm:"type:decimal(10,2);default:0"`
-	OrderCurrency CurrencyType   `json:"order_currency" gorm:"type:varchar(16)"`
-	Quota         int            `json:"quota" gorm:"type:int;default:0"`
-	Fee           float64        `json:"fee" gorm:"type:decimal(10,2);default:0"`
-	Discount      float64        `json:"discount" gorm:"type:decimal(10,2);default:0"`
-	Status        OrderStatus    `json:"status" gorm:"type:varchar(32)"`
-	CreatedAt     int            `json:"created_at"`
-	UpdatedAt     int            `json:"-"`
-	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             int            `json:"id"`
+	UserId         int            `json:"user_id"`
+	GatewayId      int            `json:"gateway_id"`
+	TradeNo        string         `json:"trade_no" gorm:"type:varchar(50);uniqueIndex"`
+	GatewayNo      string         `json:"gateway_no" gorm:"type:varchar(100)"`
+	Amount         int            `json:"amount" gorm:"default:0"`
+	OrderAmount    float64        `json:"order_amount" gorm:"type:decimal(10,2);default:0"`
+	OrderCurrency  CurrencyType   `json:"order_currency" gorm:"type:varchar(16)"`
+	Quota          int            `json:"quota" gorm:"type:int;default:0"`
+	Fee            float64        `json:"fee" gorm:"type:decimal(10,2);default:0"`
+	Discount       float64        `json:"discount" gorm:"type:decimal(10,2);default:0"`
+	Status         OrderStatus    `json:"status" gorm:"type:varchar(32)"`
+	PlanId         int            `json:"plan_id" gorm:"default:0"` // 订阅套餐的订单，为 0 时为充值订单
+	AutoRenew      bool           `json:"auto_renew" gorm:"default:false"`
+	SubscriptionId int            `json:"subscription_id" gorm:"default:0;index"` // 自动续费的订单关联的订阅
+	RecurringToken string         `json:"-" gorm:"type:text"`
+	CreatedAt      int            `json:"created_at"`
+	UpdatedAt      int            `json:"-"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // 查询并关闭未完成的订单
 func CloseUnfinishedOrder() error {
 	// 关闭超过 3 小时未支付的订单
 	unixTime := time.Now().Unix() - 3*3600
-	return DB.Model(&Order{}).Where("status = ? AND created_at < ?", OrderStatusPending, unixTime).Update("status", OrderStatusClosed).Error
+	// 自动续费的订单可能已经扣款，保留用于重试
+	return DB.Model(&Order{}).Where("status = ? AND created_at < ? AND subscription_id = 0", OrderStatusPending, unixTime).Update("status", OrderStatusClosed).Error
 }
 
 func GetOrderByTradeNo(tradeNo string) (*Order, error) {
@@ -52,6 +58,19 @@ func GetUserOrder(userId int, tradeNo string) (*Order, error) {
 	return &order, err
 }
 
+// GetPendingRenewalOrder 返回订阅未完成的自动续费订单，重试时使用同一个订单号避免重复扣款
+func GetPendingRenewalOrder(subscriptionId int) (*Order, error) {
+	var order Order
+	err := DB.Where("subscription_id = ? AND status IN ?", subscriptionId, []OrderStatus{OrderStatusPending, OrderStatusPaid}).Order("id desc").First(&order).Error
+	return &order, err
+}
+
+func GetPaidSubscriptionOrders() ([]*Order, error) {
+	var orders []*Order
+	err := DB.Where("status = ? AND plan_id > 0", OrderStatusPaid).Find(&orders).Error
+	return orders, err
+}
+
 func (o *Order) Insert() error {
 	return DB.Create(o).Error
 }
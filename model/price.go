@@ -1,10 +1,14 @@
 package model
 
 import (
+	"errors"
+	"fmt"
 	"one-api/common/config"
+	"sort"
 	"strings"
 
 	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -24,8 +28,30 @@ type Price struct {
 	ChannelType int     `json:"channel_type" gorm:"default:0" binding:"gte=0"`
 	Input       float64 `json:"input" gorm:"default:0" binding:"gte=0"`
 	Output      float64 `json:"output" gorm:"default:0" binding:"gte=0"`
+	// 按提示词 token 数分档计费，只对按 token 计费的模型生效
+	Tiers *datatypes.JSONType[PriceTiers] `json:"tiers,omitempty" gorm:"type:json"`
 
 	ExtraRatios map[string]float64 `json:"extra_ratios,omitempty" gorm:"-"`
+	// 请求时生效的价格调整
+	Modifier *PriceModifier `json:"modifier,omitempty" gorm:"-"`
+	// 价格列表中展示当前生效的价格调整
+	Modifiers []*PriceModifier `json:"modifiers,omitempty" gorm:"-"`
+}
+
+// PriceTier 提示词 token 数达到 MinPromptTokens 时，整个请求按该档的价格计费
+type PriceTier struct {
+	MinPromptTokens int     `json:"min_prompt_tokens"`
+	Input           float64 `json:"input"`
+	Output          float64 `json:"output"`
+	// 每次请求额外收取的费用，与按次计费的价格单位相同
+	PerRequest float64 `json:"per_request,omitempty"`
+}
+
+type PriceTiers []*PriceTier
+
+func NewPriceTiers(tiers PriceTiers) *datatypes.JSONType[PriceTiers] {
+	data := datatypes.NewJSONType(tiers)
+	return &data
 }
 
 func GetAllPrices() ([]*Price, error) {
@@ -89,6 +115,56 @@ func (price *Price) GetOutput() float64 {
 	return price.Output
 }
 
+func (price *Price) GetTiers() PriceTiers {
+	if price.Tiers == nil || price.Type != TokensPriceType {
+		return nil
+	}
+	return price.Tiers.Data()
+}
+
+// GetTier 返回提示词 token 数适用的档位，没有适用的档位时返回 nil，使用基础价格
+func (price *Price) GetTier(promptTokens int) *PriceTier {
+	var tier *PriceTier
+	for _, t := range price.GetTiers() {
+		if promptTokens >= t.MinPromptTokens && (tier == nil || t.MinPromptTokens > tier.MinPromptTokens) {
+			tier = t
+		}
+	}
+	return tier
+}
+
+func (price *Price) ValidateTiers() error {
+	if price.Tiers == nil {
+		return nil
+	}
+	tiers := price.Tiers.Data()
+	if len(tiers) == 0 {
+		return nil
+	}
+	if price.Type != TokensPriceType {
+		return errors.New("tiers are only supported for tokens price type")
+	}
+
+	minTokens := make(map[int]bool, len(tiers))
+	for _, tier := range tiers {
+		if tier == nil {
+			return errors.New("tier is empty")
+		}
+		if tier.MinPromptTokens < 0 || tier.Input < 0 || tier.Output < 0 || tier.PerRequest < 0 {
+			return fmt.Errorf("tier %d has negative value", tier.MinPromptTokens)
+		}
+		if minTokens[tier.MinPromptTokens] {
+			return fmt.Errorf("duplicate tier: %d", tier.MinPromptTokens)
+		}
+		minTokens[tier.MinPromptTokens] = true
+	}
+
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinPromptTokens < tiers[j].MinPromptTokens
+	})
+	return nil
+}
+
 func (price *Price) GetExtraRatio(key string) float64 {
 	if key == "cached_tokens_ratio" {
 		return DefaultCacheRatios
@@ -123,6 +199,7 @@ func UpdatePrices(tx *gorm.DB, models []string, prices *Price) error {
 			ChannelType: prices.ChannelType,
 			Input:       prices.Input,
 			Output:      prices.Output,
+			Tiers:       prices.Tiers,
 		}).Error
 
 	return err
@@ -366,16 +443,28 @@ func GetDefaultPrice() []*Price {
 		"hunyuan-pro":           {[]float64{2.1429, 7.1429}, config.ChannelTypeHunyuan},
 	}
 
+	// 提示词超过 128k tokens 时价格翻倍
+	DefaultPriceTiers := map[string]PriceTiers{
+		"gemini-1.5-pro":          {{MinPromptTokens: 128001, Input: 3.5, Output: 10.5}},
+		"gemini-1.5-pro-latest":   {{MinPromptTokens: 128001, Input: 3.5, Output: 10.5}},
+		"gemini-1.5-flash":        {{MinPromptTokens: 128001, Input: 0.35, Output: 0.53}},
+		"gemini-1.5-flash-latest": {{MinPromptTokens: 128001, Input: 0.35, Output: 0.53}},
+	}
+
 	var prices []*Price
 
 	for model, modelType := range ModelTypes {
-		prices = append(prices, &Price{
+		price := &Price{
 			Model:       model,
 			Type:        TokensPriceType,
 			ChannelType: modelType.Type,
 			Input:       modelType.Ratio[0],
 			Output:      modelType.Ratio[1],
-		})
+		}
+		if tiers, ok := DefaultPriceTiers[model]; ok {
+			price.Tiers = NewPriceTiers(tiers)
+		}
+		prices = append(prices, price)
 	}
 
 	var DefaultMJPrice = map[string]float64{
@@ -0,0 +1,267 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	PriceContractTypePrice = "price"
+	PriceContractTypeRatio = "ratio"
+)
+
+// PriceContract 用户或令牌单独约定的模型价格，优先于全局价格和分组倍率
+// 令牌的约定优先于用户的约定，同一范围内完全匹配的模型优先于 * 结尾的模糊匹配
+type PriceContract struct {
+	Id     int `json:"id"`
+	UserId int `json:"user_id" form:"user_id" gorm:"index"`
+	// 为 0 时对用户的所有令牌生效
+	TokenId int `json:"token_id" form:"token_id" gorm:"index;default:0"`
+	// 支持 * 结尾的模糊匹配
+	Model string `json:"model" form:"model" gorm:"type:varchar(100)"`
+	// price 使用约定的价格，ratio 按全局价格乘以约定的倍率
+	Type      string  `json:"type" gorm:"type:varchar(20);default:'price'"`
+	Input     float64 `json:"input" gorm:"default:0"`
+	Output    float64 `json:"output" gorm:"default:0"`
+	Ratio     float64 `json:"ratio" gorm:"default:0"`
+	Remark    string  `json:"remark" gorm:"type:varchar(255);default:''"`
+	Enable    *bool   `json:"enable" form:"enable" gorm:"default:true"`
+	CreatedAt int64   `json:"created_at" gorm:"bigint"`
+}
+
+type SearchPriceContractParams struct {
+	PriceContract
+	PaginationParams
+}
+
+var allowedPriceContractOrderFields = map[string]bool{
+	"id":       true,
+	"user_id":  true,
+	"token_id": true,
+	"model":    true,
+	"enable":   true,
+}
+
+// Validate 检查约定价格的配置
+func (pc *PriceContract) Validate() error {
+	if pc.UserId <= 0 || strings.TrimSpace(pc.Model) == "" {
+		return errors.New("user_id and model are required")
+	}
+	if pc.TokenId < 0 {
+		return errors.New("invalid token_id")
+	}
+
+	if pc.Type == "" {
+		pc.Type = PriceContractTypePrice
+	}
+	switch pc.Type {
+	case PriceContractTypePrice:
+		if pc.Input < 0 || pc.Output < 0 {
+			return errors.New("price cannot be negative")
+		}
+	case PriceContractTypeRatio:
+		if pc.Ratio < 0 {
+			return errors.New("ratio cannot be negative")
+		}
+	default:
+		return fmt.Errorf("invalid type: %s", pc.Type)
+	}
+
+	if pc.TokenId > 0 {
+		var count int64
+		DB.Model(&Token{}).Where("id = ? and user_id = ?", pc.TokenId, pc.UserId).Count(&count)
+		if count == 0 {
+			return errors.New("token does not belong to the user")
+		}
+	}
+	return nil
+}
+
+func (pc *PriceContract) matchModel(modelName string) (matched bool, exact bool) {
+	if pc.Model == modelName {
+		return true, true
+	}
+	if strings.HasSuffix(pc.Model, "*") && strings.HasPrefix(modelName, strings.TrimSuffix(pc.Model, "*")) {
+		return true, false
+	}
+	return false, false
+}
+
+// Apply 返回按约定调整后的价格，不修改原价格
+func (pc *PriceContract) Apply(price *Price) *Price {
+	newPrice := *price
+	newPrice.Modifier = nil
+
+	if pc.Type == PriceContractTypePrice {
+		newPrice.Input = pc.Input
+		newPrice.Output = pc.Output
+		newPrice.Tiers = nil
+		return &newPrice
+	}
+
+	newPrice.Input = price.Input * pc.Ratio
+	newPrice.Output = price.Output * pc.Ratio
+	if tiers := price.GetTiers(); len(tiers) > 0 {
+		newTiers := make(PriceTiers, 0, len(tiers))
+		for _, tier := range tiers {
+			newTiers = append(newTiers, &PriceTier{
+				MinPromptTokens: tier.MinPromptTokens,
+				Input:           tier.Input * pc.Ratio,
+				Output:          tier.Output * pc.Ratio,
+				PerRequest:      tier.PerRequest * pc.Ratio,
+			})
+		}
+		newPrice.Tiers = NewPriceTiers(newTiers)
+	}
+	return &newPrice
+}
+
+func GetPriceContractsList(params *SearchPriceContractParams) (*DataResult[PriceContract], error) {
+	var contracts []*PriceContract
+	db := DB
+
+	if params.UserId != 0 {
+		db = db.Where("user_id = ?", params.UserId)
+	}
+
+	if params.TokenId != 0 {
+		db = db.Where("token_id = ?", params.TokenId)
+	}
+
+	if params.Model != "" {
+		db = db.Where("model LIKE ?", params.Model+"%")
+	}
+
+	if params.Enable != nil {
+		db = db.Where("enable = ?", *params.Enable)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &contracts, allowedPriceContractOrderFields)
+}
+
+func GetPriceContractById(id int) (*PriceContract, error) {
+	var contract PriceContract
+	err := DB.Where("id = ?", id).First(&contract).Error
+	return &contract, err
+}
+
+func GetPriceContractsAll() ([]*PriceContract, error) {
+	var contracts []*PriceContract
+	err := DB.Where("enable = ?", true).Find(&contracts).Error
+	return contracts, err
+}
+
+func (pc *PriceContract) Create() error {
+	err := DB.Create(pc).Error
+	if err == nil {
+		GlobalPriceContracts.Load()
+	}
+	return err
+}
+
+func (pc *PriceContract) Update() error {
+	err := DB.Select("user_id", "token_id", "model", "type", "input", "output", "ratio", "remark").Updates(pc).Error
+	if err == nil {
+		GlobalPriceContracts.Load()
+	}
+	return err
+}
+
+func (pc *PriceContract) Delete() error {
+	err := DB.Delete(pc).Error
+	if err == nil {
+		GlobalPriceContracts.Load()
+	}
+	return err
+}
+
+func ChangePriceContractEnable(id int, enable bool) error {
+	err := DB.Model(&PriceContract{}).Where("id = ?", id).Update("enable", enable).Error
+	if err == nil {
+		GlobalPriceContracts.Load()
+	}
+	return err
+}
+
+type PriceContracts struct {
+	sync.RWMutex
+	Contracts map[int][]*PriceContract
+}
+
+var GlobalPriceContracts = PriceContracts{}
+
+func (pcs *PriceContracts) Load() {
+	contracts, err := GetPriceContractsAll()
+	if err != nil {
+		return
+	}
+
+	newContracts := make(map[int][]*PriceContract)
+	for _, contract := range contracts {
+		newContracts[contract.UserId] = append(newContracts[contract.UserId], contract)
+	}
+
+	pcs.Lock()
+	defer pcs.Unlock()
+
+	pcs.Contracts = newContracts
+}
+
+// Get 按优先级返回用户和令牌在模型上的约定价格及匹配的模型名称，依次尝试 modelNames，没有时返回 nil
+func (pcs *PriceContracts) Get(userId, tokenId int, modelNames ...string) (*PriceContract, string) {
+	pcs.RLock()
+	defer pcs.RUnlock()
+
+	contracts := pcs.Contracts[userId]
+	if len(contracts) == 0 {
+		return nil, ""
+	}
+
+	for _, modelName := range modelNames {
+		if modelName == "" {
+			continue
+		}
+
+		var best *PriceContract
+		bestScore := 0
+		for _, contract := range contracts {
+			if contract.TokenId != 0 && contract.TokenId != tokenId {
+				continue
+			}
+			matched, exact := contract.matchModel(modelName)
+			if !matched {
+				continue
+			}
+
+			// 令牌优先于用户，完全匹配优先于模糊匹配，模糊匹配时前缀越长越优先
+			score := len(contract.Model)
+			if exact {
+				score += 1000
+			}
+			if contract.TokenId != 0 {
+				score += 10000
+			}
+			if score > bestScore {
+				best, bestScore = contract, score
+			}
+		}
+
+		if best != nil {
+			return best, modelName
+		}
+	}
+
+	return nil, ""
+}
+
+// GetUserContracts 返回用户的约定价格，用于展示
+func (pcs *PriceContracts) GetUserContracts(userId int) []*PriceContract {
+	pcs.RLock()
+	defer pcs.RUnlock()
+
+	contracts := make([]*PriceContract, len(pcs.Contracts[userId]))
+	copy(contracts, pcs.Contracts[userId])
+	return contracts
+}
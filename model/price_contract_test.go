@@ -0,0 +1,80 @@
+package model_test
+
+import (
+	"one-api/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceContractApply(t *testing.T) {
+	price := &model.Price{
+		Model:    "gpt-4o",
+		Type:     model.TokensPriceType,
+		Input:    1,
+		Output:   2,
+		Tiers:    model.NewPriceTiers([]*model.PriceTier{{MinPromptTokens: 0, Input: 1, Output: 2, PerRequest: 0.1}}),
+		Modifier: &model.PriceModifier{Id: 1},
+	}
+
+	contract := &model.PriceContract{Type: model.PriceContractTypeRatio, Ratio: 0.5}
+	newPrice := contract.Apply(price)
+	assert.Equal(t, 0.5, newPrice.Input)
+	assert.Equal(t, 1.0, newPrice.Output)
+	assert.Equal(t, model.PriceTiers{{MinPromptTokens: 0, Input: 0.5, Output: 1, PerRequest: 0.05}}, newPrice.GetTiers())
+	// 约定价格不再叠加价格调整
+	assert.Nil(t, newPrice.Modifier)
+
+	contract = &model.PriceContract{Type: model.PriceContractTypePrice, Input: 0.3, Output: 0.6}
+	newPrice = contract.Apply(price)
+	assert.Equal(t, 0.3, newPrice.Input)
+	assert.Equal(t, 0.6, newPrice.Output)
+	assert.Empty(t, newPrice.GetTiers())
+	assert.Nil(t, newPrice.Modifier)
+
+	assert.Equal(t, 1.0, price.Input)
+	assert.NotNil(t, price.Modifier)
+}
+
+func TestPriceContractsGet(t *testing.T) {
+	contracts := &model.PriceContracts{
+		Contracts: map[int][]*model.PriceContract{
+			1: {
+				{Id: 1, UserId: 1, Model: "gpt-*"},
+				{Id: 2, UserId: 1, Model: "gpt-4o*"},
+				{Id: 3, UserId: 1, Model: "gpt-4o"},
+				{Id: 4, UserId: 1, TokenId: 10, Model: "gpt-*"},
+				{Id: 5, UserId: 1, Model: "my-virtual"},
+			},
+		},
+	}
+
+	// 精确匹配优先，其次是最长的前缀
+	contract, matched := contracts.Get(1, 0, "gpt-4o")
+	assert.Equal(t, 3, contract.Id)
+	assert.Equal(t, "gpt-4o", matched)
+	contract, _ = contracts.Get(1, 0, "gpt-4o-mini")
+	assert.Equal(t, 2, contract.Id)
+	contract, _ = contracts.Get(1, 0, "gpt-3.5-turbo")
+	assert.Equal(t, 1, contract.Id)
+
+	// 令牌的约定优先于用户的约定
+	contract, _ = contracts.Get(1, 10, "gpt-4o")
+	assert.Equal(t, 4, contract.Id)
+	contract, _ = contracts.Get(1, 11, "gpt-4o")
+	assert.Equal(t, 3, contract.Id)
+
+	// 按顺序尝试虚拟模型和实际模型
+	contract, matched = contracts.Get(1, 0, "my-virtual", "gpt-4o")
+	assert.Equal(t, 5, contract.Id)
+	assert.Equal(t, "my-virtual", matched)
+	contract, matched = contracts.Get(1, 0, "", "other-virtual", "gpt-4o")
+	assert.Equal(t, 3, contract.Id)
+	assert.Equal(t, "gpt-4o", matched)
+
+	contract, matched = contracts.Get(1, 0, "claude-3")
+	assert.Nil(t, contract)
+	assert.Empty(t, matched)
+	contract, _ = contracts.Get(2, 0, "gpt-4o")
+	assert.Nil(t, contract)
+}
@@ -0,0 +1,333 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PriceModifierTypeMultiplier = "multiplier"
+	PriceModifierTypeOverride   = "override"
+)
+
+// PriceModifier 定时的价格调整，例如夜间折扣和限时活动，在生效时间内按倍率调整价格或使用固定价格
+type PriceModifier struct {
+	Id   int    `json:"id"`
+	Name string `json:"name" gorm:"type:varchar(100)"`
+	// 生效的模型，多个模型用逗号分隔，支持 * 结尾的模糊匹配，* 表示所有模型
+	Models string `json:"models" gorm:"type:text"`
+	// 生效的分组，多个分组用逗号分隔，为空时对所有分组生效
+	Group string `json:"group" gorm:"type:varchar(255);default:''"`
+	// 时区，例如 Asia/Shanghai，为空时使用服务器时区
+	Timezone string `json:"timezone" gorm:"type:varchar(64);default:''"`
+	// 每天的生效时间，格式为 HH:MM，结束时间小于开始时间表示跨天，都为空时全天生效
+	StartTime string `json:"start_time" gorm:"type:varchar(5);default:''"`
+	EndTime   string `json:"end_time" gorm:"type:varchar(5);default:''"`
+	// 按位表示星期几生效，第 0 位为周日，为 0 时每天生效
+	Weekdays int `json:"weekdays" gorm:"default:0"`
+	// 活动的开始和结束时间戳，为 0 时不限制
+	StartAt int64 `json:"start_at" gorm:"bigint;default:0"`
+	EndAt   int64 `json:"end_at" gorm:"bigint;default:0"`
+
+	Type       string  `json:"type" gorm:"type:varchar(20);default:'multiplier'"`
+	Multiplier float64 `json:"multiplier"`
+	// Type 为 override 时使用的价格
+	Input  float64 `json:"input" gorm:"default:0"`
+	Output float64 `json:"output" gorm:"default:0"`
+	// 同时生效时使用优先级最高的
+	Priority  int   `json:"priority" gorm:"default:0"`
+	Enable    *bool `json:"enable" form:"enable" gorm:"default:true"`
+	CreatedAt int64 `json:"created_at" gorm:"bigint"`
+
+	location *time.Location
+	start    int
+	end      int
+}
+
+type SearchPriceModifierParams struct {
+	PriceModifier
+	PaginationParams
+}
+
+var allowedPriceModifierOrderFields = map[string]bool{
+	"id":       true,
+	"name":     true,
+	"priority": true,
+	"enable":   true,
+}
+
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time: %s", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// compile 解析时区和每天的生效时间
+func (m *PriceModifier) compile() error {
+	m.location = time.Local
+	if m.Timezone != "" {
+		location, err := time.LoadLocation(m.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %s", m.Timezone)
+		}
+		m.location = location
+	}
+
+	m.start, m.end = 0, 0
+	if m.StartTime == "" && m.EndTime == "" {
+		return nil
+	}
+	if m.StartTime == "" || m.EndTime == "" {
+		return errors.New("start time and end time must be set together")
+	}
+
+	var err error
+	if m.start, err = parseClock(m.StartTime); err != nil {
+		return err
+	}
+	if m.end, err = parseClock(m.EndTime); err != nil {
+		return err
+	}
+	if m.start == m.end {
+		return errors.New("start time and end time cannot be the same")
+	}
+	return nil
+}
+
+// Validate 检查价格调整的配置
+func (m *PriceModifier) Validate() error {
+	if m.Name == "" || strings.TrimSpace(m.Models) == "" {
+		return errors.New("name and models are required")
+	}
+
+	if m.Type == "" {
+		m.Type = PriceModifierTypeMultiplier
+	}
+
+	switch m.Type {
+	case PriceModifierTypeMultiplier:
+		if m.Multiplier < 0 {
+			return errors.New("multiplier cannot be negative")
+		}
+	case PriceModifierTypeOverride:
+		if m.Input < 0 || m.Output < 0 {
+			return errors.New("price cannot be negative")
+		}
+	default:
+		return fmt.Errorf("invalid type: %s", m.Type)
+	}
+
+	if m.Weekdays < 0 || m.Weekdays > 127 {
+		return errors.New("invalid weekdays")
+	}
+	if m.StartAt > 0 && m.EndAt > 0 && m.StartAt >= m.EndAt {
+		return errors.New("end_at must be after start_at")
+	}
+
+	return m.compile()
+}
+
+func (m *PriceModifier) matchModel(modelName string) bool {
+	for _, item := range strings.Split(m.Models, ",") {
+		item = strings.TrimSpace(item)
+		if item == modelName || item == "*" || (strings.HasSuffix(item, "*") && strings.HasPrefix(modelName, strings.TrimSuffix(item, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *PriceModifier) matchGroup(group string) bool {
+	if strings.TrimSpace(m.Group) == "" {
+		return true
+	}
+	for _, item := range strings.Split(m.Group, ",") {
+		if strings.TrimSpace(item) == group {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveAt 价格调整在指定时间是否生效
+func (m *PriceModifier) ActiveAt(now time.Time) bool {
+	if (m.StartAt > 0 && now.Unix() < m.StartAt) || (m.EndAt > 0 && now.Unix() >= m.EndAt) {
+		return false
+	}
+
+	local := now.In(m.location)
+	weekday := local.Weekday()
+	if m.start != m.end {
+		minute := local.Hour()*60 + local.Minute()
+		if m.start < m.end {
+			if minute < m.start || minute >= m.end {
+				return false
+			}
+		} else if minute < m.end {
+			// 跨天的时间段在第二天按前一天的星期判断
+			weekday = local.AddDate(0, 0, -1).Weekday()
+		} else if minute < m.start {
+			return false
+		}
+	}
+
+	return m.Weekdays == 0 || m.Weekdays&(1<<uint(weekday)) != 0
+}
+
+// Apply 返回调整后的价格，不修改原价格
+func (m *PriceModifier) Apply(price *Price) *Price {
+	newPrice := *price
+	newPrice.Modifier = m
+
+	if m.Type == PriceModifierTypeOverride {
+		newPrice.Input = m.Input
+		newPrice.Output = m.Output
+		newPrice.Tiers = nil
+		return &newPrice
+	}
+
+	newPrice.Input = price.Input * m.Multiplier
+	newPrice.Output = price.Output * m.Multiplier
+	if tiers := price.GetTiers(); len(tiers) > 0 {
+		newTiers := make(PriceTiers, 0, len(tiers))
+		for _, tier := range tiers {
+			newTiers = append(newTiers, &PriceTier{
+				MinPromptTokens: tier.MinPromptTokens,
+				Input:           tier.Input * m.Multiplier,
+				Output:          tier.Output * m.Multiplier,
+				PerRequest:      tier.PerRequest * m.Multiplier,
+			})
+		}
+		newPrice.Tiers = NewPriceTiers(newTiers)
+	}
+	return &newPrice
+}
+
+func GetPriceModifiersList(params *SearchPriceModifierParams) (*DataResult[PriceModifier], error) {
+	var modifiers []*PriceModifier
+	db := DB
+
+	if params.Name != "" {
+		db = db.Where("name LIKE ?", params.Name+"%")
+	}
+
+	if params.Enable != nil {
+		db = db.Where("enable = ?", *params.Enable)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &modifiers, allowedPriceModifierOrderFields)
+}
+
+func GetPriceModifierById(id int) (*PriceModifier, error) {
+	var modifier PriceModifier
+	err := DB.Where("id = ?", id).First(&modifier).Error
+	return &modifier, err
+}
+
+func GetPriceModifiersAll() ([]*PriceModifier, error) {
+	var modifiers []*PriceModifier
+	err := DB.Where("enable = ?", true).Find(&modifiers).Error
+	return modifiers, err
+}
+
+func (m *PriceModifier) Create() error {
+	err := DB.Create(m).Error
+	if err == nil {
+		GlobalPriceModifiers.Load()
+	}
+	return err
+}
+
+func (m *PriceModifier) Update() error {
+	err := DB.Select("name", "models", "group", "timezone", "start_time", "end_time", "weekdays", "start_at", "end_at", "type", "multiplier", "input", "output", "priority").Updates(m).Error
+	if err == nil {
+		GlobalPriceModifiers.Load()
+	}
+	return err
+}
+
+func (m *PriceModifier) Delete() error {
+	err := DB.Delete(m).Error
+	if err == nil {
+		GlobalPriceModifiers.Load()
+	}
+	return err
+}
+
+func ChangePriceModifierEnable(id int, enable bool) error {
+	err := DB.Model(&PriceModifier{}).Where("id = ?", id).Update("enable", enable).Error
+	if err == nil {
+		GlobalPriceModifiers.Load()
+	}
+	return err
+}
+
+type PriceModifiers struct {
+	sync.RWMutex
+	Modifiers []*PriceModifier
+}
+
+var GlobalPriceModifiers = PriceModifiers{}
+
+func (pm *PriceModifiers) Load() {
+	modifiers, err := GetPriceModifiersAll()
+	if err != nil {
+		return
+	}
+
+	newModifiers := make([]*PriceModifier, 0, len(modifiers))
+	for _, modifier := range modifiers {
+		if err := modifier.compile(); err != nil {
+			logger.SysError(fmt.Sprintf("price modifier #%d is invalid: %s", modifier.Id, err.Error()))
+			continue
+		}
+		newModifiers = append(newModifiers, modifier)
+	}
+	sort.SliceStable(newModifiers, func(i, j int) bool {
+		if newModifiers[i].Priority == newModifiers[j].Priority {
+			return newModifiers[i].Id < newModifiers[j].Id
+		}
+		return newModifiers[i].Priority > newModifiers[j].Priority
+	})
+
+	pm.Lock()
+	defer pm.Unlock()
+
+	pm.Modifiers = newModifiers
+}
+
+// Get 返回模型和分组当前生效的价格调整，没有时返回 nil
+func (pm *PriceModifiers) Get(modelName, group string) *PriceModifier {
+	pm.RLock()
+	defer pm.RUnlock()
+
+	now := time.Now()
+	for _, modifier := range pm.Modifiers {
+		if modifier.matchModel(modelName) && modifier.matchGroup(group) && modifier.ActiveAt(now) {
+			return modifier
+		}
+	}
+	return nil
+}
+
+// GetActive 返回模型当前生效的所有价格调整，用于展示价格
+func (pm *PriceModifiers) GetActive(modelName string) []*PriceModifier {
+	pm.RLock()
+	defer pm.RUnlock()
+
+	now := time.Now()
+	modifiers := make([]*PriceModifier, 0)
+	for _, modifier := range pm.Modifiers {
+		if modifier.matchModel(modelName) && modifier.ActiveAt(now) {
+			modifiers = append(modifiers, modifier)
+		}
+	}
+	return modifiers
+}
@@ -0,0 +1,111 @@
+package model_test
+
+import (
+	"one-api/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceModifierValidate(t *testing.T) {
+	modifier := model.PriceModifier{Name: "night", Models: "*", StartTime: "22:00", EndTime: "06:00", Timezone: "Asia/Shanghai", Multiplier: 0.5}
+	assert.Nil(t, modifier.Validate())
+
+	override := model.PriceModifier{Name: "free", Models: "gpt-4o", Type: model.PriceModifierTypeOverride}
+	assert.Nil(t, override.Validate())
+	override.Input = -1
+	assert.NotNil(t, override.Validate())
+
+	invalid := []model.PriceModifier{
+		{Models: "*"},
+		{Name: "night", Models: " "},
+		{Name: "night", Models: "*", Multiplier: -1},
+		{Name: "night", Models: "*", Type: "discount"},
+		{Name: "night", Models: "*", Weekdays: 128},
+		{Name: "night", Models: "*", StartAt: 200, EndAt: 100},
+		{Name: "night", Models: "*", StartTime: "22:00"},
+		{Name: "night", Models: "*", StartTime: "22:00", EndTime: "22:00"},
+		{Name: "night", Models: "*", StartTime: "25:00", EndTime: "06:00"},
+		{Name: "night", Models: "*", Timezone: "Mars/Base"},
+	}
+	for _, modifier := range invalid {
+		assert.NotNil(t, modifier.Validate(), "%+v", modifier)
+	}
+}
+
+func TestPriceModifierActiveAt(t *testing.T) {
+	// 2024-01-01 是星期一
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	monday := 1 << uint(time.Monday)
+
+	cases := []struct {
+		modifier model.PriceModifier
+		now      time.Time
+		active   bool
+	}{
+		{model.PriceModifier{StartTime: "09:00", EndTime: "18:00"}, at(1, 9, 0), true},
+		{model.PriceModifier{StartTime: "09:00", EndTime: "18:00"}, at(1, 18, 0), false},
+		{model.PriceModifier{StartTime: "09:00", EndTime: "18:00"}, at(1, 8, 59), false},
+		{model.PriceModifier{StartTime: "22:00", EndTime: "06:00"}, at(1, 23, 0), true},
+		{model.PriceModifier{StartTime: "22:00", EndTime: "06:00"}, at(2, 5, 59), true},
+		{model.PriceModifier{StartTime: "22:00", EndTime: "06:00"}, at(1, 12, 0), false},
+		{model.PriceModifier{Weekdays: monday}, at(1, 12, 0), true},
+		{model.PriceModifier{Weekdays: monday}, at(2, 12, 0), false},
+		// 周一晚上开始的时段在周二凌晨仍按周一判断
+		{model.PriceModifier{StartTime: "22:00", EndTime: "06:00", Weekdays: monday}, at(2, 1, 0), true},
+		{model.PriceModifier{StartTime: "22:00", EndTime: "06:00", Weekdays: monday}, at(2, 23, 0), false},
+		{model.PriceModifier{StartTime: "00:00", EndTime: "08:00", Timezone: "Asia/Shanghai"}, at(1, 20, 0), true},
+		{model.PriceModifier{StartAt: at(2, 0, 0).Unix()}, at(1, 12, 0), false},
+		{model.PriceModifier{EndAt: at(1, 12, 0).Unix()}, at(1, 12, 0), false},
+		{model.PriceModifier{StartAt: at(1, 0, 0).Unix(), EndAt: at(2, 0, 0).Unix()}, at(1, 12, 0), true},
+	}
+
+	for _, c := range cases {
+		modifier := c.modifier
+		modifier.Name, modifier.Models, modifier.Multiplier = "test", "*", 1
+		if modifier.Timezone == "" {
+			modifier.Timezone = "UTC"
+		}
+		// Validate 会解析时段和时区
+		assert.Nil(t, modifier.Validate())
+		assert.Equal(t, c.active, modifier.ActiveAt(c.now), "%+v at %s", c.modifier, c.now)
+	}
+}
+
+func TestPriceModifierApply(t *testing.T) {
+	price := &model.Price{
+		Model:  "gpt-4o",
+		Type:   model.TokensPriceType,
+		Input:  1,
+		Output: 2,
+		Tiers: model.NewPriceTiers([]*model.PriceTier{
+			{MinPromptTokens: 0, Input: 1, Output: 2, PerRequest: 0.1},
+			{MinPromptTokens: 1000, Input: 2, Output: 4},
+		}),
+	}
+
+	multiplier := &model.PriceModifier{Type: model.PriceModifierTypeMultiplier, Multiplier: 0.5}
+	newPrice := multiplier.Apply(price)
+	assert.Equal(t, 0.5, newPrice.Input)
+	assert.Equal(t, 1.0, newPrice.Output)
+	assert.Equal(t, model.PriceTiers{
+		{MinPromptTokens: 0, Input: 0.5, Output: 1, PerRequest: 0.05},
+		{MinPromptTokens: 1000, Input: 1, Output: 2},
+	}, newPrice.GetTiers())
+	assert.Same(t, multiplier, newPrice.Modifier)
+
+	// 固定价格不再使用阶梯
+	override := &model.PriceModifier{Type: model.PriceModifierTypeOverride, Input: 3, Output: 6}
+	newPrice = override.Apply(price)
+	assert.Equal(t, 3.0, newPrice.Input)
+	assert.Equal(t, 6.0, newPrice.Output)
+	assert.Empty(t, newPrice.GetTiers())
+
+	// 原价格不受影响
+	assert.Equal(t, 1.0, price.Input)
+	assert.Equal(t, 1.0, price.GetTiers()[0].Input)
+	assert.Nil(t, price.Modifier)
+}
@@ -0,0 +1,72 @@
+package model_test
+
+import (
+	"one-api/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceGetTier(t *testing.T) {
+	// 阶梯配置的顺序不影响匹配
+	price := &model.Price{
+		Model:  "gemini-1.5-pro",
+		Type:   model.TokensPriceType,
+		Input:  1,
+		Output: 2,
+		Tiers: model.NewPriceTiers([]*model.PriceTier{
+			{MinPromptTokens: 128000, Input: 4, Output: 8},
+			{MinPromptTokens: 0, Input: 1, Output: 2},
+			{MinPromptTokens: 32000, Input: 2, Output: 4, PerRequest: 0.01},
+		}),
+	}
+
+	assert.Equal(t, 0, price.GetTier(0).MinPromptTokens)
+	assert.Equal(t, 0, price.GetTier(31999).MinPromptTokens)
+
+	tier := price.GetTier(32000)
+	assert.Equal(t, 32000, tier.MinPromptTokens)
+	assert.Equal(t, 2.0, tier.Input)
+	assert.Equal(t, 0.01, tier.PerRequest)
+
+	assert.Equal(t, 128000, price.GetTier(128000).MinPromptTokens)
+	assert.Equal(t, 128000, price.GetTier(1000000).MinPromptTokens)
+}
+
+func TestPriceGetTierNoMatch(t *testing.T) {
+	price := &model.Price{Type: model.TokensPriceType, Input: 1}
+	assert.Nil(t, price.GetTier(1000))
+
+	// 低于最低阶梯时使用基础价格
+	price.Tiers = model.NewPriceTiers([]*model.PriceTier{{MinPromptTokens: 1000, Input: 2}})
+	assert.Nil(t, price.GetTier(999))
+	assert.NotNil(t, price.GetTier(1000))
+
+	// 按次计费不使用阶梯
+	price.Type = model.TimesPriceType
+	assert.Nil(t, price.GetTier(1000))
+}
+
+func TestPriceValidateTiers(t *testing.T) {
+	price := &model.Price{Type: model.TokensPriceType}
+	assert.Nil(t, price.ValidateTiers())
+
+	price.Tiers = model.NewPriceTiers([]*model.PriceTier{{MinPromptTokens: 1000, Input: 2}, {MinPromptTokens: 0, Input: 1}})
+	assert.Nil(t, price.ValidateTiers())
+
+	price.Tiers = model.NewPriceTiers([]*model.PriceTier{{MinPromptTokens: 10}, {MinPromptTokens: 10}})
+	assert.NotNil(t, price.ValidateTiers())
+
+	price.Tiers = model.NewPriceTiers([]*model.PriceTier{{MinPromptTokens: 0, Input: -1}})
+	assert.NotNil(t, price.ValidateTiers())
+
+	price.Tiers = model.NewPriceTiers([]*model.PriceTier{{MinPromptTokens: 0, PerRequest: -1}})
+	assert.NotNil(t, price.ValidateTiers())
+
+	price.Tiers = model.NewPriceTiers([]*model.PriceTier{nil})
+	assert.NotNil(t, price.ValidateTiers())
+
+	price.Type = model.TimesPriceType
+	price.Tiers = model.NewPriceTiers([]*model.PriceTier{{MinPromptTokens: 0, Input: 1}})
+	assert.NotNil(t, price.ValidateTiers())
+}
@@ -0,0 +1,364 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/common/utils"
+	"sync"
+	"time"
+)
+
+// 并发名额的租期，请求异常没有归还时到期自动失效
+const rateLimitLeaseSeconds = 600
+
+// 本地计数的清理间隔，过期的计数窗口和没有归还的并发名额会被删除
+const rateLimitCleanupSeconds = 60
+
+// 按滑动窗口估算最近一分钟的请求数和 token 数：上一分钟的计数按剩余比例加上当前分钟的计数
+var acquireRateLimitScript = redis.NewScript(`
+	local now = tonumber(ARGV[1])
+	local weight = tonumber(ARGV[2])
+	local lease = tonumber(ARGV[3])
+	local leaseId = ARGV[4]
+	local count = (#ARGV - 4) / 3
+
+	local allowed = 1
+	local result = {}
+	for i = 0, count - 1 do
+		local rpm = tonumber(ARGV[5 + i * 3])
+		local tpm = tonumber(ARGV[6 + i * 3])
+		local concurrency = tonumber(ARGV[7 + i * 3])
+		local k = i * 5
+
+		local requests = math.floor(tonumber(redis.call("GET", KEYS[k + 2]) or "0") * weight + tonumber(redis.call("GET", KEYS[k + 1]) or "0"))
+		local tokens = math.floor(tonumber(redis.call("GET", KEYS[k + 4]) or "0") * weight + tonumber(redis.call("GET", KEYS[k + 3]) or "0"))
+		local inFlight = 0
+		if concurrency > 0 then
+			redis.call("ZREMRANGEBYSCORE", KEYS[k + 5], "-inf", now - lease)
+			inFlight = redis.call("ZCARD", KEYS[k + 5])
+		end
+
+		if (rpm > 0 and requests >= rpm) or (tpm > 0 and tokens >= tpm) or (concurrency > 0 and inFlight >= concurrency) then
+			allowed = 0
+		end
+		table.insert(result, requests)
+		table.insert(result, tokens)
+		table.insert(result, inFlight)
+	end
+
+	if allowed == 1 then
+		for i = 0, count - 1 do
+			local k = i * 5
+			redis.call("INCR", KEYS[k + 1])
+			redis.call("EXPIRE", KEYS[k + 1], 120)
+			if tonumber(ARGV[7 + i * 3]) > 0 then
+				redis.call("ZADD", KEYS[k + 5], now, leaseId)
+				redis.call("EXPIRE", KEYS[k + 5], lease)
+			end
+		end
+	end
+
+	table.insert(result, 1, allowed)
+	return result
+`)
+
+// RateLimitScope 一组共享计数的限制，例如某个令牌，或者某个用户在分组下的所有请求
+// 同一次请求的限制都属于同一个用户，redis 的 key 以用户为 hash tag，保证在 Redis Cluster 下落在同一个 slot
+type RateLimitScope struct {
+	Key            string
+	UserId         int
+	RPM            int
+	TPM            int
+	MaxConcurrency int
+}
+
+func (s *RateLimitScope) limited() bool {
+	return s.RPM > 0 || s.TPM > 0 || s.MaxConcurrency > 0
+}
+
+// TokenRateLimitScope 令牌的限制，没有设置时返回 nil
+func TokenRateLimitScope(token *Token) *RateLimitScope {
+	scope := &RateLimitScope{
+		Key:            fmt.Sprintf("token:%d", token.Id),
+		UserId:         token.UserId,
+		RPM:            token.RPM,
+		TPM:            token.TPM,
+		MaxConcurrency: token.MaxConcurrency,
+	}
+	if !scope.limited() {
+		return nil
+	}
+	return scope
+}
+
+// GroupRateLimitScope 分组的限制对分组内的每个用户单独计算，没有设置时返回 nil
+func GroupRateLimitScope(group *UserGroup, userId int) *RateLimitScope {
+	scope := &RateLimitScope{
+		Key:            fmt.Sprintf("group:%s:%d", group.Symbol, userId),
+		UserId:         userId,
+		RPM:            group.RPM,
+		TPM:            group.TPM,
+		MaxConcurrency: group.MaxConcurrency,
+	}
+	if !scope.limited() {
+		return nil
+	}
+	return scope
+}
+
+// RateLimitUsage 某个限制在本次请求前的用量
+type RateLimitUsage struct {
+	Scope    *RateLimitScope
+	Requests int
+	Tokens   int
+	InFlight int
+}
+
+// RateLimitResult 限流的结果，ResetAfter 为当前计数窗口结束的时间
+type RateLimitResult struct {
+	Allowed    bool
+	Usages     []*RateLimitUsage
+	ResetAfter time.Duration
+	lease      *RateLimitLease
+}
+
+// RateLimitLease 占用的并发名额，请求结束后需要归还
+type RateLimitLease struct {
+	id     string
+	scopes []*RateLimitScope
+	once   sync.Once
+}
+
+type localRateWindow struct {
+	minute  int64
+	current int
+	prev    int
+}
+
+// count 滑动窗口估算的最近一分钟的计数
+func (w *localRateWindow) count(minute int64, weight float64) int {
+	w.roll(minute)
+	return int(float64(w.prev)*weight) + w.current
+}
+
+func (w *localRateWindow) roll(minute int64) {
+	if w.minute == minute {
+		return
+	}
+	if w.minute == minute-1 {
+		w.prev = w.current
+	} else {
+		w.prev = 0
+	}
+	w.current = 0
+	w.minute = minute
+}
+
+type rateLimiter struct {
+	sync.Mutex
+	requests    map[string]*localRateWindow
+	tokens      map[string]*localRateWindow
+	leases      map[string]map[string]int64 // scope -> leaseId -> 获取时间
+	lastCleanup int64
+}
+
+var RateLimiter = &rateLimiter{
+	requests: make(map[string]*localRateWindow),
+	tokens:   make(map[string]*localRateWindow),
+	leases:   make(map[string]map[string]int64),
+}
+
+func rateLimitKeys(scope *RateLimitScope, minute int64) []string {
+	prefix := fmt.Sprintf("rate_limit:{%d}:%s", scope.UserId, scope.Key)
+	return []string{
+		fmt.Sprintf("%s:rpm:%d", prefix, minute),
+		fmt.Sprintf("%s:rpm:%d", prefix, minute-1),
+		fmt.Sprintf("%s:tpm:%d", prefix, minute),
+		fmt.Sprintf("%s:tpm:%d", prefix, minute-1),
+		prefix + ":concurrency",
+	}
+}
+
+func getRateWindow(windows map[string]*localRateWindow, key string) *localRateWindow {
+	window, ok := windows[key]
+	if !ok {
+		window = &localRateWindow{}
+		windows[key] = window
+	}
+	return window
+}
+
+// Acquire 所有限制都没有超出时计入一次请求并占用并发名额，任何一个超出时都不计数
+func (rl *rateLimiter) Acquire(scopes []*RateLimitScope) *RateLimitResult {
+	now := time.Now()
+	minute := now.Unix() / 60
+	elapsed := float64(now.Unix()%60) / 60
+	weight := 1 - elapsed
+
+	result := &RateLimitResult{
+		Allowed:    true,
+		ResetAfter: time.Duration(60-now.Unix()%60) * time.Second,
+		lease:      &RateLimitLease{id: utils.GetUUID(), scopes: scopes},
+	}
+	if len(scopes) == 0 {
+		return result
+	}
+
+	if config.RedisEnabled {
+		keys := make([]string, 0, len(scopes)*5)
+		args := []interface{}{now.Unix(), weight, rateLimitLeaseSeconds, result.lease.id}
+		for _, scope := range scopes {
+			keys = append(keys, rateLimitKeys(scope, minute)...)
+			args = append(args, scope.RPM, scope.TPM, scope.MaxConcurrency)
+		}
+		values, err := acquireRateLimitScript.Run(context.Background(), redis.GetRedisClient(), keys, args...).Int64Slice()
+		if err == nil && len(values) == len(scopes)*3+1 {
+			result.Allowed = values[0] == 1
+			for i, scope := range scopes {
+				result.Usages = append(result.Usages, &RateLimitUsage{
+					Scope:    scope,
+					Requests: int(values[i*3+1]),
+					Tokens:   int(values[i*3+2]),
+					InFlight: int(values[i*3+3]),
+				})
+			}
+			return result
+		}
+		// redis 出错时不限制，避免所有请求失败
+		if err != nil {
+			logger.SysError("acquire rate limit failed: " + err.Error())
+		}
+		result.Usages = nil
+		return result
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
+	rl.cleanup(now.Unix())
+	for _, scope := range scopes {
+		usage := &RateLimitUsage{
+			Scope:    scope,
+			Requests: getRateWindow(rl.requests, scope.Key).count(minute, weight),
+			Tokens:   getRateWindow(rl.tokens, scope.Key).count(minute, weight),
+		}
+		if scope.MaxConcurrency > 0 {
+			for id, acquiredAt := range rl.leases[scope.Key] {
+				if acquiredAt < now.Unix()-rateLimitLeaseSeconds {
+					delete(rl.leases[scope.Key], id)
+				}
+			}
+			usage.InFlight = len(rl.leases[scope.Key])
+		}
+		if (scope.RPM > 0 && usage.Requests >= scope.RPM) || (scope.TPM > 0 && usage.Tokens >= scope.TPM) || (scope.MaxConcurrency > 0 && usage.InFlight >= scope.MaxConcurrency) {
+			result.Allowed = false
+		}
+		result.Usages = append(result.Usages, usage)
+	}
+
+	if result.Allowed {
+		for _, scope := range scopes {
+			rl.requests[scope.Key].current++
+			if scope.MaxConcurrency > 0 {
+				if rl.leases[scope.Key] == nil {
+					rl.leases[scope.Key] = make(map[string]int64)
+				}
+				rl.leases[scope.Key][result.lease.id] = now.Unix()
+			}
+		}
+	}
+	return result
+}
+
+// RecordTokens 请求结束后记录消耗的 token，用于 TPM 限制
+func (rl *rateLimiter) RecordTokens(scopes []*RateLimitScope, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+
+	minute := time.Now().Unix() / 60
+	for _, scope := range scopes {
+		if scope.TPM <= 0 {
+			continue
+		}
+		if config.RedisEnabled {
+			key := rateLimitKeys(scope, minute)[2]
+			pipe := redis.GetRedisClient().TxPipeline()
+			pipe.IncrBy(context.Background(), key, int64(tokens))
+			pipe.Expire(context.Background(), key, 120*time.Second)
+			if _, err := pipe.Exec(context.Background()); err != nil {
+				logger.SysError("record rate limit tokens failed: " + err.Error())
+			}
+			continue
+		}
+
+		rl.Lock()
+		window := getRateWindow(rl.tokens, scope.Key)
+		window.roll(minute)
+		window.current += tokens
+		rl.Unlock()
+	}
+}
+
+func (rl *rateLimiter) release(lease *RateLimitLease) {
+	for _, scope := range lease.scopes {
+		if scope.MaxConcurrency <= 0 {
+			continue
+		}
+		if config.RedisEnabled {
+			key := rateLimitKeys(scope, 0)[4]
+			if err := redis.GetRedisClient().ZRem(context.Background(), key, lease.id).Err(); err != nil {
+				logger.SysError("release rate limit failed: " + err.Error())
+			}
+			continue
+		}
+
+		rl.Lock()
+		delete(rl.leases[scope.Key], lease.id)
+		if len(rl.leases[scope.Key]) == 0 {
+			delete(rl.leases, scope.Key)
+		}
+		rl.Unlock()
+	}
+}
+
+// cleanup 删除上一分钟之前的计数窗口和过期的并发名额，调用方需要持有锁
+func (rl *rateLimiter) cleanup(now int64) {
+	if now-rl.lastCleanup < rateLimitCleanupSeconds {
+		return
+	}
+	rl.lastCleanup = now
+
+	minute := now / 60
+	for _, windows := range []map[string]*localRateWindow{rl.requests, rl.tokens} {
+		for key, window := range windows {
+			if window.minute < minute-1 {
+				delete(windows, key)
+			}
+		}
+	}
+	for key, leases := range rl.leases {
+		for id, acquiredAt := range leases {
+			if acquiredAt < now-rateLimitLeaseSeconds {
+				delete(leases, id)
+			}
+		}
+		if len(leases) == 0 {
+			delete(rl.leases, key)
+		}
+	}
+}
+
+// Release 归还并发名额，可以重复调用
+func (result *RateLimitResult) Release() {
+	if result == nil || !result.Allowed {
+		return
+	}
+
+	lease := result.lease
+	lease.once.Do(func() {
+		RateLimiter.release(lease)
+	})
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalRateWindowCount(t *testing.T) {
+	window := localRateWindow{minute: 10, current: 5, prev: 4}
+	assert.Equal(t, 7, window.count(10, 0.5))
+
+	// 进入下一分钟后，上一分钟的计数按剩余比例折算
+	window = localRateWindow{minute: 10, current: 8}
+	assert.Equal(t, 2, window.count(11, 0.25))
+	assert.Equal(t, int64(11), window.minute)
+	assert.Equal(t, 8, window.prev)
+
+	// 间隔超过一分钟则清零
+	window = localRateWindow{minute: 10, current: 8, prev: 3}
+	assert.Equal(t, 0, window.count(12, 1))
+}
+
+func TestRateLimiterRPM(t *testing.T) {
+	scopes := []*RateLimitScope{{Key: "test:rpm", RPM: 2}}
+
+	assert.True(t, RateLimiter.Acquire(scopes).Allowed)
+	assert.True(t, RateLimiter.Acquire(scopes).Allowed)
+
+	result := RateLimiter.Acquire(scopes)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 2, result.Usages[0].Requests)
+	assert.NotZero(t, result.ResetAfter)
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	scopes := []*RateLimitScope{{Key: "test:concurrency", MaxConcurrency: 1}}
+
+	first := RateLimiter.Acquire(scopes)
+	assert.True(t, first.Allowed)
+	assert.False(t, RateLimiter.Acquire(scopes).Allowed)
+
+	// 重复归还不会多释放名额
+	first.Release()
+	first.Release()
+	second := RateLimiter.Acquire(scopes)
+	assert.True(t, second.Allowed)
+	assert.False(t, RateLimiter.Acquire(scopes).Allowed)
+	second.Release()
+
+	RateLimiter.Lock()
+	_, ok := RateLimiter.leases[scopes[0].Key]
+	RateLimiter.Unlock()
+	assert.False(t, ok)
+}
+
+func TestRateLimiterDeniedNotCounted(t *testing.T) {
+	tokenScope := &RateLimitScope{Key: "test:denied:token", RPM: 10}
+	groupScope := &RateLimitScope{Key: "test:denied:group", RPM: 1}
+
+	assert.True(t, RateLimiter.Acquire([]*RateLimitScope{tokenScope, groupScope}).Allowed)
+	assert.False(t, RateLimiter.Acquire([]*RateLimitScope{tokenScope, groupScope}).Allowed)
+
+	// 被分组拒绝的请求不计入令牌的用量
+	result := RateLimiter.Acquire([]*RateLimitScope{tokenScope})
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, result.Usages[0].Requests)
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	now := int64(6000 * 60)
+	minute := now / 60
+	rl := &rateLimiter{
+		requests: map[string]*localRateWindow{
+			"current": {minute: minute},
+			"prev":    {minute: minute - 1},
+			"stale":   {minute: minute - 2},
+		},
+		tokens: map[string]*localRateWindow{
+			"stale": {minute: minute - 5},
+		},
+		leases: map[string]map[string]int64{
+			"active":  {"a": now, "b": now - rateLimitLeaseSeconds - 1},
+			"expired": {"c": now - rateLimitLeaseSeconds - 1},
+		},
+	}
+
+	rl.cleanup(now)
+	assert.Len(t, rl.requests, 2)
+	assert.NotContains(t, rl.requests, "stale")
+	assert.Empty(t, rl.tokens)
+	assert.Equal(t, map[string]map[string]int64{"active": {"a": now}}, rl.leases)
+
+	// 清理间隔内不重复清理
+	rl.requests["stale"] = &localRateWindow{minute: minute - 2}
+	rl.cleanup(now + rateLimitCleanupSeconds - 1)
+	assert.Contains(t, rl.requests, "stale")
+	rl.cleanup(now + rateLimitCleanupSeconds)
+	assert.NotContains(t, rl.requests, "stale")
+}
+
+func TestRateLimitKeysShareHashTag(t *testing.T) {
+	// 同一用户的令牌和分组限制需要落在同一个 slot，Lua 脚本才能在集群模式下执行
+	for _, key := range rateLimitKeys(&RateLimitScope{Key: "token:1", UserId: 42}, 100) {
+		assert.Contains(t, key, "rate_limit:{42}:")
+	}
+	for _, key := range rateLimitKeys(&RateLimitScope{Key: "group:default:42", UserId: 42}, 100) {
+		assert.Contains(t, key, "rate_limit:{42}:")
+	}
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"one-api/common/config"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm/clause"
+)
+
+// ResponseState 保存 Responses API 的对话上下文，用于 previous_response_id 续接对话
+type ResponseState struct {
+	ResponseId string         `json:"response_id" gorm:"type:varchar(64);primaryKey"`
+	UserId     int            `json:"user_id" gorm:"type:int;not null;index"`
+	ChannelId  int            `json:"channel_id" gorm:"type:int"`
+	Model      string         `json:"model" gorm:"type:varchar(255)"`
+	Input      datatypes.JSON `json:"input" gorm:"type:json"`
+	CreatedAt  int64          `json:"created_at" gorm:"bigint;index"`
+}
+
+func (state *ResponseState) Insert() error {
+	return DB.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(state).Error
+}
+
+func GetResponseState(responseId string, userId int) (*ResponseState, error) {
+	var state ResponseState
+	expiredAt := time.Now().AddDate(0, 0, -config.ResponsesExpireDays).Unix()
+	err := DB.Where("response_id = ? and user_id = ? and created_at > ?", responseId, userId, expiredAt).First(&state).Error
+	return &state, err
+}
+
+func RemoveResponseState() error {
+	expiredAt := time.Now().AddDate(0, 0, -config.ResponsesExpireDays).Unix()
+	return DB.Where("created_at < ?", expiredAt).Delete(ResponseState{}).Error
+}
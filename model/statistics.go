@@ -16,6 +16,7 @@ type Statistics struct {
 	PromptTokens     int       `json:"prompt_tokens"`
 	CompletionTokens int       `json:"completion_tokens"`
 	RequestTime      int       `json:"request_time"`
+	UpstreamCost     int       `json:"upstream_cost"`
 }
 
 func GetUserModelStatisticsByPeriod(userId int, startTime, endTime string) (LogStatistic []*LogStatisticGroupModel, err error) {
@@ -68,6 +69,53 @@ func GetChannelExpensesStatisticsByPeriod(startTime, endTime string) (LogStatist
 	return LogStatistics, err
 }
 
+type ProfitStatistics struct {
+	Date         string `json:"date" gorm:"column:date"`
+	ChannelId    int    `json:"channel_id" gorm:"column:channel_id"`
+	Channel      string `json:"channel" gorm:"column:channel"`
+	ModelName    string `json:"model_name" gorm:"column:model_name"`
+	RequestCount int64  `json:"request_count" gorm:"column:request_count"`
+	Quota        int64  `json:"quota" gorm:"column:quota"`
+	UpstreamCost int64  `json:"upstream_cost" gorm:"column:upstream_cost"`
+	Profit       int64  `json:"profit" gorm:"column:profit"`
+}
+
+// GetProfitStatisticsByPeriod 按天、渠道和模型对比计费额度和上游成本
+func GetProfitStatisticsByPeriod(startTime, endTime string, channelId int) (profitStatistics []*ProfitStatistics, err error) {
+	dateStr := "date"
+	if common.UsingPostgreSQL {
+		dateStr = "TO_CHAR(date, 'YYYY-MM-DD') as date"
+	} else if common.UsingSQLite {
+		dateStr = "strftime('%Y-%m-%d', date) as date"
+	}
+
+	channelWhere := ""
+	args := []any{startTime, endTime}
+	if channelId > 0 {
+		channelWhere = "AND statistics.channel_id = ?"
+		args = append(args, channelId)
+	}
+
+	err = DB.Raw(`
+		SELECT `+dateStr+`,
+		statistics.channel_id,
+		MAX(channels.name) AS channel,
+		statistics.model_name,
+		sum(statistics.request_count) as request_count,
+		sum(statistics.quota) as quota,
+		sum(statistics.upstream_cost) as upstream_cost,
+		sum(statistics.quota) - sum(statistics.upstream_cost) as profit
+		FROM statistics
+		LEFT JOIN channels ON statistics.channel_id = channels.id
+		WHERE statistics.date BETWEEN ? AND ?
+		`+channelWhere+`
+		GROUP BY statistics.date, statistics.channel_id, statistics.model_name
+		ORDER BY statistics.date, statistics.channel_id, statistics.model_name
+	`, args...).Scan(&profitStatistics).Error
+
+	return profitStatistics, err
+}
+
 type StatisticsUpdateType int
 
 const (
@@ -78,7 +126,7 @@ const (
 
 func UpdateStatistics(updateType StatisticsUpdateType) error {
 	sql := `
-	%s statistics (date, user_id, channel_id, model_name, request_count, quota, prompt_tokens, completion_tokens, request_time)
+	%s statistics (date, user_id, channel_id, model_name, request_count, quota, prompt_tokens, completion_tokens, request_time, upstream_cost)
 	SELECT 
 		%s as date,
 		user_id,
@@ -88,7 +136,8 @@ func UpdateStatistics(updateType StatisticsUpdateType) error {
 		sum(quota) as quota,
 		sum(prompt_tokens) as prompt_tokens,
 		sum(completion_tokens) as completion_tokens,
-		sum(request_time) as request_time
+		sum(request_time) as request_time,
+		sum(upstream_cost) as upstream_cost
 	FROM logs
 	WHERE
 		type = 2
@@ -114,7 +163,8 @@ func UpdateStatistics(updateType StatisticsUpdateType) error {
 		quota = EXCLUDED.quota,
 		prompt_tokens = EXCLUDED.prompt_tokens,
 		completion_tokens = EXCLUDED.completion_tokens,
-		request_time = EXCLUDED.request_time`
+		request_time = EXCLUDED.request_time,
+		upstream_cost = EXCLUDED.upstream_cost`
 	} else {
 		sqlPrefix = "INSERT INTO"
 		sqlDate = "DATE_FORMAT(FROM_UNIXTIME(created_at), '%Y-%m-%d')"
@@ -123,7 +173,8 @@ func UpdateStatistics(updateType StatisticsUpdateType) error {
 		quota = VALUES(quota),
 		prompt_tokens = VALUES(prompt_tokens),
 		completion_tokens = VALUES(completion_tokens),
-		request_time = VALUES(request_time)`
+		request_time = VALUES(request_time),
+		upstream_cost = VALUES(upstream_cost)`
 	}
 	now := time.Now()
 	todayTimestamp := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
@@ -0,0 +1,561 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/common/stmp"
+	"one-api/common/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SubscriptionPeriodMonth = "month"
+	SubscriptionPeriodYear  = "year"
+
+	SubscriptionStatusActive  = "active"
+	SubscriptionStatusExpired = "expired"
+)
+
+// 到期前多少天发送续费提醒
+const SubscriptionRemindDays = 3
+
+// SubscriptionPlan 订阅套餐，每个周期发放包含的额度，订阅期间可以升级到指定分组
+type SubscriptionPlan struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" form:"name" gorm:"type:varchar(100)"`
+	Description string `json:"description" gorm:"type:text"`
+	// 计费周期 month 或 year
+	Period string `json:"period" gorm:"type:varchar(16);default:'month'"`
+	// 每个周期的价格，单位为美元，和充值一样按支付方式的汇率换算
+	Price float64 `json:"price" gorm:"type:decimal(10,2);default:0"`
+	// 每个周期包含的额度
+	Quota int `json:"quota" gorm:"type:int;default:0"`
+	// 未用完的额度是否累计到下个周期，订阅到期时都会回收
+	Rollover bool `json:"rollover" gorm:"default:false"`
+	// 订阅期间使用的分组，为空时不改变用户分组
+	Group     string `json:"group" gorm:"type:varchar(32);default:''"`
+	Sort      int    `json:"sort" gorm:"default:0"`
+	Enable    *bool  `json:"enable" form:"enable" gorm:"default:true"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+type SearchSubscriptionPlanParams struct {
+	SubscriptionPlan
+	PaginationParams
+}
+
+var allowedSubscriptionPlanOrderFields = map[string]bool{
+	"id":     true,
+	"name":   true,
+	"price":  true,
+	"sort":   true,
+	"enable": true,
+}
+
+// Validate 检查订阅套餐的配置
+func (p *SubscriptionPlan) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if p.Period == "" {
+		p.Period = SubscriptionPeriodMonth
+	}
+	if p.Period != SubscriptionPeriodMonth && p.Period != SubscriptionPeriodYear {
+		return fmt.Errorf("invalid period: %s", p.Period)
+	}
+
+	if p.Price <= 0 {
+		return errors.New("price must be greater than 0")
+	}
+	if p.Quota < 0 {
+		return errors.New("quota cannot be negative")
+	}
+
+	if p.Group != "" && GlobalUserGroupRatio.GetBySymbol(p.Group) == nil {
+		return fmt.Errorf("group not found: %s", p.Group)
+	}
+	return nil
+}
+
+// NextPeriod 返回从指定时间开始的一个周期后的时间
+func (p *SubscriptionPlan) NextPeriod(start int64) int64 {
+	t := time.Unix(start, 0)
+	if p.Period == SubscriptionPeriodYear {
+		return t.AddDate(1, 0, 0).Unix()
+	}
+	return t.AddDate(0, 1, 0).Unix()
+}
+
+func GetSubscriptionPlansList(params *SearchSubscriptionPlanParams) (*DataResult[SubscriptionPlan], error) {
+	var plans []*SubscriptionPlan
+	db := DB
+
+	if params.Name != "" {
+		db = db.Where("name LIKE ?", params.Name+"%")
+	}
+
+	if params.Enable != nil {
+		db = db.Where("enable = ?", *params.Enable)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &plans, allowedSubscriptionPlanOrderFields)
+}
+
+func GetSubscriptionPlanById(id int) (*SubscriptionPlan, error) {
+	var plan SubscriptionPlan
+	err := DB.Where("id = ?", id).First(&plan).Error
+	return &plan, err
+}
+
+func GetEnabledSubscriptionPlans() ([]*SubscriptionPlan, error) {
+	var plans []*SubscriptionPlan
+	err := DB.Where("enable = ?", true).Order("sort desc, id asc").Find(&plans).Error
+	return plans, err
+}
+
+func (p *SubscriptionPlan) Create() error {
+	return DB.Create(p).Error
+}
+
+func (p *SubscriptionPlan) Update() error {
+	return DB.Select("name", "description", "period", "price", "quota", "rollover", "group", "sort").Updates(p).Error
+}
+
+func (p *SubscriptionPlan) Delete() error {
+	var count int64
+	DB.Model(&UserSubscription{}).Where("plan_id = ? AND status = ?", p.Id, SubscriptionStatusActive).Count(&count)
+	if count > 0 {
+		return errors.New("套餐还有生效中的订阅，请先禁用")
+	}
+	return DB.Delete(p).Error
+}
+
+func ChangeSubscriptionPlanEnable(id int, enable bool) error {
+	return DB.Model(&SubscriptionPlan{}).Where("id = ?", id).Update("enable", enable).Error
+}
+
+// UserSubscription 用户的订阅，每个用户同时只有一个生效中的订阅
+// 订阅额度和充值的余额分开记录在 RemainQuota 中，消费时优先扣除，到期后失效
+type UserSubscription struct {
+	Id     int    `json:"id"`
+	UserId int    `json:"user_id" form:"user_id" gorm:"index"`
+	PlanId int    `json:"plan_id" form:"plan_id" gorm:"index"`
+	Status string `json:"status" form:"status" gorm:"type:varchar(16);index"`
+	// 自动续费需要支付方式支持，RecurringToken 为支付方式保存的扣款凭证
+	AutoRenew      bool   `json:"auto_renew" gorm:"default:false"`
+	GatewayId      int    `json:"gateway_id" gorm:"default:0"`
+	RecurringToken string `json:"-" gorm:"type:text"`
+	// 当前额度周期
+	PeriodStart int64 `json:"period_start" gorm:"bigint"`
+	PeriodEnd   int64 `json:"period_end" gorm:"bigint"`
+	// 已付费的到期时间，提前续费时会大于 PeriodEnd
+	ExpiredAt   int64 `json:"expired_at" gorm:"bigint;index"`
+	RemainQuota int   `json:"remain_quota" gorm:"type:int;default:0"`
+	// 订阅前的分组，到期时恢复
+	OriginalGroup string `json:"original_group" gorm:"type:varchar(32);default:''"`
+	RemindedAt    int64  `json:"reminded_at" gorm:"bigint;default:0"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt     int64  `json:"updated_at" gorm:"bigint"`
+
+	Plan *SubscriptionPlan `json:"plan,omitempty" gorm:"-"`
+}
+
+type SearchUserSubscriptionParams struct {
+	UserId int    `form:"user_id"`
+	PlanId int    `form:"plan_id"`
+	Status string `form:"status"`
+	PaginationParams
+}
+
+var allowedUserSubscriptionOrderFields = map[string]bool{
+	"id":         true,
+	"user_id":    true,
+	"plan_id":    true,
+	"status":     true,
+	"expired_at": true,
+	"created_at": true,
+}
+
+func GetUserSubscriptionsList(params *SearchUserSubscriptionParams) (*DataResult[UserSubscription], error) {
+	var subscriptions []*UserSubscription
+	db := DB
+
+	if params.UserId != 0 {
+		db = db.Where("user_id = ?", params.UserId)
+	}
+
+	if params.PlanId != 0 {
+		db = db.Where("plan_id = ?", params.PlanId)
+	}
+
+	if params.Status != "" {
+		db = db.Where("status = ?", params.Status)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &subscriptions, allowedUserSubscriptionOrderFields)
+}
+
+func GetUserSubscriptionById(id int) (*UserSubscription, error) {
+	var subscription UserSubscription
+	err := DB.Where("id = ?", id).First(&subscription).Error
+	return &subscription, err
+}
+
+// GetUserActiveSubscription 返回用户生效中的订阅，没有时返回 gorm.ErrRecordNotFound
+func GetUserActiveSubscription(userId int) (*UserSubscription, error) {
+	var subscription UserSubscription
+	err := DB.Where("user_id = ? AND status = ?", userId, SubscriptionStatusActive).First(&subscription).Error
+	return &subscription, err
+}
+
+var ErrSubscriptionPlanConflict = errors.New("已有其他套餐的订阅")
+
+// FulfillSubscriptionOrder 在同一个事务中开通订阅并把已支付的订单标记为成功
+// 失败时订单保持已支付状态，由定时任务重试，已经处理过的订单不会重复开通
+func FulfillSubscriptionOrder(order *Order) error {
+	plan, err := GetSubscriptionPlanById(order.PlanId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return creditSubscriptionOrder(order, "套餐已被删除")
+	}
+	if err != nil {
+		return err
+	}
+
+	recurringToken := ""
+	if order.AutoRenew {
+		recurringToken = order.RecurringToken
+	}
+
+	groupChanged := false
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := markOrderSuccess(tx, order); err != nil {
+			return err
+		}
+
+		groupChanged, err = activateSubscription(tx, order.UserId, plan, order.GatewayId, recurringToken)
+		return err
+	})
+	if errors.Is(err, ErrSubscriptionPlanConflict) {
+		return creditSubscriptionOrder(order, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	if groupChanged {
+		clearUserGroupCache(order.UserId)
+	}
+	clearUserPlanQuotaCache(order.UserId)
+	RecordLog(order.UserId, LogTypeTopup, fmt.Sprintf("订阅套餐 %s 成功，套餐额度: %d，支付金额：%.2f %s", plan.Name, plan.Quota, order.OrderAmount, order.OrderCurrency))
+	return nil
+}
+
+// creditSubscriptionOrder 无法开通订阅时按套餐额度充值到余额，避免用户损失
+func creditSubscriptionOrder(order *Order, reason string) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := markOrderSuccess(tx, order); err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", order.UserId).Update("quota", gorm.Expr("quota + ?", order.Quota)).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	RecordLog(order.UserId, LogTypeTopup, fmt.Sprintf("订阅开通失败（%s），已按套餐额度充值: %d，支付金额：%.2f %s", reason, order.Quota, order.OrderAmount, order.OrderCurrency))
+	return nil
+}
+
+func markOrderSuccess(tx *gorm.DB, order *Order) error {
+	result := tx.Model(&Order{}).Where("id = ? AND status = ?", order.ID, OrderStatusPaid).Update("status", OrderStatusSuccess)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("order %s is not paid or already fulfilled", order.TradeNo)
+	}
+	order.Status = OrderStatusSuccess
+	return nil
+}
+
+// activateSubscription 开通订阅，已订阅同一个套餐时延长到期时间，返回用户分组是否被修改
+func activateSubscription(tx *gorm.DB, userId int, plan *SubscriptionPlan, gatewayId int, recurringToken string) (bool, error) {
+	var subscriptions []*UserSubscription
+	if err := tx.Where("user_id = ? AND status = ?", userId, SubscriptionStatusActive).Limit(1).Find(&subscriptions).Error; err != nil {
+		return false, err
+	}
+
+	now := utils.GetTimestamp()
+	if len(subscriptions) > 0 {
+		subscription := subscriptions[0]
+		if subscription.PlanId != plan.Id {
+			return false, ErrSubscriptionPlanConflict
+		}
+
+		updates := map[string]any{
+			"expired_at": plan.NextPeriod(subscription.ExpiredAt),
+			"gateway_id": gatewayId,
+			"updated_at": now,
+		}
+		if recurringToken != "" {
+			updates["auto_renew"] = true
+			updates["recurring_token"] = recurringToken
+		}
+		return false, tx.Model(subscription).Updates(updates).Error
+	}
+
+	subscription := &UserSubscription{
+		UserId:         userId,
+		PlanId:         plan.Id,
+		Status:         SubscriptionStatusActive,
+		AutoRenew:      recurringToken != "",
+		GatewayId:      gatewayId,
+		RecurringToken: recurringToken,
+		PeriodStart:    now,
+		PeriodEnd:      plan.NextPeriod(now),
+		ExpiredAt:      plan.NextPeriod(now),
+		RemainQuota:    plan.Quota,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	groupChanged := false
+	if plan.Group != "" {
+		var user User
+		if err := tx.Select("id", "group").Where("id = ?", userId).First(&user).Error; err != nil {
+			return false, err
+		}
+		if user.Group != plan.Group {
+			subscription.OriginalGroup = user.Group
+			if err := tx.Model(&User{}).Where("id = ?", userId).Update("group", plan.Group).Error; err != nil {
+				return false, err
+			}
+			groupChanged = true
+		}
+	}
+
+	return groupChanged, tx.Create(subscription).Error
+}
+
+func clearUserGroupCache(userId int) {
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, userId))
+	}
+}
+
+// startNextPeriod 开始下一个周期，重新发放套餐额度，不累计时未使用的额度失效
+func (s *UserSubscription) startNextPeriod(plan *SubscriptionPlan) error {
+	remainQuota := gorm.Expr("?", plan.Quota)
+	if plan.Rollover {
+		remainQuota = gorm.Expr("remain_quota + ?", plan.Quota)
+	}
+
+	return DB.Model(&UserSubscription{}).Where("id = ? AND status = ? AND period_end = ?", s.Id, SubscriptionStatusActive, s.PeriodEnd).Updates(map[string]any{
+		"remain_quota": remainQuota,
+		"period_start": s.PeriodEnd,
+		"period_end":   plan.NextPeriod(s.PeriodEnd),
+		"updated_at":   utils.GetTimestamp(),
+	}).Error
+}
+
+// Expire 结束订阅，未使用的订阅额度失效并恢复用户分组
+func (s *UserSubscription) Expire(plan *SubscriptionPlan) error {
+	groupChanged := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&UserSubscription{}).Where("id = ? AND status = ?", s.Id, SubscriptionStatusActive).Updates(map[string]any{
+			"status":          SubscriptionStatusExpired,
+			"remain_quota":    0,
+			"auto_renew":      false,
+			"recurring_token": "",
+			"updated_at":      utils.GetTimestamp(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("订阅已到期")
+		}
+
+		// 订阅期间管理员修改过分组时不再恢复
+		if plan.Group != "" && s.OriginalGroup != "" {
+			result := tx.Model(&User{}).Where("id = ?", s.UserId).Where(&User{Group: plan.Group}).Update("group", s.OriginalGroup)
+			if result.Error != nil {
+				return result.Error
+			}
+			groupChanged = result.RowsAffected > 0
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if groupChanged {
+		clearUserGroupCache(s.UserId)
+	}
+	s.Status = SubscriptionStatusExpired
+	RecordLog(s.UserId, LogTypeSystem, fmt.Sprintf("订阅套餐 %s 已到期，未使用的订阅额度 %d 已失效", plan.Name, s.RemainQuota))
+	return nil
+}
+
+// ExpireEarly 提前结束订阅，还有已预付的周期时不允许
+func (s *UserSubscription) ExpireEarly(plan *SubscriptionPlan) error {
+	if s.ExpiredAt > s.PeriodEnd {
+		return errors.New("订阅还有已预付的周期，不能提前结束")
+	}
+	if err := s.Expire(plan); err != nil {
+		return err
+	}
+	clearUserPlanQuotaCache(s.UserId)
+	return nil
+}
+
+// ProcessSubscriptionPeriods 处理到达周期结束时间的订阅，已付费的开始下一个周期，否则到期
+func ProcessSubscriptionPeriods() {
+	var subscriptions []*UserSubscription
+	err := DB.Where("status = ? AND period_end <= ?", SubscriptionStatusActive, utils.GetTimestamp()).Find(&subscriptions).Error
+	if err != nil {
+		logger.SysError("failed to get subscriptions: " + err.Error())
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		plan, err := GetSubscriptionPlanById(subscription.PlanId)
+		if err != nil {
+			// 套餐已被删除时按一次性的空套餐处理
+			plan = &SubscriptionPlan{Id: subscription.PlanId}
+		}
+
+		if subscription.ExpiredAt > subscription.PeriodEnd {
+			err = subscription.startNextPeriod(plan)
+		} else {
+			err = subscription.Expire(plan)
+			if err == nil {
+				go sendSubscriptionEmail(subscription.UserId, plan.Name, subscription.ExpiredAt, true)
+			}
+		}
+
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to process subscription #%d: %s", subscription.Id, err.Error()))
+			continue
+		}
+		clearUserPlanQuotaCache(subscription.UserId)
+	}
+}
+
+// GetRenewableSubscriptions 返回即将到期且可以自动续费的订阅
+func GetRenewableSubscriptions(before int64) ([]*UserSubscription, error) {
+	var subscriptions []*UserSubscription
+	err := DB.Where("status = ? AND auto_renew = ? AND expired_at <= ?", SubscriptionStatusActive, true, before).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// DisableSubscriptionAutoRenew 关闭自动续费，订阅在到期时间后结束
+func DisableSubscriptionAutoRenew(id int) error {
+	return DB.Model(&UserSubscription{}).Where("id = ?", id).Updates(map[string]any{
+		"auto_renew":      false,
+		"recurring_token": "",
+		"updated_at":      utils.GetTimestamp(),
+	}).Error
+}
+
+// RemindExpiringSubscriptions 提醒不能自动续费的用户及时续费，每个周期只提醒一次
+func RemindExpiringSubscriptions() {
+	now := utils.GetTimestamp()
+	var subscriptions []*UserSubscription
+	err := DB.Where("status = ? AND auto_renew = ? AND expired_at <= ? AND reminded_at < period_start", SubscriptionStatusActive, false, now+SubscriptionRemindDays*86400).Find(&subscriptions).Error
+	if err != nil {
+		logger.SysError("failed to get expiring subscriptions: " + err.Error())
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		planName := ""
+		if plan, err := GetSubscriptionPlanById(subscription.PlanId); err == nil {
+			planName = plan.Name
+		}
+
+		sendSubscriptionEmail(subscription.UserId, planName, subscription.ExpiredAt, false)
+		DB.Model(subscription).Update("reminded_at", now)
+	}
+}
+
+func sendSubscriptionEmail(userId int, planName string, expiredAt int64, expired bool) {
+	user := User{Id: userId}
+	if err := user.FillUserById(); err != nil || user.Email == "" {
+		return
+	}
+
+	userName := user.DisplayName
+	if userName == "" {
+		userName = user.Username
+	}
+
+	expiredTime := time.Unix(expiredAt, 0).Format("2006-01-02 15:04")
+	if err := stmp.SendSubscriptionEmail(userName, user.Email, planName, expiredTime, expired); err != nil {
+		logger.SysError("failed to send subscription email: " + err.Error())
+	}
+}
+
+// GetUserPlanQuota 返回用户生效中的订阅剩余的额度
+func GetUserPlanQuota(userId int) (quota int, err error) {
+	err = DB.Model(&UserSubscription{}).Where("user_id = ? AND status = ?", userId, SubscriptionStatusActive).Select("COALESCE(SUM(remain_quota), 0)").Scan(&quota).Error
+	return quota, err
+}
+
+// ConsumeUserQuota 扣除用户额度，优先使用订阅额度，不足的部分从余额中扣除，返回使用的订阅额度
+func ConsumeUserQuota(userId int, quota int) (planQuota int, err error) {
+	if quota <= 0 {
+		return 0, nil
+	}
+
+	planQuota = consumePlanQuota(userId, quota)
+	if quota > planQuota {
+		err = DecreaseUserQuota(userId, quota-planQuota)
+	}
+	return planQuota, err
+}
+
+func consumePlanQuota(userId int, quota int) int {
+	var subscriptions []*UserSubscription
+	err := DB.Select("id", "remain_quota").Where("user_id = ? AND status = ? AND remain_quota > 0", userId, SubscriptionStatusActive).Limit(1).Find(&subscriptions).Error
+	if err != nil || len(subscriptions) == 0 {
+		return 0
+	}
+
+	// 并发扣除导致剩余额度不足时从余额中扣除
+	planQuota := min(subscriptions[0].RemainQuota, quota)
+	result := DB.Model(&UserSubscription{}).Where("id = ? AND status = ? AND remain_quota >= ?", subscriptions[0].Id, SubscriptionStatusActive, planQuota).Update("remain_quota", gorm.Expr("remain_quota - ?", planQuota))
+	if result.Error != nil || result.RowsAffected == 0 {
+		return 0
+	}
+	clearUserPlanQuotaCache(userId)
+	return planQuota
+}
+
+// RefundUserQuota 退还用户额度，planQuota 为其中退还到订阅额度的部分，订阅已到期时这部分不再退还
+func RefundUserQuota(userId int, quota int, planQuota int) error {
+	if quota <= 0 {
+		return nil
+	}
+
+	planQuota = min(max(planQuota, 0), quota)
+	if planQuota > 0 {
+		err := DB.Model(&UserSubscription{}).Where("user_id = ? AND status = ?", userId, SubscriptionStatusActive).Update("remain_quota", gorm.Expr("remain_quota + ?", planQuota)).Error
+		if err != nil {
+			return err
+		}
+		clearUserPlanQuotaCache(userId)
+	}
+
+	if quota > planQuota {
+		return IncreaseUserQuota(userId, quota-planQuota)
+	}
+	return nil
+}
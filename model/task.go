@@ -8,7 +8,8 @@ import (
 )
 
 const (
-	TaskPlatformSuno = "suno"
+	TaskPlatformSuno  = "suno"
+	TaskPlatformBatch = "batch"
 )
 
 type TaskStatus string
@@ -21,6 +22,7 @@ const (
 	TaskStatusFailure               = "FAILURE"
 	TaskStatusSuccess               = "SUCCESS"
 	TaskStatusUnknown               = "UNKNOWN"
+	TaskStatusCancelling            = "CANCELLING"
 )
 
 type Task struct {
@@ -62,6 +64,29 @@ func GetTaskByTaskId(platform string, userId int, taskId string) (task *Task, er
 	return
 }
 
+// GetUserTasksByPlatform 按创建时间倒序获取用户的任务，after 为上一页最后一个任务的 task_id
+func GetUserTasksByPlatform(platform string, userId int, after string, limit int) (tasks []*Task, err error) {
+	tx := DB.Where("platform = ? and user_id = ?", platform, userId)
+	if after != "" {
+		afterTask, err := GetTaskByTaskId(platform, userId, after)
+		if err != nil {
+			return nil, err
+		}
+		if afterTask != nil {
+			tx = tx.Where("id < ?", afterTask.ID)
+		}
+	}
+
+	err = tx.Order("id desc").Limit(limit).Find(&tasks).Error
+	return
+}
+
+// TaskUpdateWhere 仅当任务满足条件时才更新，用于多个节点之间抢占任务
+func TaskUpdateWhere(id int64, where map[string]any, params map[string]any) (bool, error) {
+	result := DB.Model(&Task{}).Where("id = ?", id).Where(where).Updates(params)
+	return result.RowsAffected > 0, result.Error
+}
+
 func (Task *Task) Insert() error {
 	return DB.Create(Task).Error
 }
@@ -14,19 +14,27 @@ import (
 )
 
 type Token struct {
-	Id             int            `json:"id"`
-	UserId         int            `json:"user_id"`
-	Key            string         `json:"key" gorm:"type:char(48);uniqueIndex"`
-	Status         int            `json:"status" gorm:"default:1"`
-	Name           string         `json:"name" gorm:"index" `
-	CreatedTime    int64          `json:"created_time" gorm:"bigint"`
-	AccessedTime   int64          `json:"accessed_time" gorm:"bigint"`
-	ExpiredTime    int64          `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
-	RemainQuota    int            `json:"remain_quota" gorm:"default:0"`
-	UnlimitedQuota bool           `json:"unlimited_quota" gorm:"default:false"`
-	UsedQuota      int            `json:"used_quota" gorm:"default:0"` // used quota
-	ChatCache      bool           `json:"chat_cache" gorm:"default:false"`
-	Group          string         `json:"group" gorm:"default:''"`
+	Id             int    `json:"id"`
+	UserId         int    `json:"user_id"`
+	Key            string `json:"key" gorm:"type:char(48);uniqueIndex"`
+	Status         int    `json:"status" gorm:"default:1"`
+	Name           string `json:"name" gorm:"index" `
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	AccessedTime   int64  `json:"accessed_time" gorm:"bigint"`
+	ExpiredTime    int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	RemainQuota    int    `json:"remain_quota" gorm:"default:0"`
+	UnlimitedQuota bool   `json:"unlimited_quota" gorm:"default:false"`
+	UsedQuota      int    `json:"used_quota" gorm:"default:0"` // used quota
+	ChatCache      bool   `json:"chat_cache" gorm:"default:false"`
+	// 语义缓存的相似度阈值，为 0 时使用分组或全局的设置
+	ChatCacheThreshold float64 `json:"chat_cache_threshold" gorm:"default:0"`
+	Group              string  `json:"group" gorm:"default:''"`
+	// 每分钟请求数、token 数和并发数限制，为 0 时不限制
+	RPM            int `json:"rpm" gorm:"column:rpm;default:0"`
+	TPM            int `json:"tpm" gorm:"column:tpm;default:0"`
+	MaxConcurrency int `json:"max_concurrency" gorm:"default:0"`
+	// 令牌自己的模型降级链，覆盖全局设置中的同名模型
+	ModelFallbacks string         `json:"model_fallbacks" gorm:"type:text"`
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
@@ -152,7 +160,7 @@ func (token *Token) Update() error {
 		token.ChatCache = false
 	}
 
-	err := DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "chat_cache", "group").Updates(token).Error
+	err := DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "chat_cache", "chat_cache_threshold", "group", "rpm", "tpm", "max_concurrency", "model_fallbacks").Updates(token).Error
 	// 防止Redis缓存不生效，直接删除
 	if err == nil && config.RedisEnabled {
 		redis.RedisDel(fmt.Sprintf(UserTokensKey, token.Key))
@@ -235,23 +243,29 @@ func decreaseTokenQuota(id int, quota int) (err error) {
 	return err
 }
 
-func PreConsumeTokenQuota(tokenId int, quota int) (err error) {
+// PreConsumeTokenQuota 预扣额度，优先使用订阅额度，返回使用的订阅额度
+func PreConsumeTokenQuota(tokenId int, quota int) (planQuota int, err error) {
 	if quota < 0 {
-		return errors.New("quota 不能为负数！")
+		return 0, errors.New("quota 不能为负数！")
 	}
 	token, err := GetTokenById(tokenId)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if !token.UnlimitedQuota && token.RemainQuota < quota {
-		return errors.New("令牌额度不足")
+		return 0, errors.New("令牌额度不足")
 	}
 	userQuota, err := GetUserQuota(token.UserId)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	userPlanQuota, err := GetUserPlanQuota(token.UserId)
+	if err != nil {
+		return 0, err
 	}
+	userQuota += userPlanQuota
 	if userQuota < quota {
-		return errors.New("用户额度不足")
+		return 0, errors.New("用户额度不足")
 	}
 	quotaTooLow := userQuota >= config.QuotaRemindThreshold && userQuota-quota < config.QuotaRemindThreshold
 	noMoreQuota := userQuota-quota <= 0
@@ -261,11 +275,10 @@ func PreConsumeTokenQuota(tokenId int, quota int) (err error) {
 	if !token.UnlimitedQuota {
 		err = DecreaseTokenQuota(tokenId, quota)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
-	err = DecreaseUserQuota(token.UserId, quota)
-	return err
+	return ConsumeUserQuota(token.UserId, quota)
 }
 
 func sendQuotaWarningEmail(userId int, userQuota int, noMoreQuota bool) {
@@ -293,18 +306,20 @@ func sendQuotaWarningEmail(userId int, userQuota int, noMoreQuota bool) {
 	}
 }
 
-func PostConsumeTokenQuota(tokenId int, quota int) (err error) {
+// PostConsumeTokenQuota 补扣或退还额度，退还时 planQuota 为其中退还到订阅额度的部分，返回订阅额度使用量的变化
+func PostConsumeTokenQuota(tokenId int, quota int, planQuota int) (planDelta int, err error) {
 	token, err := GetTokenById(tokenId)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if quota > 0 {
-		err = DecreaseUserQuota(token.UserId, quota)
+		planDelta, err = ConsumeUserQuota(token.UserId, quota)
 	} else {
-		err = IncreaseUserQuota(token.UserId, -quota)
+		planDelta = -min(max(planQuota, 0), -quota)
+		err = RefundUserQuota(token.UserId, -quota, -planDelta)
 	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if !token.UnlimitedQuota {
 		if quota > 0 {
@@ -313,8 +328,8 @@ func PostConsumeTokenQuota(tokenId int, quota int) (err error) {
 			err = IncreaseTokenQuota(tokenId, -quota)
 		}
 		if err != nil {
-			return err
+			return planDelta, err
 		}
 	}
-	return nil
+	return planDelta, nil
 }
@@ -7,12 +7,21 @@ type UserGroup struct {
 	Symbol string  `json:"symbol" gorm:"type:varchar(50);uniqueIndex"`
 	Name   string  `json:"name" gorm:"type:varchar(50)"`
 	Ratio  float64 `json:"ratio" gorm:"type:decimal(10,2); default:1"` // 倍率
-	// APIRate   int     `json:"api_rate" gorm:"default:0"`
-	Public bool `json:"public" form:"public" gorm:"default:false"` // 是否为公开分组，如果是，则可以被用户在令牌中选择
+	Public bool    `json:"public" form:"public" gorm:"default:false"`  // 是否为公开分组，如果是，则可以被用户在令牌中选择
 	// Promotion bool  `json:"promotion" form:"promotion" gorm:"default:false"` // 是否是自动升级用户组， 如果是则用户充值金额满足条件自动升级
 	// Min       int   `json:"min" form:"min" gorm:"default:0"`                 // 晋级条件最小值
 	// Max       int   `json:"max" form:"max" gorm:"default:0"`                 // 晋级条件最大值
 	Enable *bool `json:"enable" form:"enable" gorm:"default:true"` // 是否启用
+	// 语义缓存的相似度阈值，为 0 时使用全局设置
+	ChatCacheThreshold float64 `json:"chat_cache_threshold" gorm:"default:0"`
+	// 负载均衡策略，为空时使用全局设置
+	BalanceStrategy string `json:"balance_strategy" gorm:"type:varchar(32);default:''"`
+	// 渠道繁忙排队时的优先级，越大越先获得渠道
+	QueuePriority int `json:"queue_priority" gorm:"default:0"`
+	// 分组内每个用户的每分钟请求数、token 数和并发数限制，为 0 时不限制
+	RPM            int `json:"rpm" gorm:"column:rpm;default:0"`
+	TPM            int `json:"tpm" gorm:"column:tpm;default:0"`
+	MaxConcurrency int `json:"max_concurrency" gorm:"default:0"`
 }
 
 type SearchUserGroupParams struct {
@@ -68,7 +77,7 @@ func (c *UserGroup) Create() error {
 }
 
 func (c *UserGroup) Update() error {
-	err := DB.Select("name", "ratio", "public").Updates(c).Error
+	err := DB.Select("name", "ratio", "public", "chat_cache_threshold", "balance_strategy", "queue_priority", "rpm", "tpm", "max_concurrency").Updates(c).Error
 	if err == nil {
 		GlobalUserGroupRatio.Load()
 	}
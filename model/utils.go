@@ -15,6 +15,8 @@ const (
 	BatchUpdateTypeUsedQuota
 	BatchUpdateTypeChannelUsedQuota
 	BatchUpdateTypeRequestCount
+	BatchUpdateTypeChannelKeyUsedQuota
+	BatchUpdateTypeChannelKeyUsedCount
 	BatchUpdateTypeCount // if you add a new type, you need to add a new map and a new lock
 )
 
@@ -73,6 +75,10 @@ func batchUpdate() {
 				updateUserRequestCount(key, value)
 			case BatchUpdateTypeChannelUsedQuota:
 				updateChannelUsedQuota(key, value)
+			case BatchUpdateTypeChannelKeyUsedQuota:
+				updateChannelKeyUsage(key, value, 0)
+			case BatchUpdateTypeChannelKeyUsedCount:
+				updateChannelKeyUsage(key, 0, value)
 			}
 		}
 	}
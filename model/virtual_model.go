@@ -0,0 +1,201 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// VirtualModel 虚拟模型，调用方使用固定的模型名称，实际请求的模型、系统提示词和参数由管理员设置
+type VirtualModel struct {
+	Id    int    `json:"id"`
+	Name  string `json:"name" gorm:"type:varchar(100);uniqueIndex"`
+	Model string `json:"model" gorm:"type:varchar(100)"` // 实际请求的模型
+	// 可以使用的分组，多个分组用逗号分隔
+	Group        string `json:"group" gorm:"type:varchar(255);default:'default'"`
+	SystemPrompt string `json:"system_prompt" gorm:"type:text"`
+	// 调用方没有传入时使用的参数，JSON 对象
+	Defaults string `json:"defaults" gorm:"type:text"`
+	// 强制使用的参数，会覆盖调用方传入的值，JSON 对象
+	Forced    string `json:"forced" gorm:"type:text"`
+	Enable    *bool  `json:"enable" form:"enable" gorm:"default:true"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+type SearchVirtualModelParams struct {
+	VirtualModel
+	PaginationParams
+}
+
+var allowedVirtualModelOrderFields = map[string]bool{
+	"id":     true,
+	"name":   true,
+	"model":  true,
+	"enable": true,
+}
+
+// 参数中不允许设置的字段
+var virtualModelReservedParams = []string{"model", "messages", "stream"}
+
+// ParseVirtualModelParams 解析默认参数和强制参数
+func ParseVirtualModelParams(jsonStr string) (map[string]any, error) {
+	params := map[string]any{}
+	if strings.TrimSpace(jsonStr) == "" {
+		return params, nil
+	}
+
+	if err := json.Unmarshal([]byte(jsonStr), &params); err != nil {
+		return nil, err
+	}
+
+	for _, key := range virtualModelReservedParams {
+		if _, ok := params[key]; ok {
+			return nil, fmt.Errorf("parameter %s is not allowed", key)
+		}
+	}
+
+	return params, nil
+}
+
+// Validate 检查虚拟模型的配置
+func (v *VirtualModel) Validate() error {
+	if v.Name == "" || v.Model == "" {
+		return fmt.Errorf("名称和实际模型不能为空")
+	}
+	if v.Name == v.Model {
+		return fmt.Errorf("虚拟模型不能与实际模型同名")
+	}
+	if _, err := ParseVirtualModelParams(v.Defaults); err != nil {
+		return fmt.Errorf("默认参数格式错误: %s", err.Error())
+	}
+	if _, err := ParseVirtualModelParams(v.Forced); err != nil {
+		return fmt.Errorf("强制参数格式错误: %s", err.Error())
+	}
+	return nil
+}
+
+// AllowGroup 分组是否可以使用该虚拟模型
+func (v *VirtualModel) AllowGroup(group string) bool {
+	for _, item := range strings.Split(v.Group, ",") {
+		if strings.TrimSpace(item) == group {
+			return true
+		}
+	}
+	return false
+}
+
+func GetVirtualModelsList(params *SearchVirtualModelParams) (*DataResult[VirtualModel], error) {
+	var virtualModels []*VirtualModel
+	db := DB
+
+	if params.Name != "" {
+		db = db.Where("name LIKE ?", params.Name+"%")
+	}
+
+	if params.Model != "" {
+		db = db.Where("model = ?", params.Model)
+	}
+
+	if params.Enable != nil {
+		db = db.Where("enable = ?", *params.Enable)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &virtualModels, allowedVirtualModelOrderFields)
+}
+
+func GetVirtualModelById(id int) (*VirtualModel, error) {
+	var virtualModel VirtualModel
+	err := DB.Where("id = ?", id).First(&virtualModel).Error
+	return &virtualModel, err
+}
+
+func GetVirtualModelsAll() ([]*VirtualModel, error) {
+	var virtualModels []*VirtualModel
+	err := DB.Where("enable = ?", true).Find(&virtualModels).Error
+	return virtualModels, err
+}
+
+func (v *VirtualModel) Create() error {
+	err := DB.Create(v).Error
+	if err == nil {
+		GlobalVirtualModels.Load()
+	}
+	return err
+}
+
+func (v *VirtualModel) Update() error {
+	err := DB.Select("name", "model", "group", "system_prompt", "defaults", "forced").Updates(v).Error
+	if err == nil {
+		GlobalVirtualModels.Load()
+	}
+	return err
+}
+
+func (v *VirtualModel) Delete() error {
+	err := DB.Delete(v).Error
+	if err == nil {
+		GlobalVirtualModels.Load()
+	}
+	return err
+}
+
+func ChangeVirtualModelEnable(id int, enable bool) error {
+	err := DB.Model(&VirtualModel{}).Where("id = ?", id).Update("enable", enable).Error
+	if err == nil {
+		GlobalVirtualModels.Load()
+	}
+	return err
+}
+
+type VirtualModels struct {
+	sync.RWMutex
+	Models map[string]*VirtualModel
+}
+
+var GlobalVirtualModels = VirtualModels{}
+
+func (vm *VirtualModels) Load() {
+	virtualModels, err := GetVirtualModelsAll()
+	if err != nil {
+		return
+	}
+
+	newModels := make(map[string]*VirtualModel, len(virtualModels))
+	for _, virtualModel := range virtualModels {
+		newModels[virtualModel.Name] = virtualModel
+	}
+
+	vm.Lock()
+	defer vm.Unlock()
+
+	vm.Models = newModels
+}
+
+// Get 返回分组可以使用的虚拟模型，不存在或没有权限时返回 nil
+func (vm *VirtualModels) Get(name, group string) *VirtualModel {
+	vm.RLock()
+	defer vm.RUnlock()
+
+	virtualModel, ok := vm.Models[name]
+	if !ok || !virtualModel.AllowGroup(group) {
+		return nil
+	}
+
+	return virtualModel
+}
+
+// GetGroupModels 返回分组可以使用的虚拟模型
+func (vm *VirtualModels) GetGroupModels(group string) []*VirtualModel {
+	vm.RLock()
+	defer vm.RUnlock()
+
+	virtualModels := make([]*VirtualModel, 0)
+	for _, virtualModel := range vm.Models {
+		if virtualModel.AllowGroup(group) {
+			virtualModels = append(virtualModels, virtualModel)
+		}
+	}
+
+	return virtualModels
+}
@@ -68,6 +68,14 @@ func (e *Stripe) Pay(config *types.PayConfig, gatewayConfig string) (*types.PayR
 		params.CustomerEmail = stripe.String(config.User.Email)
 	}
 
+	// 自动续费需要创建客户并保存支付方式
+	if config.Recurring {
+		params.CustomerCreation = stripe.String(string(stripe.CheckoutSessionCustomerCreationAlways))
+		params.PaymentIntentData = &stripe.CheckoutSessionPaymentIntentDataParams{
+			SetupFutureUsage: stripe.String(string(stripe.PaymentIntentSetupFutureUsageOffSession)),
+		}
+	}
+
 	result, err := sc.CheckoutSessions.New(params)
 	if err != nil {
 		return nil, err
@@ -198,8 +206,78 @@ func (e *Stripe) HandleCallback(c *gin.Context, gatewayConfig string) (*types.Pa
 			GatewayNo: session.PaymentIntent.ID,
 		}
 
+		if session.Customer != nil && session.PaymentIntent != nil {
+			payNotify.RecurringToken = getRecurringToken(sc, session.Customer.ID, session.PaymentIntent.ID)
+		}
+
 		return payNotify, nil
 	default:
 		return nil, nil
 	}
 }
+
+// getRecurringToken 获取首次支付保存的支付方式，没有保存时返回空
+func getRecurringToken(sc *client.API, customerID, paymentIntentID string) string {
+	paymentIntent, err := sc.PaymentIntents.Get(paymentIntentID, nil)
+	if err != nil || paymentIntent.PaymentMethod == nil || paymentIntent.SetupFutureUsage == "" {
+		return ""
+	}
+
+	token, err := json.Marshal(RecurringToken{
+		Customer:      customerID,
+		PaymentMethod: paymentIntent.PaymentMethod.ID,
+	})
+	if err != nil {
+		return ""
+	}
+	return string(token)
+}
+
+// Renew 使用保存的支付方式直接扣款
+func (e *Stripe) Renew(config *types.PayConfig, gatewayConfig string, recurringToken string) (*types.PayNotify, error) {
+	var stripeConfig StripeConfig
+	if err := json.Unmarshal([]byte(gatewayConfig), &stripeConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse gateway config: %v", err)
+	}
+
+	var token RecurringToken
+	if err := json.Unmarshal([]byte(recurringToken), &token); err != nil {
+		return nil, fmt.Errorf("failed to parse recurring token: %v", err)
+	}
+
+	sc := &client.API{}
+	sc.Init(stripeConfig.SecretKey, nil)
+	currency := "usd"
+	if config.Currency == "CNY" {
+		currency = "cny"
+	}
+
+	params := &stripe.PaymentIntentParams{
+		Amount:        stripe.Int64(int64(math.Round(config.Money * 100))),
+		Currency:      stripe.String(currency),
+		Customer:      stripe.String(token.Customer),
+		PaymentMethod: stripe.String(token.PaymentMethod),
+		OffSession:    stripe.Bool(true),
+		Confirm:       stripe.Bool(true),
+		Metadata: map[string]string{
+			"trade_no": config.TradeNo,
+			"user_id":  fmt.Sprintf("%d", config.User.Id),
+		},
+	}
+	// 同一个订单重试时不会重复扣款
+	params.SetIdempotencyKey("renew_" + config.TradeNo)
+
+	paymentIntent, err := sc.PaymentIntents.New(params)
+	if err != nil {
+		return nil, err
+	}
+	if paymentIntent.Status != stripe.PaymentIntentStatusSucceeded {
+		return nil, fmt.Errorf("payment intent status: %s", paymentIntent.Status)
+	}
+
+	return &types.PayNotify{
+		TradeNo:        config.TradeNo,
+		GatewayNo:      paymentIntent.ID,
+		RecurringToken: recurringToken,
+	}, nil
+}
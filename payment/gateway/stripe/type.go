@@ -6,3 +6,9 @@ type StripeConfig struct {
 	SecretKey     string `json:"secret_key"`
 	WebhookSecret string `json:"webhook_secret"`
 }
+
+// RecurringToken 自动续费使用的客户和支付方式
+type RecurringToken struct {
+	Customer      string `json:"customer"`
+	PaymentMethod string `json:"payment_method"`
+}
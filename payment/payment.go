@@ -18,6 +18,11 @@ type PaymentProcessor interface {
 	HandleCallback(c *gin.Context, gatewayConfig string) (*types.PayNotify, error)
 }
 
+// RecurringProcessor 支持自动续费的支付网关，首次支付时保存扣款凭证，续费时直接扣款
+type RecurringProcessor interface {
+	Renew(config *types.PayConfig, gatewayConfig string, recurringToken string) (*types.PayNotify, error)
+}
+
 var Gateways = make(map[string]PaymentProcessor)
 
 func init() {
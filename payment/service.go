@@ -28,6 +28,19 @@ func NewPaymentService(uuid string) (*PaymentService, error) {
 		return nil, errors.New("payment not found")
 	}
 
+	return newPaymentService(payment)
+}
+
+func NewPaymentServiceByID(id int) (*PaymentService, error) {
+	payment, err := model.GetPaymentByID(id)
+	if err != nil {
+		return nil, errors.New("payment not found")
+	}
+
+	return newPaymentService(payment)
+}
+
+func newPaymentService(payment *model.Payment) (*PaymentService, error) {
 	gateway, ok := Gateways[payment.Type]
 	if !ok {
 		return nil, errors.New("payment gateway not found")
@@ -45,7 +58,33 @@ func (s *PaymentService) CreatedPay() error {
 }
 
 func (s *PaymentService) Pay(tradeNo string, amount float64, user *model.User) (*types.PayRequest, error) {
-	config := &types.PayConfig{
+	return s.pay(s.getPayConfig(tradeNo, amount, user))
+}
+
+// PaySubscription 订阅支付，recurring 为 true 且网关支持时保存扣款凭证用于自动续费
+func (s *PaymentService) PaySubscription(tradeNo string, amount float64, user *model.User, recurring bool) (*types.PayRequest, error) {
+	config := s.getPayConfig(tradeNo, amount, user)
+	config.Recurring = recurring && s.SupportRecurring()
+	return s.pay(config)
+}
+
+func (s *PaymentService) SupportRecurring() bool {
+	_, ok := s.gateway.(RecurringProcessor)
+	return ok
+}
+
+// Renew 使用保存的扣款凭证自动续费
+func (s *PaymentService) Renew(tradeNo string, amount float64, user *model.User, recurringToken string) (*types.PayNotify, error) {
+	recurring, ok := s.gateway.(RecurringProcessor)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support recurring payment", s.gateway.Name())
+	}
+
+	return recurring.Renew(s.getPayConfig(tradeNo, amount, user), s.Payment.Config, recurringToken)
+}
+
+func (s *PaymentService) getPayConfig(tradeNo string, amount float64, user *model.User) *types.PayConfig {
+	return &types.PayConfig{
 		Money:     amount,
 		TradeNo:   tradeNo,
 		NotifyURL: s.getNotifyURL(),
@@ -53,6 +92,9 @@ func (s *PaymentService) Pay(tradeNo string, amount float64, user *model.User) (
 		Currency:  s.Payment.Currency,
 		User:      user,
 	}
+}
+
+func (s *PaymentService) pay(config *types.PayConfig) (*types.PayRequest, error) {
 	payRequest, err := s.gateway.Pay(config, s.Payment.Config)
 	if err != nil {
 		return nil, err
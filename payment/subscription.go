@@ -0,0 +1,148 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+
+	"gorm.io/gorm"
+)
+
+// CalculatePlanAmount 订阅不参与充值折扣，只计算手续费
+func CalculatePlanAmount(payment *model.Payment, price float64) (fee, payMoney float64) {
+	if payment.PercentFee > 0 {
+		fee = utils.Decimal(price*payment.PercentFee, 2)
+	} else if payment.FixedFee > 0 {
+		fee = payment.FixedFee
+	}
+
+	total := utils.Decimal(price+fee, 2)
+	if payment.Currency == model.CurrencyTypeUSD {
+		payMoney = total
+	} else {
+		payMoney = utils.Decimal(total*config.PaymentUSDRate, 2)
+	}
+	return
+}
+
+// RenewSubscriptions 重试已支付但未开通的订阅订单，并在到期前一天使用保存的扣款凭证自动续费
+func RenewSubscriptions() {
+	orders, err := model.GetPaidSubscriptionOrders()
+	if err != nil {
+		logger.SysError("failed to get paid subscription orders: " + err.Error())
+		return
+	}
+	for _, order := range orders {
+		if err := model.FulfillSubscriptionOrder(order); err != nil {
+			logger.SysError(fmt.Sprintf("failed to fulfill subscription order, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+		}
+	}
+
+	subscriptions, err := model.GetRenewableSubscriptions(utils.GetTimestamp() + 86400)
+	if err != nil {
+		logger.SysError("failed to get renewable subscriptions: " + err.Error())
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		if err := renewSubscription(subscription); err != nil {
+			logger.SysError(fmt.Sprintf("failed to renew subscription #%d: %s", subscription.Id, err.Error()))
+		}
+	}
+}
+
+// renewSubscription 先创建续费订单再扣款，扣款失败时关闭自动续费，改为提醒用户手动续费
+// 扣款成功后订单标记为已支付，开通失败时由下一次任务重试，不会重复扣款
+func renewSubscription(subscription *model.UserSubscription) error {
+	order, err := model.GetPendingRenewalOrder(subscription.Id)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil && order.Status == model.OrderStatusPaid {
+		// 已扣款但开通失败，等待重试
+		return nil
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		order, err = createRenewalOrder(subscription)
+		if err != nil {
+			disableAutoRenew(subscription, err)
+			return err
+		}
+	}
+
+	paymentService, err := NewPaymentServiceByID(order.GatewayId)
+	if err == nil && (paymentService.Payment.Enable == nil || !*paymentService.Payment.Enable) {
+		err = errors.New("支付方式已停用")
+	}
+	var user *model.User
+	if err == nil {
+		user, err = model.GetUserById(subscription.UserId, false)
+	}
+	if err != nil {
+		failRenewalOrder(order, subscription, err)
+		return err
+	}
+
+	payNotify, err := paymentService.Renew(order.TradeNo, order.OrderAmount, user, subscription.RecurringToken)
+	if err != nil {
+		failRenewalOrder(order, subscription, err)
+		return err
+	}
+
+	order.GatewayNo = payNotify.GatewayNo
+	order.RecurringToken = payNotify.RecurringToken
+	order.Status = model.OrderStatusPaid
+	if err := order.Update(); err != nil {
+		return err
+	}
+
+	return model.FulfillSubscriptionOrder(order)
+}
+
+func createRenewalOrder(subscription *model.UserSubscription) (*model.Order, error) {
+	plan, err := model.GetSubscriptionPlanById(subscription.PlanId)
+	if err != nil || plan.Enable == nil || !*plan.Enable {
+		return nil, errors.New("套餐已下架")
+	}
+
+	paymentService, err := NewPaymentServiceByID(subscription.GatewayId)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, payMoney := CalculatePlanAmount(paymentService.Payment, plan.Price)
+	order := &model.Order{
+		UserId:         subscription.UserId,
+		GatewayId:      paymentService.Payment.ID,
+		TradeNo:        utils.GenerateTradeNo(),
+		OrderAmount:    payMoney,
+		OrderCurrency:  paymentService.Payment.Currency,
+		Fee:            fee,
+		Status:         model.OrderStatusPending,
+		Quota:          plan.Quota,
+		PlanId:         plan.Id,
+		AutoRenew:      true,
+		SubscriptionId: subscription.Id,
+	}
+	if err := order.Insert(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func failRenewalOrder(order *model.Order, subscription *model.UserSubscription, err error) {
+	order.Status = model.OrderStatusFailed
+	if updateErr := order.Update(); updateErr != nil {
+		logger.SysError(fmt.Sprintf("failed to update renewal order, trade_no: %s, error: %s", order.TradeNo, updateErr.Error()))
+	}
+	disableAutoRenew(subscription, err)
+}
+
+func disableAutoRenew(subscription *model.UserSubscription, err error) {
+	model.DisableSubscriptionAutoRenew(subscription.Id)
+	model.RecordLog(subscription.UserId, model.LogTypeSystem, fmt.Sprintf("订阅自动续费失败，请手动续费：%s", err.Error()))
+}
@@ -10,6 +10,7 @@ type PayConfig struct {
 	Money     float64            `json:"money"`
 	Currency  model.CurrencyType `json:"currency"`
 	User      *model.User        `json:"user"`
+	Recurring bool               `json:"recurring"` // 是否保存扣款凭证用于自动续费
 }
 
 // 请求支付时的数据结构
@@ -28,4 +29,6 @@ type PayRequestData struct {
 type PayNotify struct {
 	TradeNo   string `json:"trade_no"`
 	GatewayNo string `json:"gateway_no"`
+	// 支持自动续费的网关返回的扣款凭证
+	RecurringToken string `json:"recurring_token,omitempty"`
 }
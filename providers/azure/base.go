@@ -37,6 +37,7 @@ func getAzureConfig() base.ProviderConfig {
 		AudioTranslations:   "/audio/translations",
 		ImagesGenerations:   "/images/generations",
 		ChatRealtime:        "/realtime",
+		Responses:           "/v1/responses",
 	}
 }
 
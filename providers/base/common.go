@@ -30,6 +30,7 @@ type ProviderConfig struct {
 	ModelList           string
 	Rerank              string
 	ChatRealtime        string
+	Responses           string
 }
 
 func (pc *ProviderConfig) SetAPIUri(customMapping map[string]interface{}) {
@@ -44,6 +45,7 @@ func (pc *ProviderConfig) SetAPIUri(customMapping map[string]interface{}) {
 		config.RelayModeImagesGenerations:  &pc.ImagesGenerations,
 		config.RelayModeImagesEdits:        &pc.ImagesEdit,
 		config.RelayModeImagesVariations:   &pc.ImagesVariations,
+		config.RelayModeResponses:          &pc.Responses,
 	}
 
 	for key, value := range customMapping {
@@ -186,6 +188,8 @@ func (p *BaseProvider) GetAPIUri(relayMode int) string {
 		return p.Config.Rerank
 	case config.RelayModeChatRealtime:
 		return p.Config.ChatRealtime
+	case config.RelayModeResponses:
+		return p.Config.Responses
 	default:
 		return ""
 	}
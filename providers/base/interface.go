@@ -53,6 +53,13 @@ type ChatInterface interface {
 	CreateChatCompletionStream(request *types.ChatCompletionRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode)
 }
 
+// Responses 接口
+type ResponsesInterface interface {
+	ProviderInterface
+	CreateResponses(request *types.ResponsesRequest) (*types.ResponsesResponse, *types.OpenAIErrorWithStatusCode)
+	CreateResponsesStream(request *types.ResponsesRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode)
+}
+
 // 嵌入接口
 type EmbeddingsInterface interface {
 	ProviderInterface
@@ -0,0 +1,545 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/providers/base"
+	"one-api/types"
+	"strings"
+)
+
+// ChatAdapter 将任意实现了 base.ChatInterface 的渠道包装为 ClaudeChatInterface
+type ChatAdapter struct {
+	base.ChatInterface
+}
+
+func NewChatAdapter(provider base.ChatInterface) *ChatAdapter {
+	return &ChatAdapter{ChatInterface: provider}
+}
+
+func (a *ChatAdapter) CreateClaudeChat(request *ClaudeRequest) (*ClaudeResponse, *ClaudeErrorWithStatusCode) {
+	chatRequest, err := ConvertToChatOpenaiRequest(request)
+	if err != nil {
+		return nil, StringErrorWrapper(err.Error(), "conversion_error", http.StatusBadRequest, true)
+	}
+
+	response, errWithCode := a.CreateChatCompletion(chatRequest)
+	if errWithCode != nil {
+		return nil, OpenaiErrToClaudeErr(errWithCode)
+	}
+
+	return ConvertFromChatOpenaiResponse(response), nil
+}
+
+func (a *ChatAdapter) CreateClaudeChatStream(request *ClaudeRequest) (requester.StreamReaderInterface[string], *ClaudeErrorWithStatusCode) {
+	chatRequest, err := ConvertToChatOpenaiRequest(request)
+	if err != nil {
+		return nil, StringErrorWrapper(err.Error(), "conversion_error", http.StatusBadRequest, true)
+	}
+
+	stream, errWithCode := a.CreateChatCompletionStream(chatRequest)
+	if errWithCode != nil {
+		return nil, OpenaiErrToClaudeErr(errWithCode)
+	}
+
+	return newOpenaiStreamConverter(stream, a.GetUsage(), request.Model), nil
+}
+
+// ConvertToChatOpenaiRequest 将 Claude 请求转换为 OpenAI 聊天请求
+func ConvertToChatOpenaiRequest(request *ClaudeRequest) (*types.ChatCompletionRequest, error) {
+	chatRequest := &types.ChatCompletionRequest{
+		Model:       request.Model,
+		Messages:    make([]types.ChatCompletionMessage, 0, len(request.Messages)+1),
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+		TopP:        request.TopP,
+		Stream:      request.Stream,
+	}
+
+	if len(request.StopSequences) > 0 {
+		chatRequest.Stop = request.StopSequences
+	}
+
+	if system := systemToString(request.System); system != "" {
+		chatRequest.Messages = append(chatRequest.Messages, types.ChatCompletionMessage{
+			Role:    types.ChatMessageRoleSystem,
+			Content: system,
+		})
+	}
+
+	for _, message := range request.Messages {
+		messages, err := convertClaudeMessage(&message)
+		if err != nil {
+			return nil, err
+		}
+		chatRequest.Messages = append(chatRequest.Messages, messages...)
+	}
+
+	for _, tool := range request.Tools {
+		// 跳过 computer use 等 Claude 内置工具
+		if tool.InputSchema == nil {
+			continue
+		}
+		chatRequest.Tools = append(chatRequest.Tools, &types.ChatCompletionTool{
+			Type: "function",
+			Function: types.ChatCompletionFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+
+	if request.ToolChoice != nil && len(chatRequest.Tools) > 0 {
+		switch request.ToolChoice.Type {
+		case "any":
+			chatRequest.ToolChoice = types.ToolChoiceTypeRequired
+		case "tool":
+			chatRequest.ToolChoice = map[string]any{
+				"type": types.ToolChoiceTypeFunction,
+				"function": map[string]any{
+					"name": request.ToolChoice.Name,
+				},
+			}
+		case "none":
+			chatRequest.ToolChoice = types.ToolChoiceTypeNone
+		default:
+			chatRequest.ToolChoice = types.ToolChoiceTypeAuto
+		}
+	}
+
+	return chatRequest, nil
+}
+
+func systemToString(system any) string {
+	switch v := system.(type) {
+	case string:
+		return v
+	case []any:
+		return joinTextBlocks(v)
+	}
+
+	return ""
+}
+
+func joinTextBlocks(blocks []any) string {
+	var texts []string
+	for _, item := range blocks {
+		block, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		if text, ok := block["text"].(string); ok && text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func convertClaudeMessage(message *Message) ([]types.ChatCompletionMessage, error) {
+	role := types.ChatMessageRoleUser
+	if message.Role == types.ChatMessageRoleAssistant {
+		role = types.ChatMessageRoleAssistant
+	}
+
+	if content, ok := message.Content.(string); ok {
+		return []types.ChatCompletionMessage{{Role: role, Content: content}}, nil
+	}
+
+	blocks, ok := message.Content.([]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid content of %s message", message.Role)
+	}
+
+	var messages []types.ChatCompletionMessage
+	var parts []any
+	var toolCalls []*types.ChatCompletionToolCalls
+
+	for _, item := range blocks {
+		block, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		switch block["type"] {
+		case ContentTypeText:
+			text, _ := block["text"].(string)
+			parts = append(parts, map[string]any{
+				"type": types.ContentTypeText,
+				"text": text,
+			})
+		case ContentTypeImage:
+			url := imageSourceToURL(block["source"])
+			if url == "" {
+				continue
+			}
+			parts = append(parts, map[string]any{
+				"type": types.ContentTypeImageURL,
+				"image_url": map[string]any{
+					"url": url,
+				},
+			})
+		case ContentTypeToolUes:
+			arguments, err := json.Marshal(block["input"])
+			if err != nil {
+				return nil, err
+			}
+			id, _ := block["id"].(string)
+			name, _ := block["name"].(string)
+			toolCalls = append(toolCalls, &types.ChatCompletionToolCalls{
+				Id:    id,
+				Type:  types.ChatMessageRoleFunction,
+				Index: len(toolCalls),
+				Function: &types.ChatCompletionToolCallsFunction{
+					Name:      name,
+					Arguments: string(arguments),
+				},
+			})
+		case ContentTypeToolResult:
+			// OpenAI 中工具结果是独立的 tool 消息
+			toolUseId, _ := block["tool_use_id"].(string)
+			messages = append(messages, types.ChatCompletionMessage{
+				Role:       types.ChatMessageRoleTool,
+				Content:    toolResultToString(block["content"]),
+				ToolCallID: toolUseId,
+			})
+		}
+	}
+
+	if len(parts) > 0 || len(toolCalls) > 0 {
+		chatMessage := types.ChatCompletionMessage{
+			Role: role,
+		}
+		if len(parts) > 0 {
+			chatMessage.Content = parts
+		}
+		if len(toolCalls) > 0 {
+			chatMessage.ToolCalls = toolCalls
+		}
+		messages = append(messages, chatMessage)
+	}
+
+	return messages, nil
+}
+
+func imageSourceToURL(source any) string {
+	sourceMap, ok := source.(map[string]any)
+	if !ok {
+		return ""
+	}
+
+	switch sourceMap["type"] {
+	case "base64":
+		mediaType, _ := sourceMap["media_type"].(string)
+		data, _ := sourceMap["data"].(string)
+		return fmt.Sprintf("data:%s;base64,%s", mediaType, data)
+	case "url":
+		url, _ := sourceMap["url"].(string)
+		return url
+	}
+
+	return ""
+}
+
+func toolResultToString(content any) string {
+	switch v := content.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		return joinTextBlocks(v)
+	}
+
+	data, _ := json.Marshal(content)
+	return string(data)
+}
+
+func stopReasonOpenAI2Claude(reason string) string {
+	switch reason {
+	case types.FinishReasonLength:
+		return "max_tokens"
+	case types.FinishReasonToolCalls, types.FinishReasonFunctionCall:
+		return FinishReasonToolUse
+	default:
+		return FinishReasonEndTurn
+	}
+}
+
+// ConvertFromChatOpenaiResponse 将 OpenAI 聊天响应转换为 Claude 响应
+func ConvertFromChatOpenaiResponse(response *types.ChatCompletionResponse) *ClaudeResponse {
+	claudeResponse := &ClaudeResponse{
+		Id:      response.ID,
+		Type:    "message",
+		Role:    types.ChatMessageRoleAssistant,
+		Content: make([]ResContent, 0),
+		Model:   response.Model,
+	}
+
+	finishReason := ""
+	if len(response.Choices) > 0 {
+		choice := response.Choices[0]
+		if reason, ok := choice.FinishReason.(string); ok {
+			finishReason = reason
+		}
+
+		if text := choice.Message.StringContent(); text != "" {
+			claudeResponse.Content = append(claudeResponse.Content, ResContent{
+				Type: ContentTypeText,
+				Text: text,
+			})
+		}
+
+		choice.Message.FuncToToolCalls()
+		for _, toolCall := range choice.Message.ToolCalls {
+			var input any = map[string]any{}
+			if toolCall.Function.Arguments != "" {
+				_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &input)
+			}
+			claudeResponse.Content = append(claudeResponse.Content, ResContent{
+				Type:  ContentTypeToolUes,
+				Id:    toolCall.Id,
+				Name:  toolCall.Function.Name,
+				Input: input,
+			})
+			finishReason = types.FinishReasonToolCalls
+		}
+	}
+	claudeResponse.StopReason = stopReasonOpenAI2Claude(finishReason)
+
+	if response.Usage != nil {
+		claudeResponse.Usage = Usage{
+			InputTokens:  response.Usage.PromptTokens,
+			OutputTokens: response.Usage.CompletionTokens,
+		}
+	}
+
+	return claudeResponse
+}
+
+// openaiStreamConverter 将 OpenAI 流式响应转换为 Claude 事件流
+type openaiStreamConverter struct {
+	stream    requester.StreamReaderInterface[string]
+	usage     *types.Usage
+	modelName string
+
+	started      bool
+	blockIndex   int
+	blockOpen    bool
+	blockType    string
+	toolIndex    int
+	finishReason string
+
+	dataChan chan string
+	errChan  chan error
+}
+
+func newOpenaiStreamConverter(stream requester.StreamReaderInterface[string], usage *types.Usage, modelName string) *openaiStreamConverter {
+	return &openaiStreamConverter{
+		stream:     stream,
+		usage:      usage,
+		modelName:  modelName,
+		blockIndex: -1,
+		toolIndex:  -1,
+		dataChan:   make(chan string),
+		errChan:    make(chan error),
+	}
+}
+
+func (s *openaiStreamConverter) Recv() (<-chan string, <-chan error) {
+	go s.process()
+	return s.dataChan, s.errChan
+}
+
+func (s *openaiStreamConverter) Close() {
+	s.stream.Close()
+}
+
+func (s *openaiStreamConverter) process() {
+	dataChan, errChan := s.stream.Recv()
+	for {
+		select {
+		case data := <-dataChan:
+			s.handleChunk(data)
+		case err := <-errChan:
+			if err != io.EOF {
+				s.errChan <- openaiStreamErrToClaudeErr(err)
+				return
+			}
+			s.finish()
+			s.errChan <- io.EOF
+			return
+		}
+	}
+}
+
+func openaiStreamErrToClaudeErr(err error) *ClaudeError {
+	aiErr, ok := err.(*types.OpenAIError)
+	if !ok {
+		return ErrorToClaudeErr(err)
+	}
+
+	return &ClaudeError{
+		Type: "error",
+		ErrorInfo: ClaudeErrorInfo{
+			Type:    aiErr.Type,
+			Message: aiErr.Message,
+		},
+	}
+}
+
+func (s *openaiStreamConverter) handleChunk(data string) {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+
+	s.start(chunk.ID)
+
+	if len(chunk.Choices) == 0 {
+		return
+	}
+
+	choice := chunk.Choices[0]
+	delta := choice.Delta
+	if delta.FunctionCall != nil && delta.ToolCalls == nil {
+		delta.ToolCalls = []*types.ChatCompletionToolCalls{{Function: delta.FunctionCall}}
+	}
+
+	if delta.Content != "" {
+		if !s.blockOpen || s.blockType != ContentTypeText {
+			s.startBlock(ContentTypeText, map[string]any{
+				"type": ContentTypeText,
+				"text": "",
+			})
+		}
+		s.send("content_block_delta", map[string]any{
+			"type":  "content_block_delta",
+			"index": s.blockIndex,
+			"delta": map[string]any{
+				"type": "text_delta",
+				"text": delta.Content,
+			},
+		})
+	}
+
+	for _, toolCall := range delta.ToolCalls {
+		if toolCall.Function == nil {
+			continue
+		}
+
+		// 新的工具调用需要开启新的 content block
+		if !s.blockOpen || s.blockType != ContentTypeToolUes || toolCall.Index != s.toolIndex || toolCall.Id != "" {
+			id := toolCall.Id
+			if id == "" {
+				id = fmt.Sprintf("toolu_%s", utils.GetUUID())
+			}
+			s.toolIndex = toolCall.Index
+			s.startBlock(ContentTypeToolUes, map[string]any{
+				"type":  ContentTypeToolUes,
+				"id":    id,
+				"name":  toolCall.Function.Name,
+				"input": map[string]any{},
+			})
+		}
+
+		if toolCall.Function.Arguments != "" {
+			s.send("content_block_delta", map[string]any{
+				"type":  "content_block_delta",
+				"index": s.blockIndex,
+				"delta": map[string]any{
+					"type":         "input_json_delta",
+					"partial_json": toolCall.Function.Arguments,
+				},
+			})
+		}
+	}
+
+	if reason, ok := choice.FinishReason.(string); ok && reason != "" {
+		s.finishReason = reason
+	}
+}
+
+func (s *openaiStreamConverter) start(id string) {
+	if s.started {
+		return
+	}
+	s.started = true
+
+	if id == "" {
+		id = fmt.Sprintf("msg_%s", utils.GetUUID())
+	}
+
+	s.send("message_start", map[string]any{
+		"type": "message_start",
+		"message": map[string]any{
+			"id":            id,
+			"type":          "message",
+			"role":          types.ChatMessageRoleAssistant,
+			"content":       []any{},
+			"model":         s.modelName,
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage": map[string]any{
+				"input_tokens":  s.usage.PromptTokens,
+				"output_tokens": 0,
+			},
+		},
+	})
+}
+
+func (s *openaiStreamConverter) startBlock(blockType string, contentBlock map[string]any) {
+	s.stopBlock()
+	s.blockIndex++
+	s.blockOpen = true
+	s.blockType = blockType
+	s.send("content_block_start", map[string]any{
+		"type":          "content_block_start",
+		"index":         s.blockIndex,
+		"content_block": contentBlock,
+	})
+}
+
+func (s *openaiStreamConverter) stopBlock() {
+	if !s.blockOpen {
+		return
+	}
+	s.blockOpen = false
+	s.send("content_block_stop", map[string]any{
+		"type":  "content_block_stop",
+		"index": s.blockIndex,
+	})
+}
+
+func (s *openaiStreamConverter) finish() {
+	s.start("")
+	s.stopBlock()
+
+	finishReason := s.finishReason
+	if s.blockType == ContentTypeToolUes {
+		finishReason = types.FinishReasonToolCalls
+	}
+
+	s.send("message_delta", map[string]any{
+		"type": "message_delta",
+		"delta": map[string]any{
+			"stop_reason":   stopReasonOpenAI2Claude(finishReason),
+			"stop_sequence": nil,
+		},
+		"usage": map[string]any{
+			"output_tokens": s.usage.CompletionTokens,
+		},
+	})
+	s.send("message_stop", map[string]any{
+		"type": "message_stop",
+	})
+}
+
+func (s *openaiStreamConverter) send(event string, data any) {
+	body, _ := json.Marshal(data)
+	s.dataChan <- fmt.Sprintf("event: %s\ndata: %s\n\n", event, body)
+}
@@ -0,0 +1,116 @@
+package claude_test
+
+import (
+	"encoding/json"
+	"one-api/providers/claude"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getClaudeRequest(t *testing.T, body string) *claude.ClaudeRequest {
+	request := &claude.ClaudeRequest{}
+	err := json.Unmarshal([]byte(body), request)
+	assert.Nil(t, err)
+
+	return request
+}
+
+func TestConvertToChatOpenaiRequestSystem(t *testing.T) {
+	request := getClaudeRequest(t, `{"model":"claude-3","system":[{"type":"text","text":"a"},{"type":"text","text":"b"}],"max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`)
+
+	chatRequest, err := claude.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "claude-3", chatRequest.Model)
+	assert.Equal(t, 10, chatRequest.MaxTokens)
+	assert.Len(t, chatRequest.Messages, 2)
+	// 多个 system 块合并为一条 system 消息
+	assert.Equal(t, types.ChatMessageRoleSystem, chatRequest.Messages[0].Role)
+	assert.Equal(t, "a\nb", chatRequest.Messages[0].Content)
+	assert.Equal(t, "hi", chatRequest.Messages[1].Content)
+}
+
+func TestConvertToChatOpenaiRequestImage(t *testing.T) {
+	request := getClaudeRequest(t, `{"model":"claude-3","messages":[{"role":"user","content":[{"type":"text","text":"what"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAA"}},{"type":"image","source":{"type":"url","url":"https://example.com/a.png"}}]}]}`)
+
+	chatRequest, err := claude.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+
+	content, _ := json.Marshal(chatRequest.Messages[0].Content)
+	assert.JSONEq(t, `[{"type":"text","text":"what"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAA"}},{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]`, string(content))
+}
+
+func TestConvertToChatOpenaiRequestToolUse(t *testing.T) {
+	request := getClaudeRequest(t, `{"model":"claude-3","messages":[{"role":"assistant","content":[{"type":"text","text":"calling"},{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Paris"}}]},{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"sunny"}]}]}]}`)
+
+	chatRequest, err := claude.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Len(t, chatRequest.Messages, 2)
+
+	assistant := chatRequest.Messages[0]
+	assert.Len(t, assistant.ToolCalls, 1)
+	assert.Equal(t, "toolu_1", assistant.ToolCalls[0].Id)
+	assert.Equal(t, "weather", assistant.ToolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"city":"Paris"}`, assistant.ToolCalls[0].Function.Arguments)
+
+	tool := chatRequest.Messages[1]
+	assert.Equal(t, types.ChatMessageRoleTool, tool.Role)
+	assert.Equal(t, "toolu_1", tool.ToolCallID)
+	assert.Equal(t, "sunny", tool.Content)
+}
+
+func TestConvertToChatOpenaiRequestToolChoice(t *testing.T) {
+	// 内置工具（computer 等）无法转换，直接丢弃
+	request := getClaudeRequest(t, `{"model":"claude-3","messages":[{"role":"user","content":"hi"}],"tools":[{"name":"weather","input_schema":{"type":"object"}},{"type":"computer_20241022","name":"computer"}],"tool_choice":{"type":"tool","name":"weather"}}`)
+
+	chatRequest, err := claude.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Len(t, chatRequest.Tools, 1)
+	assert.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "weather"}}, chatRequest.ToolChoice)
+
+	request = getClaudeRequest(t, `{"model":"claude-3","messages":[{"role":"user","content":"hi"}],"tools":[{"name":"weather","input_schema":{"type":"object"}}],"tool_choice":{"type":"any"}}`)
+	chatRequest, err = claude.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, types.ToolChoiceTypeRequired, chatRequest.ToolChoice)
+
+	// 没有工具时不传 tool_choice
+	request = getClaudeRequest(t, `{"model":"claude-3","messages":[{"role":"user","content":"hi"}],"tool_choice":{"type":"any"}}`)
+	chatRequest, err = claude.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Nil(t, chatRequest.ToolChoice)
+}
+
+func TestConvertToChatOpenaiRequestError(t *testing.T) {
+	request := &claude.ClaudeRequest{Messages: []claude.Message{{Role: "user", Content: 1}}}
+	_, err := claude.ConvertToChatOpenaiRequest(request)
+	assert.NotNil(t, err)
+}
+
+func TestConvertFromChatOpenaiResponse(t *testing.T) {
+	response := &types.ChatCompletionResponse{}
+	err := json.Unmarshal([]byte(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"hel"},"finish_reason":"length"}],"usage":{"prompt_tokens":3,"completion_tokens":2}}`), response)
+	assert.Nil(t, err)
+
+	claudeResponse := claude.ConvertFromChatOpenaiResponse(response)
+	assert.Equal(t, "message", claudeResponse.Type)
+	assert.Equal(t, "max_tokens", claudeResponse.StopReason)
+	assert.Len(t, claudeResponse.Content, 1)
+	assert.Equal(t, "hel", claudeResponse.Content[0].Text)
+	assert.Equal(t, 3, claudeResponse.Usage.InputTokens)
+	assert.Equal(t, 2, claudeResponse.Usage.OutputTokens)
+}
+
+func TestConvertFromChatOpenaiResponseToolCalls(t *testing.T) {
+	response := &types.ChatCompletionResponse{}
+	err := json.Unmarshal([]byte(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]},"finish_reason":"stop"}]}`), response)
+	assert.Nil(t, err)
+
+	// 有工具调用时即使上游返回 stop 也视为 tool_use
+	claudeResponse := claude.ConvertFromChatOpenaiResponse(response)
+	assert.Equal(t, claude.FinishReasonToolUse, claudeResponse.StopReason)
+	assert.Len(t, claudeResponse.Content, 1)
+	assert.Equal(t, "tool_use", claudeResponse.Content[0].Type)
+	assert.Equal(t, "call_1", claudeResponse.Content[0].Id)
+	assert.Equal(t, map[string]any{"city": "Paris"}, claudeResponse.Content[0].Input)
+}
@@ -0,0 +1,447 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common/requester"
+	"one-api/providers/base"
+	"one-api/types"
+	"strings"
+)
+
+// ChatAdapter 将任意实现了 base.ChatInterface 的渠道包装为 GeminiChatInterface
+type ChatAdapter struct {
+	base.ChatInterface
+}
+
+func NewChatAdapter(provider base.ChatInterface) *ChatAdapter {
+	return &ChatAdapter{ChatInterface: provider}
+}
+
+func (a *ChatAdapter) CreateGeminiChat(request *GeminiChatRequest) (*GeminiChatResponse, *GeminiErrorWithStatusCode) {
+	chatRequest, err := ConvertToChatOpenaiRequest(request)
+	if err != nil {
+		return nil, StringErrorWrapper(err.Error(), "INVALID_ARGUMENT", http.StatusBadRequest, true)
+	}
+
+	response, errWithCode := a.CreateChatCompletion(chatRequest)
+	if errWithCode != nil {
+		return nil, OpenaiErrToGeminiErr(errWithCode)
+	}
+
+	return ConvertFromChatOpenaiResponse(response, a.GetUsage()), nil
+}
+
+func (a *ChatAdapter) CreateGeminiChatStream(request *GeminiChatRequest) (requester.StreamReaderInterface[string], *GeminiErrorWithStatusCode) {
+	chatRequest, err := ConvertToChatOpenaiRequest(request)
+	if err != nil {
+		return nil, StringErrorWrapper(err.Error(), "INVALID_ARGUMENT", http.StatusBadRequest, true)
+	}
+
+	stream, errWithCode := a.CreateChatCompletionStream(chatRequest)
+	if errWithCode != nil {
+		return nil, OpenaiErrToGeminiErr(errWithCode)
+	}
+
+	return newOpenaiStreamConverter(stream, a.GetUsage(), request.Model), nil
+}
+
+// ConvertToChatOpenaiRequest 将 Gemini 请求转换为 OpenAI 聊天请求
+func ConvertToChatOpenaiRequest(request *GeminiChatRequest) (*types.ChatCompletionRequest, error) {
+	config := request.GenerationConfig
+	chatRequest := &types.ChatCompletionRequest{
+		Model:       request.Model,
+		Messages:    make([]types.ChatCompletionMessage, 0, len(request.Contents)+1),
+		MaxTokens:   config.MaxOutputTokens,
+		Temperature: config.Temperature,
+		TopP:        config.TopP,
+		Stream:      request.Stream,
+	}
+
+	if len(config.StopSequences) > 0 {
+		chatRequest.Stop = config.StopSequences
+	}
+
+	if config.CandidateCount > 1 {
+		candidateCount := config.CandidateCount
+		chatRequest.N = &candidateCount
+	}
+
+	if config.ResponseMimeType == "application/json" {
+		chatRequest.ResponseFormat = &types.ChatCompletionResponseFormat{Type: "json_object"}
+		if config.ResponseSchema != nil {
+			chatRequest.ResponseFormat = &types.ChatCompletionResponseFormat{
+				Type: "json_schema",
+				JsonSchema: map[string]any{
+					"name":   "response",
+					"schema": config.ResponseSchema,
+				},
+			}
+		}
+	}
+
+	if request.SystemInstruction != nil {
+		if system := partsToText(request.SystemInstruction.Parts); system != "" {
+			chatRequest.Messages = append(chatRequest.Messages, types.ChatCompletionMessage{
+				Role:    types.ChatMessageRoleSystem,
+				Content: system,
+			})
+		}
+	}
+
+	// Gemini 的函数调用没有 id，按函数名依次配对 functionCall 与 functionResponse
+	pendingCalls := make(map[string][]string)
+	callIndex := 0
+
+	for _, content := range request.Contents {
+		var parts []any
+		var toolCalls []*types.ChatCompletionToolCalls
+		var toolMessages []types.ChatCompletionMessage
+
+		for _, part := range content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				arguments, err := json.Marshal(part.FunctionCall.Args)
+				if err != nil {
+					return nil, err
+				}
+				callIndex++
+				id := fmt.Sprintf("call_%d_%s", callIndex, part.FunctionCall.Name)
+				pendingCalls[part.FunctionCall.Name] = append(pendingCalls[part.FunctionCall.Name], id)
+				toolCalls = append(toolCalls, &types.ChatCompletionToolCalls{
+					Id:    id,
+					Type:  types.ChatMessageRoleFunction,
+					Index: len(toolCalls),
+					Function: &types.ChatCompletionToolCallsFunction{
+						Name:      part.FunctionCall.Name,
+						Arguments: string(arguments),
+					},
+				})
+			case part.FunctionResponse != nil:
+				name := part.FunctionResponse.Name
+				id := ""
+				if ids := pendingCalls[name]; len(ids) > 0 {
+					id = ids[0]
+					pendingCalls[name] = ids[1:]
+				}
+				response, err := json.Marshal(part.FunctionResponse.Response)
+				if err != nil {
+					return nil, err
+				}
+				toolMessages = append(toolMessages, types.ChatCompletionMessage{
+					Role:       types.ChatMessageRoleTool,
+					Content:    string(response),
+					ToolCallID: id,
+				})
+			case part.InlineData != nil:
+				parts = append(parts, map[string]any{
+					"type": types.ContentTypeImageURL,
+					"image_url": map[string]any{
+						"url": fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data),
+					},
+				})
+			case part.FileData != nil:
+				parts = append(parts, map[string]any{
+					"type": types.ContentTypeImageURL,
+					"image_url": map[string]any{
+						"url": part.FileData.FileUri,
+					},
+				})
+			case part.Text != "":
+				parts = append(parts, map[string]any{
+					"type": types.ContentTypeText,
+					"text": part.Text,
+				})
+			}
+		}
+
+		chatRequest.Messages = append(chatRequest.Messages, toolMessages...)
+
+		if len(parts) == 0 && len(toolCalls) == 0 {
+			continue
+		}
+
+		message := types.ChatCompletionMessage{
+			Role: types.ChatMessageRoleUser,
+		}
+		if content.Role == "model" {
+			message.Role = types.ChatMessageRoleAssistant
+		}
+		if len(parts) > 0 {
+			message.Content = parts
+		}
+		if len(toolCalls) > 0 {
+			message.ToolCalls = toolCalls
+		}
+		chatRequest.Messages = append(chatRequest.Messages, message)
+	}
+
+	for _, tool := range request.Tools {
+		for _, function := range tool.FunctionDeclarations {
+			function := function
+			if function.Parameters == nil {
+				function.Parameters = map[string]any{
+					"type":       "object",
+					"properties": map[string]any{},
+				}
+			}
+			chatRequest.Tools = append(chatRequest.Tools, &types.ChatCompletionTool{
+				Type:     "function",
+				Function: function,
+			})
+		}
+	}
+
+	if request.ToolConfig != nil && request.ToolConfig.FunctionCallingConfig != nil && len(chatRequest.Tools) > 0 {
+		callingConfig := request.ToolConfig.FunctionCallingConfig
+		switch strings.ToUpper(callingConfig.Model) {
+		case "ANY":
+			chatRequest.ToolChoice = types.ToolChoiceTypeRequired
+			if len(callingConfig.AllowedFunctionNames) == 1 {
+				chatRequest.ToolChoice = map[string]any{
+					"type": types.ToolChoiceTypeFunction,
+					"function": map[string]any{
+						"name": callingConfig.AllowedFunctionNames[0],
+					},
+				}
+			}
+		case "NONE":
+			chatRequest.ToolChoice = types.ToolChoiceTypeNone
+		}
+	}
+
+	return chatRequest, nil
+}
+
+func partsToText(parts []GeminiPart) string {
+	var texts []string
+	for _, part := range parts {
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func finishReasonOpenAI2Gemini(reason string) string {
+	switch reason {
+	case types.FinishReasonLength:
+		return "MAX_TOKENS"
+	case types.FinishReasonContentFilter:
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+func toolCallToGeminiPart(toolCall *types.ChatCompletionToolCalls) GeminiPart {
+	args := make(map[string]interface{})
+	if toolCall.Function.Arguments != "" {
+		_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
+	}
+
+	return GeminiPart{
+		FunctionCall: &GeminiFunctionCall{
+			Name: toolCall.Function.Name,
+			Args: args,
+		},
+	}
+}
+
+func usageToGeminiUsage(usage *types.Usage) *GeminiUsageMetadata {
+	if usage == nil {
+		return nil
+	}
+
+	return &GeminiUsageMetadata{
+		PromptTokenCount:     usage.PromptTokens,
+		CandidatesTokenCount: usage.CompletionTokens,
+		TotalTokenCount:      usage.PromptTokens + usage.CompletionTokens,
+	}
+}
+
+// ConvertFromChatOpenaiResponse 将 OpenAI 聊天响应转换为 Gemini 响应
+func ConvertFromChatOpenaiResponse(response *types.ChatCompletionResponse, usage *types.Usage) *GeminiChatResponse {
+	geminiResponse := &GeminiChatResponse{
+		Candidates: make([]GeminiChatCandidate, 0, len(response.Choices)),
+		Model:      response.Model,
+	}
+
+	for _, choice := range response.Choices {
+		candidate := GeminiChatCandidate{
+			Index: int64(choice.Index),
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: make([]GeminiPart, 0),
+			},
+		}
+
+		if text := choice.Message.StringContent(); text != "" {
+			candidate.Content.Parts = append(candidate.Content.Parts, GeminiPart{Text: text})
+		}
+
+		choice.Message.FuncToToolCalls()
+		for _, toolCall := range choice.Message.ToolCalls {
+			candidate.Content.Parts = append(candidate.Content.Parts, toolCallToGeminiPart(toolCall))
+		}
+
+		finishReason, _ := choice.FinishReason.(string)
+		finishReason = finishReasonOpenAI2Gemini(finishReason)
+		candidate.FinishReason = &finishReason
+
+		geminiResponse.Candidates = append(geminiResponse.Candidates, candidate)
+	}
+
+	if response.Usage != nil {
+		usage = response.Usage
+	}
+	geminiResponse.UsageMetadata = usageToGeminiUsage(usage)
+
+	return geminiResponse
+}
+
+// openaiStreamConverter 将 OpenAI 流式响应转换为 Gemini SSE 流
+type openaiStreamConverter struct {
+	stream    requester.StreamReaderInterface[string]
+	usage     *types.Usage
+	modelName string
+
+	// 工具调用的参数是分段返回的，需要拼接完整后再一次性发送
+	toolCalls    []*types.ChatCompletionToolCalls
+	finishReason string
+
+	dataChan chan string
+	errChan  chan error
+}
+
+func newOpenaiStreamConverter(stream requester.StreamReaderInterface[string], usage *types.Usage, modelName string) *openaiStreamConverter {
+	return &openaiStreamConverter{
+		stream:    stream,
+		usage:     usage,
+		modelName: modelName,
+		dataChan:  make(chan string),
+		errChan:   make(chan error),
+	}
+}
+
+func (s *openaiStreamConverter) Recv() (<-chan string, <-chan error) {
+	go s.process()
+	return s.dataChan, s.errChan
+}
+
+func (s *openaiStreamConverter) Close() {
+	s.stream.Close()
+}
+
+func (s *openaiStreamConverter) process() {
+	dataChan, errChan := s.stream.Recv()
+	for {
+		select {
+		case data := <-dataChan:
+			s.handleChunk(data)
+		case err := <-errChan:
+			if err != io.EOF {
+				s.errChan <- openaiStreamErrToGeminiErr(err)
+				return
+			}
+			s.finish()
+			s.errChan <- io.EOF
+			return
+		}
+	}
+}
+
+func openaiStreamErrToGeminiErr(err error) *GeminiErrorResponse {
+	aiErr, ok := err.(*types.OpenAIError)
+	if !ok {
+		return ErrorToGeminiErr(err)
+	}
+
+	return &GeminiErrorResponse{
+		ErrorInfo: &GeminiError{
+			Code:    http.StatusInternalServerError,
+			Status:  aiErr.Type,
+			Message: aiErr.Message,
+		},
+	}
+}
+
+func (s *openaiStreamConverter) handleChunk(data string) {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+
+	if len(chunk.Choices) == 0 {
+		return
+	}
+
+	choice := chunk.Choices[0]
+	if reason, ok := choice.FinishReason.(string); ok && reason != "" {
+		s.finishReason = reason
+	}
+
+	delta := choice.Delta
+	if delta.FunctionCall != nil && delta.ToolCalls == nil {
+		delta.ToolCalls = []*types.ChatCompletionToolCalls{{Function: delta.FunctionCall}}
+	}
+
+	for _, toolCall := range delta.ToolCalls {
+		if toolCall.Function == nil {
+			continue
+		}
+		last := len(s.toolCalls) - 1
+		if last < 0 || toolCall.Id != "" || toolCall.Index != s.toolCalls[last].Index {
+			s.toolCalls = append(s.toolCalls, &types.ChatCompletionToolCalls{
+				Index: toolCall.Index,
+				Function: &types.ChatCompletionToolCallsFunction{
+					Name: toolCall.Function.Name,
+				},
+			})
+			last++
+		}
+		s.toolCalls[last].Function.Arguments += toolCall.Function.Arguments
+	}
+
+	if delta.Content == "" {
+		return
+	}
+
+	s.send(&GeminiChatResponse{
+		Candidates: []GeminiChatCandidate{{
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: []GeminiPart{{Text: delta.Content}},
+			},
+		}},
+		Model: s.modelName,
+	})
+}
+
+func (s *openaiStreamConverter) finish() {
+	finishReason := finishReasonOpenAI2Gemini(s.finishReason)
+	candidate := GeminiChatCandidate{
+		Content: GeminiChatContent{
+			Role:  "model",
+			Parts: make([]GeminiPart, 0, len(s.toolCalls)),
+		},
+		FinishReason: &finishReason,
+	}
+
+	for _, toolCall := range s.toolCalls {
+		candidate.Content.Parts = append(candidate.Content.Parts, toolCallToGeminiPart(toolCall))
+	}
+
+	s.send(&GeminiChatResponse{
+		Candidates:    []GeminiChatCandidate{candidate},
+		UsageMetadata: usageToGeminiUsage(s.usage),
+		Model:         s.modelName,
+	})
+}
+
+func (s *openaiStreamConverter) send(response *GeminiChatResponse) {
+	body, _ := json.Marshal(response)
+	s.dataChan <- fmt.Sprintf("data: %s\r\n\r\n", body)
+}
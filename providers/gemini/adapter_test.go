@@ -0,0 +1,170 @@
+package gemini_test
+
+import (
+	"encoding/json"
+	"one-api/providers/gemini"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func textContent(role, text string) gemini.GeminiChatContent {
+	return gemini.GeminiChatContent{Role: role, Parts: []gemini.GeminiPart{{Text: text}}}
+}
+
+func TestConvertToChatOpenaiRequest(t *testing.T) {
+	temperature := 0.3
+	request := &gemini.GeminiChatRequest{
+		Model:             "gemini-1.5-pro",
+		SystemInstruction: &gemini.GeminiChatContent{Parts: []gemini.GeminiPart{{Text: "be brief"}}},
+		Contents:          []gemini.GeminiChatContent{textContent("user", "hi"), textContent("model", "hello")},
+		GenerationConfig: gemini.GeminiChatGenerationConfig{
+			Temperature:     &temperature,
+			MaxOutputTokens: 100,
+			CandidateCount:  2,
+			StopSequences:   []string{"END"},
+		},
+	}
+
+	chatRequest, err := gemini.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "gemini-1.5-pro", chatRequest.Model)
+	assert.Equal(t, &temperature, chatRequest.Temperature)
+	assert.Equal(t, 100, chatRequest.MaxTokens)
+	assert.Equal(t, 2, *chatRequest.N)
+	assert.Equal(t, []string{"END"}, chatRequest.Stop)
+	assert.Nil(t, chatRequest.ResponseFormat)
+
+	assert.Len(t, chatRequest.Messages, 3)
+	assert.Equal(t, types.ChatMessageRoleSystem, chatRequest.Messages[0].Role)
+	assert.Equal(t, "be brief", chatRequest.Messages[0].Content)
+	assert.Equal(t, types.ChatMessageRoleUser, chatRequest.Messages[1].Role)
+	// model 角色对应 assistant
+	assert.Equal(t, types.ChatMessageRoleAssistant, chatRequest.Messages[2].Role)
+}
+
+func TestConvertToChatOpenaiRequestMedia(t *testing.T) {
+	request := &gemini.GeminiChatRequest{
+		Contents: []gemini.GeminiChatContent{{Role: "user", Parts: []gemini.GeminiPart{
+			{InlineData: &gemini.GeminiInlineData{MimeType: "image/png", Data: "AAA"}},
+			{FileData: &gemini.GeminiFileData{FileUri: "https://example.com/a.png"}},
+		}}},
+	}
+
+	chatRequest, err := gemini.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+
+	content, _ := json.Marshal(chatRequest.Messages[0].Content)
+	assert.JSONEq(t, `[{"type":"image_url","image_url":{"url":"data:image/png;base64,AAA"}},{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]`, string(content))
+}
+
+func TestConvertToChatOpenaiRequestFunctionCall(t *testing.T) {
+	request := &gemini.GeminiChatRequest{
+		Contents: []gemini.GeminiChatContent{
+			{Role: "model", Parts: []gemini.GeminiPart{
+				{FunctionCall: &gemini.GeminiFunctionCall{Name: "weather", Args: map[string]any{"city": "Paris"}}},
+				{FunctionCall: &gemini.GeminiFunctionCall{Name: "weather", Args: map[string]any{"city": "Rome"}}},
+			}},
+			{Role: "user", Parts: []gemini.GeminiPart{
+				{FunctionResponse: &gemini.GeminiFunctionResponse{Name: "weather", Response: map[string]any{"result": "sunny"}}},
+				{FunctionResponse: &gemini.GeminiFunctionResponse{Name: "weather", Response: map[string]any{"result": "rainy"}}},
+			}},
+		},
+	}
+
+	chatRequest, err := gemini.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Len(t, chatRequest.Messages, 3)
+
+	toolCalls := chatRequest.Messages[0].ToolCalls
+	assert.Len(t, toolCalls, 2)
+	assert.Equal(t, "call_1_weather", toolCalls[0].Id)
+	assert.Equal(t, "call_2_weather", toolCalls[1].Id)
+	assert.JSONEq(t, `{"city":"Rome"}`, toolCalls[1].Function.Arguments)
+
+	// Gemini 的函数调用没有 id，按函数名依次配对
+	assert.Equal(t, "call_1_weather", chatRequest.Messages[1].ToolCallID)
+	assert.Equal(t, `{"result":"sunny"}`, chatRequest.Messages[1].Content)
+	assert.Equal(t, "call_2_weather", chatRequest.Messages[2].ToolCallID)
+}
+
+func TestConvertToChatOpenaiRequestToolConfig(t *testing.T) {
+	request := &gemini.GeminiChatRequest{
+		Contents: []gemini.GeminiChatContent{textContent("user", "hi")},
+		Tools:    []gemini.GeminiChatTools{{FunctionDeclarations: []types.ChatCompletionFunction{{Name: "weather"}, {Name: "time"}}}},
+		ToolConfig: &gemini.GeminiToolConfig{FunctionCallingConfig: &gemini.GeminiFunctionCallingConfig{
+			Model:                "ANY",
+			AllowedFunctionNames: []string{"weather"},
+		}},
+	}
+
+	chatRequest, err := gemini.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Len(t, chatRequest.Tools, 2)
+	assert.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "weather"}}, chatRequest.ToolChoice)
+
+	request.ToolConfig.FunctionCallingConfig = &gemini.GeminiFunctionCallingConfig{Model: "none"}
+	chatRequest, err = gemini.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, types.ToolChoiceTypeNone, chatRequest.ToolChoice)
+}
+
+func TestConvertToChatOpenaiRequestResponseFormat(t *testing.T) {
+	request := &gemini.GeminiChatRequest{
+		Contents:         []gemini.GeminiChatContent{textContent("user", "hi")},
+		GenerationConfig: gemini.GeminiChatGenerationConfig{ResponseMimeType: "application/json"},
+	}
+
+	chatRequest, err := gemini.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "json_object", chatRequest.ResponseFormat.Type)
+
+	request.GenerationConfig.ResponseSchema = map[string]any{"type": "object"}
+	chatRequest, err = gemini.ConvertToChatOpenaiRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "json_schema", chatRequest.ResponseFormat.Type)
+}
+
+func TestConvertFromChatOpenaiResponse(t *testing.T) {
+	response := &types.ChatCompletionResponse{
+		Model: "gpt-4o",
+		Choices: []types.ChatCompletionChoice{{
+			Message:      types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant, Content: "hel"},
+			FinishReason: types.FinishReasonLength,
+		}},
+	}
+	usage := &types.Usage{PromptTokens: 3, CompletionTokens: 2}
+
+	geminiResponse := gemini.ConvertFromChatOpenaiResponse(response, usage)
+	assert.Len(t, geminiResponse.Candidates, 1)
+	candidate := geminiResponse.Candidates[0]
+	assert.Equal(t, "model", candidate.Content.Role)
+	assert.Equal(t, "MAX_TOKENS", *candidate.FinishReason)
+	assert.Equal(t, "hel", candidate.Content.Parts[0].Text)
+	assert.Equal(t, 5, geminiResponse.UsageMetadata.TotalTokenCount)
+}
+
+func TestConvertFromChatOpenaiResponseToolCalls(t *testing.T) {
+	response := &types.ChatCompletionResponse{
+		Model: "gpt-4o",
+		Choices: []types.ChatCompletionChoice{{
+			Message: types.ChatCompletionMessage{
+				Role: types.ChatMessageRoleAssistant,
+				ToolCalls: []*types.ChatCompletionToolCalls{{
+					Id:       "call_1",
+					Type:     "function",
+					Function: &types.ChatCompletionToolCallsFunction{Name: "weather", Arguments: `{"city":"Paris"}`},
+				}},
+			},
+			FinishReason: types.FinishReasonToolCalls,
+		}},
+	}
+
+	geminiResponse := gemini.ConvertFromChatOpenaiResponse(response, &types.Usage{})
+	candidate := geminiResponse.Candidates[0]
+	assert.Equal(t, "STOP", *candidate.FinishReason)
+	assert.Len(t, candidate.Content.Parts, 1)
+	assert.Equal(t, "weather", candidate.Content.Parts[0].FunctionCall.Name)
+	assert.Equal(t, map[string]any{"city": "Paris"}, candidate.Content.Parts[0].FunctionCall.Args)
+}
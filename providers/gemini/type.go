@@ -230,10 +230,10 @@ func (e *GeminiErrorResponse) Error() string {
 }
 
 type GeminiChatResponse struct {
-	Candidates     []GeminiChatCandidate    `json:"candidates"`
-	PromptFeedback GeminiChatPromptFeedback `json:"promptFeedback"`
-	UsageMetadata  *GeminiUsageMetadata     `json:"usageMetadata,omitempty"`
-	Model          string                   `json:"model,omitempty"`
+	Candidates     []GeminiChatCandidate     `json:"candidates"`
+	PromptFeedback *GeminiChatPromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *GeminiUsageMetadata      `json:"usageMetadata,omitempty"`
+	Model          string                    `json:"model,omitempty"`
 	GeminiErrorResponse
 }
 
@@ -248,7 +248,7 @@ type GeminiChatCandidate struct {
 	Content               GeminiChatContent        `json:"content"`
 	FinishReason          *string                  `json:"finishReason,omitempty"`
 	Index                 int64                    `json:"index"`
-	SafetyRatings         []GeminiChatSafetyRating `json:"safetyRatings"`
+	SafetyRatings         []GeminiChatSafetyRating `json:"safetyRatings,omitempty"`
 	CitationMetadata      any                      `json:"citationMetadata,omitempty"`
 	TokenCount            int                      `json:"tokenCount,omitempty"`
 	GroundingAttributions []any                    `json:"groundingAttributions,omitempty"`
@@ -67,6 +67,7 @@ func getOpenAIConfig(baseURL string, channel *model.Channel) base.ProviderConfig
 		ImagesVariations:    "/v1/images/variations",
 		ModelList:           "/v1/models",
 		ChatRealtime:        "/v1/realtime",
+		Responses:           "/v1/responses",
 	}
 
 	if channel.Type != config.ChannelTypeCustom || channel.Plugin == nil {
@@ -0,0 +1,86 @@
+package openai
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/types"
+)
+
+type OpenAIProviderFileResponse struct {
+	types.OpenAIFile
+	types.OpenAIErrorResponse
+}
+
+// UploadFile 将文件上传到上游，供需要上游文件 id 的接口使用
+func (p *OpenAIProvider) UploadFile(purpose, filename string, reader io.Reader) (*types.OpenAIFile, *types.OpenAIErrorWithStatusCode) {
+	fullRequestURL := p.GetFullRequestURL("/v1/files", "")
+	headers := p.GetRequestHeaders()
+
+	var formBody bytes.Buffer
+	builder := p.Requester.CreateFormBuilder(&formBody)
+	if err := builder.WriteField("purpose", purpose); err != nil {
+		return nil, common.ErrorWrapper(err, "create_form_builder_failed", http.StatusInternalServerError)
+	}
+	if err := builder.CreateFormFileReader("file", reader, filename); err != nil {
+		return nil, common.ErrorWrapper(err, "create_form_builder_failed", http.StatusInternalServerError)
+	}
+	if err := builder.Close(); err != nil {
+		return nil, common.ErrorWrapper(err, "create_form_builder_failed", http.StatusInternalServerError)
+	}
+
+	req, err := p.Requester.NewRequest(
+		http.MethodPost,
+		fullRequestURL,
+		p.Requester.WithBody(&formBody),
+		p.Requester.WithHeader(headers),
+		p.Requester.WithContentType(builder.FormDataContentType()))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	req.ContentLength = int64(formBody.Len())
+	defer req.Body.Close()
+
+	response := &OpenAIProviderFileResponse{}
+	_, errWithCode := p.Requester.SendRequest(req, response, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	openaiErr := ErrorHandle(&response.OpenAIErrorResponse)
+	if openaiErr != nil {
+		return nil, &types.OpenAIErrorWithStatusCode{
+			OpenAIError: *openaiErr,
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+
+	return &response.OpenAIFile, nil
+}
+
+// DeleteFile 删除上传到上游的文件
+func (p *OpenAIProvider) DeleteFile(fileId string) *types.OpenAIErrorWithStatusCode {
+	fullRequestURL := p.GetFullRequestURL("/v1/files/"+fileId, "")
+	headers := p.GetRequestHeaders()
+
+	req, err := p.Requester.NewRequest(http.MethodDelete, fullRequestURL, p.Requester.WithHeader(headers))
+	if err != nil {
+		return common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	response := &types.OpenAIErrorResponse{}
+	_, errWithCode := p.Requester.SendRequest(req, response, false)
+	if errWithCode != nil {
+		return errWithCode
+	}
+
+	if openaiErr := ErrorHandle(response); openaiErr != nil {
+		return &types.OpenAIErrorWithStatusCode{
+			OpenAIError: *openaiErr,
+			StatusCode:  http.StatusBadRequest,
+		}
+	}
+
+	return nil
+}
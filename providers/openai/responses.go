@@ -0,0 +1,114 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/types"
+	"strings"
+)
+
+type OpenAIResponsesStreamHandler struct {
+	Usage     *types.Usage
+	ModelName string
+}
+
+func (p *OpenAIProvider) CreateResponses(request *types.ResponsesRequest) (*types.ResponsesResponse, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getResponsesRequest(request)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	defer req.Body.Close()
+
+	response := &types.ResponsesResponse{}
+	// 发送请求
+	_, errWithCode = p.Requester.SendRequest(req, response, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	// 检测是否错误
+	if response.Error != nil && response.Error.Message != "" {
+		errWithCode = &types.OpenAIErrorWithStatusCode{
+			OpenAIError: *response.Error,
+			StatusCode:  http.StatusBadRequest,
+		}
+		return nil, errWithCode
+	}
+
+	if response.Usage != nil {
+		*p.Usage = *response.Usage.ToOpenAIUsage()
+	} else {
+		p.Usage.CompletionTokens = common.CountTokenText(response.GetOutputText(), request.Model)
+		p.Usage.TotalTokens = p.Usage.PromptTokens + p.Usage.CompletionTokens
+	}
+
+	return response, nil
+}
+
+func (p *OpenAIProvider) CreateResponsesStream(request *types.ResponsesRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getResponsesRequest(request)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	defer req.Body.Close()
+
+	// 发送请求
+	resp, errWithCode := p.Requester.SendRequestRaw(req)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	chatHandler := OpenAIResponsesStreamHandler{
+		Usage:     p.Usage,
+		ModelName: request.Model,
+	}
+
+	return requester.RequestNoTrimStream(p.Requester, resp, chatHandler.HandlerResponsesStream)
+}
+
+func (p *OpenAIProvider) getResponsesRequest(request *types.ResponsesRequest) (*http.Request, *types.OpenAIErrorWithStatusCode) {
+	url, errWithCode := p.GetSupportedAPIUri(config.RelayModeResponses)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	// Azure 的 Responses 接口不在 deployments 路径下，模型通过请求体传递
+	fullRequestURL := p.GetFullRequestURL(url, "")
+
+	headers := p.GetRequestHeaders()
+	if request.Stream {
+		headers["Accept"] = "text/event-stream"
+	}
+
+	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(request), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	return req, nil
+}
+
+// 原样转发事件流，同时从中获取用量
+func (h *OpenAIResponsesStreamHandler) HandlerResponsesStream(rawLine *[]byte, dataChan chan string, errChan chan error) {
+	rawStr := string(*rawLine)
+	if !strings.HasPrefix(rawStr, "data: ") {
+		dataChan <- rawStr
+		return
+	}
+
+	var streamResponse types.ResponsesStreamResponse
+	err := json.Unmarshal([]byte(strings.TrimSpace(rawStr[6:])), &streamResponse)
+	if err != nil {
+		errChan <- common.ErrorToOpenAIError(err)
+		return
+	}
+
+	if streamResponse.Response != nil && streamResponse.Response.Usage != nil {
+		*h.Usage = *streamResponse.Response.Usage.ToOpenAIUsage()
+	}
+
+	dataChan <- rawStr
+}
@@ -0,0 +1,525 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/providers/base"
+	"one-api/types"
+)
+
+// ResponsesAdapter 将任意实现了 base.ChatInterface 的渠道包装为 ResponsesInterface
+type ResponsesAdapter struct {
+	base.ChatInterface
+}
+
+func NewResponsesAdapter(provider base.ChatInterface) *ResponsesAdapter {
+	return &ResponsesAdapter{ChatInterface: provider}
+}
+
+func (a *ResponsesAdapter) CreateResponses(request *types.ResponsesRequest) (*types.ResponsesResponse, *types.OpenAIErrorWithStatusCode) {
+	chatRequest, err := ConvertResponsesToChatRequest(request)
+	if err != nil {
+		return nil, common.ErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+
+	response, errWithCode := a.CreateChatCompletion(chatRequest)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return ConvertChatToResponses(request, response, a.GetUsage()), nil
+}
+
+func (a *ResponsesAdapter) CreateResponsesStream(request *types.ResponsesRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode) {
+	chatRequest, err := ConvertResponsesToChatRequest(request)
+	if err != nil {
+		return nil, common.ErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+	}
+
+	stream, errWithCode := a.CreateChatCompletionStream(chatRequest)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return newResponsesStreamConverter(stream, request, a.GetUsage()), nil
+}
+
+// ConvertResponsesToChatRequest 将 Responses 请求转换为聊天请求
+func ConvertResponsesToChatRequest(request *types.ResponsesRequest) (*types.ChatCompletionRequest, error) {
+	chatRequest := &types.ChatCompletionRequest{
+		Model:       request.Model,
+		Messages:    make([]types.ChatCompletionMessage, 0),
+		MaxTokens:   request.MaxOutputTokens,
+		Temperature: request.Temperature,
+		TopP:        request.TopP,
+		Stream:      request.Stream,
+		User:        request.User,
+	}
+
+	if request.ParallelToolCalls != nil {
+		chatRequest.ParallelToolCalls = *request.ParallelToolCalls
+	}
+
+	if request.Instructions != "" {
+		chatRequest.Messages = append(chatRequest.Messages, types.ChatCompletionMessage{
+			Role:    types.ChatMessageRoleSystem,
+			Content: request.Instructions,
+		})
+	}
+
+	items, err := request.ParseInput()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		switch item.Type {
+		case types.ResponsesItemTypeMessage:
+			chatRequest.Messages = append(chatRequest.Messages, responsesMessageToChat(&item))
+		case types.ResponsesItemTypeFunctionCall:
+			toolCall := &types.ChatCompletionToolCalls{
+				Id:   item.CallId,
+				Type: types.ChatMessageRoleFunction,
+				Function: &types.ChatCompletionToolCallsFunction{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			}
+			// 连续的函数调用合并到同一条 assistant 消息中
+			last := len(chatRequest.Messages) - 1
+			if last >= 0 && chatRequest.Messages[last].Role == types.ChatMessageRoleAssistant {
+				toolCall.Index = len(chatRequest.Messages[last].ToolCalls)
+				chatRequest.Messages[last].ToolCalls = append(chatRequest.Messages[last].ToolCalls, toolCall)
+				continue
+			}
+			chatRequest.Messages = append(chatRequest.Messages, types.ChatCompletionMessage{
+				Role:      types.ChatMessageRoleAssistant,
+				ToolCalls: []*types.ChatCompletionToolCalls{toolCall},
+			})
+		case types.ResponsesItemTypeFunctionCallOutput:
+			output, ok := item.Output.(string)
+			if !ok {
+				output = utils.Marshal(item.Output)
+			}
+			chatRequest.Messages = append(chatRequest.Messages, types.ChatCompletionMessage{
+				Role:       types.ChatMessageRoleTool,
+				Content:    output,
+				ToolCallID: item.CallId,
+			})
+		}
+	}
+
+	for _, tool := range request.Tools {
+		// 内置工具（web_search、file_search 等）无法转换，直接忽略
+		if tool.Type != types.ToolChoiceTypeFunction {
+			continue
+		}
+		chatRequest.Tools = append(chatRequest.Tools, &types.ChatCompletionTool{
+			Type: types.ToolChoiceTypeFunction,
+			Function: types.ChatCompletionFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	if len(chatRequest.Tools) > 0 {
+		switch toolChoice := request.ToolChoice.(type) {
+		case string:
+			chatRequest.ToolChoice = toolChoice
+		case map[string]any:
+			if name, ok := toolChoice["name"].(string); ok {
+				chatRequest.ToolChoice = map[string]any{
+					"type": types.ToolChoiceTypeFunction,
+					"function": map[string]any{
+						"name": name,
+					},
+				}
+			}
+		}
+	}
+
+	if request.Text != nil && request.Text.Format != nil {
+		format := request.Text.Format
+		switch format.Type {
+		case "json_object":
+			chatRequest.ResponseFormat = &types.ChatCompletionResponseFormat{Type: format.Type}
+		case "json_schema":
+			jsonSchema := map[string]any{
+				"name":   format.Name,
+				"schema": format.Schema,
+			}
+			if format.Description != "" {
+				jsonSchema["description"] = format.Description
+			}
+			if format.Strict != nil {
+				jsonSchema["strict"] = *format.Strict
+			}
+			chatRequest.ResponseFormat = &types.ChatCompletionResponseFormat{
+				Type:       format.Type,
+				JsonSchema: jsonSchema,
+			}
+		}
+	}
+
+	return chatRequest, nil
+}
+
+func responsesMessageToChat(item *types.ResponsesInputItem) types.ChatCompletionMessage {
+	role := item.Role
+	if role == "developer" {
+		role = types.ChatMessageRoleSystem
+	}
+
+	message := types.ChatCompletionMessage{
+		Role: role,
+	}
+
+	if content, ok := item.Content.(string); ok {
+		message.Content = content
+		return message
+	}
+
+	parts := make([]any, 0)
+	for _, content := range item.ParseContent() {
+		switch content.Type {
+		case types.ResponsesContentTypeInputText, types.ResponsesContentTypeOutputText:
+			parts = append(parts, map[string]any{
+				"type": types.ContentTypeText,
+				"text": content.Text,
+			})
+		case types.ResponsesContentTypeRefusal:
+			message.Refusal = content.Refusal
+		case types.ResponsesContentTypeInputImage:
+			if content.ImageUrl == "" {
+				continue
+			}
+			imageURL := map[string]any{
+				"url": content.ImageUrl,
+			}
+			if content.Detail != "" {
+				imageURL["detail"] = content.Detail
+			}
+			parts = append(parts, map[string]any{
+				"type":      types.ContentTypeImageURL,
+				"image_url": imageURL,
+			})
+		}
+	}
+	message.Content = parts
+
+	return message
+}
+
+func newResponsesResponse(request *types.ResponsesRequest, id string) *types.ResponsesResponse {
+	if id == "" {
+		id = fmt.Sprintf("resp_%s", utils.GetUUID())
+	}
+
+	tools := request.Tools
+	if tools == nil {
+		tools = make([]*types.ResponsesTool, 0)
+	}
+
+	return &types.ResponsesResponse{
+		Id:                 id,
+		Object:             "response",
+		CreatedAt:          utils.GetTimestamp(),
+		Status:             types.ResponsesStatusInProgress,
+		Instructions:       request.Instructions,
+		MaxOutputTokens:    request.MaxOutputTokens,
+		Model:              request.Model,
+		Output:             make([]types.ResponsesOutputItem, 0),
+		ParallelToolCalls:  request.ParallelToolCalls,
+		PreviousResponseId: request.PreviousResponseId,
+		Temperature:        request.Temperature,
+		TopP:               request.TopP,
+		Text:               request.Text,
+		ToolChoice:         request.ToolChoice,
+		Tools:              tools,
+		Metadata:           request.Metadata,
+		User:               request.User,
+	}
+}
+
+func newResponsesMessageItem(text string) types.ResponsesOutputItem {
+	return types.ResponsesOutputItem{
+		Type:   types.ResponsesItemTypeMessage,
+		Id:     fmt.Sprintf("msg_%s", utils.GetUUID()),
+		Status: types.ResponsesStatusCompleted,
+		Role:   types.ChatMessageRoleAssistant,
+		Content: []types.ResponsesContent{{
+			Type:        types.ResponsesContentTypeOutputText,
+			Text:        text,
+			Annotations: make([]any, 0),
+		}},
+	}
+}
+
+func newResponsesFunctionCallItem(toolCall *types.ChatCompletionToolCalls) types.ResponsesOutputItem {
+	return types.ResponsesOutputItem{
+		Type:      types.ResponsesItemTypeFunctionCall,
+		Id:        fmt.Sprintf("fc_%s", utils.GetUUID()),
+		Status:    types.ResponsesStatusCompleted,
+		CallId:    toolCall.Id,
+		Name:      toolCall.Function.Name,
+		Arguments: toolCall.Function.Arguments,
+	}
+}
+
+// 根据结束原因设置响应状态
+func setResponsesStatus(response *types.ResponsesResponse, finishReason string) {
+	if finishReason == types.FinishReasonLength {
+		response.Status = types.ResponsesStatusIncomplete
+		response.IncompleteDetails = map[string]any{"reason": "max_output_tokens"}
+		return
+	}
+
+	if finishReason == types.FinishReasonContentFilter {
+		response.Status = types.ResponsesStatusIncomplete
+		response.IncompleteDetails = map[string]any{"reason": "content_filter"}
+		return
+	}
+
+	response.Status = types.ResponsesStatusCompleted
+}
+
+// ConvertChatToResponses 将聊天响应转换为 Responses 响应
+func ConvertChatToResponses(request *types.ResponsesRequest, response *types.ChatCompletionResponse, usage *types.Usage) *types.ResponsesResponse {
+	responsesResponse := newResponsesResponse(request, "")
+
+	finishReason := ""
+	if len(response.Choices) > 0 {
+		choice := response.Choices[0]
+		finishReason, _ = choice.FinishReason.(string)
+
+		if text := choice.Message.StringContent(); text != "" {
+			responsesResponse.Output = append(responsesResponse.Output, newResponsesMessageItem(text))
+		}
+
+		choice.Message.FuncToToolCalls()
+		for _, toolCall := range choice.Message.ToolCalls {
+			responsesResponse.Output = append(responsesResponse.Output, newResponsesFunctionCallItem(toolCall))
+		}
+	}
+	setResponsesStatus(responsesResponse, finishReason)
+
+	if response.Usage != nil {
+		usage = response.Usage
+	}
+	responsesResponse.Usage = types.NewResponsesUsage(usage)
+
+	return responsesResponse
+}
+
+// responsesStreamConverter 将聊天流式响应转换为 Responses 事件流
+type responsesStreamConverter struct {
+	stream   requester.StreamReaderInterface[string]
+	request  *types.ResponsesRequest
+	usage    *types.Usage
+	response *types.ResponsesResponse
+
+	sequence     int
+	item         *types.ResponsesOutputItem
+	toolIndex    int
+	finishReason string
+
+	dataChan chan string
+	errChan  chan error
+}
+
+func newResponsesStreamConverter(stream requester.StreamReaderInterface[string], request *types.ResponsesRequest, usage *types.Usage) *responsesStreamConverter {
+	return &responsesStreamConverter{
+		stream:    stream,
+		request:   request,
+		usage:     usage,
+		response:  newResponsesResponse(request, ""),
+		toolIndex: -1,
+		dataChan:  make(chan string),
+		errChan:   make(chan error),
+	}
+}
+
+func (s *responsesStreamConverter) Recv() (<-chan string, <-chan error) {
+	go s.process()
+	return s.dataChan, s.errChan
+}
+
+func (s *responsesStreamConverter) Close() {
+	s.stream.Close()
+}
+
+func (s *responsesStreamConverter) process() {
+	s.send("response.created", map[string]any{"response": s.response})
+	s.send("response.in_progress", map[string]any{"response": s.response})
+
+	dataChan, errChan := s.stream.Recv()
+	for {
+		select {
+		case data := <-dataChan:
+			s.handleChunk(data)
+		case err := <-errChan:
+			if err != io.EOF {
+				s.errChan <- err
+				return
+			}
+			s.finish()
+			s.errChan <- io.EOF
+			return
+		}
+	}
+}
+
+func (s *responsesStreamConverter) handleChunk(data string) {
+	var chunk types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+
+	if len(chunk.Choices) == 0 {
+		return
+	}
+
+	choice := chunk.Choices[0]
+	if reason, ok := choice.FinishReason.(string); ok && reason != "" {
+		s.finishReason = reason
+	}
+
+	delta := choice.Delta
+	if delta.FunctionCall != nil && delta.ToolCalls == nil {
+		delta.ToolCalls = []*types.ChatCompletionToolCalls{{Function: delta.FunctionCall}}
+	}
+
+	if delta.Content != "" {
+		if s.item == nil || s.item.Type != types.ResponsesItemTypeMessage {
+			item := newResponsesMessageItem("")
+			item.Status = types.ResponsesStatusInProgress
+			s.startItem(&item)
+			s.send("response.content_part.added", map[string]any{
+				"item_id":       s.item.Id,
+				"output_index":  len(s.response.Output),
+				"content_index": 0,
+				"part":          s.item.Content[0],
+			})
+		}
+		s.item.Content[0].Text += delta.Content
+		s.send("response.output_text.delta", map[string]any{
+			"item_id":       s.item.Id,
+			"output_index":  len(s.response.Output),
+			"content_index": 0,
+			"delta":         delta.Content,
+		})
+	}
+
+	for _, toolCall := range delta.ToolCalls {
+		if toolCall.Function == nil {
+			continue
+		}
+
+		// 新的工具调用需要开启新的输出项
+		if s.item == nil || s.item.Type != types.ResponsesItemTypeFunctionCall || toolCall.Index != s.toolIndex || toolCall.Id != "" {
+			callId := toolCall.Id
+			if callId == "" {
+				callId = fmt.Sprintf("call_%s", utils.GetUUID())
+			}
+			item := newResponsesFunctionCallItem(&types.ChatCompletionToolCalls{
+				Id:       callId,
+				Function: &types.ChatCompletionToolCallsFunction{Name: toolCall.Function.Name},
+			})
+			item.Status = types.ResponsesStatusInProgress
+			s.toolIndex = toolCall.Index
+			s.startItem(&item)
+		}
+
+		if toolCall.Function.Arguments == "" {
+			continue
+		}
+		s.item.Arguments += toolCall.Function.Arguments
+		s.send("response.function_call_arguments.delta", map[string]any{
+			"item_id":      s.item.Id,
+			"output_index": len(s.response.Output),
+			"delta":        toolCall.Function.Arguments,
+		})
+	}
+}
+
+func (s *responsesStreamConverter) startItem(item *types.ResponsesOutputItem) {
+	s.stopItem()
+	s.item = item
+
+	added := *item
+	if added.Type == types.ResponsesItemTypeMessage {
+		added.Content = make([]types.ResponsesContent, 0)
+	}
+	s.send("response.output_item.added", map[string]any{
+		"output_index": len(s.response.Output),
+		"item":         added,
+	})
+}
+
+func (s *responsesStreamConverter) stopItem() {
+	if s.item == nil {
+		return
+	}
+
+	item := s.item
+	outputIndex := len(s.response.Output)
+	item.Status = types.ResponsesStatusCompleted
+
+	switch item.Type {
+	case types.ResponsesItemTypeMessage:
+		s.send("response.output_text.done", map[string]any{
+			"item_id":       item.Id,
+			"output_index":  outputIndex,
+			"content_index": 0,
+			"text":          item.Content[0].Text,
+		})
+		s.send("response.content_part.done", map[string]any{
+			"item_id":       item.Id,
+			"output_index":  outputIndex,
+			"content_index": 0,
+			"part":          item.Content[0],
+		})
+	case types.ResponsesItemTypeFunctionCall:
+		s.send("response.function_call_arguments.done", map[string]any{
+			"item_id":      item.Id,
+			"output_index": outputIndex,
+			"arguments":    item.Arguments,
+		})
+	}
+
+	s.send("response.output_item.done", map[string]any{
+		"output_index": outputIndex,
+		"item":         item,
+	})
+
+	s.response.Output = append(s.response.Output, *item)
+	s.item = nil
+}
+
+func (s *responsesStreamConverter) finish() {
+	s.stopItem()
+	setResponsesStatus(s.response, s.finishReason)
+	s.response.Usage = types.NewResponsesUsage(s.usage)
+
+	event := "response.completed"
+	if s.response.Status == types.ResponsesStatusIncomplete {
+		event = "response.incomplete"
+	}
+	s.send(event, map[string]any{"response": s.response})
+}
+
+func (s *responsesStreamConverter) send(event string, data map[string]any) {
+	data["type"] = event
+	data["sequence_number"] = s.sequence
+	s.sequence++
+
+	body, _ := json.Marshal(data)
+	s.dataChan <- fmt.Sprintf("event: %s\ndata: %s\n\n", event, body)
+}
@@ -0,0 +1,123 @@
+package openai_test
+
+import (
+	"encoding/json"
+	"one-api/providers/openai"
+	"one-api/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func convertResponsesRequest(t *testing.T, body string) *types.ChatCompletionRequest {
+	request := &types.ResponsesRequest{}
+	err := json.Unmarshal([]byte(body), request)
+	assert.Nil(t, err)
+
+	chatRequest, err := openai.ConvertResponsesToChatRequest(request)
+	assert.Nil(t, err)
+	assert.Equal(t, request.Model, chatRequest.Model)
+
+	return chatRequest
+}
+
+func TestConvertResponsesToChatRequestInput(t *testing.T) {
+	chatRequest := convertResponsesRequest(t, `{"model":"gpt-4o","instructions":"be brief","input":"hi"}`)
+	messages, _ := json.Marshal(chatRequest.Messages)
+	assert.JSONEq(t, `[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]`, string(messages))
+
+	// developer 角色按 system 处理，内容块转换为 chat 格式
+	chatRequest = convertResponsesRequest(t, `{"model":"gpt-4o","input":[{"role":"developer","content":"rules"},{"role":"user","content":[{"type":"input_text","text":"what"},{"type":"input_image","image_url":"https://example.com/a.png","detail":"low"}]}]}`)
+	messages, _ = json.Marshal(chatRequest.Messages)
+	assert.JSONEq(t, `[{"role":"system","content":"rules"},{"role":"user","content":[{"type":"text","text":"what"},{"type":"image_url","image_url":{"url":"https://example.com/a.png","detail":"low"}}]}]`, string(messages))
+
+	chatRequest = convertResponsesRequest(t, `{"model":"gpt-4o","input":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"ok"},{"type":"refusal","refusal":"no"}]}]}`)
+	assert.Equal(t, "no", chatRequest.Messages[0].Refusal)
+}
+
+func TestConvertResponsesToChatRequestFunctionCall(t *testing.T) {
+	chatRequest := convertResponsesRequest(t, `{"model":"gpt-4o","input":[{"role":"user","content":"weather?"},{"type":"function_call","call_id":"call_1","name":"weather","arguments":"{\"city\":\"Paris\"}"},{"type":"function_call","call_id":"call_2","name":"weather","arguments":"{\"city\":\"Rome\"}"},{"type":"function_call_output","call_id":"call_1","output":"sunny"},{"type":"function_call_output","call_id":"call_2","output":{"result":"rainy"}}]}`)
+	assert.Len(t, chatRequest.Messages, 4)
+
+	// 连续的函数调用合并到同一条 assistant 消息
+	assistant := chatRequest.Messages[1]
+	assert.Equal(t, types.ChatMessageRoleAssistant, assistant.Role)
+	assert.Len(t, assistant.ToolCalls, 2)
+	assert.Equal(t, "call_2", assistant.ToolCalls[1].Id)
+	assert.Equal(t, 1, assistant.ToolCalls[1].Index)
+
+	assert.Equal(t, "call_1", chatRequest.Messages[2].ToolCallID)
+	assert.Equal(t, "sunny", chatRequest.Messages[2].Content)
+	assert.Equal(t, `{"result":"rainy"}`, chatRequest.Messages[3].Content)
+}
+
+func TestConvertResponsesToChatRequestTools(t *testing.T) {
+	// 内置工具没有对应的 chat 工具，直接丢弃
+	chatRequest := convertResponsesRequest(t, `{"model":"gpt-4o","input":"hi","tools":[{"type":"function","name":"weather","parameters":{"type":"object"}},{"type":"web_search_preview"}],"tool_choice":{"type":"function","name":"weather"}}`)
+	assert.Len(t, chatRequest.Tools, 1)
+	assert.Equal(t, "weather", chatRequest.Tools[0].Function.Name)
+	assert.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "weather"}}, chatRequest.ToolChoice)
+
+	chatRequest = convertResponsesRequest(t, `{"model":"gpt-4o","input":"hi","tools":[{"type":"function","name":"weather"}],"tool_choice":"required"}`)
+	assert.Equal(t, "required", chatRequest.ToolChoice)
+
+	chatRequest = convertResponsesRequest(t, `{"model":"gpt-4o","input":"hi","tools":[{"type":"file_search"}],"tool_choice":"auto"}`)
+	assert.Empty(t, chatRequest.Tools)
+	assert.Nil(t, chatRequest.ToolChoice)
+}
+
+func TestConvertResponsesToChatRequestTextFormat(t *testing.T) {
+	chatRequest := convertResponsesRequest(t, `{"model":"gpt-4o","input":"hi","text":{"format":{"type":"json_schema","name":"answer","schema":{"type":"object"},"strict":true}}}`)
+	assert.Equal(t, "json_schema", chatRequest.ResponseFormat.Type)
+
+	chatRequest = convertResponsesRequest(t, `{"model":"gpt-4o","input":"hi","text":{"format":{"type":"text"}}}`)
+	assert.Nil(t, chatRequest.ResponseFormat)
+}
+
+func TestConvertResponsesToChatRequestError(t *testing.T) {
+	request := &types.ResponsesRequest{Model: "gpt-4o", Input: 1}
+	_, err := openai.ConvertResponsesToChatRequest(request)
+	assert.NotNil(t, err)
+}
+
+func TestConvertChatToResponses(t *testing.T) {
+	request := &types.ResponsesRequest{Model: "gpt-4o", Instructions: "be brief"}
+	response := &types.ChatCompletionResponse{}
+	err := json.Unmarshal([]byte(`{"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"checking","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":3,"completion_tokens":2}}`), response)
+	assert.Nil(t, err)
+
+	responsesResponse := openai.ConvertChatToResponses(request, response, &types.Usage{PromptTokens: 1, CompletionTokens: 1})
+	assert.Equal(t, "response", responsesResponse.Object)
+	assert.Equal(t, "be brief", responsesResponse.Instructions)
+	assert.Equal(t, types.ResponsesStatusCompleted, responsesResponse.Status)
+	assert.Nil(t, responsesResponse.IncompleteDetails)
+
+	assert.Len(t, responsesResponse.Output, 2)
+	assert.Equal(t, types.ResponsesItemTypeMessage, responsesResponse.Output[0].Type)
+	assert.Equal(t, types.ResponsesItemTypeFunctionCall, responsesResponse.Output[1].Type)
+	assert.Equal(t, "call_1", responsesResponse.Output[1].CallId)
+	// 上游返回的 usage 优先
+	assert.Equal(t, 5, responsesResponse.Usage.TotalTokens)
+}
+
+func TestConvertChatToResponsesIncomplete(t *testing.T) {
+	request := &types.ResponsesRequest{Model: "gpt-4o"}
+	response := &types.ChatCompletionResponse{
+		Model: "gpt-4o",
+		Choices: []types.ChatCompletionChoice{{
+			Message:      types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant, Content: "hel"},
+			FinishReason: types.FinishReasonLength,
+		}},
+	}
+
+	responsesResponse := openai.ConvertChatToResponses(request, response, &types.Usage{PromptTokens: 1, CompletionTokens: 1})
+	assert.Equal(t, types.ResponsesStatusIncomplete, responsesResponse.Status)
+	assert.Equal(t, map[string]any{"reason": "max_output_tokens"}, responsesResponse.IncompleteDetails)
+	assert.Equal(t, 2, responsesResponse.Usage.TotalTokens)
+
+	response.Choices[0].Message.Content = ""
+	response.Choices[0].FinishReason = types.FinishReasonContentFilter
+	responsesResponse = openai.ConvertChatToResponses(request, response, &types.Usage{})
+	assert.Equal(t, map[string]any{"reason": "content_filter"}, responsesResponse.IncompleteDetails)
+	assert.Empty(t, responsesResponse.Output)
+}
@@ -1,7 +1,10 @@
 package providers
 
 import (
+	"fmt"
+	"one-api/common"
 	"one-api/common/config"
+	"one-api/common/logger"
 	"one-api/model"
 	"one-api/providers/ali"
 	"one-api/providers/azure"
@@ -35,6 +38,7 @@ import (
 	"one-api/providers/vertexai"
 	"one-api/providers/xunfei"
 	"one-api/providers/zhipu"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -86,6 +90,11 @@ func init() {
 
 // 获取供应商
 func GetProvider(channel *model.Channel, c *gin.Context) base.ProviderInterface {
+	// 渠道有密钥池时按轮换策略选择密钥
+	if channel.KeyId == 0 {
+		channel = model.ChannelKeys.Pick(channel)
+	}
+
 	factory, ok := providerFactories[channel.Type]
 	var provider base.ProviderInterface
 	if !ok {
@@ -103,6 +112,33 @@ func GetProvider(channel *model.Channel, c *gin.Context) base.ProviderInterface
 		provider = factory.Create(channel)
 	}
 	provider.SetContext(c)
+	setParamOverride(provider, c)
 
 	return provider
 }
+
+// setParamOverride 按渠道的参数改写规则修改发往上游的请求体
+func setParamOverride(provider base.ProviderInterface, c *gin.Context) {
+	channel := provider.GetChannel()
+	requester := provider.GetRequester()
+	if requester == nil || channel.GetParamOverride() == "" {
+		return
+	}
+
+	rules, err := common.ParseParamOverrideRules(channel.GetParamOverride())
+	if err != nil {
+		logger.SysError(fmt.Sprintf("channel #%d has invalid param override rules: %s", channel.Id, err.Error()))
+		return
+	}
+
+	vars := map[string]string{}
+	if c != nil {
+		vars["user_id"] = strconv.Itoa(c.GetInt("id"))
+		vars["token_id"] = strconv.Itoa(c.GetInt("token_id"))
+		vars["group"] = c.GetString("token_group")
+	}
+	requester.BodyHandler = func(body []byte) ([]byte, error) {
+		body, _, err := common.ApplyParamOverrideRules(rules, provider.GetOriginalModel(), body, vars)
+		return body, err
+	}
+}
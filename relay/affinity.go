@@ -0,0 +1,276 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/types"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// 上游的有状态对象只存在于创建它的渠道中，引用这些对象的请求需要转发到同一个渠道
+var objectIdRegex = regexp.MustCompile(`\b(?:file-|asst_|thread_|vs_|ftjob-|batch_)[A-Za-z0-9]+`)
+
+// assistantsChannelTypes 支持助手、线程、向量库等接口的渠道类型
+var assistantsChannelTypes = []int{config.ChannelTypeOpenAI, config.ChannelTypeAzure}
+
+func isObjectId(id string) bool {
+	return id != "" && objectIdRegex.FindString(id) == id
+}
+
+// readJSONBody 读取 JSON 请求体后放回，其他类型的请求返回 nil
+func readJSONBody(c *gin.Context) ([]byte, error) {
+	if c.Request.Body == nil || !strings.HasPrefix(c.GetHeader("Content-Type"), "application/json") {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+	return body, nil
+}
+
+// setObjectAffinity 检查请求引用的对象是否属于当前用户，并将请求固定到创建对象的渠道
+// 没有引用已知对象时，使用请求体中的模型（没有时使用默认模型）选择渠道，返回该模型名称
+func setObjectAffinity(c *gin.Context) (string, *types.OpenAIErrorWithStatusCode) {
+	body, err := readJSONBody(c)
+	if err != nil {
+		return "", common.ErrorWrapperLocal(err, "read_request_body_failed", http.StatusBadRequest)
+	}
+
+	objectIds := objectIdRegex.FindAllString(c.Request.URL.Path, -1)
+	if len(body) > 0 {
+		var value any
+		if json.Unmarshal(body, &value) == nil {
+			objectIds = collectObjectIds(value, objectIds)
+		}
+	}
+	// 网关文件在转发前会替换为上游文件 id，由 replaceLocalFileIds 检查归属
+	objectIds = lo.Filter(lo.Uniq(objectIds), func(id string, _ int) bool {
+		return localFileIdRegex.FindString(id) != id
+	})
+
+	objects, err := model.GetChannelObjects(objectIds)
+	if err != nil {
+		return "", common.ErrorWrapper(err, "get_channel_object_failed", http.StatusInternalServerError)
+	}
+
+	userId := c.GetInt("id")
+	// 没有记录的对象可能属于其他用户，只有管理员可以引用
+	if len(objects) < len(objectIds) && !model.IsAdmin(userId) {
+		tracked := lo.SliceToMap(objects, func(object *model.ChannelObject) (string, bool) {
+			return object.ObjectId, true
+		})
+		for _, objectId := range objectIds {
+			if !tracked[objectId] {
+				return "", common.StringErrorWrapperLocal(fmt.Sprintf("No such object: %s", objectId), "not_found", http.StatusNotFound)
+			}
+		}
+	}
+
+	channelId := 0
+	for _, object := range objects {
+		if object.UserId != userId {
+			return "", common.StringErrorWrapperLocal(fmt.Sprintf("No such object: %s", object.ObjectId), "not_found", http.StatusNotFound)
+		}
+
+		if channelId > 0 && channelId != object.ChannelId {
+			return "", common.StringErrorWrapperLocal("objects in the request were created on different channels", "invalid_request", http.StatusBadRequest)
+		}
+		channelId = object.ChannelId
+	}
+
+	// 管理员指定的渠道优先
+	c.Set("specific_channel_id_ignore", false)
+	if c.GetInt("specific_channel_id") > 0 {
+		return "", nil
+	}
+
+	if channelId > 0 {
+		c.Set("specific_channel_id", channelId)
+		return "", nil
+	}
+
+	var request struct {
+		Model string `json:"model"`
+	}
+	if len(body) > 0 {
+		json.Unmarshal(body, &request)
+	}
+
+	if request.Model == "" {
+		request.Model = defaultAssistantsModel(c.GetString("token_group"))
+		if request.Model == "" {
+			return "", common.StringErrorWrapperLocal("当前分组下没有支持该接口的渠道", "channel_not_found", http.StatusServiceUnavailable)
+		}
+	}
+
+	return request.Model, nil
+}
+
+// defaultAssistantsModel 不带模型的请求（创建线程、列出助手等）使用配置的默认模型选择渠道，
+// 没有配置时使用分组下任一由 OpenAI 或 Azure 渠道提供的模型
+func defaultAssistantsModel(group string) string {
+	if config.AssistantsDefaultModel != "" {
+		return config.AssistantsDefaultModel
+	}
+	return model.ChannelGroup.GetGroupModelByChannelTypes(group, assistantsChannelTypes)
+}
+
+// collectObjectIds 收集 JSON 中整个字符串值为对象 id 的值，消息内容中提到的 id 不算引用
+func collectObjectIds(value any, objectIds []string) []string {
+	switch v := value.(type) {
+	case string:
+		if isObjectId(v) {
+			objectIds = append(objectIds, v)
+		}
+	case []any:
+		for _, item := range v {
+			objectIds = collectObjectIds(item, objectIds)
+		}
+	case map[string]any:
+		for _, item := range v {
+			objectIds = collectObjectIds(item, objectIds)
+		}
+	}
+	return objectIds
+}
+
+type channelObjectResponse struct {
+	Id      string            `json:"id"`
+	Object  string            `json:"object"`
+	Deleted bool              `json:"deleted"`
+	Data    []json.RawMessage `json:"data"`
+}
+
+// responseChannelObjects 转发上游的 JSON 响应，同时记录新创建和已删除的对象，返回响应中的运行
+func responseChannelObjects(c *gin.Context, resp *http.Response) ([]*assistantRun, *types.OpenAIErrorWithStatusCode) {
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode < http.StatusMultipleChoices && strings.HasPrefix(contentType, "text/event-stream") {
+		return responseRunEvents(c, resp)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices || !strings.HasPrefix(contentType, "application/json") {
+		return nil, responseMultipart(c, resp)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+
+	body = updateChannelObjects(c, body)
+
+	for k, v := range resp.Header {
+		c.Writer.Header().Set(k, v[0])
+	}
+	c.Writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	c.Writer.WriteHeader(resp.StatusCode)
+
+	if _, err := c.Writer.Write(body); err != nil {
+		return nil, common.ErrorWrapper(err, "write_response_body_failed", http.StatusInternalServerError)
+	}
+
+	return collectAssistantRuns(body, nil), nil
+}
+
+func updateChannelObjects(c *gin.Context, body []byte) []byte {
+	response := &channelObjectResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return body
+	}
+
+	userId := c.GetInt("id")
+	switch {
+	case response.Object == "list":
+		return filterChannelObjects(c, body, response.Data)
+	case response.Deleted && isObjectId(response.Id):
+		if err := model.DeleteChannelObject(response.Id, userId); err != nil {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("failed to delete channel object %s: %s", response.Id, err.Error()))
+		}
+	case c.Request.Method == http.MethodPost && isObjectId(response.Id):
+		object := &model.ChannelObject{
+			ObjectId:  response.Id,
+			UserId:    userId,
+			ChannelId: c.GetInt("channel_id"),
+			CreatedAt: utils.GetTimestamp(),
+		}
+		if err := object.Insert(); err != nil {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("failed to save channel object %s: %s", response.Id, err.Error()))
+		}
+	}
+
+	return body
+}
+
+// filterChannelObjects 上游的列表接口会返回渠道下所有的对象，普通用户只保留自己创建的对象
+// 管理员可以看到没有记录的对象，消息、运行等不单独记录的子对象不过滤
+func filterChannelObjects(c *gin.Context, body []byte, data []json.RawMessage) []byte {
+	items := make([]struct {
+		Id string `json:"id"`
+	}, len(data))
+
+	objectIds := make([]string, 0, len(data))
+	for i, item := range data {
+		if err := json.Unmarshal(item, &items[i]); err == nil && isObjectId(items[i].Id) {
+			objectIds = append(objectIds, items[i].Id)
+		}
+	}
+
+	if len(objectIds) == 0 {
+		return body
+	}
+
+	objects, err := model.GetChannelObjects(objectIds)
+	if err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("failed to get channel objects: %s", err.Error()))
+		return body
+	}
+
+	userId := c.GetInt("id")
+	owners := lo.SliceToMap(objects, func(object *model.ChannelObject) (string, int) {
+		return object.ObjectId, object.UserId
+	})
+	isAdmin := model.IsAdmin(userId)
+
+	filtered := make([]json.RawMessage, 0, len(data))
+	for i, item := range data {
+		owner, tracked := owners[items[i].Id]
+		switch {
+		case !isObjectId(items[i].Id):
+			filtered = append(filtered, item)
+		case tracked && owner == userId:
+			filtered = append(filtered, item)
+		case !tracked && isAdmin:
+			filtered = append(filtered, item)
+		}
+	}
+
+	if len(filtered) == len(data) {
+		return body
+	}
+
+	var list map[string]json.RawMessage
+	if err := json.Unmarshal(body, &list); err != nil {
+		return body
+	}
+	list["data"] = json.RawMessage(utils.Marshal(filtered))
+
+	return []byte(utils.Marshal(list))
+}
@@ -0,0 +1,230 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/providers/openai"
+	"one-api/relay/relay_util"
+	"one-api/types"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 助手接口的费用来自运行（run）消耗的 token，上游在运行结束后才会返回 usage
+// 运行第一次以结束状态出现在响应中时按运行的模型计费，已计费的运行记录在 ChannelObject 中避免重复计费
+const (
+	assistantRunPollInterval = 5 * time.Second
+	assistantRunPollTimeout  = 15 * time.Minute
+)
+
+// 创建运行和提交工具输出会让上游调用模型
+var runRequestRegex = regexp.MustCompile(`/runs(/[^/]+/submit_tool_outputs)?$`)
+
+type assistantRun struct {
+	Id       string       `json:"id"`
+	Object   string       `json:"object"`
+	ThreadId string       `json:"thread_id"`
+	Model    string       `json:"model"`
+	Status   string       `json:"status"`
+	Usage    *types.Usage `json:"usage"`
+}
+
+func (r *assistantRun) finished() bool {
+	switch r.Status {
+	case "completed", "failed", "cancelled", "expired", "incomplete":
+		return true
+	}
+	return false
+}
+
+func (r *assistantRun) billable() bool {
+	return r.finished() && r.Usage != nil && r.Usage.PromptTokens+r.Usage.CompletionTokens > 0
+}
+
+func isRunRequest(c *gin.Context) bool {
+	return c.Request.Method == http.MethodPost && runRequestRegex.MatchString(c.Request.URL.Path)
+}
+
+// collectAssistantRuns 收集运行对象或运行列表中的运行
+func collectAssistantRuns(body []byte, runs []*assistantRun) []*assistantRun {
+	var response struct {
+		assistantRun
+		Data []*assistantRun `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return runs
+	}
+
+	if response.Object == "thread.run" {
+		runs = append(runs, &response.assistantRun)
+	}
+	for _, run := range response.Data {
+		if run.Object == "thread.run" {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// runEventWriter 从转发的事件流中解析运行事件
+type runEventWriter struct {
+	buffer []byte
+	runs   []*assistantRun
+}
+
+func (w *runEventWriter) Write(p []byte) (int, error) {
+	w.buffer = append(w.buffer, p...)
+	for {
+		index := bytes.IndexByte(w.buffer, '\n')
+		if index < 0 {
+			break
+		}
+		line := bytes.TrimSpace(w.buffer[:index])
+		w.buffer = w.buffer[index+1:]
+
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if ok && bytes.Contains(data, []byte(`"thread.run"`)) {
+			w.runs = collectAssistantRuns(bytes.TrimSpace(data), w.runs)
+		}
+	}
+	return len(p), nil
+}
+
+// responseRunEvents 转发流式运行的事件，返回事件中的运行
+func responseRunEvents(c *gin.Context, resp *http.Response) ([]*assistantRun, *types.OpenAIErrorWithStatusCode) {
+	defer resp.Body.Close()
+
+	for k, v := range resp.Header {
+		c.Writer.Header().Set(k, v[0])
+	}
+	c.Writer.WriteHeader(resp.StatusCode)
+
+	events := &runEventWriter{}
+	reader := io.TeeReader(resp.Body, events)
+	buffer := make([]byte, 4096)
+	for {
+		n, err := reader.Read(buffer)
+		if n > 0 {
+			if _, err := c.Writer.Write(buffer[:n]); err != nil {
+				return events.runs, common.ErrorWrapper(err, "write_response_body_failed", http.StatusInternalServerError)
+			}
+			c.Writer.Flush()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events.runs, common.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		}
+	}
+
+	return events.runs, nil
+}
+
+// billAssistantRuns 按运行的 usage 计费，预扣的额度用于第一个计费的运行
+// 运行请求返回的运行还没有结束时，在后台查询运行直到结束后计费，没有需要计费的运行时退还预扣额度
+// 返回是否已经计费
+func billAssistantRuns(c *gin.Context, provider *openai.OpenAIProvider, quota *relay_util.Quota, runs []*assistantRun, isStream bool) bool {
+	// 同一个运行在事件流中会出现多次，只保留最后的状态
+	latest := make(map[string]*assistantRun, len(runs))
+	for _, run := range runs {
+		latest[run.Id] = run
+	}
+
+	billed := false
+	var pending *assistantRun
+	for _, run := range latest {
+		if !run.billable() {
+			if !run.finished() && run.ThreadId != "" {
+				pending = run
+			}
+			continue
+		}
+		if billAssistantRun(c, quota, run, isStream) {
+			quota = nil
+			billed = true
+		}
+	}
+
+	if quota == nil {
+		return billed
+	}
+	if pending != nil && isRunRequest(c) {
+		go pollAssistantRun(c.Copy(), provider, quota, pending)
+		return billed
+	}
+	quota.Undo(c)
+	return billed
+}
+
+// billAssistantRun 运行没有计费过时按运行的模型计费，quota 为 nil 时不使用预扣额度
+func billAssistantRun(c *gin.Context, quota *relay_util.Quota, run *assistantRun, isStream bool) bool {
+	object := &model.ChannelObject{
+		ObjectId:  run.Id,
+		UserId:    c.GetInt("id"),
+		ChannelId: c.GetInt("channel_id"),
+		CreatedAt: utils.GetTimestamp(),
+	}
+	created, err := object.InsertIfNotExists()
+	if err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("failed to save assistant run %s: %s", run.Id, err.Error()))
+		return false
+	}
+	if !created {
+		return false
+	}
+
+	if quota == nil {
+		quota = relay_util.NewQuota(c, run.Model, 0)
+	} else {
+		quota.SetChannel(c.GetInt("channel_id"), c.GetInt("channel_key_id"), run.Model)
+	}
+	run.Usage.TotalTokens = run.Usage.PromptTokens + run.Usage.CompletionTokens
+	quota.Consume(c, run.Usage, isStream)
+	return true
+}
+
+// pollAssistantRun 客户端不一定会查询运行的结果，由网关查询运行直到结束后计费
+func pollAssistantRun(c *gin.Context, provider *openai.OpenAIProvider, quota *relay_util.Quota, run *assistantRun) {
+	url := provider.GetFullRequestURL(fmt.Sprintf("/v1/threads/%s/runs/%s", run.ThreadId, run.Id), "")
+	headers := provider.GetRequestHeaders()
+	if beta := c.GetHeader("OpenAI-Beta"); beta != "" {
+		headers["OpenAI-Beta"] = beta
+	}
+
+	deadline := time.Now().Add(assistantRunPollTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(assistantRunPollInterval)
+
+		req, err := provider.Requester.NewRequest(http.MethodGet, url, provider.Requester.WithHeader(headers))
+		if err != nil {
+			break
+		}
+		current := &assistantRun{}
+		if _, errWithCode := provider.Requester.SendRequest(req, current, false); errWithCode != nil {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("failed to poll assistant run %s: %s", run.Id, errWithCode.Message))
+			continue
+		}
+
+		if current.billable() {
+			if !billAssistantRun(c, quota, current, false) {
+				quota.Undo(c)
+			}
+			return
+		}
+		if current.finished() {
+			break
+		}
+	}
+
+	quota.Undo(c)
+}
@@ -32,6 +32,10 @@ func (r *relayChat) setRequest() error {
 		return err
 	}
 
+	if err := applyVirtualModel(r.c, &r.chatRequest); err != nil {
+		return err
+	}
+
 	if r.chatRequest.MaxTokens < 0 || r.chatRequest.MaxTokens > math.MaxInt32/2 {
 		return errors.New("max_tokens is invalid")
 	}
@@ -76,6 +80,10 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 
 	r.chatRequest.Model = r.modelName
 
+	if shouldHedge(r.c) {
+		return r.sendHedged(chatProvider)
+	}
+
 	if r.chatRequest.Stream {
 		var response requester.StreamReaderInterface[string]
 		response, err = chatProvider.CreateChatCompletionStream(&r.chatRequest)
@@ -83,6 +91,11 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 			return
 		}
 
+		if r.canStreamFailover() {
+			err = r.responseStreamWithFailover(response)
+			return
+		}
+
 		doneStr := func() string {
 			return r.getUsageResponse()
 		}
@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -10,7 +11,7 @@ import (
 	"one-api/common/image"
 	"one-api/common/logger"
 	"one-api/common/requester"
-	"one-api/model"
+	providersBase "one-api/providers/base"
 	"one-api/providers/claude"
 	"one-api/relay/relay_util"
 	"one-api/types"
@@ -58,12 +59,14 @@ func RelaycClaudeOnly(c *gin.Context) {
 	errWithCode, done := RelayClaudeHandler(c, promptTokens, chatProvider, cacheProps, request, originalModel)
 
 	if errWithCode == nil {
+		recordChannelResult(c, nil)
 		return
 	}
 
 	apiErr := errWithCode.ToOpenAiError()
 
-	go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr, channel.Type)
+	recordChannelResult(c, apiErr)
+	go processChannelRelayError(c.Request.Context(), channel, apiErr)
 
 	retryTimes := config.RetryTimes
 	if done || !shouldRetry(c, apiErr, channel.Type) {
@@ -73,7 +76,7 @@ func RelaycClaudeOnly(c *gin.Context) {
 
 	for i := retryTimes; i > 0; i-- {
 		// 冻结通道
-		model.ChannelGroup.Cooldowns(channel.Id)
+		shouldCooldowns(c, apiErr, channel)
 		chatProvider, modelName, fail := GetClaudeChatInterface(c, originalModel)
 		if fail != nil {
 			continue
@@ -93,11 +96,13 @@ func RelaycClaudeOnly(c *gin.Context) {
 
 		errWithCode, done = RelayClaudeHandler(c, promptTokens, chatProvider, cacheProps, request, originalModel)
 		if errWithCode == nil {
+			recordChannelResult(c, nil)
 			return
 		}
 
 		apiErr = errWithCode.ToOpenAiError()
-		go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr, channel.Type)
+		recordChannelResult(c, apiErr)
+		go processChannelRelayError(c.Request.Context(), channel, apiErr)
 		if done || !shouldRetry(c, apiErr, channel.Type) {
 			break
 		}
@@ -112,6 +117,7 @@ func RelaycClaudeOnly(c *gin.Context) {
 }
 
 func RelayClaudeHandler(c *gin.Context, promptTokens int, chatProvider claude.ClaudeChatInterface, cache *relay_util.ChatCacheProps, request *claude.ClaudeRequest, originalModel string) (errWithCode *claude.ClaudeErrorWithStatusCode, done bool) {
+	startChannelRequest(c)
 
 	usage := &types.Usage{
 		PromptTokens: promptTokens,
@@ -212,7 +218,8 @@ func CountTokenMessages(request *claude.ClaudeRequest, preCostType int) (int, er
 					tokenNum += int(math.Ceil((float64(width) * float64(height)) / 750))
 
 				case "tool_use":
-					tokenNum += common.CountTokenInput(content["input"].(string), request.Model)
+					input, _ := json.Marshal(content["input"])
+					tokenNum += common.CountTokenInput(string(input), request.Model)
 				case "tool_result":
 					// 不算了  就只算他50吧
 					tokenNum += 50
@@ -230,10 +237,15 @@ func GetClaudeChatInterface(c *gin.Context, modelName string) (claude.ClaudeChat
 		return nil, "", claude.ErrorToClaudeErr(fail)
 	}
 
-	chatProvider, ok := provider.(claude.ClaudeChatInterface)
+	if chatProvider, ok := provider.(claude.ClaudeChatInterface); ok {
+		return chatProvider, modelName, nil
+	}
+
+	// 其他渠道通过 OpenAI 格式进行转换
+	openaiProvider, ok := provider.(providersBase.ChatInterface)
 	if !ok {
 		return nil, "", claude.ErrorToClaudeErr(errors.New("channel not implemented"))
 	}
 
-	return chatProvider, modelName, nil
+	return claude.NewChatAdapter(openaiProvider), modelName, nil
 }
@@ -20,6 +20,7 @@ import (
 	"one-api/relay/relay_util"
 	"one-api/types"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,6 +35,7 @@ func Path2Relay(c *gin.Context, path string) RelayBaseInterface {
 		allowCache = true
 		relay = NewRelayCompletions(c)
 	} else if strings.HasPrefix(path, "/v1/embeddings") {
+		allowCache = true
 		relay = NewRelayEmbeddings(c)
 	} else if strings.HasPrefix(path, "/v1/moderations") {
 		relay = NewRelayModerations(c)
@@ -49,6 +51,8 @@ func Path2Relay(c *gin.Context, path string) RelayBaseInterface {
 		relay = NewRelayTranscriptions(c)
 	} else if strings.HasPrefix(path, "/v1/audio/translations") {
 		relay = NewRelayTranslations(c)
+	} else if strings.HasPrefix(path, "/v1/responses") {
+		relay = NewRelayResponses(c)
 	}
 
 	if relay != nil {
@@ -71,6 +75,7 @@ func GetProvider(c *gin.Context, modeName string) (provider providersBase.Provid
 		fail = errors.New("channel not found")
 		return
 	}
+	c.Set("channel_key_id", provider.GetChannel().KeyId)
 	provider.SetOriginalModel(modeName)
 	c.Set("original_model", modeName)
 
@@ -116,18 +121,91 @@ func fetchChannelByModel(c *gin.Context, modelName string) (*model.Channel, erro
 	if ok {
 		filters = append(filters, model.FilterChannelId(skipChannelIds))
 	}
+	if channelTypes, ok := utils.GetGinValue[[]int](c, "channel_types"); ok {
+		filters = append(filters, model.FilterChannelTypes(channelTypes))
+	}
+	filters = append(filters, capabilityFilters(c, modelName)...)
+
+	// 重试时先归还上一个渠道的名额
+	releaseChannelPermit(c)
+
+	var waiter *model.QueueWaiter
+	defer func() {
+		waiter.Leave()
+	}()
+	deadline := time.Now().Add(time.Duration(config.ChannelQueueTimeout) * time.Second)
+	for {
+		channel, permit, err := model.ChannelGroup.Acquire(group, modelName, filters...)
+		if err == nil {
+			c.Set("channel_permit", permit)
+			return channel, nil
+		}
 
-	channel, err := model.ChannelGroup.Next(group, modelName, filters...)
-	if err != nil {
-		message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", group, modelName)
-		if channel != nil {
-			logger.SysError(fmt.Sprintf("渠道不存在：%d", channel.Id))
-			message = "数据库一致性已被破坏，请联系管理员"
+		if !errors.Is(err, model.ErrChannelsSaturated) {
+			message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", group, modelName)
+			if channel != nil {
+				logger.SysError(fmt.Sprintf("渠道不存在：%d", channel.Id))
+				message = "数据库一致性已被破坏，请联系管理员"
+			}
+			return nil, errors.New(message)
+		}
+
+		// 所有渠道都已满载，排队等待名额释放
+		if config.ChannelQueueTimeout <= 0 || config.ChannelQueueSize <= 0 {
+			return nil, errors.New("当前分组上游负载已饱和，请稍后再试")
+		}
+		if waiter == nil {
+			priority := 0
+			if userGroup := model.GlobalUserGroupRatio.GetBySymbol(group); userGroup != nil {
+				priority = userGroup.QueuePriority
+			}
+			waiter = model.NewQueueWaiter(priority)
+		} else {
+			waiter.Pass()
+		}
+		if err := waiter.Wait(c.Request.Context(), deadline); err != nil {
+			logger.LogWarn(c.Request.Context(), fmt.Sprintf("wait for channel failed: %s", err.Error()))
+			return nil, errors.New("当前分组上游负载已饱和，请稍后再试")
 		}
-		return nil, errors.New(message)
 	}
+}
 
-	return channel, nil
+// acquireChatProvider 在当前模型下另选一个聊天渠道，不会排队等待，没有可用渠道时返回 nil
+func acquireChatProvider(c *gin.Context, excludeIds []int) (providersBase.ChatInterface, string, *model.ChannelPermit) {
+	skipChannelIds, _ := utils.GetGinValue[[]int](c, "skip_channel_ids")
+	skipChannelIds = append(append([]int{}, skipChannelIds...), excludeIds...)
+	filters := []model.ChannelsFilterFunc{model.FilterChannelId(skipChannelIds)}
+	if c.GetBool("skip_only_chat") {
+		filters = append(filters, model.FilterOnlyChat())
+	}
+
+	originalModel := c.GetString("original_model")
+	filters = append(filters, capabilityFilters(c, originalModel)...)
+	channel, permit, err := model.ChannelGroup.Acquire(c.GetString("token_group"), originalModel, filters...)
+	if err != nil {
+		return nil, "", nil
+	}
+
+	provider, ok := providers.GetProvider(channel, c).(providersBase.ChatInterface)
+	if !ok {
+		permit.Release()
+		return nil, "", nil
+	}
+	provider.SetOriginalModel(originalModel)
+	modelName, err := provider.ModelMappingHandler(originalModel)
+	if err != nil {
+		permit.Release()
+		return nil, "", nil
+	}
+
+	return provider, modelName, permit
+}
+
+// releaseChannelPermit 归还渠道的并发名额
+func releaseChannelPermit(c *gin.Context) {
+	if permit, ok := utils.GetGinValue[*model.ChannelPermit](c, "channel_permit"); ok {
+		permit.Release()
+	}
 }
 
 func responseJsonClient(c *gin.Context, data interface{}) *types.OpenAIErrorWithStatusCode {
@@ -157,6 +235,7 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 	c.Stream(func(w io.Writer) bool {
 		select {
 		case data := <-dataChan:
+			markFirstResponse(c)
 			streamData := "data: " + data + "\n\n"
 			fmt.Fprint(w, streamData)
 			cache.SetResponse(streamData)
@@ -195,6 +274,7 @@ func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderIn
 	c.Stream(func(w io.Writer) bool {
 		select {
 		case data := <-dataChan:
+			markFirstResponse(c)
 			fmt.Fprint(w, data)
 			cache.SetResponse(data)
 			return true
@@ -281,45 +361,95 @@ func shouldRetry(c *gin.Context, apiErr *types.OpenAIErrorWithStatusCode, channe
 		return false
 	}
 
-	if apiErr.StatusCode == http.StatusTooManyRequests {
-		return true
+	// 由重试规则决定，没有匹配的规则时重试
+	_, rule := common.MatchRetryRule(channelType, apiErr)
+	return rule == nil || rule.Action != common.RetryActionFail
+}
+
+// startChannelRequest 记录当前渠道请求的开始时间和进行中的请求数，由 recordChannelResult 结束
+func startChannelRequest(c *gin.Context) {
+	channelId := c.GetInt("channel_id")
+	if channelId == 0 {
+		return
+	}
+
+	model.ChannelGroup.StartRequest(channelId)
+	c.Set("channel_request_start", time.Now())
+	c.Set("channel_first_response", time.Time{})
+}
+
+// markFirstResponse 记录流式响应第一个数据块的时间
+func markFirstResponse(c *gin.Context) {
+	if firstResponse, ok := utils.GetGinValue[time.Time](c, "channel_first_response"); ok && firstResponse.IsZero() {
+		c.Set("channel_first_response", time.Now())
 	}
+}
 
-	if apiErr.StatusCode == 307 {
-		return true
+// recordChannelResult 将请求结果计入渠道熔断器和负载均衡统计，请求参数等客户端错误不计入
+func recordChannelResult(c *gin.Context, apiErr *types.OpenAIErrorWithStatusCode) {
+	releaseChannelPermit(c)
+	if c.GetBool("channel_result_recorded") {
+		c.Set("channel_result_recorded", false)
+		return
 	}
+	channelId := c.GetInt("channel_id")
+	if channelId == 0 {
+		return
+	}
+	modelName := c.GetString("original_model")
 
-	if apiErr.StatusCode/100 == 5 {
-		// 超时不重试
-		if apiErr.StatusCode == 504 || apiErr.StatusCode == 524 {
-			return false
+	if startTime, ok := utils.GetGinValue[time.Time](c, "channel_request_start"); ok && !startTime.IsZero() {
+		c.Set("channel_request_start", time.Time{})
+		model.ChannelGroup.FinishRequest(channelId)
+
+		// 非流式请求没有首字时间，使用完整的响应时间
+		if apiErr == nil {
+			firstResponse, _ := utils.GetGinValue[time.Time](c, "channel_first_response")
+			if firstResponse.IsZero() {
+				firstResponse = time.Now()
+			}
+			model.ChannelGroup.RecordLatency(channelId, modelName, firstResponse.Sub(startTime))
 		}
-		return true
 	}
 
-	if apiErr.StatusCode == http.StatusBadRequest {
-		// 如果是culade 400错误，需要重试
-		if channelType == config.ChannelTypeAnthropic && strings.Contains(apiErr.Message, "This organization has been disabled") {
-			return true
-		}
-		return false
+	if apiErr != nil && !isChannelFailure(apiErr) {
+		return
 	}
+	model.ChannelGroup.RecordResult(channelId, modelName, apiErr == nil)
+}
 
-	if apiErr.StatusCode == 408 {
-		// azure处理超时不重试
+// isChannelFailure 只有服务端错误、鉴权失败、超时和限流说明渠道有问题
+func isChannelFailure(apiErr *types.OpenAIErrorWithStatusCode) bool {
+	if apiErr.LocalError {
 		return false
 	}
+	return apiErr.StatusCode >= http.StatusInternalServerError || utils.Contains(apiErr.StatusCode, []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests})
+}
 
-	if apiErr.StatusCode/100 == 2 {
-		return false
+func processChannelRelayError(ctx context.Context, channel *model.Channel, err *types.OpenAIErrorWithStatusCode) {
+	logger.LogError(ctx, fmt.Sprintf("relay error (channel #%d(%s)): %s", channel.Id, channel.Name, err.Message))
+	if channel.KeyId > 0 {
+		processChannelKeyError(channel, err)
+		return
+	}
+	if controller.ShouldDisableChannel(channel.Type, err) {
+		controller.DisableChannel(channel.Id, channel.Name, err.Message, true)
 	}
-	return true
 }
 
-func processChannelRelayError(ctx context.Context, channelId int, channelName string, err *types.OpenAIErrorWithStatusCode, channelType int) {
-	logger.LogError(ctx, fmt.Sprintf("relay error (channel #%d(%s)): %s", channelId, channelName, err.Message))
-	if controller.ShouldDisableChannel(channelType, err) {
-		controller.DisableChannel(channelId, channelName, err.Message, true)
+// processChannelKeyError 使用密钥池的渠道只禁用出错的密钥，所有密钥都被禁用后才禁用渠道
+func processChannelKeyError(channel *model.Channel, err *types.OpenAIErrorWithStatusCode) {
+	if err.LocalError {
+		return
+	}
+	model.RecordChannelKeyError(channel.KeyId, err.Message)
+	if !controller.ShouldDisableChannel(channel.Type, err) {
+		return
+	}
+
+	controller.DisableChannelKey(channel.Id, channel.KeyId, err.Message)
+	if !model.ChannelKeys.HasEnabled(channel.Id) {
+		controller.DisableChannel(channel.Id, channel.Name, "所有密钥均已被禁用", true)
 	}
 }
 
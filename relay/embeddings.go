@@ -1,18 +1,30 @@
 package relay
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/common/utils"
 	providersBase "one-api/providers/base"
+	"one-api/relay/relay_util"
 	"one-api/types"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
 )
 
 type relayEmbeddings struct {
 	relayBase
 	request types.EmbeddingRequest
+
+	// 按输入项缓存时使用：inputs 为拆分后的输入，cached 为命中缓存的向量，missing 为需要请求上游的下标
+	inputs  []string
+	cached  map[int]any
+	missing []int
+	// 命中缓存的输入项来自的渠道，用于全部命中时记录日志
+	cachedChannelId int
 }
 
 func NewRelayEmbeddings(c *gin.Context) *relayEmbeddings {
@@ -31,11 +43,16 @@ func (r *relayEmbeddings) setRequest() error {
 	}
 
 	r.originalModel = r.request.Model
+	r.loadItemCache()
 
 	return nil
 }
 
 func (r *relayEmbeddings) getPromptTokens() (int, error) {
+	if r.cached != nil {
+		return common.CountTokenInput(r.missingInputs(), r.modelName), nil
+	}
+
 	return common.CountTokenInput(r.request.Input, r.modelName), nil
 }
 
@@ -49,7 +66,12 @@ func (r *relayEmbeddings) send() (err *types.OpenAIErrorWithStatusCode, done boo
 
 	r.request.Model = r.modelName
 
-	response, err := provider.CreateEmbeddings(&r.request)
+	var response *types.EmbeddingResponse
+	if r.cached != nil {
+		response, err = r.sendMissing(provider)
+	} else {
+		response, err = provider.CreateEmbeddings(&r.request)
+	}
 	if err != nil {
 		return
 	}
@@ -61,3 +83,166 @@ func (r *relayEmbeddings) send() (err *types.OpenAIErrorWithStatusCode, done boo
 
 	return
 }
+
+// loadItemCache 按输入项查询缓存，只支持文本输入
+func (r *relayEmbeddings) loadItemCache() {
+	if r.cache == nil || !r.cache.Enabled() {
+		return
+	}
+
+	inputs := r.request.ParseInput()
+	switch input := r.request.Input.(type) {
+	case string:
+	case []any:
+		// token 数组等非文本输入不缓存
+		if len(inputs) != len(input) {
+			return
+		}
+	default:
+		return
+	}
+
+	if len(inputs) == 0 {
+		return
+	}
+
+	r.inputs = inputs
+	r.cached = make(map[int]any, len(inputs))
+	for i, input := range inputs {
+		item := r.cache.GetItemCache(r.itemKey(input))
+		if item == nil {
+			r.missing = append(r.missing, i)
+			continue
+		}
+
+		var embedding any
+		if err := json.Unmarshal([]byte(item.Response), &embedding); err != nil {
+			r.missing = append(r.missing, i)
+			continue
+		}
+		r.cached[i] = embedding
+		r.cachedChannelId = item.ChannelID
+	}
+}
+
+// getFullCache 所有输入项都命中缓存时，直接返回完整的缓存响应，不再选择渠道
+func (r *relayEmbeddings) getFullCache() *relay_util.ChatCacheProps {
+	if r.cached == nil || len(r.missing) > 0 {
+		return nil
+	}
+
+	response := &types.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]types.Embedding, 0, len(r.inputs)),
+		Model:  r.originalModel,
+		Usage:  &types.Usage{},
+	}
+	for index := range r.inputs {
+		response.Data = append(response.Data, types.Embedding{
+			Object:    "embedding",
+			Embedding: r.cached[index],
+			Index:     index,
+		})
+	}
+
+	itemCache, tokens := r.itemCacheMeta()
+	return &relay_util.ChatCacheProps{
+		UserId:       r.cache.UserId,
+		TokenId:      r.cache.TokenId,
+		ChannelID:    r.cachedChannelId,
+		PromptTokens: tokens,
+		ModelName:    r.originalModel,
+		Response:     utils.Marshal(response),
+		ItemCache:    itemCache,
+	}
+}
+
+// itemCacheMeta 命中缓存的输入项数量和 token 数，记录在日志中
+func (r *relayEmbeddings) itemCacheMeta() (map[string]any, int) {
+	inputs := make([]string, 0, len(r.cached))
+	for index := range r.inputs {
+		if _, ok := r.cached[index]; ok {
+			inputs = append(inputs, r.inputs[index])
+		}
+	}
+
+	tokens := common.CountTokenInput(inputs, r.originalModel)
+	return map[string]any{
+		"inputs": len(inputs),
+		"tokens": tokens,
+	}, tokens
+}
+
+// itemKey 相同模型、维度和编码格式下的相同输入才能复用
+func (r *relayEmbeddings) itemKey(input string) string {
+	return fmt.Sprintf("embeddings-%s-%d-%s-%s", r.originalModel, r.request.Dimensions, r.request.EncodingFormat, input)
+}
+
+func (r *relayEmbeddings) missingInputs() []string {
+	return lo.Map(r.missing, func(index int, _ int) string {
+		return r.inputs[index]
+	})
+}
+
+// sendMissing 只请求没有命中缓存的输入项，再与缓存合并为完整的响应
+func (r *relayEmbeddings) sendMissing(provider providersBase.EmbeddingsInterface) (*types.EmbeddingResponse, *types.OpenAIErrorWithStatusCode) {
+	response := &types.EmbeddingResponse{
+		Object: "list",
+		Model:  r.modelName,
+		Usage:  &types.Usage{},
+	}
+
+	embeddings := make(map[int]any, len(r.inputs))
+	for index, embedding := range r.cached {
+		embeddings[index] = embedding
+	}
+	if len(r.cached) > 0 {
+		itemCache, _ := r.itemCacheMeta()
+		r.c.Set("item_cache", itemCache)
+	}
+
+	if len(r.missing) > 0 {
+		request := r.request
+		request.Input = r.missingInputs()
+		upstream, errWithCode := provider.CreateEmbeddings(&request)
+		if errWithCode != nil {
+			return nil, errWithCode
+		}
+
+		items := make(map[string]any, len(upstream.Data))
+		for _, item := range upstream.Data {
+			if item.Index < 0 || item.Index >= len(r.missing) {
+				continue
+			}
+			index := r.missing[item.Index]
+			embeddings[index] = item.Embedding
+			items[r.itemKey(r.inputs[index])] = item.Embedding
+		}
+
+		channelId := r.c.GetInt("channel_id")
+		go func() {
+			for key, embedding := range items {
+				r.cache.StoreItemCache(key, channelId, r.modelName, embedding)
+			}
+		}()
+
+		response.Model = upstream.Model
+		response.Usage = upstream.Usage
+	}
+
+	response.Data = make([]types.Embedding, 0, len(r.inputs))
+	for index := range r.inputs {
+		embedding, ok := embeddings[index]
+		if !ok {
+			return nil, common.StringErrorWrapper("upstream returned incomplete embeddings", "embeddings_error", http.StatusInternalServerError)
+		}
+
+		response.Data = append(response.Data, types.Embedding{
+			Object:    "embedding",
+			Embedding: embedding,
+			Index:     index,
+		})
+	}
+
+	return response, nil
+}
@@ -0,0 +1,349 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"one-api/common"
+	"one-api/common/logger"
+	"one-api/common/storage"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/providers"
+	"one-api/providers/openai"
+	"one-api/types"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+const maxFileBytes = 512 * 1024 * 1024
+
+var filePurposes = map[string]bool{
+	types.FilePurposeAssistants: true,
+	types.FilePurposeBatch:      true,
+	types.FilePurposeFineTune:   true,
+	types.FilePurposeVision:     true,
+	types.FilePurposeUserData:   true,
+	types.FilePurposeEvals:      true,
+}
+
+// 网关文件 id 与上游的格式不同，用于在转发请求时识别
+var localFileIdRegex = regexp.MustCompile(`file-[0-9a-f]{32}`)
+
+func NewFileId() string {
+	return "file-" + utils.GetUUID()
+}
+
+// SaveFile 将文件内容写入存储并记录文件信息，file 需要设置 FileId、UserId、Filename 和 Purpose
+func SaveFile(file *model.File, reader io.Reader) error {
+	file.StorageKey = fmt.Sprintf("%d/%s", file.UserId, file.FileId)
+	size, err := storage.PutFile(file.StorageKey, reader)
+	if err != nil {
+		return err
+	}
+
+	file.Bytes = size
+	file.Drive = storage.FileDriveName()
+	file.CreatedAt = utils.GetTimestamp()
+
+	if err := file.Insert(); err != nil {
+		storage.DeleteFile(file.Drive, file.StorageKey)
+		return err
+	}
+
+	return nil
+}
+
+func OpenFile(file *model.File) (io.ReadCloser, error) {
+	return storage.GetFile(file.Drive, file.StorageKey)
+}
+
+func fileModel2OpenAI(file *model.File) *types.OpenAIFile {
+	return &types.OpenAIFile{
+		Id:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+	}
+}
+
+type uploadFileRequest struct {
+	Purpose string                `form:"purpose" binding:"required"`
+	File    *multipart.FileHeader `form:"file" binding:"required"`
+}
+
+func UploadFile(c *gin.Context) {
+	var request uploadFileRequest
+	if err := c.ShouldBind(&request); err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !filePurposes[request.Purpose] {
+		common.AbortWithMessage(c, http.StatusBadRequest, fmt.Sprintf("'%s' is not a valid purpose", request.Purpose))
+		return
+	}
+
+	if request.File.Size > maxFileBytes {
+		common.AbortWithMessage(c, http.StatusBadRequest, "file is too large")
+		return
+	}
+
+	reader, err := request.File.Open()
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer reader.Close()
+
+	file := &model.File{
+		FileId:   NewFileId(),
+		UserId:   c.GetInt("id"),
+		Filename: request.File.Filename,
+		Purpose:  request.Purpose,
+	}
+
+	if err := SaveFile(file, reader); err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, fileModel2OpenAI(file))
+}
+
+func ListFiles(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10000"))
+	if limit < 1 || limit > 10000 {
+		limit = 10000
+	}
+
+	files, err := model.GetUserFilesList(c.GetInt("id"), c.Query("purpose"), c.Query("after"), limit+1, c.Query("order") != "asc")
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	list := &types.OpenAIFileList{
+		Object:  "list",
+		Data:    make([]*types.OpenAIFile, 0, len(files)),
+		HasMore: len(files) > limit,
+	}
+
+	if list.HasMore {
+		files = files[:limit]
+	}
+
+	for _, file := range files {
+		list.Data = append(list.Data, fileModel2OpenAI(file))
+	}
+
+	if len(list.Data) > 0 {
+		list.FirstId = &list.Data[0].Id
+		list.LastId = &list.Data[len(list.Data)-1].Id
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func getUserFile(c *gin.Context) *model.File {
+	fileId := c.Param("id")
+	file, err := model.GetUserFile(fileId, c.GetInt("id"))
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+
+	if file == nil {
+		common.AbortWithMessage(c, http.StatusNotFound, fmt.Sprintf("No such File object: %s", fileId))
+		return nil
+	}
+
+	return file
+}
+
+func RetrieveFile(c *gin.Context) {
+	file := getUserFile(c)
+	if file == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, fileModel2OpenAI(file))
+}
+
+func RetrieveFileContent(c *gin.Context) {
+	file := getUserFile(c)
+	if file == nil {
+		return
+	}
+
+	reader, err := OpenFile(file)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, file.Bytes, "application/octet-stream", reader, nil)
+}
+
+func DeleteFile(c *gin.Context) {
+	file := getUserFile(c)
+	if file == nil {
+		return
+	}
+
+	upstreamFiles, err := model.GetUpstreamFiles(file.FileId)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := file.Delete(); err != nil {
+		common.AbortWithMessage(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := storage.DeleteFile(file.Drive, file.StorageKey); err != nil {
+		logger.SysError(fmt.Sprintf("failed to delete file %s: %s", file.FileId, err.Error()))
+	}
+
+	if len(upstreamFiles) > 0 {
+		common.SafeGoroutine(func() {
+			deleteUpstreamFiles(file.UserId, upstreamFiles)
+		})
+	}
+
+	c.JSON(http.StatusOK, types.OpenAIFileDeleted{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
+
+// deleteUpstreamFiles 删除文件上传到各个上游渠道的副本，渠道已删除时跳过
+func deleteUpstreamFiles(userId int, upstreamFiles []*model.UpstreamFile) {
+	for _, upstreamFile := range upstreamFiles {
+		channel := model.ChannelGroup.GetChannel(upstreamFile.ChannelId)
+		if channel == nil {
+			continue
+		}
+
+		provider, ok := getOpenAIProvider(providers.GetProvider(channel, nil))
+		if !ok {
+			continue
+		}
+
+		if errWithCode := provider.DeleteFile(upstreamFile.UpstreamFileId); errWithCode != nil {
+			logger.SysError(fmt.Sprintf("failed to delete upstream file %s on channel %d: %s", upstreamFile.UpstreamFileId, upstreamFile.ChannelId, errWithCode.Message))
+			continue
+		}
+
+		if err := model.DeleteChannelObject(upstreamFile.UpstreamFileId, userId); err != nil {
+			logger.SysError(fmt.Sprintf("failed to delete channel object %s: %s", upstreamFile.UpstreamFileId, err.Error()))
+		}
+	}
+}
+
+// getUpstreamFileId 获取文件在上游渠道中的 id，还没有上传过则先上传
+func getUpstreamFileId(provider *openai.OpenAIProvider, file *model.File) (string, *types.OpenAIErrorWithStatusCode) {
+	channelId := provider.GetChannel().Id
+	upstreamFile, err := model.GetUpstreamFile(file.FileId, channelId)
+	if err != nil {
+		return "", common.ErrorWrapper(err, "get_upstream_file_failed", http.StatusInternalServerError)
+	}
+
+	if upstreamFile != nil {
+		return upstreamFile.UpstreamFileId, nil
+	}
+
+	reader, err := OpenFile(file)
+	if err != nil {
+		return "", common.ErrorWrapper(err, "open_file_failed", http.StatusInternalServerError)
+	}
+	defer reader.Close()
+
+	purpose := file.Purpose
+	if purpose == types.FilePurposeBatchOutput {
+		purpose = types.FilePurposeUserData
+	}
+
+	response, errWithCode := provider.UploadFile(purpose, file.Filename, reader)
+	if errWithCode != nil {
+		return "", errWithCode
+	}
+
+	upstreamFile = &model.UpstreamFile{
+		FileId:         file.FileId,
+		ChannelId:      channelId,
+		UpstreamFileId: response.Id,
+		CreatedAt:      utils.GetTimestamp(),
+	}
+	if err := upstreamFile.Insert(); err != nil {
+		logger.SysError(fmt.Sprintf("failed to save upstream file %s: %s", file.FileId, err.Error()))
+	}
+
+	// 上游文件 id 会出现在向量库等对象的响应中，记录归属后用户可以直接引用
+	object := &model.ChannelObject{
+		ObjectId:  response.Id,
+		UserId:    file.UserId,
+		ChannelId: channelId,
+		CreatedAt: utils.GetTimestamp(),
+	}
+	if err := object.Insert(); err != nil {
+		logger.SysError(fmt.Sprintf("failed to save channel object %s: %s", response.Id, err.Error()))
+	}
+
+	return response.Id, nil
+}
+
+// replaceLocalFileIds 将请求路径和 JSON 请求体中的网关文件 id 替换为上游文件 id
+func replaceLocalFileIds(c *gin.Context, provider *openai.OpenAIProvider, path string) (string, *types.OpenAIErrorWithStatusCode) {
+	body, err := readJSONBody(c)
+	if err != nil {
+		return "", common.ErrorWrapperLocal(err, "read_request_body_failed", http.StatusBadRequest)
+	}
+
+	fileIds := localFileIdRegex.FindAllString(path, -1)
+	if len(body) > 0 && json.Valid(body) {
+		fileIds = append(fileIds, localFileIdRegex.FindAllString(string(body), -1)...)
+	}
+
+	if len(fileIds) == 0 {
+		return path, nil
+	}
+
+	userId := c.GetInt("id")
+	for _, fileId := range lo.Uniq(fileIds) {
+		file, err := model.GetUserFile(fileId, userId)
+		if err != nil {
+			return "", common.ErrorWrapper(err, "get_file_failed", http.StatusInternalServerError)
+		}
+		if file == nil {
+			continue
+		}
+
+		upstreamFileId, errWithCode := getUpstreamFileId(provider, file)
+		if errWithCode != nil {
+			return "", errWithCode
+		}
+
+		path = strings.ReplaceAll(path, fileId, upstreamFileId)
+		body = bytes.ReplaceAll(body, []byte(fileId), []byte(upstreamFileId))
+	}
+
+	if body != nil {
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+		c.Request.ContentLength = int64(len(body))
+	}
+
+	return path, nil
+}
@@ -10,7 +10,7 @@ import (
 	"one-api/common/image"
 	"one-api/common/logger"
 	"one-api/common/requester"
-	"one-api/model"
+	providersBase "one-api/providers/base"
 	"one-api/providers/gemini"
 	"one-api/relay/relay_util"
 	"one-api/types"
@@ -19,8 +19,6 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var AllowGeminiChannelType = []int{config.ChannelTypeGemini}
-
 func RelaycGeminiOnly(c *gin.Context) {
 	modelAction := c.Param("model")
 	if modelAction == "" {
@@ -49,8 +47,6 @@ func RelaycGeminiOnly(c *gin.Context) {
 	request.Model = modelList[0]
 	request.Stream = isStream
 
-	c.Set("allow_channel_type", AllowGeminiChannelType)
-
 	cacheProps := relay_util.NewChatCacheProps(c, true)
 	cacheProps.SetHash(request)
 
@@ -83,12 +79,14 @@ func RelaycGeminiOnly(c *gin.Context) {
 	errWithCode, done := RelayGeminiHandler(c, promptTokens, chatProvider, cacheProps, request, originalModel)
 
 	if errWithCode == nil {
+		recordChannelResult(c, nil)
 		return
 	}
 
 	apiErr := errWithCode.ToOpenAiError()
 
-	go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr, channel.Type)
+	recordChannelResult(c, apiErr)
+	go processChannelRelayError(c.Request.Context(), channel, apiErr)
 
 	retryTimes := config.RetryTimes
 	if done || !shouldRetry(c, apiErr, channel.Type) {
@@ -98,7 +96,7 @@ func RelaycGeminiOnly(c *gin.Context) {
 
 	for i := retryTimes; i > 0; i-- {
 		// 冻结通道
-		model.ChannelGroup.Cooldowns(channel.Id)
+		shouldCooldowns(c, apiErr, channel)
 		chatProvider, modelName, fail := GetGeminiChatInterface(c, originalModel)
 		if fail != nil {
 			continue
@@ -118,11 +116,13 @@ func RelaycGeminiOnly(c *gin.Context) {
 
 		errWithCode, done = RelayGeminiHandler(c, promptTokens, chatProvider, cacheProps, request, originalModel)
 		if errWithCode == nil {
+			recordChannelResult(c, nil)
 			return
 		}
 
 		apiErr = errWithCode.ToOpenAiError()
-		go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr, channel.Type)
+		recordChannelResult(c, apiErr)
+		go processChannelRelayError(c.Request.Context(), channel, apiErr)
 		if done || !shouldRetry(c, apiErr, channel.Type) {
 			break
 		}
@@ -137,6 +137,7 @@ func RelaycGeminiOnly(c *gin.Context) {
 }
 
 func RelayGeminiHandler(c *gin.Context, promptTokens int, chatProvider gemini.GeminiChatInterface, cache *relay_util.ChatCacheProps, request *gemini.GeminiChatRequest, originalModel string) (errWithCode *gemini.GeminiErrorWithStatusCode, done bool) {
+	startChannelRequest(c)
 
 	usage := &types.Usage{
 		PromptTokens: promptTokens,
@@ -234,16 +235,20 @@ func CountGeminiTokenMessages(request *gemini.GeminiChatRequest, preCostType int
 }
 
 func GetGeminiChatInterface(c *gin.Context, modelName string) (gemini.GeminiChatInterface, string, *gemini.GeminiErrorResponse) {
-	fmt.Println(modelName)
 	provider, modelName, fail := GetProvider(c, modelName)
 	if fail != nil {
 		return nil, "", gemini.ErrorToGeminiErr(fail)
 	}
 
-	chatProvider, ok := provider.(gemini.GeminiChatInterface)
+	if chatProvider, ok := provider.(gemini.GeminiChatInterface); ok {
+		return chatProvider, modelName, nil
+	}
+
+	// 其他渠道通过 OpenAI 格式进行转换
+	openaiProvider, ok := provider.(providersBase.ChatInterface)
 	if !ok {
 		return nil, "", gemini.ErrorToGeminiErr(errors.New("channel not implemented"))
 	}
 
-	return chatProvider, modelName, nil
+	return gemini.NewChatAdapter(openaiProvider), modelName, nil
 }
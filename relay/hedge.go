@@ -0,0 +1,327 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/model"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hedgeAttempt 对冲请求中的一次尝试，流式请求以收到第一个数据块为完成，非流式请求以收到完整响应为完成
+type hedgeAttempt struct {
+	provider  providersBase.ChatInterface
+	modelName string
+	permit    *model.ChannelPermit
+	cancel    context.CancelFunc
+	startAt   time.Time
+	firstAt   time.Time
+
+	stream   requester.StreamReaderInterface[string]
+	dataChan <-chan string
+	errChan  <-chan error
+	first    string
+	response *types.ChatCompletionResponse
+	err      *types.OpenAIErrorWithStatusCode
+	result   string
+}
+
+// newHedgeAttempt 的 ctx 使用客户端请求的 context，客户端断开时一起取消
+func newHedgeAttempt(ctx context.Context, provider providersBase.ChatInterface, modelName string, permit *model.ChannelPermit) *hedgeAttempt {
+	ctx, cancel := context.WithCancel(ctx)
+	if requester := provider.GetRequester(); requester != nil {
+		requester.Context = ctx
+	}
+	return &hedgeAttempt{
+		provider:  provider,
+		modelName: modelName,
+		permit:    permit,
+		cancel:    cancel,
+		startAt:   time.Now(),
+	}
+}
+
+func (a *hedgeAttempt) channel() *model.Channel {
+	return a.provider.GetChannel()
+}
+
+// run 执行一次尝试，每次尝试使用请求的深拷贝，避免渠道修改请求时互相影响
+func (a *hedgeAttempt) run(original *types.ChatCompletionRequest, results chan<- *hedgeAttempt) {
+	defer func() {
+		a.firstAt = time.Now()
+		results <- a
+	}()
+
+	request, err := cloneChatRequest(original)
+	if err != nil {
+		a.err = common.ErrorWrapperLocal(err, "clone_request_failed", http.StatusInternalServerError)
+		return
+	}
+	request.Model = a.modelName
+	if !request.Stream {
+		a.response, a.err = a.provider.CreateChatCompletion(request)
+		return
+	}
+
+	a.stream, a.err = a.provider.CreateChatCompletionStream(request)
+	if a.err != nil {
+		return
+	}
+	a.dataChan, a.errChan = a.stream.Recv()
+	select {
+	case a.first = <-a.dataChan:
+	case err := <-a.errChan:
+		a.stream.Close()
+		if errors.Is(err, io.EOF) {
+			err = errors.New("empty stream")
+		}
+		a.err = common.ErrorWrapper(err, "stream_error", http.StatusInternalServerError)
+	}
+}
+
+func cloneChatRequest(request *types.ChatCompletionRequest) (*types.ChatCompletionRequest, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := &types.ChatCompletionRequest{}
+	if err := json.Unmarshal(data, clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// abandon 取消输掉的请求并归还渠道名额，已经建立的流读完剩余数据后关闭
+func (a *hedgeAttempt) abandon(results <-chan *hedgeAttempt) {
+	a.cancel()
+	a.permit.Release()
+	model.ChannelGroup.FinishRequest(a.channel().Id)
+
+	go func() {
+		<-results
+		if a.err != nil || a.stream == nil {
+			return
+		}
+		a.stream.Close()
+		for {
+			select {
+			case <-a.dataChan:
+			case <-a.errChan:
+				return
+			}
+		}
+	}()
+}
+
+func (a *hedgeAttempt) log() map[string]any {
+	entry := map[string]any{
+		"channel_id": a.channel().Id,
+		"model":      a.modelName,
+		"result":     a.result,
+	}
+	// 被取消的请求仍可能在运行，不读取它的时间
+	if a.result != "canceled" {
+		entry["first_response_ms"] = a.firstAt.Sub(a.startAt).Milliseconds()
+	}
+	return entry
+}
+
+// prefetchedStream 先返回对冲时已经读取的第一个数据块，再按顺序转发剩余的数据
+type prefetchedStream struct {
+	attempt *hedgeAttempt
+	done    chan struct{}
+}
+
+func (s *prefetchedStream) Recv() (<-chan string, <-chan error) {
+	dataChan := make(chan string)
+	errChan := make(chan error)
+	go func() {
+		select {
+		case dataChan <- s.attempt.first:
+		case <-s.done:
+			return
+		}
+		for {
+			select {
+			case data := <-s.attempt.dataChan:
+				select {
+				case dataChan <- data:
+				case <-s.done:
+					return
+				}
+			case err := <-s.attempt.errChan:
+				select {
+				case errChan <- err:
+				case <-s.done:
+				}
+				return
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return dataChan, errChan
+}
+
+func (s *prefetchedStream) Close() {
+	close(s.done)
+	s.attempt.stream.Close()
+}
+
+// shouldHedge 只对没有指定渠道的聊天请求对冲
+func shouldHedge(c *gin.Context) bool {
+	if !config.HedgeEnabled || config.HedgeDelay <= 0 {
+		return false
+	}
+	return c.GetInt("specific_channel_id") == 0 || c.GetBool("specific_channel_id_ignore")
+}
+
+// startHedge 为对冲请求选择另一个渠道，渠道都满载时不排队，直接放弃对冲
+func startHedge(c *gin.Context, attempts []*hedgeAttempt, promptTokens int) *hedgeAttempt {
+	excludeIds := make([]int, 0, len(attempts))
+	for _, attempt := range attempts {
+		excludeIds = append(excludeIds, attempt.channel().Id)
+	}
+
+	provider, modelName, permit := acquireChatProvider(c, excludeIds)
+	if provider == nil {
+		return nil
+	}
+	provider.SetUsage(&types.Usage{PromptTokens: promptTokens})
+
+	model.ChannelGroup.StartRequest(provider.GetChannel().Id)
+	logger.LogWarn(c.Request.Context(), fmt.Sprintf("channel #%d has no response after %dms, hedging with channel #%d", attempts[0].channel().Id, config.HedgeDelay, provider.GetChannel().Id))
+	return newHedgeAttempt(c.Request.Context(), provider, modelName, permit)
+}
+
+// sendHedged 先请求当前渠道，超过 HedgeDelay 没有首字时再请求另一个渠道，使用先返回的结果并取消另一个
+func (r *relayChat) sendHedged(chatProvider providersBase.ChatInterface) (err *types.OpenAIErrorWithStatusCode, done bool) {
+	c := r.c
+	permit, _ := utils.GetGinValue[*model.ChannelPermit](c, "channel_permit")
+	primary := newHedgeAttempt(c.Request.Context(), chatProvider, r.modelName, permit)
+	// 主请求的开始时间与渠道统计保持一致
+	if startAt, ok := utils.GetGinValue[time.Time](c, "channel_request_start"); ok && !startAt.IsZero() {
+		primary.startAt = startAt
+	}
+
+	results := make(chan *hedgeAttempt, 2)
+	attempts := []*hedgeAttempt{primary}
+	go primary.run(&r.chatRequest, results)
+
+	timer := time.NewTimer(time.Duration(config.HedgeDelay) * time.Millisecond)
+	defer timer.Stop()
+
+	var winner *hedgeAttempt
+	pending := 1
+	for winner == nil && pending > 0 {
+		select {
+		case attempt := <-results:
+			pending--
+			if attempt.err == nil {
+				winner = attempt
+				winner.result = "won"
+				continue
+			}
+			attempt.result = "failed"
+			// 主请求失败时由调用方统计，对冲的渠道在这里统计
+			if attempt != primary {
+				r.failHedge(attempt)
+			}
+		case <-timer.C:
+			if secondary := startHedge(c, attempts, chatProvider.GetUsage().PromptTokens); secondary != nil {
+				attempts = append(attempts, secondary)
+				pending++
+				go secondary.run(&r.chatRequest, results)
+			}
+		}
+	}
+
+	if len(attempts) > 1 {
+		defer func() {
+			logs := make([]map[string]any, 0, len(attempts))
+			for _, attempt := range attempts {
+				logs = append(logs, attempt.log())
+			}
+			c.Set("hedge_attempts", logs)
+		}()
+	}
+
+	if winner == nil {
+		primary.cancel()
+		return primary.err, false
+	}
+
+	for _, attempt := range attempts {
+		if attempt != winner && attempt.result == "" {
+			attempt.result = "canceled"
+			attempt.abandon(results)
+		}
+	}
+	if winner != primary {
+		r.switchToHedge(primary, winner)
+	}
+	defer winner.cancel()
+
+	if winner.stream != nil {
+		c.Set("channel_first_response", winner.firstAt)
+		stream := &prefetchedStream{attempt: winner, done: make(chan struct{})}
+		err = responseStreamClient(c, stream, r.cache, func() string {
+			return r.getUsageResponse()
+		})
+	} else {
+		err = responseJsonClient(c, winner.response)
+		if err == nil && winner.response.GetContent() != "" {
+			r.cache.SetResponse(winner.response)
+		}
+	}
+
+	if err != nil {
+		done = true
+	}
+	return
+}
+
+// failHedge 对冲的渠道出错时计入渠道统计，并在重试时跳过
+func (r *relayChat) failHedge(attempt *hedgeAttempt) {
+	channel := attempt.channel()
+	attempt.permit.Release()
+	model.ChannelGroup.FinishRequest(channel.Id)
+	if isChannelFailure(attempt.err) {
+		model.ChannelGroup.RecordResult(channel.Id, r.c.GetString("original_model"), false)
+	}
+	go processChannelRelayError(r.c.Request.Context(), channel, attempt.err)
+
+	skipChannelIds, _ := utils.GetGinValue[[]int](r.c, "skip_channel_ids")
+	r.c.Set("skip_channel_ids", append(skipChannelIds, channel.Id))
+}
+
+// switchToHedge 对冲的渠道胜出后，后续的计费、统计和名额归还都使用该渠道
+func (r *relayChat) switchToHedge(primary, winner *hedgeAttempt) {
+	// 主请求被取消时已经在 abandon 中归还名额
+	if primary.result == "failed" {
+		r.failHedge(primary)
+	}
+
+	channel := winner.channel()
+	r.c.Set("channel_id", channel.Id)
+	r.c.Set("channel_type", channel.Type)
+	r.c.Set("channel_key_id", channel.KeyId)
+	r.c.Set("channel_permit", winner.permit)
+	r.c.Set("channel_request_start", winner.startAt)
+	r.provider = winner.provider
+	r.modelName = winner.modelName
+	r.chatRequest.Model = winner.modelName
+}
@@ -28,11 +28,21 @@ func Relay(c *gin.Context) {
 		return
 	}
 
+	if validator, ok := relay.(requestValidator); ok {
+		if apiErr := validator.validateRequest(); apiErr != nil {
+			relayResponseWithErr(c, apiErr)
+			return
+		}
+	}
+
 	cacheProps := relay.GetChatCache()
 	cacheProps.SetHash(relay.getRequest())
 
 	// 获取缓存
 	cache := cacheProps.GetCache()
+	if embeddings, ok := relay.(*relayEmbeddings); ok && cache == nil {
+		cache = embeddings.getFullCache()
+	}
 
 	if cache != nil {
 		// 说明有缓存， 直接返回缓存内容
@@ -40,30 +50,88 @@ func Relay(c *gin.Context) {
 		return
 	}
 
-	if err := relay.setProvider(relay.getOriginalModel()); err != nil {
-		common.AbortWithMessage(c, http.StatusServiceUnavailable, err.Error())
-		return
+	models := relayModels(c, relay.getOriginalModel())
+	initialSkipIds, _ := utils.GetGinValue[[]int](c, "skip_channel_ids")
+
+	var apiErr *types.OpenAIErrorWithStatusCode
+	var fail error
+	for i, modelName := range models {
+		if i > 0 {
+			logger.LogWarn(c.Request.Context(), fmt.Sprintf("model %s is unavailable, fallback to %s", models[i-1], modelName))
+			// 换模型后之前跳过的渠道可以重新使用
+			c.Set("skip_channel_ids", append([]int{}, initialSkipIds...))
+			c.Set("model_fallback_path", models[:i+1])
+		}
+		if len(models) > 1 {
+			c.Header("X-Served-Model", modelName)
+		}
+
+		modelErr, modelFail, fallback := relayModel(c, relay, modelName)
+		if modelErr == nil && modelFail == nil {
+			return
+		}
+		// 降级模型没有可用渠道时，返回之前模型的上游错误
+		if modelErr != nil {
+			apiErr = modelErr
+		} else if apiErr == nil {
+			fail = modelFail
+		}
+		if !fallback {
+			break
+		}
+	}
+
+	if apiErr != nil {
+		if apiErr.StatusCode == http.StatusTooManyRequests {
+			apiErr.OpenAIError.Message = "当前分组上游负载已饱和，请稍后再试"
+		}
+		relayResponseWithErr(c, apiErr)
+	} else if fail != nil {
+		c.Writer.Header().Del("X-Served-Model")
+		common.AbortWithMessage(c, http.StatusServiceUnavailable, fail.Error())
+	}
+}
+
+// relayModels 请求的模型及其降级链，指定渠道时不降级
+func relayModels(c *gin.Context, modelName string) []string {
+	models := []string{modelName}
+	if c.GetInt("specific_channel_id") > 0 && !c.GetBool("specific_channel_id_ignore") {
+		return models
+	}
+
+	tokenFallbacks, err := common.ParseModelFallbacks(c.GetString("token_model_fallbacks"))
+	if err != nil {
+		logger.LogError(c.Request.Context(), "invalid token model fallbacks: "+err.Error())
+	}
+	return append(models, common.GetModelFallbacks(modelName, tokenFallbacks)...)
+}
+
+// relayModel 使用指定的模型请求并在渠道间重试，fallback 表示该模型的渠道都已失败或不可用，可以改用降级模型
+func relayModel(c *gin.Context, relay RelayBaseInterface, modelName string) (apiErr *types.OpenAIErrorWithStatusCode, fail error, fallback bool) {
+	if fail = relay.setProvider(modelName); fail != nil {
+		return nil, fail, true
 	}
 
 	apiErr, done := RelayHandler(relay)
 	if apiErr == nil {
+		recordChannelResult(c, nil)
 		metrics.RecordProvider(c, 200)
 		return
 	}
 
 	channel := relay.getProvider().GetChannel()
-	go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr, channel.Type)
+	recordChannelResult(c, apiErr)
+	go processChannelRelayError(c.Request.Context(), channel, apiErr)
 
-	retryTimes := config.RetryTimes
 	if done || !shouldRetry(c, apiErr, channel.Type) {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("relay error happen, status code is %d, won't retry in this case", apiErr.StatusCode))
-		retryTimes = 0
+		return
 	}
 
-	for i := retryTimes; i > 0; i-- {
+	for i := config.RetryTimes; i > 0; i-- {
 		// 冻结通道
-		shouldCooldowns(c, apiErr, channel.Id)
-		if err := relay.setProvider(relay.getOriginalModel()); err != nil {
+		shouldCooldowns(c, apiErr, channel)
+		if err := relay.setProvider(modelName); err != nil {
 			continue
 		}
 
@@ -71,23 +139,21 @@ func Relay(c *gin.Context) {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("using channel #%d(%s) to retry (remain times %d)", channel.Id, channel.Name, i))
 		apiErr, done = RelayHandler(relay)
 		if apiErr == nil {
+			recordChannelResult(c, nil)
 			return
 		}
-		go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr, channel.Type)
+		recordChannelResult(c, apiErr)
+		go processChannelRelayError(c.Request.Context(), channel, apiErr)
 		if done || !shouldRetry(c, apiErr, channel.Type) {
-			break
+			return
 		}
 	}
 
-	if apiErr != nil {
-		if apiErr.StatusCode == http.StatusTooManyRequests {
-			apiErr.OpenAIError.Message = "当前分组上游负载已饱和，请稍后再试"
-		}
-		relayResponseWithErr(c, apiErr)
-	}
+	return apiErr, nil, true
 }
 
 func RelayHandler(relay RelayBaseInterface) (err *types.OpenAIErrorWithStatusCode, done bool) {
+	startChannelRequest(relay.getContext())
 	promptTokens, tonkeErr := relay.getPromptTokens()
 	if tonkeErr != nil {
 		err = common.ErrorWrapperLocal(tonkeErr, "token_error", http.StatusBadRequest)
@@ -114,6 +180,9 @@ func RelayHandler(relay RelayBaseInterface) (err *types.OpenAIErrorWithStatusCod
 		return
 	}
 
+	// 对冲请求可能由另一个渠道完成，按实际完成的渠道计费
+	usage = relay.getProvider().GetUsage()
+	quota.SetChannel(relay.getContext().GetInt("channel_id"), relay.getContext().GetInt("channel_key_id"), relay.getModelName())
 	quota.Consume(relay.getContext(), usage, relay.IsStream())
 	if usage.CompletionTokens > 0 {
 		cacheProps := relay.GetChatCache()
@@ -138,13 +207,46 @@ func cacheProcessing(c *gin.Context, cacheProps *relay_util.ChatCacheProps, isSt
 		}
 	}
 
-	model.RecordConsumeLog(c.Request.Context(), cacheProps.UserId, cacheProps.ChannelID, cacheProps.PromptTokens, cacheProps.CompletionTokens, cacheProps.ModelName, tokenName, 0, "缓存", requestTime, isStream, nil)
+	content := "缓存"
+	var metadata map[string]any
+	if cacheProps.Semantic {
+		content = "语义缓存"
+		metadata = map[string]any{
+			"cache":      "semantic",
+			"similarity": cacheProps.Similarity,
+		}
+	} else if cacheProps.ItemCache != nil {
+		metadata = map[string]any{
+			"cache":      "item",
+			"item_cache": cacheProps.ItemCache,
+		}
+	}
+
+	model.RecordConsumeLog(c.Request.Context(), cacheProps.UserId, cacheProps.ChannelID, cacheProps.PromptTokens, cacheProps.CompletionTokens, cacheProps.ModelName, tokenName, 0, content, requestTime, isStream, metadata)
 }
 
-func shouldCooldowns(c *gin.Context, apiErr *types.OpenAIErrorWithStatusCode, channelId int) {
-	// 如果是频率限制，冻结通道
-	if apiErr.StatusCode == http.StatusTooManyRequests {
-		model.ChannelGroup.Cooldowns(channelId)
+func shouldCooldowns(c *gin.Context, apiErr *types.OpenAIErrorWithStatusCode, channel *model.Channel) {
+	_, rule := common.MatchRetryRule(channel.Type, apiErr)
+	if rule != nil && rule.Action != common.RetryActionCooldown {
+		rule = nil
+	}
+	_, disableRule := common.MatchDisableRule(channel.Type, apiErr)
+
+	// 使用密钥池的渠道只冻结出错的密钥，渠道还有可用的密钥时可以用其他密钥重试
+	if channel.KeyId > 0 && (rule != nil || disableRule != nil) {
+		cooldownSeconds := 0
+		if rule != nil {
+			cooldownSeconds = rule.CooldownSeconds
+		} else {
+			cooldownSeconds = disableRule.CooldownSeconds
+		}
+		model.ChannelKeys.Cooldowns(channel.KeyId, cooldownSeconds)
+		if model.ChannelKeys.Available(channel.Id) {
+			return
+		}
+	} else if rule != nil {
+		// 匹配冷却规则时冻结通道
+		model.ChannelGroup.Cooldowns(channel.Id, rule.CooldownSeconds)
 	}
 
 	skipChannelIds, ok := utils.GetGinValue[[]int](c, "skip_channel_ids")
@@ -152,7 +254,7 @@ func shouldCooldowns(c *gin.Context, apiErr *types.OpenAIErrorWithStatusCode, ch
 		skipChannelIds = make([]int, 0)
 	}
 
-	skipChannelIds = append(skipChannelIds, channelId)
+	skipChannelIds = append(skipChannelIds, channel.Id)
 
 	c.Set("skip_channel_ids", skipChannelIds)
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"one-api/common"
+	"one-api/common/utils"
 	"one-api/model"
 	"one-api/relay/relay_util"
 	"one-api/types"
@@ -43,6 +44,10 @@ type OpenAIModels struct {
 	Root       *string                  `json:"root"`
 	Parent     *string                  `json:"parent"`
 	Price      *ModelPrice              `json:"price"`
+
+	ContextLength   int      `json:"context_length,omitempty"`
+	MaxOutputTokens int      `json:"max_output_tokens,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
 }
 
 func ListModels(c *gin.Context) {
@@ -72,6 +77,13 @@ func ListModels(c *gin.Context) {
 		groupOpenAIModels = append(groupOpenAIModels, getOpenAIModelWithName(modelName))
 	}
 
+	// 实际模型在分组中可用时才列出虚拟模型
+	for _, virtualModel := range model.GlobalVirtualModels.GetGroupModels(groupName) {
+		if utils.Contains(virtualModel.Model, models) {
+			groupOpenAIModels = append(groupOpenAIModels, getOpenAIVirtualModel(virtualModel))
+		}
+	}
+
 	// 根据 OwnedBy 排序
 	sort.Slice(groupOpenAIModels, func(i, j int) bool {
 		if groupOpenAIModels[i].OwnedBy == nil {
@@ -122,8 +134,13 @@ func ListModelsForAdmin(c *gin.Context) {
 
 func RetrieveModel(c *gin.Context) {
 	modelName := c.Param("model")
+	if virtualModel := model.GlobalVirtualModels.Get(modelName, c.GetString("token_group")); virtualModel != nil {
+		c.JSON(200, getOpenAIVirtualModel(virtualModel))
+		return
+	}
+
 	openaiModel := getOpenAIModelWithName(modelName)
-	if *openaiModel.OwnedBy != relay_util.UnknownOwnedBy {
+	if *openaiModel.OwnedBy != relay_util.UnknownOwnedBy || relay_util.ModelInfoInstance.GetModelInfo(modelName) != nil {
 		c.JSON(200, openaiModel)
 	} else {
 		openAIError := types.OpenAIError{
@@ -147,9 +164,9 @@ func getModelOwnedBy(channelType int) (ownedBy *string) {
 }
 
 func getOpenAIModelWithName(modelName string) *OpenAIModels {
-	price := relay_util.PricingInstance.GetPrice(modelName)
+	price := relay_util.PricingInstance.GetPrice(modelName, "")
 
-	return &OpenAIModels{
+	openAIModel := &OpenAIModels{
 		Id:         modelName,
 		Object:     "model",
 		Created:    1677649963,
@@ -158,6 +175,27 @@ func getOpenAIModelWithName(modelName string) *OpenAIModels {
 		Root:       nil,
 		Parent:     nil,
 	}
+	setModelInfo(openAIModel, modelName)
+
+	return openAIModel
+}
+
+// getOpenAIVirtualModel 虚拟模型不返回实际模型，没有单独设置价格时使用实际模型的归属
+func getOpenAIVirtualModel(virtualModel *model.VirtualModel) *OpenAIModels {
+	price, ok := relay_util.PricingInstance.LookupPrice(virtualModel.Name)
+	if !ok {
+		price = relay_util.PricingInstance.GetPrice(virtualModel.Model, "")
+	}
+
+	openAIModel := &OpenAIModels{
+		Id:      virtualModel.Name,
+		Object:  "model",
+		Created: int(virtualModel.CreatedAt),
+		OwnedBy: getModelOwnedBy(price.ChannelType),
+	}
+	setModelInfo(openAIModel, virtualModel.Model)
+
+	return openAIModel
 }
 
 func GetModelOwnedBy(c *gin.Context) {
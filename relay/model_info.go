@@ -0,0 +1,162 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/model"
+	"one-api/relay/relay_util"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestValidator 在选择渠道前按模型元数据校验请求
+type requestValidator interface {
+	validateRequest() *types.OpenAIErrorWithStatusCode
+}
+
+// chatCapabilities 返回聊天请求需要的模型能力
+func chatCapabilities(request *types.ChatCompletionRequest) []string {
+	capabilities := make([]string, 0)
+	vision, audio := false, false
+	for _, message := range request.Messages {
+		parts, ok := message.Content.([]any)
+		if !ok {
+			continue
+		}
+		for _, part := range parts {
+			if partMap, ok := part.(map[string]any); ok {
+				switch partMap["type"] {
+				case types.ContentTypeImageURL:
+					vision = true
+				case "input_audio":
+					audio = true
+				}
+			}
+		}
+	}
+	for _, modality := range request.Modalities {
+		if modality == "audio" {
+			audio = true
+		}
+	}
+
+	if vision {
+		capabilities = append(capabilities, model.ModelCapabilityVision)
+	}
+	if len(request.Tools) > 0 || len(request.Functions) > 0 {
+		capabilities = append(capabilities, model.ModelCapabilityTools)
+	}
+	if audio {
+		capabilities = append(capabilities, model.ModelCapabilityAudio)
+	}
+	if request.ResponseFormat != nil && (request.ResponseFormat.Type == "json_object" || request.ResponseFormat.Type == "json_schema") {
+		capabilities = append(capabilities, model.ModelCapabilityJSONMode)
+	}
+
+	return capabilities
+}
+
+// validateRequest 按模型元数据检查能力、输出长度和上下文长度，没有元数据的模型不检查
+func (r *relayChat) validateRequest() *types.OpenAIErrorWithStatusCode {
+	capabilities := chatCapabilities(&r.chatRequest)
+	if len(capabilities) > 0 {
+		r.c.Set("required_capabilities", capabilities)
+	}
+
+	info := relay_util.ModelInfoInstance.GetModelInfo(r.originalModel)
+	if info == nil {
+		return nil
+	}
+
+	for _, capability := range capabilities {
+		if !info.Supports([]string{capability}) {
+			return invalidRequestError(fmt.Sprintf("The model `%s` does not support %s.", r.originalModel, capability), capabilityParams[capability], "model_not_supported")
+		}
+	}
+
+	maxTokens, param := r.chatRequest.MaxTokens, "max_tokens"
+	if r.chatRequest.MaxCompletionTokens > 0 {
+		maxTokens, param = r.chatRequest.MaxCompletionTokens, "max_completion_tokens"
+	}
+	if info.MaxOutputTokens > 0 && maxTokens > info.MaxOutputTokens {
+		return invalidRequestError(fmt.Sprintf("%s is too large: %d. This model supports at most %d completion tokens, whereas you provided %d.", param, maxTokens, info.MaxOutputTokens, maxTokens), param, "invalid_value")
+	}
+
+	if info.ContextLength > 0 {
+		promptTokens := common.CountTokenMessages(r.chatRequest.Messages, r.originalModel, config.PreCostNotImage)
+		if promptTokens+maxTokens > info.ContextLength {
+			return invalidRequestError(fmt.Sprintf("This model's maximum context length is %d tokens. However, you requested %d tokens (%d in the messages, %d in the completion). Please reduce the length of the messages or completion.", info.ContextLength, promptTokens+maxTokens, promptTokens, maxTokens), "messages", "context_length_exceeded")
+		}
+	}
+
+	return nil
+}
+
+var capabilityParams = map[string]string{
+	model.ModelCapabilityVision:   "messages",
+	model.ModelCapabilityTools:    "tools",
+	model.ModelCapabilityAudio:    "modalities",
+	model.ModelCapabilityJSONMode: "response_format",
+}
+
+func invalidRequestError(message, param, code string) *types.OpenAIErrorWithStatusCode {
+	return &types.OpenAIErrorWithStatusCode{
+		OpenAIError: types.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Param:   param,
+			Code:    code,
+		},
+		StatusCode: http.StatusBadRequest,
+		LocalError: true,
+	}
+}
+
+// filterModelCapabilities 跳过映射后的模型缺少所需能力的渠道
+func filterModelCapabilities(modelName string, capabilities []string) model.ChannelsFilterFunc {
+	return func(_ int, choice *model.ChannelChoice) bool {
+		info := relay_util.ModelInfoInstance.GetModelInfo(mappedModelName(choice.Channel, modelName))
+		return info != nil && !info.Supports(capabilities)
+	}
+}
+
+func capabilityFilters(c *gin.Context, modelName string) []model.ChannelsFilterFunc {
+	capabilities := c.GetStringSlice("required_capabilities")
+	if len(capabilities) == 0 {
+		return nil
+	}
+	return []model.ChannelsFilterFunc{filterModelCapabilities(modelName, capabilities)}
+}
+
+// mappedModelName 与 ModelMappingHandler 相同，返回渠道实际请求的模型
+func mappedModelName(channel *model.Channel, modelName string) string {
+	modelMapping := channel.GetModelMapping()
+	if modelMapping == "" || modelMapping == "{}" {
+		return modelName
+	}
+
+	modelMap := make(map[string]string)
+	if err := json.Unmarshal([]byte(modelMapping), &modelMap); err != nil {
+		return modelName
+	}
+	if modelMap[modelName] != "" {
+		return modelMap[modelName]
+	}
+	return modelName
+}
+
+// setModelInfo 在模型列表中返回模型的元数据
+func setModelInfo(openAIModel *OpenAIModels, modelName string) {
+	info := relay_util.ModelInfoInstance.GetModelInfo(modelName)
+	if info == nil {
+		return
+	}
+
+	openAIModel.ContextLength = info.ContextLength
+	openAIModel.MaxOutputTokens = info.MaxOutputTokens
+	openAIModel.Capabilities = info.Capabilities()
+}
@@ -6,7 +6,9 @@ import (
 	"one-api/common/config"
 	"one-api/model"
 	"one-api/providers/azure"
+	providersBase "one-api/providers/base"
 	"one-api/providers/openai"
+	"one-api/relay/relay_util"
 	"strings"
 	"time"
 
@@ -14,7 +16,14 @@ import (
 )
 
 func RelayOnly(c *gin.Context) {
-	provider, _, fail := GetProvider(c, "")
+	modelName, errWithCode := setObjectAffinity(c)
+	if errWithCode != nil {
+		relayResponseWithErr(c, errWithCode)
+		return
+	}
+
+	c.Set("channel_types", assistantsChannelTypes)
+	provider, _, fail := GetProvider(c, modelName)
 	if fail != nil {
 		common.AbortWithMessage(c, http.StatusServiceUnavailable, fail.Error())
 		return
@@ -27,19 +36,30 @@ func RelayOnly(c *gin.Context) {
 	}
 
 	// 获取请求的path
-	url := ""
 	path := c.Request.URL.Path
-	openAIProvider, ok := provider.(*openai.OpenAIProvider)
+	openAIProvider, ok := getOpenAIProvider(provider)
 	if !ok {
-		azureProvider, ok := provider.(*azure.AzureProvider)
-		if !ok {
-			common.AbortWithMessage(c, http.StatusServiceUnavailable, "provider must be of type openai")
+		common.AbortWithMessage(c, http.StatusServiceUnavailable, "provider must be of type openai")
+		return
+	}
+
+	// 请求中引用的网关文件需要先上传到当前渠道
+	requestPath, errWithCode := replaceLocalFileIds(c, openAIProvider, path)
+	if errWithCode != nil {
+		relayResponseWithErr(c, errWithCode)
+		return
+	}
+
+	// 运行会消耗 token，先预扣额度，运行结束后按实际用量计费
+	var quota *relay_util.Quota
+	if isRunRequest(c) {
+		quota = relay_util.NewQuota(c, modelName, 0)
+		if errWithCode = quota.PreQuotaConsumption(); errWithCode != nil {
+			relayResponseWithErr(c, errWithCode)
 			return
 		}
-		url = azureProvider.GetFullRequestURL(path, "")
-	} else {
-		url = openAIProvider.GetFullRequestURL(path, "")
 	}
+	url := openAIProvider.GetFullRequestURL(requestPath, "")
 
 	headers := c.Request.Header
 	mapHeaders := provider.GetRequestHeaders()
@@ -62,11 +82,18 @@ func RelayOnly(c *gin.Context) {
 
 	response, errWithCode := requester.SendRequestRaw(req)
 	if errWithCode != nil {
+		if quota != nil {
+			quota.Undo(c)
+		}
 		relayResponseWithErr(c, errWithCode)
 		return
 	}
 
-	errWithCode = responseMultipart(c, response)
+	runs, errWithCode := responseChannelObjects(c, response)
+	isStream := strings.HasPrefix(response.Header.Get("Content-Type"), "text/event-stream")
+	if billAssistantRuns(c, openAIProvider, quota, runs, isStream) {
+		return
+	}
 
 	if errWithCode != nil {
 		relayResponseWithErr(c, errWithCode)
@@ -84,3 +111,14 @@ func RelayOnly(c *gin.Context) {
 	model.RecordConsumeLog(c.Request.Context(), c.GetInt("id"), c.GetInt("channel_id"), 0, 0, "", c.GetString("token_name"), 0, "中继:"+path, requestTime, false, nil)
 
 }
+
+// getOpenAIProvider 返回 OpenAI 或 Azure 渠道底层的 OpenAIProvider
+func getOpenAIProvider(provider providersBase.ProviderInterface) (*openai.OpenAIProvider, bool) {
+	if openAIProvider, ok := provider.(*openai.OpenAIProvider); ok {
+		return openAIProvider, true
+	}
+	if azureProvider, ok := provider.(*azure.AzureProvider); ok {
+		return &azureProvider.OpenAIProvider, true
+	}
+	return nil, false
+}
@@ -22,11 +22,22 @@ type ChatCacheProps struct {
 	Hash   string      `json:"-"`
 	Cache  bool        `json:"-"`
 	Driver CacheDriver `json:"-"`
+
+	// 通过语义相似度命中的缓存
+	Semantic   bool    `json:"-"`
+	Similarity float64 `json:"-"`
+
+	// 按输入项命中的缓存
+	ItemCache map[string]any `json:"-"`
+
+	semantic *semanticCache
 }
 
 type CacheDriver interface {
 	Get(hash string, userId int) *ChatCacheProps
 	Set(hash string, props *ChatCacheProps, expire int64) error
+	GetVectors(scope string, userId int) []*CacheVector
+	SetVector(scope string, userId int, vector *CacheVector, expire int64) error
 }
 
 func NewChatCacheProps(c *gin.Context, allow bool) *ChatCacheProps {
@@ -50,6 +61,7 @@ func NewChatCacheProps(c *gin.Context, allow bool) *ChatCacheProps {
 
 	props.UserId = c.GetInt("id")
 	props.TokenId = c.GetInt("token_id")
+	props.semantic = newSemanticCache(c)
 
 	return props
 }
@@ -60,6 +72,9 @@ func (p *ChatCacheProps) SetHash(request any) {
 	}
 
 	p.hash(utils.Marshal(request))
+	if p.semantic != nil {
+		p.semantic.setRequest(p.UserId, p.TokenId, request)
+	}
 }
 
 func (p *ChatCacheProps) SetResponse(response any) {
@@ -85,7 +100,7 @@ func (p *ChatCacheProps) NoCache() {
 }
 
 func (p *ChatCacheProps) StoreCache(channelId, promptTokens, completionTokens int, modelName string) error {
-	if !p.needCache() || p.Response == "" {
+	if !p.needCache() || p.Response == "" || p.getHash() == "" {
 		return nil
 	}
 
@@ -94,15 +109,67 @@ func (p *ChatCacheProps) StoreCache(channelId, promptTokens, completionTokens in
 	p.CompletionTokens = completionTokens
 	p.ModelName = modelName
 
-	return p.Driver.Set(p.getHash(), p, int64(config.ChatCacheExpireMinute))
+	expire := int64(config.ChatCacheExpireMinute)
+	if err := p.Driver.Set(p.getHash(), p, expire); err != nil {
+		return err
+	}
+
+	if p.semantic != nil {
+		return p.semantic.store(p.Driver, p.getHash(), p.UserId, expire)
+	}
+
+	return nil
 }
 
 func (p *ChatCacheProps) GetCache() *ChatCacheProps {
+	if !p.needCache() || p.getHash() == "" {
+		return nil
+	}
+
+	if cache := p.Driver.Get(p.getHash(), p.UserId); cache != nil {
+		return cache
+	}
+
+	if p.semantic != nil {
+		return p.semantic.get(p.Driver, p.UserId)
+	}
+
+	return nil
+}
+
+// GetItemCache 按内容获取单个输入项的缓存，用于可以拆分的请求（如 embeddings）
+func (p *ChatCacheProps) GetItemCache(content string) *ChatCacheProps {
 	if !p.needCache() {
 		return nil
 	}
 
-	return p.Driver.Get(p.getHash(), p.UserId)
+	return p.Driver.Get(p.itemHash(content), p.UserId)
+}
+
+func (p *ChatCacheProps) StoreItemCache(content string, channelId int, modelName string, response any) error {
+	if !p.needCache() {
+		return nil
+	}
+
+	responseStr := utils.Marshal(response)
+	if responseStr == "" {
+		return nil
+	}
+
+	item := &ChatCacheProps{
+		UserId:    p.UserId,
+		TokenId:   p.TokenId,
+		ChannelID: channelId,
+		ModelName: modelName,
+		Response:  responseStr,
+		Cache:     true,
+	}
+
+	return p.Driver.Set(p.itemHash(content), item, int64(config.ChatCacheExpireMinute))
+}
+
+func (p *ChatCacheProps) Enabled() bool {
+	return p.needCache()
 }
 
 func (p *ChatCacheProps) needCache() bool {
@@ -113,6 +180,12 @@ func (p *ChatCacheProps) getHash() string {
 	return p.Hash
 }
 
+// 输入项的缓存在同一用户的所有令牌之间共享
+func (p *ChatCacheProps) itemHash(content string) string {
+	hash := md5.Sum([]byte(fmt.Sprintf("%d-item-%s", p.UserId, content)))
+	return hex.EncodeToString(hash[:])
+}
+
 func (p *ChatCacheProps) hash(request string) {
 	hash := md5.Sum([]byte(fmt.Sprintf("%d-%d-%s", p.UserId, p.TokenId, request)))
 	p.Hash = hex.EncodeToString(hash[:])
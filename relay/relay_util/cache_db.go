@@ -27,6 +27,41 @@ func (db *ChatCacheDB) Set(hash string, props *ChatCacheProps, expire int64) err
 	return SetCacheDB(hash, props, expire)
 }
 
+func (db *ChatCacheDB) GetVectors(scope string, userId int) []*CacheVector {
+	vectors, err := model.GetChatCacheVectors(scope, userId)
+	if err != nil {
+		return nil
+	}
+
+	cacheVectors := make([]*CacheVector, 0, len(vectors))
+	for _, vector := range vectors {
+		values, err := utils.UnmarshalString[[]float64](vector.Vector)
+		if err != nil {
+			continue
+		}
+		cacheVectors = append(cacheVectors, &CacheVector{Hash: vector.Hash, Vector: values})
+	}
+
+	return cacheVectors
+}
+
+func (db *ChatCacheDB) SetVector(scope string, userId int, vector *CacheVector, expire int64) error {
+	data := utils.Marshal(vector.Vector)
+	if data == "" {
+		return errors.New("marshal error")
+	}
+
+	cacheVector := &model.ChatCacheVector{
+		Scope:      scope,
+		UserId:     userId,
+		Hash:       vector.Hash,
+		Vector:     data,
+		Expiration: time.Now().Unix() + expire*60,
+	}
+
+	return cacheVector.Insert()
+}
+
 func SetCacheDB(hash string, props *ChatCacheProps, expire int64) error {
 	data := utils.Marshal(props)
 	if data == "" {
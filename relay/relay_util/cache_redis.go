@@ -40,6 +40,38 @@ func (r *ChatCacheRedis) Set(hash string, props *ChatCacheProps, expire int64) e
 	return redis.RedisSet(r.getKey(hash, props.UserId), data, time.Duration(expire)*time.Minute)
 }
 
+// 同一个 scope 的向量保存在一个列表中，列表随最后一次写入一起过期
+func (r *ChatCacheRedis) GetVectors(scope string, userId int) []*CacheVector {
+	values, err := redis.RedisListRange(r.getVectorKey(scope, userId))
+	if err != nil {
+		return nil
+	}
+
+	vectors := make([]*CacheVector, 0, len(values))
+	for _, value := range values {
+		vector, err := utils.UnmarshalString[CacheVector](value)
+		if err != nil {
+			continue
+		}
+		vectors = append(vectors, &vector)
+	}
+
+	return vectors
+}
+
+func (r *ChatCacheRedis) SetVector(scope string, userId int, vector *CacheVector, expire int64) error {
+	data := utils.Marshal(vector)
+	if data == "" {
+		return errors.New("marshal error")
+	}
+
+	return redis.RedisListPush(r.getVectorKey(scope, userId), data, time.Duration(expire)*time.Minute)
+}
+
+func (r *ChatCacheRedis) getVectorKey(scope string, userId int) string {
+	return fmt.Sprintf("%s_vector:%d:%s", chatCacheKey, userId, scope)
+}
+
 func (r *ChatCacheRedis) getKey(hash string, userId int) string {
 	return fmt.Sprintf("%s:%d:%s", chatCacheKey, userId, hash)
 }
@@ -0,0 +1,202 @@
+package relay_util
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CacheVector struct {
+	Hash   string    `json:"hash"`
+	Vector []float64 `json:"vector"`
+}
+
+type semanticCache struct {
+	c         *gin.Context
+	threshold float64
+	// 去掉最后一条用户消息后的请求哈希，只有 scope 相同的缓存才比较相似度
+	scope  string
+	text   string
+	vector []float64
+}
+
+func newSemanticCache(c *gin.Context) *semanticCache {
+	if !config.ChatCacheSemanticEnabled || config.ChatCacheSemanticChannelId == 0 {
+		return nil
+	}
+
+	// 阈值优先级：令牌 > 分组 > 全局
+	threshold := c.GetFloat64("chat_cache_threshold")
+	if threshold <= 0 {
+		if group := model.GlobalUserGroupRatio.GetBySymbol(c.GetString("token_group")); group != nil {
+			threshold = group.ChatCacheThreshold
+		}
+	}
+	if threshold <= 0 {
+		threshold = config.ChatCacheSemanticThreshold
+	}
+
+	return &semanticCache{
+		c:         c,
+		threshold: threshold,
+	}
+}
+
+// setRequest 目前只有对话请求支持语义缓存，且最后一条用户消息需要是纯文本
+func (s *semanticCache) setRequest(userId, tokenId int, request any) {
+	chatRequest, ok := request.(*types.ChatCompletionRequest)
+	if !ok {
+		return
+	}
+
+	index := -1
+	for i := len(chatRequest.Messages) - 1; i >= 0; i-- {
+		if chatRequest.Messages[i].Role == types.ChatMessageRoleUser {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return
+	}
+
+	text, ok := chatRequest.Messages[index].Content.(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return
+	}
+
+	scopeRequest := *chatRequest
+	scopeRequest.Messages = slices.Clone(chatRequest.Messages)
+	scopeRequest.Messages[index].Content = nil
+
+	hash := md5.Sum([]byte(fmt.Sprintf("%d-%d-%s", userId, tokenId, utils.Marshal(scopeRequest))))
+	s.scope = hex.EncodeToString(hash[:])
+	s.text = text
+}
+
+// get 生成最后一条用户消息的向量，返回相似度最高且达到阈值的缓存
+func (s *semanticCache) get(driver CacheDriver, userId int) *ChatCacheProps {
+	if s.scope == "" {
+		return nil
+	}
+
+	// 没有可比较的缓存时也需要生成向量，用于保存本次请求的缓存
+	vector, err := s.embedding()
+	if err != nil {
+		logger.LogError(s.c.Request.Context(), "semantic cache embedding failed: "+err.Error())
+		s.scope = ""
+		return nil
+	}
+	s.vector = vector
+
+	var best *CacheVector
+	bestSimilarity := 0.0
+	for _, cacheVector := range driver.GetVectors(s.scope, userId) {
+		similarity := cosineSimilarity(vector, cacheVector.Vector)
+		if similarity >= s.threshold && similarity > bestSimilarity {
+			best = cacheVector
+			bestSimilarity = similarity
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+
+	props := driver.Get(best.Hash, userId)
+	if props == nil {
+		return nil
+	}
+
+	props.Semantic = true
+	props.Similarity = bestSimilarity
+
+	return props
+}
+
+func (s *semanticCache) store(driver CacheDriver, hash string, userId int, expire int64) error {
+	if s.scope == "" || len(s.vector) == 0 {
+		return nil
+	}
+
+	return driver.SetVector(s.scope, userId, &CacheVector{Hash: hash, Vector: s.vector}, expire)
+}
+
+func (s *semanticCache) embedding() ([]float64, error) {
+	channel := model.ChannelGroup.GetChannel(config.ChatCacheSemanticChannelId)
+	if channel == nil {
+		return nil, errors.New("embedding channel not found")
+	}
+
+	provider := providers.GetProvider(channel, s.c)
+	embeddingsProvider, ok := provider.(providersBase.EmbeddingsInterface)
+	if !ok {
+		return nil, errors.New("channel does not support embeddings")
+	}
+
+	modelName, err := provider.ModelMappingHandler(config.ChatCacheSemanticModel)
+	if err != nil {
+		return nil, err
+	}
+
+	provider.SetUsage(&types.Usage{})
+	response, errWithCode := embeddingsProvider.CreateEmbeddings(&types.EmbeddingRequest{
+		Model: modelName,
+		Input: s.text,
+	})
+	if errWithCode != nil {
+		return nil, errors.New(errWithCode.Message)
+	}
+
+	if len(response.Data) == 0 {
+		return nil, errors.New("empty embedding")
+	}
+
+	values, ok := response.Data[0].Embedding.([]any)
+	if !ok {
+		return nil, errors.New("invalid embedding")
+	}
+
+	vector := make([]float64, 0, len(values))
+	for _, value := range values {
+		number, ok := value.(float64)
+		if !ok {
+			return nil, errors.New("invalid embedding")
+		}
+		vector = append(vector, number)
+	}
+
+	return vector, nil
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,195 @@
+package relay_util
+
+import (
+	"errors"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// ModelInfoInstance is the ModelInfos instance
+var ModelInfoInstance *ModelInfos
+
+// ModelInfos 模型元数据，与价格一样支持 * 结尾的模糊匹配
+type ModelInfos struct {
+	sync.RWMutex
+	Infos map[string]*model.ModelInfo
+	Match []string
+}
+
+func NewModelInfos() {
+	logger.SysLog("Initializing model infos")
+
+	ModelInfoInstance = &ModelInfos{
+		Infos: make(map[string]*model.ModelInfo),
+		Match: make([]string, 0),
+	}
+
+	if err := ModelInfoInstance.Init(); err != nil {
+		logger.SysError("Failed to initialize model infos:" + err.Error())
+		return
+	}
+
+	if viper.GetBool("auto_price_updates") || len(ModelInfoInstance.Infos) == 0 {
+		ModelInfoInstance.SyncModelInfos(model.GetDefaultModelInfos(), false)
+	}
+}
+
+func (m *ModelInfos) Init() error {
+	infos, err := model.GetAllModelInfos()
+	if err != nil {
+		return err
+	}
+
+	newInfos := make(map[string]*model.ModelInfo, len(infos))
+	newMatch := make([]string, 0)
+	for _, info := range infos {
+		newInfos[info.Model] = info
+		if strings.HasSuffix(info.Model, "*") {
+			newMatch = append(newMatch, info.Model)
+		}
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.Infos = newInfos
+	m.Match = newMatch
+
+	return nil
+}
+
+// GetModelInfo 返回模型的元数据，没有设置时返回 nil
+func (m *ModelInfos) GetModelInfo(modelName string) *model.ModelInfo {
+	m.RLock()
+	defer m.RUnlock()
+
+	if info, ok := m.Infos[modelName]; ok {
+		return info
+	}
+
+	matchModel := utils.GetModelsWithMatch(&m.Match, modelName)
+	if info, ok := m.Infos[matchModel]; ok {
+		return info
+	}
+
+	return nil
+}
+
+func (m *ModelInfos) GetAllModelInfosList() []*model.ModelInfo {
+	m.RLock()
+	defer m.RUnlock()
+
+	infos := make([]*model.ModelInfo, 0, len(m.Infos))
+	for _, info := range m.Infos {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Model < infos[j].Model
+	})
+
+	return infos
+}
+
+func (m *ModelInfos) AddModelInfo(info *model.ModelInfo) error {
+	if m.hasModel(info.Model) {
+		return errors.New("model already exists")
+	}
+
+	if err := info.Insert(); err != nil {
+		return err
+	}
+
+	return m.Init()
+}
+
+func (m *ModelInfos) UpdateModelInfo(modelName string, info *model.ModelInfo) error {
+	if !m.hasModel(modelName) {
+		return errors.New("model not found")
+	}
+	if modelName != info.Model && m.hasModel(info.Model) {
+		return errors.New("model names cannot be duplicated")
+	}
+
+	tx := model.DB.Begin()
+	if err := tx.Where("model = ?", modelName).Delete(&model.ModelInfo{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Create(info).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+
+	return m.Init()
+}
+
+func (m *ModelInfos) DeleteModelInfo(modelName string) error {
+	if !m.hasModel(modelName) {
+		return errors.New("model not found")
+	}
+
+	info := &model.ModelInfo{Model: modelName}
+	if err := info.Delete(); err != nil {
+		return err
+	}
+
+	return m.Init()
+}
+
+func (m *ModelInfos) hasModel(modelName string) bool {
+	m.RLock()
+	defer m.RUnlock()
+
+	_, ok := m.Infos[modelName]
+	return ok
+}
+
+// SyncModelInfos 与 SyncPricing 相同，overwrite 为 false 时只添加不存在的模型
+func (m *ModelInfos) SyncModelInfos(infos []*model.ModelInfo, overwrite bool) error {
+	tx := model.DB.Begin()
+	if overwrite {
+		if err := model.DeleteAllModelInfos(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else {
+		newInfos := make([]*model.ModelInfo, 0, len(infos))
+		for _, info := range infos {
+			if !m.hasModel(info.Model) {
+				newInfos = append(newInfos, info)
+			}
+		}
+		infos = newInfos
+	}
+
+	if len(infos) > 0 {
+		if err := model.InsertModelInfos(tx, infos); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	tx.Commit()
+
+	return m.Init()
+}
+
+func GetModelInfosList(infoType string) []*model.ModelInfo {
+	switch infoType {
+	case "default":
+		infos := model.GetDefaultModelInfos()
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].Model < infos[j].Model
+		})
+		return infos
+	case "db":
+		return ModelInfoInstance.GetAllModelInfosList()
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package relay_util
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/utils"
@@ -91,20 +92,25 @@ func (p *Pricing) Init() error {
 	return nil
 }
 
-// GetPrice returns the price of a model
-func (p *Pricing) GetPrice(modelName string) *model.Price {
-	p.RLock()
-	defer p.RUnlock()
-
-	if price, ok := p.Prices[modelName]; ok {
-		return price
+// GetPrice returns the price of a model, with the price modifier active for the group applied
+func (p *Pricing) GetPrice(modelName, group string) *model.Price {
+	if price, ok := p.LookupPrice(modelName); ok {
+		return applyPriceModifier(price, modelName, group)
 	}
 
-	matchModel := utils.GetModelsWithMatch(&p.Match, modelName)
-	if price, ok := p.Prices[matchModel]; ok {
+	return defaultPrice()
+}
+
+// GetRawPrice returns the price of a model without price modifiers
+func (p *Pricing) GetRawPrice(modelName string) *model.Price {
+	if price, ok := p.LookupPrice(modelName); ok {
 		return price
 	}
 
+	return defaultPrice()
+}
+
+func defaultPrice() *model.Price {
 	return &model.Price{
 		Type:        model.TokensPriceType,
 		ChannelType: config.ChannelTypeUnknown,
@@ -113,6 +119,28 @@ func (p *Pricing) GetPrice(modelName string) *model.Price {
 	}
 }
 
+// LookupPrice returns the price of a model, ok is false if the model has no price
+func (p *Pricing) LookupPrice(modelName string) (price *model.Price, ok bool) {
+	p.RLock()
+	defer p.RUnlock()
+
+	if price, ok = p.Prices[modelName]; ok {
+		return
+	}
+
+	matchModel := utils.GetModelsWithMatch(&p.Match, modelName)
+	price, ok = p.Prices[matchModel]
+	return
+}
+
+// applyPriceModifier 使用当前生效的价格调整，没有时返回原价格
+func applyPriceModifier(price *model.Price, modelName, group string) *model.Price {
+	if modifier := model.GlobalPriceModifiers.Get(modelName, group); modifier != nil {
+		return modifier.Apply(price)
+	}
+	return price
+}
+
 func (p *Pricing) GetAllPrices() map[string]*model.Price {
 	return p.Prices
 }
@@ -135,6 +163,10 @@ func (p *Pricing) updateRawPrice(modelName string, price *model.Price) error {
 		return errors.New("model names cannot be duplicated")
 	}
 
+	if err := price.ValidateTiers(); err != nil {
+		return err
+	}
+
 	if err := p.deleteRawPrice(modelName); err != nil {
 		return err
 	}
@@ -159,6 +191,10 @@ func (p *Pricing) addRawPrice(price *model.Price) error {
 		return errors.New("model already exists")
 	}
 
+	if err := price.ValidateTiers(); err != nil {
+		return err
+	}
+
 	return price.Insert()
 }
 
@@ -195,6 +231,12 @@ func (p *Pricing) DeletePrice(modelName string) error {
 
 // SyncPricing syncs the pricing data
 func (p *Pricing) SyncPricing(pricing []*model.Price, overwrite bool) error {
+	for _, price := range pricing {
+		if err := price.ValidateTiers(); err != nil {
+			return fmt.Errorf("%s: %s", price.Model, err.Error())
+		}
+	}
+
 	var err error
 	if overwrite {
 		err = p.SyncPriceWithOverwrite(pricing)
@@ -277,6 +319,10 @@ func (p *Pricing) BatchDeletePrices(models []string) error {
 }
 
 func (p *Pricing) BatchSetPrices(batchPrices *BatchPrices, originalModels []string) error {
+	if err := batchPrices.Price.ValidateTiers(); err != nil {
+		return err
+	}
+
 	// 查找需要删除的model
 	var deletePrices []string
 	var addPrices []*model.Price
@@ -336,6 +382,14 @@ func GetPricesList(pricingType string) []*model.Price {
 		prices = model.GetDefaultPrice()
 	case "db":
 		prices = PricingInstance.GetAllPricesList()
+		// 展示当前生效的价格调整，不修改缓存中的价格
+		for i, price := range prices {
+			if modifiers := model.GlobalPriceModifiers.GetActive(price.Model); len(modifiers) > 0 {
+				newPrice := *price
+				newPrice.Modifiers = modifiers
+				prices[i] = &newPrice
+			}
+		}
 	case "old":
 		prices = GetOldPricesList()
 	default:
@@ -367,7 +421,7 @@ func GetOldPricesList() []*model.Price {
 
 	var prices []*model.Price
 	for modelName, oldPrice := range oldData {
-		price := PricingInstance.GetPrice(modelName)
+		price := PricingInstance.GetPrice(modelName, "")
 		prices = append(prices, &model.Price{
 			Model:       modelName,
 			Type:        model.TokensPriceType,
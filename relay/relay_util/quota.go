@@ -9,6 +9,7 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/utils"
 	"one-api/model"
 	"one-api/types"
 	"time"
@@ -18,17 +19,30 @@ import (
 
 type Quota struct {
 	modelName        string
+	virtualModel     string
 	promptTokens     int
 	price            model.Price
 	groupName        string
 	groupRatio       float64
+	ratio            float64
 	inputRatio       float64
 	outputRatio      float64
+	tier             *model.PriceTier
+	contract         *model.PriceContract
+	batchDiscount    float64
 	preConsumedQuota int
+	planQuota        int
 	cacheQuota       int
 	userId           int
 	channelId        int
+	channelKeyId     int
+	costRatio        float64
 	tokenId          int
+	rateLimitScopes  []*model.RateLimitScope
+	fallbackPath     []string
+	hedgeAttempts    []map[string]any
+	streamFailover   []map[string]any
+	itemCache        map[string]any
 	HandelStatus     bool
 }
 
@@ -38,31 +52,100 @@ func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 		promptTokens: promptTokens,
 		userId:       c.GetInt("id"),
 		channelId:    c.GetInt("channel_id"),
+		channelKeyId: c.GetInt("channel_key_id"),
 		tokenId:      c.GetInt("token_id"),
 		HandelStatus: false,
 	}
 
-	quota.price = *PricingInstance.GetPrice(quota.modelName)
+	quota.costRatio = model.ChannelGroup.GetCostRatio(quota.channelId, modelName)
 	quota.groupRatio = c.GetFloat64("group_ratio")
 	quota.groupName = c.GetString("token_group")
-	quota.inputRatio = quota.price.GetInput() * quota.groupRatio
-	quota.outputRatio = quota.price.GetOutput() * quota.groupRatio
+	quota.rateLimitScopes, _ = utils.GetGinValue[[]*model.RateLimitScope](c, "rate_limit_scopes")
+	quota.fallbackPath, _ = utils.GetGinValue[[]string](c, "model_fallback_path")
+	quota.virtualModel = c.GetString("virtual_model")
+
+	// 批处理请求按折扣计费
+	if discount, ok := utils.GetGinValue[float64](c, "batch_discount"); ok {
+		quota.batchDiscount = discount
+	}
+	quota.setPrice()
 
 	return quota
 }
 
+func (q *Quota) setPrice() {
+	ratio := q.groupRatio
+	q.contract, q.price = nil, *PricingInstance.GetPrice(q.modelName, q.groupName)
+
+	// 用户或令牌的约定价格优先，此时不再使用分组倍率和价格调整
+	if contract, matched := model.GlobalPriceContracts.Get(q.userId, q.tokenId, q.virtualModel, q.modelName); contract != nil {
+		price := PricingInstance.GetRawPrice(q.modelName)
+		if matched == q.virtualModel {
+			if virtualPrice, ok := PricingInstance.LookupPrice(q.virtualModel); ok {
+				price = virtualPrice
+			}
+		}
+		q.contract = contract
+		q.price = *contract.Apply(price)
+		ratio = 1
+	} else if q.virtualModel != "" {
+		// 虚拟模型设置了价格时按虚拟模型计费
+		if price, ok := PricingInstance.LookupPrice(q.virtualModel); ok {
+			q.price = *applyPriceModifier(price, q.virtualModel, q.groupName)
+		}
+	}
+
+	if q.batchDiscount > 0 {
+		ratio *= q.batchDiscount
+	}
+
+	q.ratio = ratio
+	q.inputRatio = q.price.GetInput() * ratio
+	q.outputRatio = q.price.GetOutput() * ratio
+
+	// 档位按请求的提示词 token 数选择一次，之后的计费和日志都只读取
+	q.tier = q.price.GetTier(q.promptTokens)
+	if q.tier != nil {
+		q.inputRatio = q.tier.Input * ratio
+		q.outputRatio = q.tier.Output * ratio
+	}
+}
+
+// getPerRequestQuota 档位设置的每次请求额外收取的额度
+func (q *Quota) getPerRequestQuota() int {
+	if q.tier == nil || q.tier.PerRequest <= 0 {
+		return 0
+	}
+	return int(math.Ceil(1000 * q.tier.PerRequest * q.ratio))
+}
+
+// SetChannel 请求最终由其他渠道完成时（例如对冲请求），按实际完成的渠道和模型计费
+func (q *Quota) SetChannel(channelId, channelKeyId int, modelName string) {
+	q.channelKeyId = channelKeyId
+	if q.channelId == channelId && q.modelName == modelName {
+		return
+	}
+
+	q.channelId = channelId
+	q.costRatio = model.ChannelGroup.GetCostRatio(channelId, modelName)
+	if q.modelName != modelName {
+		q.modelName = modelName
+		q.setPrice()
+	}
+}
+
 func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 	if q.price.Type == model.TimesPriceType {
 		q.preConsumedQuota = int(1000 * q.inputRatio)
-	} else if q.price.Input != 0 || q.price.Output != 0 {
-		q.preConsumedQuota = int(float64(q.promptTokens)*q.inputRatio) + config.PreConsumedQuota
+	} else if q.price.Input != 0 || q.price.Output != 0 || len(q.price.GetTiers()) > 0 {
+		q.preConsumedQuota = int(float64(q.promptTokens)*q.inputRatio) + q.getPerRequestQuota() + config.PreConsumedQuota
 	}
 
 	if q.preConsumedQuota == 0 {
 		return nil
 	}
 
-	userQuota, err := model.CacheGetUserQuota(q.userId)
+	userQuota, err := q.getUserQuota()
 	if err != nil {
 		return common.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 	}
@@ -71,11 +154,6 @@ func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 		return common.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusPaymentRequired)
 	}
 
-	err = model.CacheDecreaseUserQuota(q.userId, q.preConsumedQuota)
-	if err != nil {
-		return common.ErrorWrapper(err, "decrease_user_quota_failed", http.StatusInternalServerError)
-	}
-
 	if userQuota > 100*q.preConsumedQuota {
 		// in this case, we do not pre-consume quota
 		// because the user has enough quota
@@ -84,11 +162,16 @@ func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 	}
 
 	if q.preConsumedQuota > 0 {
-		err := model.PreConsumeTokenQuota(q.tokenId, q.preConsumedQuota)
+		q.planQuota, err = model.PreConsumeTokenQuota(q.tokenId, q.preConsumedQuota)
 		if err != nil {
 			return common.ErrorWrapper(err, "pre_consume_token_quota_failed", http.StatusForbidden)
 		}
 		q.HandelStatus = true
+
+		// 订阅额度已经从订阅中扣除，余额缓存只扣除从余额中预扣的部分
+		if err = model.CacheDecreaseUserQuota(q.userId, q.preConsumedQuota-q.planQuota); err != nil {
+			logger.SysError("failed to decrease user quota cache: " + err.Error())
+		}
 	}
 
 	return nil
@@ -112,7 +195,7 @@ func (q *Quota) UpdateUserRealtimeQuota(usage *types.UsageEvent, nowUsage *types
 	}
 
 	q.cacheQuota += increaseQuota
-	userQuota, err := model.CacheGetUserQuota(q.userId)
+	userQuota, err := q.getUserQuota()
 	if err != nil {
 		return errors.New("error get user quota cache: " + err.Error())
 	}
@@ -131,13 +214,19 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 		}
 	}()
 
+	model.ChannelGroup.RecordTokens(q.channelId, usage.PromptTokens+usage.CompletionTokens)
+	model.RateLimiter.RecordTokens(q.rateLimitScopes, usage.PromptTokens+usage.CompletionTokens)
+
 	quota := q.GetTotalQuotaByUsage(usage)
 	if quota == 0 {
 		return fmt.Errorf("user_id: %d, channel_id: %d, token_id: %d, quota is 0", q.userId, q.channelId, q.tokenId)
 	}
 
+	// 退还多预扣的额度时先退还余额，再退还订阅额度
 	quotaDelta := quota - q.preConsumedQuota
-	err := model.PostConsumeTokenQuota(q.tokenId, quotaDelta)
+	planRefund := max(0, -quotaDelta-(q.preConsumedQuota-q.planQuota))
+	planDelta, err := model.PostConsumeTokenQuota(q.tokenId, quotaDelta, planRefund)
+	q.planQuota += planDelta
 	if err != nil {
 		return errors.New("error consuming token remain quota: " + err.Error())
 	}
@@ -152,7 +241,7 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 		q.channelId,
 		usage.PromptTokens,
 		usage.CompletionTokens,
-		q.modelName,
+		q.getLogModelName(),
 		tokenName,
 		quota,
 		q.getLogContent(),
@@ -162,6 +251,9 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 	)
 	model.UpdateUserUsedQuotaAndRequestCount(q.userId, quota)
 	model.UpdateChannelUsedQuota(q.channelId, quota)
+	if q.channelKeyId > 0 {
+		model.UpdateChannelKeyUsage(q.channelKeyId, quota)
+	}
 
 	return nil
 }
@@ -171,7 +263,7 @@ func (q *Quota) Undo(c *gin.Context) {
 	if q.HandelStatus {
 		go func(ctx context.Context) {
 			// return pre-consumed quota
-			err := model.PostConsumeTokenQuota(tokenId, -q.preConsumedQuota)
+			_, err := model.PostConsumeTokenQuota(tokenId, -q.preConsumedQuota, q.planQuota)
 			if err != nil {
 				logger.LogError(ctx, "error return pre-consumed quota: "+err.Error())
 			}
@@ -181,6 +273,9 @@ func (q *Quota) Undo(c *gin.Context) {
 
 func (q *Quota) Consume(c *gin.Context, usage *types.Usage, isStream bool) {
 	tokenName := c.GetString("token_name")
+	q.hedgeAttempts, _ = utils.GetGinValue[[]map[string]any](c, "hedge_attempts")
+	q.streamFailover, _ = utils.GetGinValue[[]map[string]any](c, "stream_failover")
+	q.itemCache, _ = utils.GetGinValue[map[string]any](c, "item_cache")
 	// 如果没有报错，则消费配额
 	go func(ctx context.Context) {
 		err := q.completedQuotaConsumption(usage, tokenName, isStream, ctx)
@@ -190,6 +285,20 @@ func (q *Quota) Consume(c *gin.Context, usage *types.Usage, isStream bool) {
 	}(c.Request.Context())
 }
 
+// getUserQuota 用户可用的额度，包括余额和订阅额度
+func (q *Quota) getUserQuota() (int, error) {
+	userQuota, err := model.CacheGetUserQuota(q.userId)
+	if err != nil {
+		return 0, err
+	}
+
+	planQuota, err := model.CacheGetUserPlanQuota(q.userId)
+	if err != nil {
+		return 0, err
+	}
+	return userQuota + planQuota, nil
+}
+
 func (q *Quota) GetInputRatio() float64 {
 	return q.inputRatio
 }
@@ -203,7 +312,69 @@ func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 		"output_ratio": q.outputRatio,
 	}
 
+	if q.batchDiscount > 0 {
+		meta["batch_discount"] = q.batchDiscount
+	}
+
+	if q.planQuota > 0 {
+		meta["subscription_quota"] = q.planQuota
+	}
+
+	// 记录请求时生效的价格调整，方便用户核对账单
+	if modifier := q.price.Modifier; modifier != nil {
+		meta["price_modifier"] = map[string]any{
+			"id":         modifier.Id,
+			"name":       modifier.Name,
+			"type":       modifier.Type,
+			"multiplier": modifier.Multiplier,
+			"input":      modifier.Input,
+			"output":     modifier.Output,
+		}
+	}
+
+	if q.contract != nil {
+		meta["price_contract"] = map[string]any{
+			"id":       q.contract.Id,
+			"token_id": q.contract.TokenId,
+			"model":    q.contract.Model,
+			"type":     q.contract.Type,
+			"input":    q.contract.Input,
+			"output":   q.contract.Output,
+			"ratio":    q.contract.Ratio,
+		}
+	}
+
+	// 按档位计费时记录使用的档位，倍率已经是档位的倍率
+	if q.tier != nil {
+		meta["price_tier"] = q.tier
+	}
+
+	// 降级后实际使用的模型为路径的最后一个
+	if len(q.fallbackPath) > 1 {
+		meta["fallback"] = q.fallbackPath
+	}
+
+	if q.virtualModel != "" {
+		meta["upstream_model"] = q.modelName
+	}
+
+	if len(q.hedgeAttempts) > 0 {
+		meta["hedge"] = q.hedgeAttempts
+	}
+
+	// 流中断后续写时，之前中断的请求已经单独结算
+	if len(q.streamFailover) > 0 {
+		meta["stream_failover"] = q.streamFailover
+	}
+
+	// 部分输入项命中缓存时，只有未命中的输入项计费
+	if q.itemCache != nil {
+		meta["item_cache"] = q.itemCache
+	}
+
 	if usage != nil {
+		meta["upstream_cost"] = q.getUpstreamCost(usage)
+
 		promptDetails := usage.PromptTokensDetails
 		completionDetails := usage.CompletionTokensDetails
 
@@ -227,6 +398,14 @@ func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 	return meta
 }
 
+// getLogModelName 虚拟模型的请求在日志中记录虚拟模型的名称
+func (q *Quota) getLogModelName() string {
+	if q.virtualModel != "" {
+		return q.virtualModel
+	}
+	return q.modelName
+}
+
 func getRequestTime(ctx context.Context) int {
 	requestTime := 0
 	requestStartTimeValue := ctx.Value("requestStartTime")
@@ -253,7 +432,15 @@ func (q *Quota) getLogContent() string {
 		}
 	}
 
-	return fmt.Sprintf("模型费率 %s，分组倍率 %.2f", modelRatioStr, q.groupRatio)
+	content := fmt.Sprintf("模型费率 %s，分组倍率 %.2f", modelRatioStr, q.groupRatio)
+	if q.contract != nil {
+		content = fmt.Sprintf("模型费率 %s，约定价格 #%d", modelRatioStr, q.contract.Id)
+	}
+	if q.batchDiscount > 0 {
+		content += fmt.Sprintf("，批处理折扣 %.2f", q.batchDiscount)
+	}
+
+	return content
 }
 
 // 通过 token 数获取消费配额
@@ -262,6 +449,7 @@ func (q *Quota) GetTotalQuota(promptTokens, completionTokens int) (quota int) {
 		quota = int(1000 * q.inputRatio)
 	} else {
 		quota = int(math.Ceil((float64(promptTokens) * q.inputRatio) + (float64(completionTokens) * q.outputRatio)))
+		quota += q.getPerRequestQuota()
 	}
 
 	if q.inputRatio != 0 && quota <= 0 {
@@ -326,6 +514,20 @@ func (q *Quota) getComputeTokensByUsageEvent(usage *types.UsageEvent) (promptTok
 	return
 }
 
+// getUpstreamCost 按渠道的上游成本比例计算本次请求的成本，不受分组倍率和折扣影响
+func (q *Quota) getUpstreamCost(usage *types.Usage) int {
+	if q.price.Type == model.TimesPriceType {
+		return int(1000 * q.price.GetInput() * q.costRatio)
+	}
+
+	promptTokens, completionTokens := q.getComputeTokensByUsage(usage)
+	input, output, perRequest := q.price.GetInput(), q.price.GetOutput(), 0.0
+	if q.tier != nil {
+		input, output, perRequest = q.tier.Input, q.tier.Output, q.tier.PerRequest
+	}
+	return int(math.Ceil((float64(promptTokens)*input + float64(completionTokens)*output + 1000*perRequest) * q.costRatio))
+}
+
 // 通过 usage 获取消费配额
 func (q *Quota) GetTotalQuotaByUsage(usage *types.Usage) (quota int) {
 	promptTokens, completionTokens := q.getComputeTokensByUsage(usage)
@@ -6,7 +6,6 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
-	"one-api/model"
 	providersBase "one-api/providers/base"
 	"one-api/types"
 
@@ -41,11 +40,13 @@ func RelayRerank(c *gin.Context) {
 
 	apiErr, done := RelayHandler(relay)
 	if apiErr == nil {
+		recordChannelResult(c, nil)
 		return
 	}
 
 	channel := relay.getProvider().GetChannel()
-	go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr, channel.Type)
+	recordChannelResult(c, apiErr)
+	go processChannelRelayError(c.Request.Context(), channel, apiErr)
 
 	retryTimes := config.RetryTimes
 	if done || !shouldRetry(c, apiErr, channel.Type) {
@@ -55,7 +56,7 @@ func RelayRerank(c *gin.Context) {
 
 	for i := retryTimes; i > 0; i-- {
 		// 冻结通道
-		model.ChannelGroup.Cooldowns(channel.Id)
+		shouldCooldowns(c, apiErr, channel)
 		if err := relay.setProvider(relay.getOriginalModel()); err != nil {
 			continue
 		}
@@ -64,9 +65,11 @@ func RelayRerank(c *gin.Context) {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("using channel #%d(%s) to retry (remain times %d)", channel.Id, channel.Name, i))
 		apiErr, done = RelayHandler(relay)
 		if apiErr == nil {
+			recordChannelResult(c, nil)
 			return
 		}
-		go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr, channel.Type)
+		recordChannelResult(c, apiErr)
+		go processChannelRelayError(c.Request.Context(), channel, apiErr)
 		if done || !shouldRetry(c, apiErr, channel.Type) {
 			break
 		}
@@ -101,6 +104,10 @@ func (r *relayRerank) setRequest() error {
 	return nil
 }
 
+func (r *relayRerank) getRequest() interface{} {
+	return &r.request
+}
+
 func (r *relayRerank) getPromptTokens() (int, error) {
 	channel := r.provider.GetChannel()
 	return common.CountTokenRerankMessages(r.request, r.modelName, channel.PreCost), nil
@@ -125,6 +132,9 @@ func (r *relayRerank) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 
 	if err == nil {
 		r.cache.SetResponse(response)
+		// 重排序没有输出 token，不会在 RelayHandler 中保存缓存
+		usage := r.provider.GetUsage()
+		go r.cache.StoreCache(r.c.GetInt("channel_id"), usage.PromptTokens, 0, r.modelName)
 	}
 
 	if err != nil {
@@ -0,0 +1,261 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/model"
+	providersBase "one-api/providers/base"
+	"one-api/providers/openai"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type relayResponses struct {
+	relayBase
+	request types.ResponsesRequest
+	// previous_response_id 对应的对话上下文
+	history []types.ResponsesInputItem
+	state   *model.ResponseState
+}
+
+func NewRelayResponses(c *gin.Context) *relayResponses {
+	relay := &relayResponses{}
+	relay.c = c
+	return relay
+}
+
+func (r *relayResponses) setRequest() error {
+	if err := common.UnmarshalBodyReusable(r.c, &r.request); err != nil {
+		return err
+	}
+
+	if r.request.MaxOutputTokens < 0 || r.request.MaxOutputTokens > math.MaxInt32/2 {
+		return errors.New("max_output_tokens is invalid")
+	}
+
+	if _, err := r.request.ParseInput(); err != nil {
+		return err
+	}
+
+	if r.request.PreviousResponseId != "" {
+		state, err := model.GetResponseState(r.request.PreviousResponseId, r.c.GetInt("id"))
+		if err != nil {
+			return fmt.Errorf("previous response with id '%s' not found", r.request.PreviousResponseId)
+		}
+
+		if err := json.Unmarshal(state.Input, &r.history); err != nil {
+			return fmt.Errorf("previous response with id '%s' is invalid", r.request.PreviousResponseId)
+		}
+		r.state = state
+	}
+
+	if r.request.Tools != nil {
+		r.c.Set("skip_only_chat", true)
+	}
+
+	r.originalModel = r.request.Model
+
+	return nil
+}
+
+func (r *relayResponses) getRequest() interface{} {
+	return &r.request
+}
+
+func (r *relayResponses) IsStream() bool {
+	return r.request.Stream
+}
+
+func (r *relayResponses) getPromptTokens() (int, error) {
+	chatRequest, err := openai.ConvertResponsesToChatRequest(r.expandRequest())
+	if err != nil {
+		return 0, err
+	}
+
+	channel := r.provider.GetChannel()
+	return common.CountTokenMessages(chatRequest.Messages, r.modelName, channel.PreCost), nil
+}
+
+// expandRequest 将保存的对话上下文展开到 input 中，替代 previous_response_id
+func (r *relayResponses) expandRequest() *types.ResponsesRequest {
+	request := r.request
+	request.Model = r.modelName
+
+	if r.state == nil {
+		return &request
+	}
+
+	items, _ := r.request.ParseInput()
+	request.Input = append(append([]types.ResponsesInputItem{}, r.history...), items...)
+	request.PreviousResponseId = ""
+
+	return &request
+}
+
+func (r *relayResponses) getResponsesProvider() (providersBase.ResponsesInterface, *types.ResponsesRequest) {
+	channel := r.provider.GetChannel()
+
+	// 仅 OpenAI/Azure 渠道原生支持 Responses API
+	if channel.Type == config.ChannelTypeOpenAI || channel.Type == config.ChannelTypeAzure {
+		if provider, ok := r.provider.(providersBase.ResponsesInterface); ok {
+			// 同一渠道创建的上下文由上游保存，可以直接使用 previous_response_id
+			if r.state != nil && r.state.ChannelId == channel.Id {
+				request := r.request
+				request.Model = r.modelName
+				return provider, &request
+			}
+			return provider, r.expandRequest()
+		}
+	}
+
+	chatProvider, ok := r.provider.(providersBase.ChatInterface)
+	if !ok {
+		return nil, nil
+	}
+
+	request := r.expandRequest()
+	// 转换后 previous_response_id 不会发送给上游，这里仅用于在响应中回显
+	request.PreviousResponseId = r.request.PreviousResponseId
+
+	return openai.NewResponsesAdapter(chatProvider), request
+}
+
+func (r *relayResponses) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
+	provider, request := r.getResponsesProvider()
+	if provider == nil {
+		err = common.StringErrorWrapperLocal("channel not implemented", "channel_error", http.StatusServiceUnavailable)
+		done = true
+		return
+	}
+
+	var response *types.ResponsesResponse
+	if request.Stream {
+		var stream requester.StreamReaderInterface[string]
+		stream, err = provider.CreateResponsesStream(request)
+		if err != nil {
+			return
+		}
+
+		response, err = responseResponsesStreamClient(r.c, stream)
+	} else {
+		response, err = provider.CreateResponses(request)
+		if err != nil {
+			return
+		}
+
+		if response.PreviousResponseId == "" {
+			response.PreviousResponseId = r.request.PreviousResponseId
+		}
+		err = responseJsonClient(r.c, response)
+	}
+
+	if err != nil {
+		done = true
+		return
+	}
+
+	r.saveState(response)
+
+	return
+}
+
+// saveState 保存本次对话上下文，供后续请求通过 previous_response_id 续接
+func (r *relayResponses) saveState(response *types.ResponsesResponse) {
+	if response == nil || response.Id == "" {
+		return
+	}
+
+	if r.request.Store != nil && !*r.request.Store {
+		return
+	}
+
+	items, _ := r.request.ParseInput()
+	history := append(append([]types.ResponsesInputItem{}, r.history...), items...)
+	for _, output := range response.Output {
+		// 推理内容依赖上游保存的状态，无法跨渠道复用
+		if output.Type == types.ResponsesItemTypeReasoning {
+			continue
+		}
+		history = append(history, output.ToInputItem())
+	}
+
+	input, err := json.Marshal(history)
+	if err != nil {
+		return
+	}
+
+	state := &model.ResponseState{
+		ResponseId: response.Id,
+		UserId:     r.c.GetInt("id"),
+		ChannelId:  r.provider.GetChannel().Id,
+		Model:      r.originalModel,
+		Input:      input,
+		CreatedAt:  utils.GetTimestamp(),
+	}
+
+	if err := state.Insert(); err != nil {
+		logger.LogError(r.c.Request.Context(), "failed to save response state: "+err.Error())
+	}
+}
+
+func responseResponsesStreamClient(c *gin.Context, stream requester.StreamReaderInterface[string]) (response *types.ResponsesResponse, errWithOP *types.OpenAIErrorWithStatusCode) {
+	requester.SetEventStreamHeaders(c)
+	dataChan, errChan := stream.Recv()
+
+	defer stream.Close()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case data := <-dataChan:
+			markFirstResponse(c)
+			fmt.Fprint(w, data)
+			if finalResponse := getFinalResponse(data); finalResponse != nil {
+				response = finalResponse
+			}
+			return true
+		case err := <-errChan:
+			if !errors.Is(err, io.EOF) {
+				logger.LogError(c.Request.Context(), "Stream err:"+err.Error())
+				errorEvent, _ := json.Marshal(map[string]any{
+					"type":    "error",
+					"code":    "stream_error",
+					"message": err.Error(),
+				})
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", errorEvent)
+			}
+			return false
+		}
+	})
+
+	return
+}
+
+// getFinalResponse 从事件流中获取最终的响应
+func getFinalResponse(data string) *types.ResponsesResponse {
+	for _, line := range strings.Split(data, "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event types.ResponsesStreamResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(line[6:])), &event); err != nil {
+			continue
+		}
+
+		if event.Type == "response.completed" || event.Type == "response.incomplete" {
+			return event.Response
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"one-api/relay/relay_util"
+	"one-api/types"
+	"strings"
+)
+
+// streamFailover 记录已经输出给客户端的内容，流中断时用来续写
+type streamFailover struct {
+	text     strings.Builder
+	toolCall bool // 输出了工具调用，无法续写
+	attempts []map[string]any
+}
+
+func (s *streamFailover) collect(data string) {
+	var response types.ChatCompletionStreamResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		return
+	}
+
+	for _, choice := range response.Choices {
+		s.text.WriteString(choice.Delta.Content)
+		if choice.Delta.ToolCalls != nil || choice.Delta.FunctionCall != nil {
+			s.toolCall = true
+		}
+	}
+}
+
+// canStreamFailover 只续写没有指定渠道、只有一个候选结果的请求
+func (r *relayChat) canStreamFailover() bool {
+	if !config.StreamFailoverEnabled || config.StreamFailoverTimes <= 0 {
+		return false
+	}
+	if r.chatRequest.N != nil && *r.chatRequest.N > 1 {
+		return false
+	}
+	return r.c.GetInt("specific_channel_id") == 0 || r.c.GetBool("specific_channel_id_ignore")
+}
+
+// responseStreamWithFailover 与 responseStreamClient 相同，但上游流中断时换一个渠道续写，并拼接到同一个响应中
+func (r *relayChat) responseStreamWithFailover(stream requester.StreamReaderInterface[string]) *types.OpenAIErrorWithStatusCode {
+	c := r.c
+	requester.SetEventStreamHeaders(c)
+
+	state := &streamFailover{}
+	var streamErr error
+	// 当前渠道的结果是否已经记录，续写失败时中断的渠道已经记录为失败
+	recorded := false
+	for failovers := 0; ; failovers++ {
+		streamErr = r.pipeStream(stream, state)
+		if streamErr == nil {
+			break
+		}
+
+		logger.LogError(c.Request.Context(), fmt.Sprintf("stream from channel #%d interrupted: %s", c.GetInt("channel_id"), streamErr.Error()))
+		if state.toolCall || failovers >= config.StreamFailoverTimes {
+			break
+		}
+		if stream = r.failoverStream(state, streamErr); stream == nil {
+			recorded = true
+			break
+		}
+	}
+
+	if streamErr != nil {
+		// 响应已经输出给客户端，不能再返回错误，由这里记录渠道失败，避免被记录为成功
+		if !recorded {
+			recordChannelResult(c, common.ErrorWrapper(streamErr, "stream_interrupted", http.StatusBadGateway))
+		}
+		c.Set("channel_result_recorded", true)
+
+		fmt.Fprint(c.Writer, "data: "+streamErr.Error()+"\n\n")
+		// 报错不应该缓存
+		r.cache.NoCache()
+	} else if streamData := r.getUsageResponse(); streamData != "" {
+		fmt.Fprint(c.Writer, "data: "+streamData+"\n\n")
+		r.cache.SetResponse(streamData)
+	}
+
+	streamData := "data: [DONE]\n\n"
+	fmt.Fprint(c.Writer, streamData)
+	c.Writer.Flush()
+	r.cache.SetResponse(streamData)
+
+	return nil
+}
+
+// pipeStream 把上游的数据转发给客户端，读取出错时返回错误
+// 有些上游不返回 finish_reason，正常的 EOF 都视为结束，只有连接中断等读取错误才续写
+func (r *relayChat) pipeStream(stream requester.StreamReaderInterface[string], state *streamFailover) error {
+	dataChan, errChan := stream.Recv()
+	defer stream.Close()
+
+	for {
+		select {
+		case data := <-dataChan:
+			markFirstResponse(r.c)
+			state.collect(data)
+			streamData := "data: " + data + "\n\n"
+			fmt.Fprint(r.c.Writer, streamData)
+			r.c.Writer.Flush()
+			r.cache.SetResponse(streamData)
+		case err := <-errChan:
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			return nil
+		case <-r.c.Request.Context().Done():
+			return nil
+		}
+	}
+}
+
+// failoverStream 换一个渠道续写，成功后单独结算中断的请求，失败时返回 nil 并仍由中断的渠道结算
+func (r *relayChat) failoverStream(state *streamFailover, streamErr error) requester.StreamReaderInterface[string] {
+	c := r.c
+	channel := r.provider.GetChannel()
+	recordChannelResult(c, common.ErrorWrapper(streamErr, "stream_interrupted", http.StatusBadGateway))
+
+	skipChannelIds, _ := utils.GetGinValue[[]int](c, "skip_channel_ids")
+	c.Set("skip_channel_ids", append(skipChannelIds, channel.Id))
+
+	interrupted := *r.provider.GetUsage()
+	interruptedQuota := relay_util.NewQuota(c, r.modelName, interrupted.PromptTokens)
+
+	provider, modelName, permit := acquireChatProvider(c, nil)
+	if provider == nil {
+		return nil
+	}
+
+	request := r.chatRequest
+	request.Model = modelName
+	request.Messages = append(make([]types.ChatCompletionMessage, 0, len(r.chatRequest.Messages)+2), r.chatRequest.Messages...)
+	request.Messages = append(request.Messages, types.ChatCompletionMessage{
+		Role:    types.ChatMessageRoleAssistant,
+		Content: state.text.String(),
+	})
+	if config.StreamFailoverPrompt != "" {
+		request.Messages = append(request.Messages, types.ChatCompletionMessage{
+			Role:    types.ChatMessageRoleUser,
+			Content: config.StreamFailoverPrompt,
+		})
+	}
+	provider.SetUsage(&types.Usage{
+		PromptTokens: common.CountTokenMessages(request.Messages, modelName, provider.GetChannel().PreCost),
+	})
+
+	next := provider.GetChannel()
+	c.Set("channel_id", next.Id)
+	c.Set("channel_type", next.Type)
+	c.Set("channel_key_id", next.KeyId)
+	c.Set("channel_permit", permit)
+	startChannelRequest(c)
+
+	stream, apiErr := provider.CreateChatCompletionStream(&request)
+	if apiErr != nil {
+		recordChannelResult(c, apiErr)
+		go processChannelRelayError(c.Request.Context(), next, apiErr)
+		c.Set("channel_id", channel.Id)
+		c.Set("channel_type", channel.Type)
+		c.Set("channel_key_id", channel.KeyId)
+		return nil
+	}
+	logger.LogWarn(c.Request.Context(), fmt.Sprintf("continue interrupted stream from channel #%d with channel #%d", channel.Id, next.Id))
+
+	state.attempts = append(state.attempts, map[string]any{
+		"channel_id":        channel.Id,
+		"model":             r.modelName,
+		"completion_tokens": interrupted.CompletionTokens,
+		"error":             streamErr.Error(),
+	})
+	interruptedQuota.Consume(c, &interrupted, true)
+	c.Set("stream_failover", state.attempts)
+
+	r.provider = provider
+	r.modelName = modelName
+	r.chatRequest.Model = modelName
+	return stream
+}
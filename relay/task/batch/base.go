@@ -0,0 +1,145 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"one-api/common"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/relay/task/base"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+// 批处理任务在网关内执行，每一行请求都会走正常的中继流程
+var supportedEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+	"/v1/responses":        true,
+}
+
+const completionWindow = "24h"
+
+type BatchTask struct {
+	base.TaskBase
+	Request *types.BatchRequest
+}
+
+type BatchProperties struct {
+	TokenId      int    `json:"token_id"`
+	OutputFileId string `json:"output_file_id"`
+	ErrorFileId  string `json:"error_file_id"`
+}
+
+func StringError(c *gin.Context, httpCode int, code, message string) {
+	c.JSON(httpCode, types.OpenAIErrorResponse{
+		Error: types.OpenAIError{
+			Code:    code,
+			Message: message,
+			Type:    "one_hub_error",
+		},
+	})
+}
+
+func (t *BatchTask) HandleError(err *base.TaskError) {
+	StringError(t.C, err.StatusCode, err.Code, err.Message)
+}
+
+func (t *BatchTask) Init() *base.TaskError {
+	if err := common.UnmarshalBodyReusable(t.C, &t.Request); err != nil {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", err.Error(), true)
+	}
+
+	if !supportedEndpoints[t.Request.Endpoint] {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", "endpoint is not supported", true)
+	}
+
+	if t.Request.CompletionWindow != completionWindow {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", "completion_window must be 24h", true)
+	}
+
+	file, err := model.GetUserFile(t.Request.InputFileId, t.C.GetInt("id"))
+	if err != nil {
+		return base.StringTaskError(http.StatusInternalServerError, "get_file_failed", err.Error(), true)
+	}
+
+	if file == nil || file.Purpose != types.FilePurposeBatch {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", "input_file_id is invalid", true)
+	}
+
+	return nil
+}
+
+// SetProvider 批处理任务本身不占用渠道，每一行请求执行时再单独选择渠道
+func (t *BatchTask) SetProvider() *base.TaskError {
+	return nil
+}
+
+func (t *BatchTask) Relay() *base.TaskError {
+	t.InitTask()
+
+	expiresAt := t.Task.SubmitTime + 24*60*60
+	batch := &types.Batch{
+		Id:               "batch_" + utils.GetUUID(),
+		Object:           "batch",
+		Endpoint:         t.Request.Endpoint,
+		InputFileId:      t.Request.InputFileId,
+		CompletionWindow: t.Request.CompletionWindow,
+		Status:           types.BatchStatusValidating,
+		CreatedAt:        t.Task.SubmitTime,
+		ExpiresAt:        &expiresAt,
+		Metadata:         t.Request.Metadata,
+	}
+
+	properties := &BatchProperties{
+		TokenId:      t.C.GetInt("token_id"),
+		OutputFileId: relay.NewFileId(),
+		ErrorFileId:  relay.NewFileId(),
+	}
+
+	t.Task.TaskID = batch.Id
+	t.Task.Action = batch.Endpoint
+	t.Task.Status = model.TaskStatusSubmitted
+	t.Task.Properties = datatypes.JSON(utils.Marshal(properties))
+	t.Task.Data = datatypes.JSON(utils.Marshal(batch))
+
+	if err := t.Task.Insert(); err != nil {
+		return base.StringTaskError(http.StatusInternalServerError, "create_batch_failed", err.Error(), true)
+	}
+
+	t.C.JSON(http.StatusOK, batch)
+
+	return nil
+}
+
+func (t *BatchTask) ShouldRetry(err *base.TaskError) bool {
+	return false
+}
+
+func (t *BatchTask) UpdateTaskStatus(ctx context.Context, taskChannelM map[int][]string, taskM map[string]*model.Task) error {
+	for _, task := range taskM {
+		startBatch(ctx, task)
+	}
+
+	return nil
+}
+
+// TaskModel2Batch 将任务转换为 Batch 对象
+func TaskModel2Batch(task *model.Task) *types.Batch {
+	batch := &types.Batch{}
+	if err := json.Unmarshal(task.Data, batch); err != nil {
+		return nil
+	}
+
+	// 取消请求只修改任务状态，Batch 对象由执行任务的节点更新
+	if task.Status == model.TaskStatusCancelling && batch.Status == types.BatchStatusInProgress {
+		batch.Status = types.BatchStatusCancelling
+	}
+
+	return batch
+}
@@ -0,0 +1,131 @@
+package batch
+
+import (
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/types"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+// RelayBatches 处理网关创建的批处理，其他的批处理仍然转发给上游
+func RelayBatches(c *gin.Context) {
+	if !config.BatchEnabled {
+		relay.RelayOnly(c)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(c.Param("any"), "/"), "/")
+	task, err := model.GetTaskByTaskId(model.TaskPlatformBatch, c.GetInt("id"), parts[0])
+	if err != nil {
+		StringError(c, http.StatusInternalServerError, "get_batch_failed", err.Error())
+		return
+	}
+
+	if task == nil {
+		relay.RelayOnly(c)
+		return
+	}
+
+	switch {
+	case c.Request.Method == http.MethodGet && len(parts) == 1:
+		c.JSON(http.StatusOK, TaskModel2Batch(task))
+	case c.Request.Method == http.MethodPost && len(parts) == 2 && parts[1] == "cancel":
+		cancelBatch(c, task)
+	default:
+		StringError(c, http.StatusNotFound, "not_found", "Not Found")
+	}
+}
+
+func ListBatches(c *gin.Context) {
+	if !config.BatchEnabled {
+		relay.RelayOnly(c)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	tasks, err := model.GetUserTasksByPlatform(model.TaskPlatformBatch, c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		StringError(c, http.StatusInternalServerError, "get_batches_failed", err.Error())
+		return
+	}
+
+	list := &types.BatchList{
+		Object:  "list",
+		Data:    make([]*types.Batch, 0, len(tasks)),
+		HasMore: len(tasks) > limit,
+	}
+
+	if list.HasMore {
+		tasks = tasks[:limit]
+	}
+
+	for _, task := range tasks {
+		if batch := TaskModel2Batch(task); batch != nil {
+			list.Data = append(list.Data, batch)
+		}
+	}
+
+	if len(list.Data) > 0 {
+		list.FirstId = &list.Data[0].Id
+		list.LastId = &list.Data[len(list.Data)-1].Id
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func cancelBatch(c *gin.Context, task *model.Task) {
+	batch := TaskModel2Batch(task)
+	if batch == nil {
+		StringError(c, http.StatusInternalServerError, "get_batch_failed", "batch data is invalid")
+		return
+	}
+
+	var ok bool
+	var err error
+	now := utils.GetTimestamp()
+
+	switch task.Status {
+	case model.TaskStatusSubmitted:
+		// 还未开始执行，直接取消
+		batch.Status = types.BatchStatusCancelled
+		batch.CancellingAt = &now
+		batch.CancelledAt = &now
+		ok, err = model.TaskUpdateWhere(task.ID, map[string]any{"status": task.Status}, map[string]any{
+			"status":      model.TaskStatusFailure,
+			"progress":    100,
+			"finish_time": now,
+			"data":        datatypes.JSON(utils.Marshal(batch)),
+		})
+	case model.TaskStatusInProgress:
+		// 正在执行，由执行任务的节点停止并更新状态
+		batch.Status = types.BatchStatusCancelling
+		ok, err = model.TaskUpdateWhere(task.ID, map[string]any{"status": task.Status}, map[string]any{
+			"status": model.TaskStatusCancelling,
+		})
+	case model.TaskStatusCancelling:
+		ok = true
+	}
+
+	if err != nil {
+		StringError(c, http.StatusInternalServerError, "cancel_batch_failed", err.Error())
+		return
+	}
+
+	if !ok {
+		StringError(c, http.StatusConflict, "invalid_request", "Cannot cancel a batch with status '"+batch.Status+"'.")
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
@@ -0,0 +1,595 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/types"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"gorm.io/datatypes"
+)
+
+const (
+	maxBatchLines     = 50000
+	maxBatchErrors    = 100
+	maxBatchLineBytes = 16 * 1024 * 1024
+	// 执行任务的节点超过这个时间没有更新任务，视为已经中断，由其他节点接管
+	staleSeconds  = 5 * 60
+	flushInterval = 10 * time.Second
+	// 达到令牌或分组的速率限制时，等待后重试
+	rateLimitRetryInterval = time.Second
+)
+
+var runningBatches sync.Map
+
+// startBatch 抢占并在后台执行批处理任务
+func startBatch(ctx context.Context, task *model.Task) {
+	if _, ok := runningBatches.Load(task.ID); ok {
+		return
+	}
+
+	now := utils.GetTimestamp()
+	where := map[string]any{"status": task.Status}
+	params := map[string]any{"updated_at": now}
+
+	switch task.Status {
+	case model.TaskStatusSubmitted:
+		params["status"] = model.TaskStatusInProgress
+		params["start_time"] = now
+	case model.TaskStatusInProgress, model.TaskStatusCancelling:
+		if now-task.UpdatedAt < staleSeconds {
+			return
+		}
+		where["updated_at"] = task.UpdatedAt
+	default:
+		return
+	}
+
+	ok, err := model.TaskUpdateWhere(task.ID, where, params)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("claim batch %s failed: %s", task.TaskID, err.Error()))
+		return
+	}
+	if !ok {
+		return
+	}
+
+	runner, err := newBatchRunner(ctx, task)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("batch %s is invalid: %s", task.TaskID, err.Error()))
+		return
+	}
+
+	runningBatches.Store(task.ID, true)
+	common.SafeGoroutine(func() {
+		defer runningBatches.Delete(task.ID)
+		runner.run()
+	})
+}
+
+type batchRunner struct {
+	ctx        context.Context
+	task       *model.Task
+	batch      *types.Batch
+	properties *BatchProperties
+
+	token      *model.Token
+	group      string
+	tokenGroup string
+	groupRatio float64
+	// 令牌和分组的速率限制，与在线请求共用计数
+	rateLimitScopes []*model.RateLimitScope
+
+	lock sync.Mutex
+	// 已经执行过的 custom_id，接管中断的任务时跳过
+	finished map[string]bool
+	// 停止执行的原因，为空表示正常执行
+	stopStatus string
+}
+
+func newBatchRunner(ctx context.Context, task *model.Task) (*batchRunner, error) {
+	runner := &batchRunner{
+		ctx:        ctx,
+		task:       task,
+		batch:      TaskModel2Batch(task),
+		properties: &BatchProperties{},
+		finished:   make(map[string]bool),
+	}
+
+	if runner.batch == nil {
+		return nil, errors.New("batch data is invalid")
+	}
+
+	if err := json.Unmarshal(task.Properties, runner.properties); err != nil {
+		return nil, err
+	}
+
+	if task.Status == model.TaskStatusCancelling {
+		runner.stopStatus = types.BatchStatusCancelled
+	}
+
+	return runner, nil
+}
+
+func (r *batchRunner) run() {
+	lines, lineErrors := r.readLines()
+	if len(lineErrors) > 0 {
+		r.fail(lineErrors)
+		return
+	}
+
+	if err := r.prepare(); err != nil {
+		r.fail([]types.BatchErrorData{{Code: "batch_prepare_failed", Message: err.Error()}})
+		return
+	}
+
+	if r.batch.InProgressAt == nil {
+		now := utils.GetTimestamp()
+		r.batch.InProgressAt = &now
+	}
+	r.batch.Status = types.BatchStatusInProgress
+	r.batch.RequestCounts.Total = len(lines)
+	r.flush()
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.flush()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	concurrency := viper.GetInt("batch.concurrency")
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan *types.BatchInputLine)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range jobs {
+				result, ok := r.waitRateLimit()
+				if !ok {
+					continue
+				}
+				output, ok := r.execute(line, result)
+				result.Release()
+				r.write(output, ok)
+			}
+		}()
+	}
+
+	for _, line := range lines {
+		if r.getStopStatus() != "" {
+			break
+		}
+		if r.finished[line.CustomId] {
+			continue
+		}
+		jobs <- line
+	}
+
+	close(jobs)
+	wg.Wait()
+	close(stop)
+
+	r.finish()
+}
+
+// readLines 读取并校验输入文件，任意一行不合法则整个批处理失败
+func (r *batchRunner) readLines() ([]*types.BatchInputLine, []types.BatchErrorData) {
+	inputFile, err := model.GetUserFile(r.batch.InputFileId, r.task.UserId)
+	if err != nil || inputFile == nil {
+		return nil, []types.BatchErrorData{{Code: "file_not_found", Message: "input file not found"}}
+	}
+
+	file, err := relay.OpenFile(inputFile)
+	if err != nil {
+		return nil, []types.BatchErrorData{{Code: "file_not_found", Message: err.Error()}}
+	}
+	defer file.Close()
+
+	var lines []*types.BatchInputLine
+	var lineErrors []types.BatchErrorData
+	addError := func(lineNo int, code, message string) {
+		if len(lineErrors) < maxBatchErrors {
+			lineErrors = append(lineErrors, types.BatchErrorData{Code: code, Message: message, Line: &lineNo})
+		}
+	}
+
+	customIds := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBatchLineBytes)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		line := &types.BatchInputLine{}
+		if err := json.Unmarshal(text, line); err != nil {
+			addError(lineNo, "invalid_json_line", "This line is not parseable as valid JSON.")
+			continue
+		}
+
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+
+		switch {
+		case line.CustomId == "":
+			addError(lineNo, "missing_required_parameter", "custom_id is required.")
+		case customIds[line.CustomId]:
+			addError(lineNo, "duplicate_custom_id", "The custom ID for this request is a duplicate of another request.")
+		case line.Method != http.MethodPost:
+			addError(lineNo, "invalid_method", "Only POST is supported.")
+		case line.Url != r.batch.Endpoint:
+			addError(lineNo, "mismatched_endpoint", "The URL provided for this request does not match the batch endpoint.")
+		case json.Unmarshal(line.Body, &body) != nil:
+			addError(lineNo, "invalid_request", "body must be a JSON object.")
+		case body.Stream:
+			addError(lineNo, "invalid_request", "stream is not supported in batch.")
+		}
+
+		customIds[line.CustomId] = true
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, []types.BatchErrorData{{Code: "invalid_file", Message: err.Error()}}
+	}
+
+	if len(lineErrors) > 0 {
+		return nil, lineErrors
+	}
+
+	if len(lines) == 0 {
+		return nil, []types.BatchErrorData{{Code: "empty_file", Message: "The input file is empty."}}
+	}
+
+	if len(lines) > maxBatchLines {
+		return nil, []types.BatchErrorData{{Code: "too_many_requests", Message: fmt.Sprintf("The input file can contain at most %d requests.", maxBatchLines)}}
+	}
+
+	return lines, nil
+}
+
+// prepare 校验令牌，并读取已经执行过的请求
+func (r *batchRunner) prepare() error {
+	token, err := model.GetTokenById(r.properties.TokenId)
+	if err != nil {
+		return errors.New("token not found")
+	}
+
+	if _, err := model.ValidateUserToken(token.Key); err != nil {
+		return err
+	}
+
+	userEnabled, err := model.CacheIsUserEnabled(r.task.UserId)
+	if err != nil {
+		return err
+	}
+	if !userEnabled {
+		return errors.New("user is disabled")
+	}
+
+	r.token = token
+	r.group, _ = model.CacheGetUserGroup(r.task.UserId)
+	r.tokenGroup = token.Group
+	if r.tokenGroup == "" {
+		r.tokenGroup = r.group
+	}
+
+	groupRatio := model.GlobalUserGroupRatio.GetBySymbol(r.tokenGroup)
+	if groupRatio == nil {
+		return fmt.Errorf("group %s not found", r.tokenGroup)
+	}
+	r.groupRatio = groupRatio.Ratio
+
+	if scope := model.TokenRateLimitScope(token); scope != nil {
+		r.rateLimitScopes = append(r.rateLimitScopes, scope)
+	}
+	if scope := model.GroupRateLimitScope(groupRatio, r.task.UserId); scope != nil {
+		r.rateLimitScopes = append(r.rateLimitScopes, scope)
+	}
+
+	return r.loadFinished()
+}
+
+// waitRateLimit 等待速率限制放行，任务停止时返回 false
+func (r *batchRunner) waitRateLimit() (*model.RateLimitResult, bool) {
+	if len(r.rateLimitScopes) == 0 {
+		return nil, true
+	}
+
+	for r.getStopStatus() == "" {
+		result := model.RateLimiter.Acquire(r.rateLimitScopes)
+		if result.Allowed {
+			return result, true
+		}
+		time.Sleep(rateLimitRetryInterval)
+	}
+
+	return nil, false
+}
+
+// loadFinished 读取之前保存的结果，结果保存在数据库中，其他节点接管时也可以读取
+func (r *batchRunner) loadFinished() error {
+	finished, err := model.GetBatchFinished(r.task.ID)
+	if err != nil {
+		return err
+	}
+
+	r.batch.RequestCounts.Completed = 0
+	r.batch.RequestCounts.Failed = 0
+	for customId, isError := range finished {
+		r.finished[customId] = true
+		if isError {
+			r.batch.RequestCounts.Failed++
+		} else {
+			r.batch.RequestCounts.Completed++
+		}
+	}
+
+	return nil
+}
+
+// execute 构造请求上下文，通过正常的中继流程执行一行请求
+func (r *batchRunner) execute(line *types.BatchInputLine, rateLimit *model.RateLimitResult) (output *types.BatchOutputLine, ok bool) {
+	output = &types.BatchOutputLine{
+		Id:       "batch_req_" + utils.GetUUID(),
+		CustomId: line.CustomId,
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			logger.LogError(r.ctx, fmt.Sprintf("batch %s panic: %v", r.batch.Id, err))
+			output.Response = nil
+			output.Error = &types.BatchOutputError{Code: "server_error", Message: "internal server error"}
+			ok = false
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, line.Url, bytes.NewReader(line.Body))
+	if err != nil {
+		output.Error = &types.BatchOutputError{Code: "invalid_request", Message: err.Error()}
+		return output, false
+	}
+
+	requestId := utils.GetTimeString() + utils.GetRandomString(8)
+	ctx := context.WithValue(context.Background(), logger.RequestIdKey, requestId)
+	ctx = context.WithValue(ctx, "requestStartTime", time.Now())
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set(logger.RequestIdKey, requestId)
+	c.Set("id", r.task.UserId)
+	c.Set("token_id", r.token.Id)
+	c.Set("token_name", r.token.Name)
+	c.Set("group", r.group)
+	c.Set("token_group", r.tokenGroup)
+	c.Set("group_ratio", r.groupRatio)
+	c.Set("batch_discount", config.BatchDiscount)
+	if rateLimit != nil {
+		c.Set("rate_limit_scopes", r.rateLimitScopes)
+		c.Set("rate_limit_result", rateLimit)
+	}
+
+	relay.Relay(c)
+
+	body := w.Body.Bytes()
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+
+	output.Response = &types.BatchOutputResponse{
+		StatusCode: w.Code,
+		RequestId:  requestId,
+		Body:       body,
+	}
+
+	return output, w.Code == http.StatusOK
+}
+
+func (r *batchRunner) write(output *types.BatchOutputLine, ok bool) {
+	data, err := json.Marshal(output)
+	if err != nil {
+		logger.LogError(r.ctx, fmt.Sprintf("batch %s marshal output failed: %s", r.batch.Id, err.Error()))
+		return
+	}
+
+	err = model.InsertBatchResult(&model.BatchResult{
+		TaskId:   r.task.ID,
+		CustomId: output.CustomId,
+		IsError:  !ok,
+		Data:     datatypes.JSON(data),
+	})
+	if err != nil {
+		logger.LogError(r.ctx, fmt.Sprintf("batch %s write output failed: %s", r.batch.Id, err.Error()))
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if ok {
+		r.batch.RequestCounts.Completed++
+	} else {
+		r.batch.RequestCounts.Failed++
+	}
+}
+
+func (r *batchRunner) getStopStatus() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.stopStatus
+}
+
+// flush 保存执行进度，同时检查任务是否被取消或者已经超时
+func (r *batchRunner) flush() {
+	r.lock.Lock()
+	counts := r.batch.RequestCounts
+	data := utils.Marshal(r.batch)
+	r.lock.Unlock()
+
+	progress := 0
+	if counts.Total > 0 {
+		progress = (counts.Completed + counts.Failed) * 100 / counts.Total
+	}
+	// 进度为 100 表示任务已结束，执行中最多为 99
+	if progress > 99 {
+		progress = 99
+	}
+
+	err := model.TaskBulkUpdateByID([]int64{r.task.ID}, map[string]any{
+		"progress": progress,
+		"data":     datatypes.JSON(data),
+	})
+	if err != nil {
+		logger.LogError(r.ctx, fmt.Sprintf("batch %s update progress failed: %s", r.batch.Id, err.Error()))
+	}
+
+	now := utils.GetTimestamp()
+	stopStatus := ""
+	if task, err := model.GetTaskByTaskId(model.TaskPlatformBatch, r.task.UserId, r.task.TaskID); err == nil && task != nil && task.Status == model.TaskStatusCancelling {
+		stopStatus = types.BatchStatusCancelled
+	} else if r.batch.ExpiresAt != nil && now >= *r.batch.ExpiresAt {
+		stopStatus = types.BatchStatusExpired
+	}
+
+	if stopStatus == "" {
+		return
+	}
+
+	r.lock.Lock()
+	if r.stopStatus == "" {
+		r.stopStatus = stopStatus
+	}
+	r.lock.Unlock()
+}
+
+func (r *batchRunner) finish() {
+	now := utils.GetTimestamp()
+	taskStatus := model.TaskStatus(model.TaskStatusSuccess)
+
+	switch r.stopStatus {
+	case types.BatchStatusCancelled:
+		if r.batch.CancellingAt == nil {
+			r.batch.CancellingAt = &now
+		}
+		r.batch.CancelledAt = &now
+		taskStatus = model.TaskStatusFailure
+	case types.BatchStatusExpired:
+		r.batch.ExpiredAt = &now
+		taskStatus = model.TaskStatusFailure
+	default:
+		r.batch.FinalizingAt = &now
+		r.batch.CompletedAt = &now
+		r.stopStatus = types.BatchStatusCompleted
+	}
+	r.batch.Status = r.stopStatus
+
+	r.batch.OutputFileId = r.commitFile(r.properties.OutputFileId, false, r.batch.RequestCounts.Completed)
+	r.batch.ErrorFileId = r.commitFile(r.properties.ErrorFileId, true, r.batch.RequestCounts.Failed)
+
+	r.update(taskStatus, "")
+}
+
+// commitFile 将执行结果保存为用户的文件
+func (r *batchRunner) commitFile(fileId string, isError bool, count int) *string {
+	if count == 0 {
+		return nil
+	}
+
+	// 上次执行已经保存了文件，但中断在更新任务之前
+	if file, err := model.GetUserFile(fileId, r.task.UserId); err == nil && file != nil {
+		return &fileId
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(model.EachBatchResult(r.task.ID, isError, func(results []*model.BatchResult) error {
+			for _, result := range results {
+				if _, err := writer.Write(append(result.Data, '\n')); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}()
+	defer reader.Close()
+
+	file := &model.File{
+		FileId:   fileId,
+		UserId:   r.task.UserId,
+		Filename: fmt.Sprintf("%s_%s.jsonl", r.batch.Id, fileId),
+		Purpose:  types.FilePurposeBatchOutput,
+	}
+
+	if err := relay.SaveFile(file, reader); err != nil {
+		logger.LogError(r.ctx, fmt.Sprintf("batch %s commit file failed: %s", r.batch.Id, err.Error()))
+		return nil
+	}
+
+	return &fileId
+}
+
+func (r *batchRunner) fail(errs []types.BatchErrorData) {
+	now := utils.GetTimestamp()
+	r.batch.Status = types.BatchStatusFailed
+	r.batch.FailedAt = &now
+	r.batch.Errors = &types.BatchErrors{
+		Object: "list",
+		Data:   errs,
+	}
+
+	r.update(model.TaskStatusFailure, errs[0].Message)
+}
+
+func (r *batchRunner) update(status model.TaskStatus, failReason string) {
+	err := model.TaskBulkUpdateByID([]int64{r.task.ID}, map[string]any{
+		"status":      status,
+		"fail_reason": failReason,
+		"progress":    100,
+		"finish_time": utils.GetTimestamp(),
+		"data":        datatypes.JSON(utils.Marshal(r.batch)),
+	})
+	if err != nil {
+		logger.LogError(r.ctx, fmt.Sprintf("batch %s update failed: %s", r.batch.Id, err.Error()))
+		return
+	}
+
+	if err := model.DeleteBatchResults(r.task.ID); err != nil {
+		logger.LogError(r.ctx, fmt.Sprintf("batch %s delete results failed: %s", r.batch.Id, err.Error()))
+	}
+}
@@ -3,9 +3,11 @@ package task
 import (
 	"fmt"
 	"net/http"
+	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/model"
+	"one-api/relay"
 	"one-api/relay/relay_util"
 	"one-api/relay/task/base"
 	"one-api/types"
@@ -58,7 +60,7 @@ func RelayTaskSubmit(c *gin.Context) {
 
 	channel := taskAdaptor.GetProvider().GetChannel()
 	for i := retryTimes; i > 0; i-- {
-		model.ChannelGroup.Cooldowns(channel.Id)
+		model.ChannelGroup.Cooldowns(channel.Id, 0)
 		taskErr = taskAdaptor.SetProvider()
 		if taskErr != nil {
 			continue
@@ -86,6 +88,32 @@ func RelayTaskSubmit(c *gin.Context) {
 
 }
 
+// RelayBatchSubmit 创建由网关执行的批处理，未开启时转发给上游
+func RelayBatchSubmit(c *gin.Context) {
+	if !config.BatchEnabled {
+		relay.RelayOnly(c)
+		return
+	}
+
+	taskAdaptor, err := GetTaskAdaptor(config.RelayModeBatch, c)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, "adaptor not found")
+		return
+	}
+
+	if taskErr := taskAdaptor.Init(); taskErr != nil {
+		taskAdaptor.HandleError(taskErr)
+		return
+	}
+
+	if taskErr := taskAdaptor.Relay(); taskErr != nil {
+		taskAdaptor.HandleError(taskErr)
+		return
+	}
+
+	ActivateUpdateTaskBulk()
+}
+
 func CompletedTask(quotaInstance *relay_util.Quota, taskAdaptor base.TaskInterface, c *gin.Context) {
 	quotaInstance.Consume(c, &types.Usage{CompletionTokens: 0, PromptTokens: 1, TotalTokens: 1}, false)
 
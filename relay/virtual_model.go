@@ -0,0 +1,66 @@
+package relay
+
+import (
+	"encoding/json"
+	"one-api/model"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// applyVirtualModel 把虚拟模型的请求改写为实际模型的请求，调用方传入的参数会被强制参数覆盖
+func applyVirtualModel(c *gin.Context, request *types.ChatCompletionRequest) error {
+	virtualModel := model.GlobalVirtualModels.Get(request.Model, c.GetString("token_group"))
+	if virtualModel == nil {
+		return nil
+	}
+
+	defaults, err := model.ParseVirtualModelParams(virtualModel.Defaults)
+	if err != nil {
+		return err
+	}
+	forced, err := model.ParseVirtualModelParams(virtualModel.Forced)
+	if err != nil {
+		return err
+	}
+
+	if len(defaults) > 0 || len(forced) > 0 {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body := map[string]any{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return err
+		}
+
+		for key, value := range defaults {
+			if _, ok := body[key]; !ok {
+				body[key] = value
+			}
+		}
+		for key, value := range forced {
+			body[key] = value
+		}
+
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+		*request = types.ChatCompletionRequest{}
+		if err := json.Unmarshal(data, request); err != nil {
+			return err
+		}
+	}
+
+	if virtualModel.SystemPrompt != "" {
+		request.Messages = append([]types.ChatCompletionMessage{{
+			Role:    types.ChatMessageRoleSystem,
+			Content: virtualModel.SystemPrompt,
+		}}, request.Messages...)
+	}
+
+	request.Model = virtualModel.Model
+	c.Set("virtual_model", virtualModel.Name)
+
+	return nil
+}
@@ -57,9 +57,14 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.POST("/topup", controller.TopUp)
 				selfRoute.GET("/models", relay.ListModels)
+				selfRoute.GET("/prices", controller.GetUserPriceContracts)
 				selfRoute.GET("/payment", controller.GetUserPaymentList)
 				selfRoute.POST("/order", controller.CreateOrder)
 				selfRoute.GET("/order/status", controller.CheckOrderStatus)
+				selfRoute.GET("/subscription", controller.GetSelfSubscription)
+				selfRoute.GET("/subscription/plans", controller.GetAvailableSubscriptionPlans)
+				selfRoute.POST("/subscription", controller.CreateSubscriptionOrder)
+				selfRoute.PUT("/subscription/cancel", controller.CancelSubscriptionAutoRenew)
 			}
 
 			adminRoute := userRoute.Group("/")
@@ -78,6 +83,7 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			optionRoute.GET("/", controller.GetOptions)
 			optionRoute.PUT("/", controller.UpdateOption)
+			optionRoute.POST("/retry_rules/test", controller.TestRetryRules)
 			optionRoute.GET("/telegram", controller.GetTelegramMenuList)
 			optionRoute.POST("/telegram", controller.AddOrUpdateTelegramMenu)
 			optionRoute.GET("/telegram/status", controller.GetTelegramBotStatus)
@@ -96,12 +102,44 @@ func SetApiRouter(router *gin.Engine) {
 			userGroup.DELETE("/:id", controller.DeleteUserGroup)
 
 		}
+		virtualModelRoute := apiRouter.Group("/virtual_model")
+		virtualModelRoute.Use(middleware.AdminAuth())
+		{
+			virtualModelRoute.GET("/", controller.GetVirtualModels)
+			virtualModelRoute.GET("/:id", controller.GetVirtualModelById)
+			virtualModelRoute.POST("/", controller.AddVirtualModel)
+			virtualModelRoute.PUT("/enable/:id", controller.ChangeVirtualModelEnable)
+			virtualModelRoute.PUT("/", controller.UpdateVirtualModel)
+			virtualModelRoute.DELETE("/:id", controller.DeleteVirtualModel)
+		}
+		priceModifierRoute := apiRouter.Group("/price_modifier")
+		priceModifierRoute.Use(middleware.AdminAuth())
+		{
+			priceModifierRoute.GET("/", controller.GetPriceModifiers)
+			priceModifierRoute.GET("/:id", controller.GetPriceModifierById)
+			priceModifierRoute.POST("/", controller.AddPriceModifier)
+			priceModifierRoute.PUT("/enable/:id", controller.ChangePriceModifierEnable)
+			priceModifierRoute.PUT("/", controller.UpdatePriceModifier)
+			priceModifierRoute.DELETE("/:id", controller.DeletePriceModifier)
+		}
+		priceContractRoute := apiRouter.Group("/price_contract")
+		priceContractRoute.Use(middleware.AdminAuth())
+		{
+			priceContractRoute.GET("/", controller.GetPriceContracts)
+			priceContractRoute.GET("/:id", controller.GetPriceContractById)
+			priceContractRoute.POST("/", controller.AddPriceContract)
+			priceContractRoute.PUT("/enable/:id", controller.ChangePriceContractEnable)
+			priceContractRoute.PUT("/", controller.UpdatePriceContract)
+			priceContractRoute.DELETE("/:id", controller.DeletePriceContract)
+		}
 		channelRoute := apiRouter.Group("/channel")
 		channelRoute.Use(middleware.AdminAuth())
 		{
 			channelRoute.GET("/", controller.GetChannelsList)
 			channelRoute.GET("/models", relay.ListModelsForAdmin)
+			channelRoute.GET("/balance", controller.GetChannelBalance)
 			channelRoute.POST("/provider_models_list", controller.GetModelList)
+			channelRoute.POST("/param_override/test", controller.TestParamOverride)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
@@ -113,6 +151,11 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.PUT("/batch/del_model", controller.BatchDelModelChannels)
 			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
 			channelRoute.DELETE("/:id/tag", controller.DeleteChannelTag)
+			channelRoute.GET("/:id/keys", controller.GetChannelKeys)
+			channelRoute.POST("/:id/keys", controller.AddChannelKeys)
+			channelRoute.POST("/:id/keys/test", controller.TestChannelKeys)
+			channelRoute.PUT("/:id/keys/:key_id/enable", controller.EnableChannelKey)
+			channelRoute.DELETE("/:id/keys/:key_id", controller.DeleteChannelKey)
 			channelRoute.DELETE("/:id", controller.DeleteChannel)
 		}
 		channelTagRoute := apiRouter.Group("/channel_tag")
@@ -164,6 +207,7 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			analyticsRoute.GET("/statistics", controller.GetStatisticsDetail)
 			analyticsRoute.GET("/period", controller.GetStatisticsByPeriod)
+			analyticsRoute.GET("/profit", controller.GetProfitStatistics)
 		}
 
 		pricesRoute := apiRouter.Group("/prices")
@@ -179,6 +223,28 @@ func SetApiRouter(router *gin.Engine) {
 
 		}
 
+		modelInfoRoute := apiRouter.Group("/model_info")
+		modelInfoRoute.Use(middleware.AdminAuth())
+		{
+			modelInfoRoute.GET("/", controller.GetModelInfosList)
+			modelInfoRoute.POST("/single", controller.AddModelInfo)
+			modelInfoRoute.PUT("/single/*model", controller.UpdateModelInfo)
+			modelInfoRoute.DELETE("/single/*model", controller.DeleteModelInfo)
+			modelInfoRoute.POST("/sync", controller.SyncModelInfos)
+		}
+
+		subscriptionRoute := apiRouter.Group("/subscription")
+		subscriptionRoute.Use(middleware.AdminAuth())
+		{
+			subscriptionRoute.GET("/", controller.GetUserSubscriptions)
+			subscriptionRoute.PUT("/expire/:id", controller.ExpireUserSubscription)
+			subscriptionRoute.GET("/plan", controller.GetSubscriptionPlans)
+			subscriptionRoute.GET("/plan/:id", controller.GetSubscriptionPlanById)
+			subscriptionRoute.POST("/plan", controller.AddSubscriptionPlan)
+			subscriptionRoute.PUT("/plan/enable/:id", controller.ChangeSubscriptionPlanEnable)
+			subscriptionRoute.PUT("/plan", controller.UpdateSubscriptionPlan)
+			subscriptionRoute.DELETE("/plan/:id", controller.DeleteSubscriptionPlan)
+		}
 		paymentRoute := apiRouter.Group("/payment")
 		paymentRoute.Use(middleware.AdminAuth())
 		{
@@ -5,6 +5,7 @@ import (
 	"one-api/relay"
 	"one-api/relay/midjourney"
 	"one-api/relay/task"
+	"one-api/relay/task/batch"
 	"one-api/relay/task/suno"
 
 	"github.com/gin-gonic/gin"
@@ -30,32 +31,46 @@ func setOpenAIRouter(router *gin.Engine) {
 	relayV1Router := router.Group("/v1")
 	relayV1Router.Use(middleware.RelayPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute())
 	{
-		relayV1Router.POST("/completions", relay.Relay)
-		relayV1Router.POST("/chat/completions", relay.Relay)
-		// relayV1Router.POST("/edits", controller.Relay)
-		relayV1Router.POST("/images/generations", relay.Relay)
-		relayV1Router.POST("/images/edits", relay.Relay)
-		relayV1Router.POST("/images/variations", relay.Relay)
-		relayV1Router.POST("/embeddings", relay.Relay)
-		// relayV1Router.POST("/engines/:model/embeddings", controller.RelayEmbeddings)
-		relayV1Router.POST("/audio/transcriptions", relay.Relay)
-		relayV1Router.POST("/audio/translations", relay.Relay)
-		relayV1Router.POST("/audio/speech", relay.Relay)
-		relayV1Router.POST("/moderations", relay.Relay)
-		relayV1Router.POST("/rerank", relay.RelayRerank)
-		relayV1Router.GET("/realtime", relay.ChatRealtime)
+		// 调用模型的接口受令牌和分组的速率限制
+		modelRouter := relayV1Router.Group("", middleware.RelayRateLimit())
+		modelRouter.POST("/completions", relay.Relay)
+		modelRouter.POST("/chat/completions", relay.Relay)
+		modelRouter.POST("/responses", relay.Relay)
+		// modelRouter.POST("/edits", controller.Relay)
+		modelRouter.POST("/images/generations", relay.Relay)
+		modelRouter.POST("/images/edits", relay.Relay)
+		modelRouter.POST("/images/variations", relay.Relay)
+		modelRouter.POST("/embeddings", relay.Relay)
+		// modelRouter.POST("/engines/:model/embeddings", controller.RelayEmbeddings)
+		modelRouter.POST("/audio/transcriptions", relay.Relay)
+		modelRouter.POST("/audio/translations", relay.Relay)
+		modelRouter.POST("/audio/speech", relay.Relay)
+		modelRouter.POST("/moderations", relay.Relay)
+		modelRouter.POST("/rerank", relay.RelayRerank)
+		modelRouter.GET("/realtime", relay.ChatRealtime)
+		// 运行在对话下创建
+		modelRouter.Any("/threads", relay.RelayOnly)
+		modelRouter.Any("/threads/*any", relay.RelayOnly)
 
+		relayV1Router.GET("/files", relay.ListFiles)
+		relayV1Router.POST("/files", relay.UploadFile)
+		relayV1Router.GET("/files/:id", relay.RetrieveFile)
+		relayV1Router.GET("/files/:id/content", relay.RetrieveFileContent)
+		relayV1Router.DELETE("/files/:id", relay.DeleteFile)
+		// 开启批处理后由网关处理，否则与下面的接口一样转发给上游
+		relayV1Router.GET("/batches", batch.ListBatches)
+		relayV1Router.POST("/batches", task.RelayBatchSubmit)
+		relayV1Router.Any("/batches/*any", batch.RelayBatches)
+		// 引用已有对象的请求会转发到创建该对象的渠道，运行按上游返回的用量计费
+		relayV1Router.Any("/assistants", relay.RelayOnly)
+		relayV1Router.Any("/assistants/*any", relay.RelayOnly)
+		relayV1Router.Any("/vector_stores", relay.RelayOnly)
+		relayV1Router.Any("/vector_stores/*any", relay.RelayOnly)
+
+		// 微调的训练费用无法按模型价格计算，只允许管理员指定渠道使用
 		relayV1Router.Use(middleware.SpecifiedChannel())
 		{
-			relayV1Router.Any("/files", relay.RelayOnly)
-			relayV1Router.Any("/files/*any", relay.RelayOnly)
 			relayV1Router.Any("/fine_tuning/*any", relay.RelayOnly)
-			relayV1Router.Any("/assistants", relay.RelayOnly)
-			relayV1Router.Any("/assistants/*any", relay.RelayOnly)
-			relayV1Router.Any("/threads", relay.RelayOnly)
-			relayV1Router.Any("/threads/*any", relay.RelayOnly)
-			relayV1Router.Any("/batches/*any", relay.RelayOnly)
-			relayV1Router.Any("/vector_stores/*any", relay.RelayOnly)
 			relayV1Router.DELETE("/models/:model", relay.RelayOnly)
 		}
 	}
@@ -76,20 +91,22 @@ func registerMjRouterGroup(relayMjRouter *gin.RouterGroup) {
 	relayMjRouter.GET("/image/:id", midjourney.RelayMidjourneyImage)
 	relayMjRouter.Use(middleware.RelayMJPanicRecover(), middleware.MjAuth(), middleware.Distribute())
 	{
-		relayMjRouter.POST("/submit/action", midjourney.RelayMidjourney)
-		relayMjRouter.POST("/submit/shorten", midjourney.RelayMidjourney)
-		relayMjRouter.POST("/submit/modal", midjourney.RelayMidjourney)
-		relayMjRouter.POST("/submit/imagine", midjourney.RelayMidjourney)
-		relayMjRouter.POST("/submit/change", midjourney.RelayMidjourney)
-		relayMjRouter.POST("/submit/simple-change", midjourney.RelayMidjourney)
-		relayMjRouter.POST("/submit/describe", midjourney.RelayMidjourney)
-		relayMjRouter.POST("/submit/blend", midjourney.RelayMidjourney)
+		submitRouter := relayMjRouter.Group("", middleware.RelayRateLimit())
+		submitRouter.POST("/submit/action", midjourney.RelayMidjourney)
+		submitRouter.POST("/submit/shorten", midjourney.RelayMidjourney)
+		submitRouter.POST("/submit/modal", midjourney.RelayMidjourney)
+		submitRouter.POST("/submit/imagine", midjourney.RelayMidjourney)
+		submitRouter.POST("/submit/change", midjourney.RelayMidjourney)
+		submitRouter.POST("/submit/simple-change", midjourney.RelayMidjourney)
+		submitRouter.POST("/submit/describe", midjourney.RelayMidjourney)
+		submitRouter.POST("/submit/blend", midjourney.RelayMidjourney)
+		submitRouter.POST("/insight-face/swap", midjourney.RelayMidjourney)
+		submitRouter.POST("/submit/upload-discord-images", midjourney.RelayMidjourney)
+
 		relayMjRouter.POST("/notify", midjourney.RelayMidjourney)
 		relayMjRouter.GET("/task/:id/fetch", midjourney.RelayMidjourney)
 		relayMjRouter.GET("/task/:id/image-seed", midjourney.RelayMidjourney)
 		relayMjRouter.POST("/task/list-by-condition", midjourney.RelayMidjourney)
-		relayMjRouter.POST("/insight-face/swap", midjourney.RelayMidjourney)
-		relayMjRouter.POST("/submit/upload-discord-images", midjourney.RelayMidjourney)
 	}
 }
 
@@ -97,7 +114,7 @@ func setSunoRouter(router *gin.Engine) {
 	relaySunoRouter := router.Group("/suno")
 	relaySunoRouter.Use(middleware.RelaySunoPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute())
 	{
-		relaySunoRouter.POST("/submit/:action", task.RelayTaskSubmit)
+		relaySunoRouter.POST("/submit/:action", middleware.RelayRateLimit(), task.RelayTaskSubmit)
 		relaySunoRouter.POST("/fetch", suno.GetFetch)
 		relaySunoRouter.GET("/fetch/:id", suno.GetFetchByID)
 	}
@@ -108,7 +125,7 @@ func setClaudeRouter(router *gin.Engine) {
 	relayV1Router := relayClaudeRouter.Group("/v1")
 	relayV1Router.Use(middleware.RelayCluadePanicRecover(), middleware.ClaudeAuth(), middleware.Distribute())
 	{
-		relayV1Router.POST("/messages", relay.RelaycClaudeOnly)
+		relayV1Router.POST("/messages", middleware.RelayRateLimit(), relay.RelaycClaudeOnly)
 	}
 }
 
@@ -117,6 +134,6 @@ func setGeminiRouter(router *gin.Engine) {
 	relayV1Router := relayGeminiRouter.Group("/v1beta")
 	relayV1Router.Use(middleware.RelayGeminiPanicRecover(), middleware.GeminiAuth(), middleware.Distribute())
 	{
-		relayV1Router.POST("/models/:model", relay.RelaycGeminiOnly)
+		relayV1Router.POST("/models/:model", middleware.RelayRateLimit(), relay.RelaycGeminiOnly)
 	}
 }
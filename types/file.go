@@ -0,0 +1,34 @@
+package types
+
+const (
+	FilePurposeAssistants  = "assistants"
+	FilePurposeBatch       = "batch"
+	FilePurposeBatchOutput = "batch_output"
+	FilePurposeFineTune    = "fine-tune"
+	FilePurposeVision      = "vision"
+	FilePurposeUserData    = "user_data"
+	FilePurposeEvals       = "evals"
+)
+
+type OpenAIFile struct {
+	Id        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+type OpenAIFileList struct {
+	Object  string        `json:"object"`
+	Data    []*OpenAIFile `json:"data"`
+	FirstId *string       `json:"first_id"`
+	LastId  *string       `json:"last_id"`
+	HasMore bool          `json:"has_more"`
+}
+
+type OpenAIFileDeleted struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
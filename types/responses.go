@@ -0,0 +1,286 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const (
+	ResponsesItemTypeMessage            = "message"
+	ResponsesItemTypeFunctionCall       = "function_call"
+	ResponsesItemTypeFunctionCallOutput = "function_call_output"
+	ResponsesItemTypeReasoning          = "reasoning"
+)
+
+const (
+	ResponsesContentTypeInputText  = "input_text"
+	ResponsesContentTypeInputImage = "input_image"
+	ResponsesContentTypeOutputText = "output_text"
+	ResponsesContentTypeRefusal    = "refusal"
+)
+
+const (
+	ResponsesStatusCompleted  = "completed"
+	ResponsesStatusIncomplete = "incomplete"
+	ResponsesStatusInProgress = "in_progress"
+	ResponsesStatusFailed     = "failed"
+)
+
+type ResponsesRequest struct {
+	Model              string           `json:"model" binding:"required"`
+	Input              any              `json:"input,omitempty"`
+	Instructions       string           `json:"instructions,omitempty"`
+	MaxOutputTokens    int              `json:"max_output_tokens,omitempty"`
+	Temperature        *float64         `json:"temperature,omitempty"`
+	TopP               *float64         `json:"top_p,omitempty"`
+	Tools              []*ResponsesTool `json:"tools,omitempty"`
+	ToolChoice         any              `json:"tool_choice,omitempty"`
+	ParallelToolCalls  *bool            `json:"parallel_tool_calls,omitempty"`
+	PreviousResponseId string           `json:"previous_response_id,omitempty"`
+	Store              *bool            `json:"store,omitempty"`
+	Stream             bool             `json:"stream,omitempty"`
+	Metadata           any              `json:"metadata,omitempty"`
+	Text               *ResponsesText   `json:"text,omitempty"`
+	Reasoning          any              `json:"reasoning,omitempty"`
+	Truncation         string           `json:"truncation,omitempty"`
+	Include            []string         `json:"include,omitempty"`
+	User               string           `json:"user,omitempty"`
+}
+
+// ParseInput 将 input 统一解析为输入项列表，字符串输入视为一条用户消息
+func (r *ResponsesRequest) ParseInput() ([]ResponsesInputItem, error) {
+	switch input := r.Input.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []ResponsesInputItem{{
+			Type:    ResponsesItemTypeMessage,
+			Role:    ChatMessageRoleUser,
+			Content: input,
+		}}, nil
+	case []ResponsesInputItem:
+		return input, nil
+	}
+
+	data, err := json.Marshal(r.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []ResponsesInputItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, errors.New("input must be a string or an array of input items")
+	}
+
+	for i := range items {
+		if items[i].Type == "" {
+			items[i].Type = ResponsesItemTypeMessage
+		}
+	}
+
+	return items, nil
+}
+
+type ResponsesTool struct {
+	Type              string   `json:"type"`
+	Name              string   `json:"name,omitempty"`
+	Description       string   `json:"description,omitempty"`
+	Parameters        any      `json:"parameters,omitempty"`
+	Strict            *bool    `json:"strict,omitempty"`
+	VectorStoreIds    []string `json:"vector_store_ids,omitempty"`
+	MaxNumResults     int      `json:"max_num_results,omitempty"`
+	Filters           any      `json:"filters,omitempty"`
+	RankingOptions    any      `json:"ranking_options,omitempty"`
+	UserLocation      any      `json:"user_location,omitempty"`
+	SearchContextSize string   `json:"search_context_size,omitempty"`
+	DisplayWidth      int      `json:"display_width,omitempty"`
+	DisplayHeight     int      `json:"display_height,omitempty"`
+	Environment       string   `json:"environment,omitempty"`
+}
+
+type ResponsesText struct {
+	Format *ResponsesTextFormat `json:"format,omitempty"`
+}
+
+type ResponsesTextFormat struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Schema      any    `json:"schema,omitempty"`
+	Description string `json:"description,omitempty"`
+	Strict      *bool  `json:"strict,omitempty"`
+}
+
+// ResponsesInputItem 输入项，同时也用于保存对话上下文
+type ResponsesInputItem struct {
+	Type      string `json:"type,omitempty"`
+	Id        string `json:"id,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Content   any    `json:"content,omitempty"`
+	Status    string `json:"status,omitempty"`
+	CallId    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    any    `json:"output,omitempty"`
+	Summary   any    `json:"summary,omitempty"`
+}
+
+type ResponsesContent struct {
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`
+	ImageUrl    string `json:"image_url,omitempty"`
+	FileId      string `json:"file_id,omitempty"`
+	Detail      string `json:"detail,omitempty"`
+	Refusal     string `json:"refusal,omitempty"`
+	Annotations []any  `json:"annotations,omitempty"`
+}
+
+// ParseContent 将消息内容统一解析为内容列表
+func (i *ResponsesInputItem) ParseContent() []ResponsesContent {
+	switch content := i.Content.(type) {
+	case nil:
+		return nil
+	case string:
+		contentType := ResponsesContentTypeInputText
+		if i.Role == ChatMessageRoleAssistant {
+			contentType = ResponsesContentTypeOutputText
+		}
+		return []ResponsesContent{{Type: contentType, Text: content}}
+	case []ResponsesContent:
+		return content
+	}
+
+	data, err := json.Marshal(i.Content)
+	if err != nil {
+		return nil
+	}
+
+	var contents []ResponsesContent
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return nil
+	}
+
+	return contents
+}
+
+type ResponsesOutputItem struct {
+	Type      string             `json:"type"`
+	Id        string             `json:"id"`
+	Status    string             `json:"status,omitempty"`
+	Role      string             `json:"role,omitempty"`
+	Content   []ResponsesContent `json:"content,omitempty"`
+	CallId    string             `json:"call_id,omitempty"`
+	Name      string             `json:"name,omitempty"`
+	Arguments string             `json:"arguments,omitempty"`
+	Summary   any                `json:"summary,omitempty"`
+}
+
+// ToInputItem 将输出项转换为可以在下一轮对话中使用的输入项
+// 不保留 id，避免换到其他渠道后上游无法识别
+func (o *ResponsesOutputItem) ToInputItem() ResponsesInputItem {
+	item := ResponsesInputItem{
+		Type:      o.Type,
+		Role:      o.Role,
+		CallId:    o.CallId,
+		Name:      o.Name,
+		Arguments: o.Arguments,
+	}
+
+	if o.Content != nil {
+		item.Content = o.Content
+	}
+
+	return item
+}
+
+type ResponsesUsage struct {
+	InputTokens         int                           `json:"input_tokens"`
+	OutputTokens        int                           `json:"output_tokens"`
+	TotalTokens         int                           `json:"total_tokens"`
+	InputTokensDetails  *ResponsesInputTokensDetails  `json:"input_tokens_details,omitempty"`
+	OutputTokensDetails *ResponsesOutputTokensDetails `json:"output_tokens_details,omitempty"`
+}
+
+type ResponsesInputTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+type ResponsesOutputTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+func (u *ResponsesUsage) ToOpenAIUsage() *Usage {
+	usage := &Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+
+	if u.InputTokensDetails != nil {
+		usage.PromptTokensDetails.CachedTokens = u.InputTokensDetails.CachedTokens
+	}
+
+	if u.OutputTokensDetails != nil {
+		usage.CompletionTokensDetails.ReasoningTokens = u.OutputTokensDetails.ReasoningTokens
+	}
+
+	return usage
+}
+
+func NewResponsesUsage(usage *Usage) *ResponsesUsage {
+	if usage == nil {
+		return nil
+	}
+
+	return &ResponsesUsage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.PromptTokens + usage.CompletionTokens,
+		InputTokensDetails: &ResponsesInputTokensDetails{
+			CachedTokens: usage.PromptTokensDetails.CachedTokens,
+		},
+		OutputTokensDetails: &ResponsesOutputTokensDetails{
+			ReasoningTokens: usage.CompletionTokensDetails.ReasoningTokens,
+		},
+	}
+}
+
+type ResponsesResponse struct {
+	Id                 string                `json:"id"`
+	Object             string                `json:"object"`
+	CreatedAt          int64                 `json:"created_at"`
+	Status             string                `json:"status"`
+	Error              *OpenAIError          `json:"error"`
+	IncompleteDetails  any                   `json:"incomplete_details"`
+	Instructions       string                `json:"instructions,omitempty"`
+	MaxOutputTokens    int                   `json:"max_output_tokens,omitempty"`
+	Model              string                `json:"model"`
+	Output             []ResponsesOutputItem `json:"output"`
+	ParallelToolCalls  *bool                 `json:"parallel_tool_calls,omitempty"`
+	PreviousResponseId string                `json:"previous_response_id,omitempty"`
+	Temperature        *float64              `json:"temperature,omitempty"`
+	TopP               *float64              `json:"top_p,omitempty"`
+	Text               *ResponsesText        `json:"text,omitempty"`
+	ToolChoice         any                   `json:"tool_choice,omitempty"`
+	Tools              []*ResponsesTool      `json:"tools"`
+	Metadata           any                   `json:"metadata,omitempty"`
+	User               string                `json:"user,omitempty"`
+	Usage              *ResponsesUsage       `json:"usage,omitempty"`
+}
+
+// GetOutputText 获取所有输出文本
+func (r *ResponsesResponse) GetOutputText() string {
+	var text string
+	for _, item := range r.Output {
+		for _, content := range item.Content {
+			if content.Type == ResponsesContentTypeOutputText {
+				text += content.Text
+			}
+		}
+	}
+	return text
+}
+
+type ResponsesStreamResponse struct {
+	Type     string             `json:"type"`
+	Response *ResponsesResponse `json:"response,omitempty"`
+}